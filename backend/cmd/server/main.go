@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -14,10 +15,19 @@ import (
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
+	"github.com/kubevision/kubevision/internal/alerting"
 	"github.com/kubevision/kubevision/internal/api"
+	"github.com/kubevision/kubevision/internal/audit"
+	"github.com/kubevision/kubevision/internal/auth"
 	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/forward"
+	"github.com/kubevision/kubevision/internal/history"
+	"github.com/kubevision/kubevision/internal/jobs"
 	"github.com/kubevision/kubevision/internal/metrics"
 	"github.com/kubevision/kubevision/internal/middleware"
+	"github.com/kubevision/kubevision/internal/notify"
+	"github.com/kubevision/kubevision/internal/registry"
+	"github.com/kubevision/kubevision/internal/updates"
 	"github.com/kubevision/kubevision/internal/websocket"
 )
 
@@ -36,15 +46,162 @@ func main() {
 		logger.Fatal("Failed to load configuration", zap.Error(err))
 	}
 
-	// Initialize Docker client
-	dockerClient, err := docker.GetClient(logger)
+	// Initialize Docker hosts
+	var hostConfigs []docker.HostConfig
+	if hostsFile := viper.GetString("DOCKER_HOSTS_FILE"); hostsFile != "" {
+		hostConfigs, err = docker.LoadHostConfigs(hostsFile)
+		if err != nil {
+			logger.Fatal("Failed to load Docker hosts", zap.Error(err))
+		}
+	}
+	hostRegistry, err := docker.NewHostRegistry(hostConfigs, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize Docker client", zap.Error(err))
 	}
-	defer dockerClient.Close()
+	defer hostRegistry.Close()
+	defaultHost := hostRegistry.Default()
+
+	// Background workers stop when appCtx is cancelled during shutdown
+	appCtx, appCancel := context.WithCancel(context.Background())
+	defer appCancel()
+	var workers sync.WaitGroup
+
+	// Keep per-host health up to date
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		hostRegistry.RunHealthChecks(appCtx, viper.GetDuration("DOCKER_HOST_HEALTH_INTERVAL"))
+	}()
+
+	// Initialize metrics history store
+	var historyStore *history.Store
+	if viper.GetBool("METRICS_HISTORY_ENABLED") {
+		historyStore, err = history.Open(viper.GetString("METRICS_HISTORY_DIR"), history.Retention{
+			Raw:    viper.GetDuration("METRICS_RETENTION_RAW"),
+			Minute: viper.GetDuration("METRICS_RETENTION_1M"),
+			Hour:   viper.GetDuration("METRICS_RETENTION_1H"),
+		}, logger)
+		if err != nil {
+			logger.Fatal("Failed to open metrics history store", zap.Error(err))
+		}
+	}
+
+	// Initialize alerting engine
+	collectorInterval := viper.GetDuration("METRICS_HISTORY_INTERVAL")
+	var alertEngine *alerting.Engine
+	if viper.GetBool("ALERTING_ENABLED") {
+		rules, err := alerting.LoadRules(viper.GetString("ALERT_RULES_FILE"))
+		if err != nil {
+			logger.Fatal("Failed to load alert rules", zap.Error(err))
+		}
+		logger.Info("Loaded alert rules", zap.Int("count", len(rules)))
+
+		alertEngine = alerting.NewEngine(rules, 3*collectorInterval, logger)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			alertEngine.Run(appCtx)
+		}()
+		for _, host := range hostRegistry.Hosts() {
+			workers.Add(1)
+			go func(host *docker.Host) {
+				defer workers.Done()
+				alertEngine.WatchEvents(appCtx, host.Client())
+			}(host)
+		}
+
+		// Notifiers are configured in the same file as the rules
+		notifyConfig, err := notify.LoadConfig(viper.GetString("ALERT_RULES_FILE"))
+		if err != nil {
+			logger.Fatal("Failed to load notification config", zap.Error(err))
+		}
+		if len(notifyConfig.Notifiers) > 0 {
+			dispatcher, err := notify.NewDispatcher(notifyConfig, logger)
+			if err != nil {
+				logger.Fatal("Failed to initialize notifiers", zap.Error(err))
+			}
+			transitions, unsubscribe := alertEngine.Subscribe()
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				defer unsubscribe()
+				dispatcher.Run(appCtx, transitions)
+			}()
+			logger.Info("Alert notifications enabled", zap.Int("notifiers", len(notifyConfig.Notifiers)))
+		}
+	}
+
+	// Initialize per-container Prometheus metrics
+	var containerExporter *metrics.ContainerExporter
+	if viper.GetBool("CONTAINER_METRICS_ENABLED") {
+		containerExporter = metrics.NewContainerExporter(3*collectorInterval, logger)
+		for _, host := range hostRegistry.Hosts() {
+			containerExporter.AddHost(host.Name(), host.Client())
+			workers.Add(1)
+			go func(host *docker.Host) {
+				defer workers.Done()
+				containerExporter.WatchEvents(appCtx, host.Name(), host.Client())
+			}(host)
+		}
+	}
+
+	// Keep the latest sample per container for stack resource usage
+	var latestStats *history.Latest
+	if viper.GetBool("STACK_STATS_ENABLED") {
+		latestStats = history.NewLatest(3 * collectorInterval)
+	}
+
+	// Start a stats collector per host feeding history, alerting, metrics and
+	// stack usage
+	if historyStore != nil || alertEngine != nil || containerExporter != nil || latestStats != nil {
+		for _, host := range hostRegistry.Hosts() {
+			collector := history.NewCollector(
+				host.Client(),
+				historyStore,
+				collectorInterval,
+				logger.With(zap.String("host", host.Name())),
+			)
+			if alertEngine != nil {
+				collector.AddObserver(alertEngine.Observe)
+			}
+			if containerExporter != nil {
+				collector.AddObserver(containerExporter.Observer(host.Name()))
+			}
+			if latestStats != nil {
+				collector.AddObserver(latestStats.Observer(host.Name()))
+			}
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				collector.Run(appCtx)
+			}()
+		}
+	}
 
-	// Initialize stats calculator
-	statsCalculator := docker.NewStatsCalculator(logger)
+	// Ship container logs to external sinks
+	if viper.GetBool("LOG_FORWARD_ENABLED") {
+		forwardConfig, err := forward.LoadConfig(viper.GetString("LOG_FORWARD_FILE"))
+		if err != nil {
+			logger.Fatal("Failed to load log forwarding config", zap.Error(err))
+		}
+		positions, err := forward.OpenPositions(viper.GetString("LOG_FORWARD_POSITIONS_FILE"))
+		if err != nil {
+			logger.Fatal("Failed to open log forwarding positions", zap.Error(err))
+		}
+		forwarder, err := forward.NewForwarder(forwardConfig, positions, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize log forwarding sinks", zap.Error(err))
+		}
+		for _, host := range hostRegistry.Hosts() {
+			forwarder.AddHost(host.Name(), host.Client())
+		}
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			forwarder.Run(appCtx)
+		}()
+		logger.Info("Log forwarding enabled", zap.Int("sinks", len(forwardConfig.Sinks)))
+	}
 
 	// Initialize Gin router
 	if viper.GetString("LOG_LEVEL") == "debug" {
@@ -73,6 +230,9 @@ func main() {
 	// Correlation ID middleware (must be first)
 	router.Use(middleware.CorrelationIDMiddleware())
 
+	// Request metrics middleware
+	router.Use(middleware.MetricsMiddleware())
+
 	// CORS middleware
 	allowedOrigins := viper.GetStringSlice("CORS_ALLOWED_ORIGINS")
 	if len(allowedOrigins) == 0 {
@@ -95,17 +255,23 @@ func main() {
 	}
 
 	// Metrics endpoint (Prometheus format)
-	router.GET("/metrics", metrics.MetricsHandler)
+	router.GET("/metrics", metrics.Handler(containerExporter))
 
 	// Health check endpoint
 	router.GET("/api/health", func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		if err := dockerClient.HealthCheck(ctx); err != nil {
+		hosts := make([]docker.HostHealth, 0, len(hostRegistry.Hosts()))
+		for _, host := range hostRegistry.Hosts() {
+			hosts = append(hosts, host.Health())
+		}
+
+		if err := defaultHost.HealthCheck(ctx); err != nil {
 			c.JSON(http.StatusServiceUnavailable, gin.H{
 				"status":  "unhealthy",
 				"message": "Docker connection failed",
+				"hosts":   hosts,
 			})
 			return
 		}
@@ -113,58 +279,184 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{
 			"status":  "healthy",
 			"message": "Service is running",
+			"hosts":   hosts,
 		})
 	})
 
-	// API routes
-	apiGroup := router.Group("/api")
+	// Users and API tokens
+	authStore, err := auth.OpenStore(viper.GetString("AUTH_USERS_FILE"), viper.GetString("AUTH_TOKEN"))
+	if err != nil {
+		logger.Fatal("Failed to open users file", zap.Error(err))
+	}
+	authEnabled := viper.GetBool("AUTH_ENABLED")
+	if authEnabled && len(authStore.Users()) == 0 && viper.GetString("AUTH_TOKEN") == "" {
+		logger.Warn("Authentication is enabled but no users or AUTH_TOKEN are configured")
+	}
+
+	// Audit log of control actions
+	var auditLog *audit.Log
+	if viper.GetBool("AUDIT_ENABLED") {
+		auditLog, err = audit.Open(
+			viper.GetString("AUDIT_DIR"),
+			viper.GetInt64("AUDIT_MAX_SIZE_MB")*1024*1024,
+			viper.GetInt("AUDIT_MAX_FILES"),
+		)
+		if err != nil {
+			logger.Fatal("Failed to open audit log", zap.Error(err))
+		}
+		defer auditLog.Close()
+	}
+
+	// Login sessions: signed access tokens plus rotating refresh tokens
+	jwtKey, err := auth.LoadOrCreateKey(viper.GetString("AUTH_JWT_SECRET"), viper.GetString("AUTH_JWT_KEY_FILE"))
+	if err != nil {
+		logger.Fatal("Failed to load JWT signing key", zap.Error(err))
+	}
+	jwtSigner, err := auth.NewSigner(jwtKey)
+	if err != nil {
+		logger.Fatal("Invalid JWT signing key", zap.Error(err))
+	}
+	sessionManager, err := auth.NewSessionManager(
+		viper.GetString("AUTH_SESSIONS_FILE"),
+		jwtSigner,
+		viper.GetDuration("AUTH_ACCESS_TTL"),
+		viper.GetDuration("AUTH_REFRESH_TTL"),
+	)
+	if err != nil {
+		logger.Fatal("Failed to open sessions file", zap.Error(err))
+	}
+	authenticator := auth.NewAuthenticator(authStore, sessionManager, viper.GetDuration("AUTH_WS_TICKET_TTL"))
+	authHandler := api.NewAuthHandler(authStore, sessionManager, authenticator, logger)
+
+	// Login and refresh are public; the refresh token is the credential
+	router.POST("/api/auth/login", audited(auditLog, "auth.login", "", logger), authHandler.Login)
+	router.POST("/api/auth/refresh", authHandler.Refresh)
+
+	// Background jobs such as image pulls
+	jobManager := jobs.NewManager(appCtx, viper.GetDuration("JOBS_RETENTION"), logger)
+
+	// Registry credentials used for pulls, encrypted at rest
+	credentialStore, err := registry.OpenCredentialStore(
+		viper.GetString("REGISTRY_CREDENTIALS_FILE"),
+		viper.GetString("REGISTRY_CREDENTIALS_KEY"),
+	)
+	if err != nil {
+		logger.Fatal("Failed to open registry credentials", zap.Error(err))
+	}
+	if !credentialStore.Enabled() {
+		logger.Info("REGISTRY_CREDENTIALS_KEY is not set, registry credentials are disabled")
+	}
+	registryClient := registry.NewClient(viper.GetDuration("REGISTRY_TIMEOUT"))
+
+	// Compare local image digests with their registries in the background
+	var updateChecker *updates.Checker
+	if viper.GetBool("IMAGE_UPDATES_ENABLED") {
+		updateChecker = updates.NewChecker(
+			registryClient,
+			credentialStore,
+			viper.GetDuration("IMAGE_UPDATES_INTERVAL"),
+			viper.GetInt("IMAGE_UPDATES_RATE_LIMIT"),
+			logger,
+		)
+		for _, host := range hostRegistry.Hosts() {
+			updateChecker.AddHost(host.Name(), host.Client())
+		}
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			updateChecker.Run(appCtx)
+		}()
+	}
+
+	routes := hostRouteDeps{
+		historyStore: historyStore,
+		auditLog:     auditLog,
+		jobs:         jobManager,
+		credentials:  credentialStore,
+		updates:      updateChecker,
+		latestStats:  latestStats,
+		logger:       logger,
+	}
+
+	// API and WebSocket routes; every route requires authentication, and
+	// rejected requests are audited ahead of it
+	authMiddleware := middleware.AuthMiddleware(authEnabled, authenticator)
+	auditAuth := func(c *gin.Context) { c.Next() }
+	if auditLog != nil {
+		auditAuth = middleware.AuditUnauthenticated(auditLog, logger)
+	}
+	apiGroup := router.Group("/api", auditAuth, authMiddleware)
+	wsGroup := router.Group("/ws", auditAuth, authMiddleware)
 	{
-		// Container routes
-		containerHandler := api.NewContainerHandler(dockerClient.GetRawClient(), logger)
-		apiGroup.GET("/containers", containerHandler.ListContainers)
-		apiGroup.GET("/containers/:id", containerHandler.GetContainer)
-
-		// Container control routes (require auth)
-		authEnabled := viper.GetBool("AUTH_ENABLED")
-		authToken := viper.GetString("AUTH_TOKEN")
-		controlHandler := api.NewContainerControlHandler(dockerClient.GetRawClient(), logger)
-		controlGroup := apiGroup.Group("/containers/:id")
-		controlGroup.Use(middleware.AuthMiddleware(authEnabled, authToken))
+		// Auth and user management routes
+		userHandler := api.NewUserHandler(authStore, sessionManager, logger)
+		apiGroup.GET("/auth/whoami", userHandler.WhoAmI)
+		apiGroup.POST("/auth/logout", audited(auditLog, "auth.logout", "", logger), authHandler.Logout)
+		apiGroup.POST("/auth/ws-ticket", authHandler.WebSocketTicket)
+		manageUsers := middleware.RequirePermission(auth.PermUsersManage)
+		usersGroup := apiGroup.Group("/users")
 		{
-			controlGroup.POST("/start", controlHandler.StartContainer)
-			controlGroup.POST("/stop", controlHandler.StopContainer)
-			controlGroup.POST("/restart", controlHandler.RestartContainer)
-			controlGroup.POST("/pause", controlHandler.PauseContainer)
-			controlGroup.POST("/unpause", controlHandler.UnpauseContainer)
+			usersGroup.GET("", manageUsers, userHandler.ListUsers)
+			usersGroup.POST("", audited(auditLog, "user.create", "", logger), manageUsers, userHandler.CreateUser)
+			usersGroup.DELETE("/:name", audited(auditLog, "user.delete", "", logger), manageUsers, userHandler.DeleteUser)
+			usersGroup.PUT("/:name/password", audited(auditLog, "user.password", "", logger), manageUsers, userHandler.SetPassword)
+			usersGroup.POST("/:name/tokens", audited(auditLog, "token.create", "", logger), manageUsers, userHandler.CreateToken)
+			usersGroup.DELETE("/:name/tokens/:token", audited(auditLog, "token.revoke", "", logger), manageUsers, userHandler.RevokeToken)
+		}
+
+		// Audit log routes
+		if auditLog != nil {
+			readAudit := middleware.RequirePermission(auth.PermAuditRead)
+			auditHandler := api.NewAuditHandler(auditLog, logger)
+			apiGroup.GET("/audit", readAudit, auditHandler.ListAudit)
+			apiGroup.GET("/audit/export", readAudit, auditHandler.ExportAudit)
 		}
 
-		// Image routes
-		imageHandler := api.NewImageHandler(dockerClient.GetRawClient(), logger)
-		apiGroup.GET("/images", imageHandler.ListImages)
-		apiGroup.GET("/images/:id", imageHandler.GetImage)
-		imageControlGroup := apiGroup.Group("/images/:id")
-		imageControlGroup.Use(middleware.AuthMiddleware(authEnabled, authToken))
+		// Registry credential routes
+		manageRegistries := middleware.RequirePermission(auth.PermRegistriesManage)
+		registryHandler := api.NewRegistryHandler(credentialStore, registryClient, logger)
+		registriesGroup := apiGroup.Group("/registries")
 		{
-			imageControlGroup.DELETE("", imageHandler.RemoveImage)
+			registriesGroup.GET("", manageRegistries, registryHandler.ListRegistries)
+			registriesGroup.GET("/:name", manageRegistries, registryHandler.GetRegistry)
+			registriesGroup.PUT("/:name", audited(auditLog, "registry.put", "", logger), manageRegistries, registryHandler.PutRegistry)
+			registriesGroup.DELETE("/:name", audited(auditLog, "registry.delete", "", logger), manageRegistries, registryHandler.DeleteRegistry)
+			registriesGroup.POST("/:name/test", audited(auditLog, "registry.test", "", logger), manageRegistries, registryHandler.TestRegistry)
 		}
-	}
 
-	// WebSocket routes (must be before static files)
-	wsGroup := router.Group("/ws")
-	{
-		wsGroup.GET("/stats/:id", websocket.StatsHandler(
-			dockerClient.GetRawClient(),
-			statsCalculator,
-			logger,
-		))
-		wsGroup.GET("/logs/:id", websocket.LogsHandler(
-			dockerClient.GetRawClient(),
-			logger,
-		))
-		wsGroup.GET("/events", websocket.EventsHandler(
-			dockerClient.GetRawClient(),
-			logger,
-		))
+		// Host routes; the unprefixed container list spans every host
+		readContainers := middleware.RequirePermission(auth.PermContainersRead)
+		hostHandler := api.NewHostHandler(hostRegistry, logger)
+		apiGroup.GET("/hosts", readContainers, hostHandler.ListHosts)
+		apiGroup.GET("/containers", readContainers, hostHandler.ListAllContainers)
+
+		// Unprefixed routes target the default host
+		registerHostRoutes(apiGroup, wsGroup, defaultHost, routes)
+
+		// Every host is also reachable under /api/hosts/<name> and /ws/hosts/<name>
+		for _, host := range hostRegistry.Hosts() {
+			hostAPI := apiGroup.Group("/hosts/" + host.Name())
+			hostWS := wsGroup.Group("/hosts/" + host.Name())
+			hostAPI.GET("/containers", readContainers, api.NewHostContainerHandler(host.Client(), host.Name(), logger).ListContainers)
+			registerHostRoutes(hostAPI, hostWS, host, routes)
+		}
+
+		// Job routes
+		readJobs := middleware.RequirePermission(auth.PermImagesRead)
+		jobHandler := api.NewJobHandler(jobManager, logger)
+		apiGroup.GET("/jobs", readJobs, jobHandler.ListJobs)
+		apiGroup.GET("/jobs/:id", readJobs, jobHandler.GetJob)
+		apiGroup.DELETE("/jobs/:id", audited(auditLog, "job.cancel", "", logger), middleware.RequirePermission(auth.PermImagesPull), jobHandler.CancelJob)
+		wsGroup.GET("/jobs/:id", readJobs, websocket.JobHandler(jobManager, logger))
+
+		// Alert routes
+		if alertEngine != nil {
+			readAlerts := middleware.RequirePermission(auth.PermAlertsRead)
+			alertHandler := api.NewAlertHandler(alertEngine, logger)
+			apiGroup.GET("/alerts", readAlerts, alertHandler.ListAlerts)
+			apiGroup.GET("/alerts/rules", readAlerts, alertHandler.ListRules)
+			wsGroup.GET("/alerts", readAlerts, websocket.AlertsHandler(alertEngine, logger))
+		}
 	}
 
 	// Serve static files (frontend) - simple direct approach
@@ -228,9 +520,163 @@ func main() {
 		logger.Error("Server forced to shutdown", zap.Error(err))
 	}
 
+	appCancel()
+	workers.Wait()
+
 	logger.Info("Server exited")
 }
 
+// hostRouteDeps holds the dependencies shared by every host's routes
+type hostRouteDeps struct {
+	historyStore *history.Store
+	auditLog     *audit.Log
+	jobs         *jobs.Manager
+	credentials  *registry.CredentialStore
+	updates      *updates.Checker
+	latestStats  *history.Latest
+	logger       *zap.Logger
+}
+
+// audited returns middleware recording a request as action in the audit
+// log, or a pass-through when auditing is disabled. It goes before
+// permission checks so denied attempts are recorded.
+func audited(auditLog *audit.Log, action, host string, logger *zap.Logger) gin.HandlerFunc {
+	if auditLog == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return middleware.AuditMiddleware(auditLog, action, host, logger)
+}
+
+// registerHostRoutes registers the container, image and WebSocket routes
+// for a single Docker host. The container list route is registered by the
+// caller since the unprefixed one aggregates all hosts.
+func registerHostRoutes(apiGroup, wsGroup *gin.RouterGroup, host *docker.Host, deps hostRouteDeps) {
+	dockerClient := host.Client()
+	logger := deps.logger
+
+	// Permission checks; container-scoped ones honour label selectors
+	readContainer := middleware.RequireContainerPermission(auth.PermContainersRead, dockerClient)
+	controlContainer := middleware.RequireContainerPermission(auth.PermContainersControl, dockerClient)
+	readLogs := middleware.RequireContainerPermission(auth.PermLogsRead, dockerClient)
+	readAnyLogs := middleware.RequirePermission(auth.PermLogsRead)
+	execContainer := middleware.RequireContainerPermission(auth.PermExec, dockerClient)
+	readContainers := middleware.RequirePermission(auth.PermContainersRead)
+	createContainers := middleware.RequirePermission(auth.PermContainersControl)
+	readImages := middleware.RequirePermission(auth.PermImagesRead)
+	deleteImages := middleware.RequirePermission(auth.PermImagesDelete)
+	pullImages := middleware.RequirePermission(auth.PermImagesPull)
+	pruneSystem := middleware.RequirePermission(auth.PermSystemPrune)
+	readVolumes := middleware.RequirePermission(auth.PermVolumesRead)
+	createVolumes := middleware.RequirePermission(auth.PermVolumesCreate)
+	deleteVolumes := middleware.RequirePermission(auth.PermVolumesDelete)
+	readNetworks := middleware.RequirePermission(auth.PermNetworksRead)
+	createNetworks := middleware.RequirePermission(auth.PermNetworksCreate)
+	deleteNetworks := middleware.RequirePermission(auth.PermNetworksDelete)
+
+	// Control actions are recorded in the audit log, including denied ones
+	auditAction := func(action string) gin.HandlerFunc {
+		return audited(deps.auditLog, action, host.Name(), logger)
+	}
+
+	// Container routes
+	containerHandler := api.NewHostContainerHandler(dockerClient, host.Name(), logger)
+	apiGroup.GET("/containers/:id", readContainer, containerHandler.GetContainer)
+
+	// Log search and stack export only cover containers the principal may
+	// read logs of
+	logSearchHandler := api.NewLogSearchHandler(dockerClient, host.Name(), logger)
+	apiGroup.GET("/logs/search", readAnyLogs, logSearchHandler.SearchLogs)
+	logExportHandler := api.NewLogExportHandler(dockerClient, logger)
+	apiGroup.GET("/containers/:id/logs/export", readLogs, logExportHandler.ExportContainerLogs)
+	apiGroup.GET("/stacks/:name/logs/export", readAnyLogs, logExportHandler.ExportStackLogs)
+
+	// Historical metrics routes
+	if deps.historyStore != nil {
+		historyHandler := api.NewHistoryHandler(dockerClient, deps.historyStore, logger)
+		apiGroup.GET("/containers/:id/metrics", readContainer, historyHandler.GetContainerMetrics)
+	}
+
+	// Container control routes
+	controlHandler := api.NewContainerControlHandler(dockerClient, deps.credentials, logger)
+	apiGroup.POST("/containers", auditAction("container.create"), createContainers, controlHandler.CreateContainer)
+	controlGroup := apiGroup.Group("/containers/:id")
+	{
+		controlGroup.DELETE("", auditAction("container.remove"), controlContainer, controlHandler.RemoveContainer)
+		controlGroup.POST("/rename", auditAction("container.rename"), controlContainer, controlHandler.RenameContainer)
+		controlGroup.PATCH("/resources", auditAction("container.update"), controlContainer, controlHandler.UpdateResources)
+		controlGroup.POST("/start", auditAction("container.start"), controlContainer, controlHandler.StartContainer)
+		controlGroup.POST("/stop", auditAction("container.stop"), controlContainer, controlHandler.StopContainer)
+		controlGroup.POST("/restart", auditAction("container.restart"), controlContainer, controlHandler.RestartContainer)
+		controlGroup.POST("/pause", auditAction("container.pause"), controlContainer, controlHandler.PauseContainer)
+		controlGroup.POST("/unpause", auditAction("container.unpause"), controlContainer, controlHandler.UnpauseContainer)
+	}
+
+	// Stack routes; actions check every container against the principal's
+	// control selector. Deploys also create networks and volumes and pull
+	// images.
+	stackHandler := api.NewStackHandler(dockerClient, controlHandler, deps.latestStats, deps.jobs, host.Name(), logger)
+	apiGroup.GET("/stacks", readContainers, stackHandler.ListStacks)
+	apiGroup.GET("/stacks/:name", readContainers, stackHandler.GetStack)
+	apiGroup.POST("/stacks", auditAction("stack.deploy"), createContainers, createNetworks, createVolumes, pullImages, stackHandler.DeployStack)
+	apiGroup.PUT("/stacks/:name", auditAction("stack.update"), createContainers, createNetworks, createVolumes, pullImages, stackHandler.UpdateStack)
+	stackGroup := apiGroup.Group("/stacks/:name")
+	{
+		stackGroup.POST("/start", auditAction("stack.start"), createContainers, stackHandler.StartStack)
+		stackGroup.POST("/stop", auditAction("stack.stop"), createContainers, stackHandler.StopStack)
+		stackGroup.POST("/restart", auditAction("stack.restart"), createContainers, stackHandler.RestartStack)
+	}
+
+	// Image routes
+	imageHandler := api.NewImageHandler(dockerClient, logger)
+	apiGroup.GET("/images", readImages, imageHandler.ListImages)
+	apiGroup.GET("/images/:id", readImages, imageHandler.GetImage)
+	if deps.updates != nil {
+		updateHandler := api.NewImageUpdateHandler(deps.updates, host.Name(), logger)
+		apiGroup.GET("/images/updates", readImages, updateHandler.ListUpdates)
+	}
+	pullHandler := api.NewImagePullHandler(dockerClient, deps.jobs, deps.credentials, host.Name(), logger)
+	apiGroup.POST("/images/pull", auditAction("image.pull"), pullImages, pullHandler.PullImage)
+	imageControlGroup := apiGroup.Group("/images/:id")
+	{
+		imageControlGroup.DELETE("", auditAction("image.remove"), deleteImages, imageHandler.RemoveImage)
+	}
+
+	// Volume routes
+	volumeHandler := api.NewVolumeHandler(dockerClient, logger)
+	apiGroup.GET("/volumes", readVolumes, volumeHandler.ListVolumes)
+	apiGroup.GET("/volumes/:name", readVolumes, volumeHandler.GetVolume)
+	apiGroup.POST("/volumes", auditAction("volume.create"), createVolumes, volumeHandler.CreateVolume)
+	apiGroup.DELETE("/volumes/:name", auditAction("volume.remove"), deleteVolumes, volumeHandler.RemoveVolume)
+
+	// Network routes; connecting a container needs control of the container
+	networkHandler := api.NewNetworkHandler(dockerClient, host.Name(), logger)
+	apiGroup.GET("/networks", readNetworks, networkHandler.ListNetworks)
+	apiGroup.GET("/networks/:name", readNetworks, networkHandler.GetNetwork)
+	apiGroup.POST("/networks", auditAction("network.create"), createNetworks, networkHandler.CreateNetwork)
+	apiGroup.DELETE("/networks/:name", auditAction("network.remove"), deleteNetworks, networkHandler.RemoveNetwork)
+	apiGroup.POST("/networks/:name/containers/:id", auditAction("network.connect"), readNetworks, controlContainer, networkHandler.ConnectContainer)
+	apiGroup.DELETE("/networks/:name/containers/:id", auditAction("network.disconnect"), readNetworks, controlContainer, networkHandler.DisconnectContainer)
+	apiGroup.GET("/topology", readContainers, readNetworks, networkHandler.Topology)
+
+	// Disk usage and prune routes
+	systemHandler := api.NewSystemHandler(dockerClient, logger)
+	apiGroup.GET("/system/df", readImages, systemHandler.DiskUsage)
+	apiGroup.POST("/system/prune", auditAction("system.prune"), pruneSystem, systemHandler.Prune)
+
+	// WebSocket routes
+	wsGroup.GET("/stats", readContainers, websocket.MultiStatsHandler(host.StatsHub(), dockerClient, logger))
+	wsGroup.GET("/stats/:id", readContainer, websocket.StatsHandler(host.StatsHub(), logger))
+	wsGroup.GET("/logs/:id", readLogs, websocket.LogsHandler(
+		dockerClient,
+		logger,
+	))
+	wsGroup.GET("/events", readContainers, websocket.EventsHandler(
+		dockerClient,
+		logger,
+	))
+	wsGroup.GET("/exec/:id", auditAction("container.exec"), execContainer, websocket.ExecHandler(dockerClient, logger))
+}
+
 func initLogger() (*zap.Logger, error) {
 	config := zap.NewProductionConfig()
 	
@@ -256,7 +702,42 @@ func loadConfig() error {
 	viper.SetDefault("HOST", "0.0.0.0")
 	viper.SetDefault("LOG_LEVEL", "info")
 	viper.SetDefault("DOCKER_HOST", "unix:///var/run/docker.sock")
+	viper.SetDefault("DOCKER_HOSTS_FILE", "")
+	viper.SetDefault("DOCKER_HOST_HEALTH_INTERVAL", "30s")
 	viper.SetDefault("AUTH_ENABLED", false)
+	viper.SetDefault("AUTH_USERS_FILE", "data/users.yaml")
+	viper.SetDefault("AUTH_JWT_SECRET", "")
+	viper.SetDefault("AUTH_JWT_KEY_FILE", "data/jwt.key")
+	viper.SetDefault("AUTH_SESSIONS_FILE", "data/sessions.json")
+	viper.SetDefault("AUTH_ACCESS_TTL", "15m")
+	viper.SetDefault("AUTH_REFRESH_TTL", "168h")
+	viper.SetDefault("AUTH_WS_TICKET_TTL", "30s")
+	viper.SetDefault("AUDIT_ENABLED", true)
+	viper.SetDefault("AUDIT_DIR", "data/audit")
+	viper.SetDefault("AUDIT_MAX_SIZE_MB", 10)
+	viper.SetDefault("AUDIT_MAX_FILES", 10)
+	viper.SetDefault("JOBS_RETENTION", "1h")
+	viper.SetDefault("REGISTRY_CREDENTIALS_FILE", "data/registries.json")
+	viper.SetDefault("REGISTRY_CREDENTIALS_KEY", "")
+	viper.SetDefault("REGISTRY_TIMEOUT", "30s")
+	viper.SetDefault("IMAGE_UPDATES_ENABLED", true)
+	viper.SetDefault("IMAGE_UPDATES_INTERVAL", "6h")
+	viper.SetDefault("IMAGE_UPDATES_RATE_LIMIT", 30)
+	viper.SetDefault("EXEC_COMMAND", "/bin/sh")
+	viper.SetDefault("STATS_BATCH_INTERVAL", "2s")
+	viper.SetDefault("METRICS_HISTORY_ENABLED", true)
+	viper.SetDefault("METRICS_HISTORY_DIR", "data/metrics")
+	viper.SetDefault("METRICS_HISTORY_INTERVAL", "15s")
+	viper.SetDefault("METRICS_RETENTION_RAW", "24h")
+	viper.SetDefault("METRICS_RETENTION_1M", "168h")
+	viper.SetDefault("METRICS_RETENTION_1H", "2160h")
+	viper.SetDefault("CONTAINER_METRICS_ENABLED", true)
+	viper.SetDefault("STACK_STATS_ENABLED", true)
+	viper.SetDefault("ALERTING_ENABLED", false)
+	viper.SetDefault("ALERT_RULES_FILE", "alerts.yaml")
+	viper.SetDefault("LOG_FORWARD_ENABLED", false)
+	viper.SetDefault("LOG_FORWARD_FILE", "forward.yaml")
+	viper.SetDefault("LOG_FORWARD_POSITIONS_FILE", "data/forward-positions.json")
 	viper.SetDefault("CORS_ALLOWED_ORIGINS", []string{"*"})
 	viper.SetDefault("RATE_LIMIT_ENABLED", true)
 	viper.SetDefault("RATE_LIMIT_REQUESTS", 100)
@@ -0,0 +1,387 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+)
+
+// State is the lifecycle state of an alert
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+const (
+	// resolvedRetention is how long resolved alerts remain visible
+	resolvedRetention = time.Hour
+
+	// subscriberBuffer is the per-subscriber transition buffer size
+	subscriberBuffer = 64
+
+	// eventReconnectDelay is the delay before re-subscribing to Docker events
+	eventReconnectDelay = 5 * time.Second
+)
+
+// eventAttributeKeys are event actor attributes that are not container labels
+var eventAttributeKeys = map[string]bool{
+	"name":     true,
+	"image":    true,
+	"exitCode": true,
+	"signal":   true,
+}
+
+// Alert is an instance of a rule firing for a container
+type Alert struct {
+	ID            string            `json:"id"`
+	Rule          string            `json:"rule"`
+	Severity      string            `json:"severity"`
+	State         State             `json:"state"`
+	ContainerID   string            `json:"container_id"`
+	ContainerName string            `json:"container_name"`
+	Image         string            `json:"image"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Metric        string            `json:"metric,omitempty"`
+	Event         string            `json:"event,omitempty"`
+	Value         float64           `json:"value,omitempty"`
+	Threshold     float64           `json:"threshold,omitempty"`
+	Message       string            `json:"message"`
+	ActiveAt      time.Time         `json:"active_at"`
+	FiredAt       *time.Time        `json:"fired_at,omitempty"`
+	ResolvedAt    *time.Time        `json:"resolved_at,omitempty"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// Engine evaluates alert rules against container stats and Docker events
+type Engine struct {
+	rules       []Rule
+	staleAfter  time.Duration
+	mu          sync.RWMutex
+	alerts      map[string]*Alert // keyed by rule name and container ID
+	lastSeen    map[string]time.Time
+	subscribers map[chan Alert]struct{}
+	now         func() time.Time
+	logger      *zap.Logger
+}
+
+// NewEngine creates a new alerting engine. Metric alerts for containers that
+// have not been observed for staleAfter are resolved.
+func NewEngine(rules []Rule, staleAfter time.Duration, logger *zap.Logger) *Engine {
+	return &Engine{
+		rules:       rules,
+		staleAfter:  staleAfter,
+		alerts:      make(map[string]*Alert),
+		lastSeen:    make(map[string]time.Time),
+		subscribers: make(map[chan Alert]struct{}),
+		now:         time.Now,
+		logger:      logger,
+	}
+}
+
+// Rules returns the engine's rules
+func (e *Engine) Rules() []Rule {
+	return e.rules
+}
+
+// Observe evaluates metric rules against a container's latest stats
+func (e *Engine) Observe(ctr container.Summary, stats *docker.ContainerStats) {
+	name := containerName(ctr.ID, ctr.Names)
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.lastSeen[ctr.ID] = now
+
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if rule.IsEventRule() || !rule.matchesContainer(ctr.ID, name, ctr.Labels) {
+			continue
+		}
+
+		value := rule.value(stats)
+		key := alertKey(rule.Name, ctr.ID)
+		alert, active := e.alerts[key]
+		if active && alert.State == StateResolved {
+			active = false
+		}
+
+		if !rule.breached(value) {
+			if !active {
+				continue
+			}
+			if alert.State == StatePending {
+				delete(e.alerts, key)
+				continue
+			}
+			e.resolveLocked(alert, now)
+			continue
+		}
+
+		if !active {
+			alert = &Alert{
+				ID:            key,
+				Rule:          rule.Name,
+				Severity:      rule.Severity,
+				State:         StatePending,
+				ContainerID:   ctr.ID,
+				ContainerName: name,
+				Image:         ctr.Image,
+				Labels:        ctr.Labels,
+				Metric:        rule.Metric,
+				Threshold:     rule.Threshold,
+				ActiveAt:      now,
+			}
+			e.alerts[key] = alert
+		}
+
+		alert.Value = value
+		alert.UpdatedAt = now
+		alert.Message = fmt.Sprintf("%s on %s is %.2f (%s %.2f)", rule.Metric, name, value, rule.Operator, rule.Threshold)
+
+		if alert.State == StatePending {
+			if now.Sub(alert.ActiveAt) >= rule.For {
+				alert.State = StateFiring
+				alert.FiredAt = &now
+				e.publishLocked(alert)
+			} else if !active {
+				e.publishLocked(alert)
+			}
+		}
+	}
+}
+
+// HandleEvent evaluates event rules against a Docker event. A container
+// start or destroy resolves firing event alerts for that container.
+func (e *Engine) HandleEvent(msg events.Message) {
+	if msg.Type != events.ContainerEventType || msg.Actor.ID == "" {
+		return
+	}
+
+	action := string(msg.Action)
+	attributes := msg.Actor.Attributes
+	name := attributes["name"]
+	if name == "" {
+		name = containerName(msg.Actor.ID, nil)
+	}
+	labels := make(map[string]string, len(attributes))
+	for key, value := range attributes {
+		if !eventAttributeKeys[key] {
+			labels[key] = value
+		}
+	}
+
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if !rule.IsEventRule() {
+			continue
+		}
+
+		key := alertKey(rule.Name, msg.Actor.ID)
+		if _, ok := resolvingEvents[action]; ok {
+			if alert, ok := e.alerts[key]; ok && alert.State == StateFiring {
+				e.resolveLocked(alert, now)
+			}
+			continue
+		}
+
+		if !rule.matchesEvent(action) || !rule.matchesContainer(msg.Actor.ID, name, labels) {
+			continue
+		}
+
+		message := fmt.Sprintf("container %s received %s event", name, action)
+		if exitCode := attributes["exitCode"]; exitCode != "" {
+			message += " (exit code " + exitCode + ")"
+		}
+
+		alert := &Alert{
+			ID:            key,
+			Rule:          rule.Name,
+			Severity:      rule.Severity,
+			State:         StateFiring,
+			ContainerID:   msg.Actor.ID,
+			ContainerName: name,
+			Image:         attributes["image"],
+			Labels:        labels,
+			Event:         action,
+			Message:       message,
+			ActiveAt:      now,
+			FiredAt:       &now,
+			UpdatedAt:     now,
+		}
+		e.alerts[key] = alert
+		e.publishLocked(alert)
+	}
+}
+
+// Run periodically resolves stale alerts until ctx is cancelled
+func (e *Engine) Run(ctx context.Context) {
+	sweepTicker := time.NewTicker(time.Minute)
+	defer sweepTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sweepTicker.C:
+			e.sweep()
+		}
+	}
+}
+
+// WatchEvents feeds container events from a Docker host into the engine
+// until ctx is cancelled, reconnecting after stream errors
+func (e *Engine) WatchEvents(ctx context.Context, dockerClient interface {
+	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+}) {
+	eventFilters := filters.NewArgs()
+	eventFilters.Add("type", string(events.ContainerEventType))
+
+	for {
+		eventChan, errChan := dockerClient.Events(ctx, events.ListOptions{
+			Filters: eventFilters,
+		})
+
+	stream:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errChan:
+				if err != nil {
+					e.logger.Warn("Alerting events stream error, reconnecting", zap.Error(err))
+				}
+				break stream
+			case event := <-eventChan:
+				e.HandleEvent(event)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventReconnectDelay):
+		}
+	}
+}
+
+// sweep resolves metric alerts for containers that are no longer observed
+// and forgets resolved alerts past their retention
+func (e *Engine) sweep() {
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key, alert := range e.alerts {
+		switch {
+		case alert.State == StateResolved:
+			if now.Sub(*alert.ResolvedAt) > resolvedRetention {
+				delete(e.alerts, key)
+			}
+		case alert.Metric != "" && now.Sub(e.lastSeen[alert.ContainerID]) > e.staleAfter:
+			if alert.State == StatePending {
+				delete(e.alerts, key)
+			} else {
+				e.resolveLocked(alert, now)
+			}
+		}
+	}
+
+	for containerID, seen := range e.lastSeen {
+		if now.Sub(seen) > e.staleAfter {
+			delete(e.lastSeen, containerID)
+		}
+	}
+}
+
+// Alerts returns alerts sorted by activation time, optionally filtered by state
+func (e *Engine) Alerts(state State) []Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make([]Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		if state != "" && alert.State != state {
+			continue
+		}
+		result = append(result, *alert)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ActiveAt.After(result[j].ActiveAt)
+	})
+	return result
+}
+
+// Subscribe returns a channel of alert state transitions and a function to
+// unsubscribe. Transitions are dropped for subscribers that fall behind.
+func (e *Engine) Subscribe() (<-chan Alert, func()) {
+	ch := make(chan Alert, subscriberBuffer)
+
+	e.mu.Lock()
+	e.subscribers[ch] = struct{}{}
+	e.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.mu.Lock()
+			delete(e.subscribers, ch)
+			e.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (e *Engine) resolveLocked(alert *Alert, now time.Time) {
+	alert.State = StateResolved
+	alert.ResolvedAt = &now
+	alert.UpdatedAt = now
+	e.publishLocked(alert)
+}
+
+func (e *Engine) publishLocked(alert *Alert) {
+	for ch := range e.subscribers {
+		select {
+		case ch <- *alert:
+		default:
+			e.logger.Warn("Alert subscriber buffer full, dropping transition",
+				zap.String("alert_id", alert.ID))
+		}
+	}
+}
+
+func alertKey(rule, containerID string) string {
+	return rule + "/" + containerID
+}
+
+// containerName returns the first container name without its leading slash,
+// or the short ID if the container has no name
+func containerName(id string, names []string) string {
+	if len(names) > 0 && len(names[0]) > 0 {
+		return strings.TrimPrefix(names[0], "/")
+	}
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,195 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+)
+
+const testRules = `
+rules:
+  - name: high-cpu
+    metric: cpu_percent
+    threshold: 80
+    for: 1m
+    selector: com.docker.compose.project=payments
+  - name: died
+    events: [die, oom]
+    severity: critical
+`
+
+// testClock is a manually advanced clock
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func newTestEngine(t *testing.T) (*Engine, *testClock) {
+	t.Helper()
+	rules, err := ParseRules([]byte(testRules))
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	engine := NewEngine(rules, 5*time.Minute, zap.NewNop())
+	engine.now = clock.Now
+	return engine, clock
+}
+
+var paymentsAPI = container.Summary{
+	ID:     "aaaaaaaaaaaaaaaaaaaa",
+	Names:  []string{"/payments-api"},
+	Image:  "payments:1.2",
+	Labels: map[string]string{"com.docker.compose.project": "payments"},
+}
+
+func TestParseRules_Validation(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rules string
+	}{
+		{name: "missing name", rules: "rules:\n  - metric: cpu_percent\n"},
+		{name: "unknown metric", rules: "rules:\n  - name: a\n    metric: disk\n"},
+		{name: "metric and events", rules: "rules:\n  - name: a\n    metric: pids\n    events: [die]\n"},
+		{name: "unknown severity", rules: "rules:\n  - name: a\n    events: [die]\n    severity: page\n"},
+		{name: "resolving event", rules: "rules:\n  - name: a\n    events: [die, start]\n"},
+		{name: "duplicate name", rules: "rules:\n  - name: a\n    events: [die]\n  - name: a\n    events: [oom]\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseRules([]byte(tc.rules)); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+func TestEngine_MetricLifecycle(t *testing.T) {
+	engine, clock := newTestEngine(t)
+	transitions, unsubscribe := engine.Subscribe()
+	defer unsubscribe()
+
+	engine.Observe(paymentsAPI, &docker.ContainerStats{CPUPercent: 95})
+	alerts := engine.Alerts("")
+	if len(alerts) != 1 || alerts[0].State != StatePending {
+		t.Fatalf("Expected one pending alert, got %+v", alerts)
+	}
+	if got := <-transitions; got.State != StatePending {
+		t.Errorf("Expected pending transition, got %s", got.State)
+	}
+
+	clock.now = clock.now.Add(30 * time.Second)
+	engine.Observe(paymentsAPI, &docker.ContainerStats{CPUPercent: 90})
+	if alerts = engine.Alerts(StateFiring); len(alerts) != 0 {
+		t.Fatalf("Expected no firing alert before duration elapsed, got %+v", alerts)
+	}
+
+	clock.now = clock.now.Add(31 * time.Second)
+	engine.Observe(paymentsAPI, &docker.ContainerStats{CPUPercent: 91})
+	alerts = engine.Alerts(StateFiring)
+	if len(alerts) != 1 {
+		t.Fatalf("Expected firing alert, got %+v", engine.Alerts(""))
+	}
+	if alerts[0].ContainerName != "payments-api" || alerts[0].Image != "payments:1.2" || alerts[0].Value != 91 {
+		t.Errorf("Unexpected alert details: %+v", alerts[0])
+	}
+	if got := <-transitions; got.State != StateFiring {
+		t.Errorf("Expected firing transition, got %s", got.State)
+	}
+
+	clock.now = clock.now.Add(15 * time.Second)
+	engine.Observe(paymentsAPI, &docker.ContainerStats{CPUPercent: 10})
+	if alerts = engine.Alerts(StateResolved); len(alerts) != 1 || alerts[0].ResolvedAt == nil {
+		t.Fatalf("Expected resolved alert, got %+v", engine.Alerts(""))
+	}
+	if got := <-transitions; got.State != StateResolved {
+		t.Errorf("Expected resolved transition, got %s", got.State)
+	}
+}
+
+func TestEngine_PendingClearsSilently(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	engine.Observe(paymentsAPI, &docker.ContainerStats{CPUPercent: 95})
+	engine.Observe(paymentsAPI, &docker.ContainerStats{CPUPercent: 5})
+
+	if alerts := engine.Alerts(""); len(alerts) != 0 {
+		t.Errorf("Expected pending alert to be dropped, got %+v", alerts)
+	}
+}
+
+func TestEngine_SelectorMismatch(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	other := paymentsAPI
+	other.Labels = map[string]string{"com.docker.compose.project": "billing"}
+	engine.Observe(other, &docker.ContainerStats{CPUPercent: 99})
+
+	if alerts := engine.Alerts(""); len(alerts) != 0 {
+		t.Errorf("Expected no alerts for non-matching container, got %+v", alerts)
+	}
+}
+
+func TestEngine_StaleResolution(t *testing.T) {
+	engine, clock := newTestEngine(t)
+
+	engine.Observe(paymentsAPI, &docker.ContainerStats{CPUPercent: 95})
+	clock.now = clock.now.Add(2 * time.Minute)
+	engine.Observe(paymentsAPI, &docker.ContainerStats{CPUPercent: 95})
+
+	clock.now = clock.now.Add(10 * time.Minute)
+	engine.sweep()
+
+	if alerts := engine.Alerts(StateResolved); len(alerts) != 1 {
+		t.Errorf("Expected alert for vanished container to resolve, got %+v", engine.Alerts(""))
+	}
+}
+
+func TestEngine_EventRules(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	engine.HandleEvent(events.Message{
+		Type:   events.ContainerEventType,
+		Action: events.ActionDie,
+		Actor: events.Actor{
+			ID: paymentsAPI.ID,
+			Attributes: map[string]string{
+				"name":                       "payments-api",
+				"image":                      "payments:1.2",
+				"exitCode":                   "137",
+				"com.docker.compose.project": "payments",
+			},
+		},
+	})
+
+	alerts := engine.Alerts(StateFiring)
+	if len(alerts) != 1 {
+		t.Fatalf("Expected firing event alert, got %+v", engine.Alerts(""))
+	}
+	if alerts[0].Event != "die" || alerts[0].Severity != SeverityCritical {
+		t.Errorf("Unexpected event alert: %+v", alerts[0])
+	}
+	if alerts[0].Labels["com.docker.compose.project"] != "payments" {
+		t.Errorf("Expected labels from event attributes, got %v", alerts[0].Labels)
+	}
+	if _, ok := alerts[0].Labels["exitCode"]; ok {
+		t.Error("Expected exitCode to be excluded from labels")
+	}
+
+	engine.HandleEvent(events.Message{
+		Type:   events.ContainerEventType,
+		Action: events.ActionStart,
+		Actor:  events.Actor{ID: paymentsAPI.ID},
+	})
+	if alerts = engine.Alerts(StateResolved); len(alerts) != 1 {
+		t.Errorf("Expected event alert to resolve on start, got %+v", engine.Alerts(""))
+	}
+}
@@ -0,0 +1,206 @@
+package alerting
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/utils"
+)
+
+// resolvingEvents resolve a container's event alerts, so rules cannot fire
+// on them
+var resolvingEvents = map[string]struct{}{
+	string(events.ActionStart):   {},
+	string(events.ActionDestroy): {},
+}
+
+// Supported metrics for threshold rules
+const (
+	MetricCPUPercent    = "cpu_percent"
+	MetricMemoryPercent = "memory_percent"
+	MetricMemoryUsage   = "memory_usage"
+	MetricPIDs          = "pids"
+)
+
+// Supported severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Rule defines when an alert should fire. A rule either compares a metric
+// against a threshold for a duration, or matches Docker container events.
+type Rule struct {
+	Name        string        `yaml:"name" json:"name"`
+	Description string        `yaml:"description,omitempty" json:"description,omitempty"`
+	Metric      string        `yaml:"metric,omitempty" json:"metric,omitempty"`
+	Operator    string        `yaml:"operator,omitempty" json:"operator,omitempty"`
+	Threshold   float64       `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+	For         time.Duration `yaml:"for,omitempty" json:"for,omitempty"`
+	Events      []string      `yaml:"events,omitempty" json:"events,omitempty"`
+	Severity    string        `yaml:"severity,omitempty" json:"severity"`
+	Containers  []string      `yaml:"containers,omitempty" json:"containers,omitempty"`
+	Selector    string        `yaml:"selector,omitempty" json:"selector,omitempty"`
+
+	selector utils.LabelSelector
+}
+
+// rulesFile is the on-disk layout of the rules file
+type rulesFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadRules reads and validates alert rules from a YAML file
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	return ParseRules(data)
+}
+
+// ParseRules parses and validates alert rules from YAML
+func ParseRules(data []byte) ([]Rule, error) {
+	var file rulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+
+	seen := make(map[string]bool, len(file.Rules))
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, rule.Name, err)
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("rule %d: duplicate rule name %q", i, rule.Name)
+		}
+		seen[rule.Name] = true
+	}
+
+	return file.Rules, nil
+}
+
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	switch {
+	case r.Metric != "" && len(r.Events) > 0:
+		return fmt.Errorf("a rule cannot have both metric and events")
+	case r.Metric != "":
+		switch r.Metric {
+		case MetricCPUPercent, MetricMemoryPercent, MetricMemoryUsage, MetricPIDs:
+		default:
+			return fmt.Errorf("unknown metric %q", r.Metric)
+		}
+		if r.Operator == "" {
+			r.Operator = ">"
+		}
+		switch r.Operator {
+		case ">", ">=", "<", "<=":
+		default:
+			return fmt.Errorf("unknown operator %q", r.Operator)
+		}
+		if r.For < 0 {
+			return fmt.Errorf("for must not be negative")
+		}
+	case len(r.Events) > 0:
+		for i, event := range r.Events {
+			r.Events[i] = strings.TrimSpace(event)
+			if _, ok := resolvingEvents[r.Events[i]]; ok {
+				return fmt.Errorf("event %q resolves event alerts and cannot trigger them", r.Events[i])
+			}
+		}
+	default:
+		return fmt.Errorf("either metric or events is required")
+	}
+
+	if r.Severity == "" {
+		r.Severity = SeverityWarning
+	}
+	switch r.Severity {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("unknown severity %q", r.Severity)
+	}
+
+	selector, err := utils.ParseLabelSelector(r.Selector)
+	if err != nil {
+		return err
+	}
+	r.selector = selector
+
+	return nil
+}
+
+// IsEventRule reports whether the rule matches Docker events
+func (r *Rule) IsEventRule() bool {
+	return len(r.Events) > 0
+}
+
+// matchesContainer reports whether the rule's container list and label
+// selector both match
+func (r *Rule) matchesContainer(id, name string, labels map[string]string) bool {
+	if len(r.Containers) > 0 {
+		matched := false
+		for _, c := range r.Containers {
+			if c == name || (len(c) >= 12 && strings.HasPrefix(id, c)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return r.selector.Matches(labels)
+}
+
+// matchesEvent reports whether the rule triggers on the given event action
+func (r *Rule) matchesEvent(action string) bool {
+	for _, event := range r.Events {
+		if event == action {
+			return true
+		}
+	}
+	return false
+}
+
+// value extracts the rule's metric from stats
+func (r *Rule) value(stats *docker.ContainerStats) float64 {
+	switch r.Metric {
+	case MetricCPUPercent:
+		return stats.CPUPercent
+	case MetricMemoryPercent:
+		return stats.MemoryPercent
+	case MetricMemoryUsage:
+		return float64(stats.MemoryUsage)
+	case MetricPIDs:
+		return float64(stats.PIDs)
+	}
+	return 0
+}
+
+// breached reports whether value crosses the rule's threshold
+func (r *Rule) breached(value float64) bool {
+	switch r.Operator {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	}
+	return false
+}
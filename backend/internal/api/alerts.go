@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/alerting"
+)
+
+// AlertHandler handles alert-related API endpoints
+type AlertHandler struct {
+	engine interface {
+		Alerts(state alerting.State) []alerting.Alert
+		Rules() []alerting.Rule
+	}
+	logger *zap.Logger
+}
+
+// NewAlertHandler creates a new alert handler
+func NewAlertHandler(engine interface {
+	Alerts(state alerting.State) []alerting.Alert
+	Rules() []alerting.Rule
+}, logger *zap.Logger) *AlertHandler {
+	return &AlertHandler{
+		engine: engine,
+		logger: logger,
+	}
+}
+
+// ListAlerts handles GET /api/alerts?state=pending|firing|resolved
+func (h *AlertHandler) ListAlerts(c *gin.Context) {
+	state := alerting.State(c.Query("state"))
+	switch state {
+	case "", alerting.StatePending, alerting.StateFiring, alerting.StateResolved:
+	default:
+		BadRequest(c, "Invalid state", "state must be one of pending, firing, resolved")
+		return
+	}
+
+	alerts := h.engine.Alerts(state)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      alerts,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total: len(alerts),
+		},
+	})
+}
+
+// ListRules handles GET /api/alerts/rules
+func (h *AlertHandler) ListRules(c *gin.Context) {
+	rules := h.engine.Rules()
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      rules,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total: len(rules),
+		},
+	})
+}
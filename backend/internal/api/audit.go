@@ -0,0 +1,126 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/audit"
+)
+
+const (
+	// defaultAuditLimit is the number of records returned when limit is not specified
+	defaultAuditLimit = 100
+
+	// maxAuditLimit caps the records returned by a single query
+	maxAuditLimit = 1000
+)
+
+// AuditHandler handles audit log queries
+type AuditHandler struct {
+	log interface {
+		Query(filter audit.Filter) ([]audit.Record, error)
+		Export(w io.Writer, filter audit.Filter) error
+	}
+	logger *zap.Logger
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(log interface {
+	Query(filter audit.Filter) ([]audit.Record, error)
+	Export(w io.Writer, filter audit.Filter) error
+}, logger *zap.Logger) *AuditHandler {
+	return &AuditHandler{
+		log:    log,
+		logger: logger,
+	}
+}
+
+// parseAuditFilter reads the actor, action, target, from, to and limit
+// query parameters
+func parseAuditFilter(c *gin.Context, defaultLimit int) (audit.Filter, bool) {
+	filter := audit.Filter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Target: c.Query("target"),
+		Limit:  defaultLimit,
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(c.Query("from"), time.Time{}); err != nil {
+		BadRequest(c, "Invalid 'from' parameter", err.Error())
+		return filter, false
+	}
+	if filter.To, err = parseTimeParam(c.Query("to"), time.Time{}); err != nil {
+		BadRequest(c, "Invalid 'to' parameter", err.Error())
+		return filter, false
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		BadRequest(c, "'to' must be after 'from'")
+		return filter, false
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			BadRequest(c, "Invalid 'limit' parameter", "must be a positive integer")
+			return filter, false
+		}
+		filter.Limit = limit
+	}
+	return filter, true
+}
+
+// ListAudit handles GET /api/audit?actor=&action=&target=&from=&to=&limit=
+// and returns the newest matching records first
+func (h *AuditHandler) ListAudit(c *gin.Context) {
+	filter, ok := parseAuditFilter(c, defaultAuditLimit)
+	if !ok {
+		return
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+
+	records, err := h.log.Query(filter)
+	if err != nil {
+		h.logger.Error("Failed to query audit log", zap.Error(err))
+		InternalServerError(c, "Failed to query audit log", err.Error())
+		return
+	}
+	if records == nil {
+		records = []audit.Record{}
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      records,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total: len(records),
+		},
+	})
+}
+
+// ExportAudit handles GET /api/audit/export and streams matching records
+// as NDJSON, oldest first. Without limit every record is exported.
+func (h *AuditHandler) ExportAudit(c *gin.Context) {
+	filter, ok := parseAuditFilter(c, 0)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="audit.ndjson"`)
+	clearWriteDeadline(c)
+	c.Status(http.StatusOK)
+
+	if err := h.log.Export(c.Writer, filter); err != nil {
+		// Headers are already sent; the export is truncated
+		h.logger.Error("Failed to export audit log", zap.Error(err))
+	}
+}
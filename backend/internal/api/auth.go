@@ -0,0 +1,205 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// AuthHandler handles login sessions and WebSocket tickets
+type AuthHandler struct {
+	users interface {
+		VerifyPassword(name, password string) (*auth.Principal, error)
+		Lookup(name string) (*auth.Principal, error)
+	}
+	sessions interface {
+		Login(user string) (*auth.TokenPair, error)
+		Refresh(refreshToken string) (*auth.TokenPair, error)
+		Revoke(sessionID string) error
+		RevokeRefresh(refreshToken string) error
+	}
+	tickets interface {
+		IssueTicket(principal *auth.Principal) (string, time.Time, error)
+	}
+	logger *zap.Logger
+}
+
+// NewAuthHandler creates a new auth handler
+func NewAuthHandler(users interface {
+	VerifyPassword(name, password string) (*auth.Principal, error)
+	Lookup(name string) (*auth.Principal, error)
+}, sessions interface {
+	Login(user string) (*auth.TokenPair, error)
+	Refresh(refreshToken string) (*auth.TokenPair, error)
+	Revoke(sessionID string) error
+	RevokeRefresh(refreshToken string) error
+}, tickets interface {
+	IssueTicket(principal *auth.Principal) (string, time.Time, error)
+}, logger *zap.Logger) *AuthHandler {
+	return &AuthHandler{
+		users:    users,
+		sessions: sessions,
+		tickets:  tickets,
+		logger:   logger,
+	}
+}
+
+// LoginRequest is the body of POST /api/auth/login
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// RefreshRequest is the body of POST /api/auth/refresh and, optionally,
+// POST /api/auth/logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// WebSocketTicket is returned by POST /api/auth/ws-ticket
+type WebSocketTicket struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Login handles POST /api/auth/login
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	principal, err := h.users.VerifyPassword(req.Username, req.Password)
+	if err != nil {
+		h.logger.Warn("Login failed",
+			zap.String("user", req.Username),
+			zap.String("client_ip", c.ClientIP()))
+		Unauthorized(c, "Invalid username or password")
+		return
+	}
+
+	// Expose the user to the audit log, which runs before authentication here
+	c.Set(middleware.PrincipalKey, principal)
+
+	pair, err := h.sessions.Login(principal.User)
+	if err != nil {
+		h.logger.Error("Failed to start session", zap.String("user", principal.User), zap.Error(err))
+		InternalServerError(c, "Failed to start session", err.Error())
+		return
+	}
+
+	h.logger.Info("User logged in", zap.String("user", principal.User))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      pair,
+		Timestamp: time.Now(),
+	})
+}
+
+// Refresh handles POST /api/auth/refresh. The presented refresh token is
+// rotated and cannot be used again.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		BadRequest(c, "Invalid request body", "refresh_token is required")
+		return
+	}
+
+	pair, err := h.sessions.Refresh(req.RefreshToken)
+	switch {
+	case errors.Is(err, auth.ErrRefreshReused):
+		h.logger.Warn("Refresh token reused, session revoked", zap.String("client_ip", c.ClientIP()))
+		Unauthorized(c, "Invalid refresh token")
+		return
+	case errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrSessionRevoked):
+		Unauthorized(c, "Invalid refresh token")
+		return
+	case err != nil:
+		h.logger.Error("Failed to refresh session", zap.Error(err))
+		InternalServerError(c, "Failed to refresh session", err.Error())
+		return
+	}
+
+	// Users deleted since login lose their sessions
+	if _, err := h.users.Lookup(pair.User); err != nil {
+		_ = h.sessions.RevokeRefresh(pair.RefreshToken)
+		Unauthorized(c, "Invalid refresh token")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      pair,
+		Timestamp: time.Now(),
+	})
+}
+
+// Logout handles POST /api/auth/logout. It ends the session of the access
+// token used, or of the refresh token in the body.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req RefreshRequest
+	_ = c.ShouldBindJSON(&req)
+
+	principal := middleware.GetPrincipal(c)
+	var err error
+	switch {
+	case principal != nil && principal.SessionID != "":
+		err = h.sessions.Revoke(principal.SessionID)
+	case strings.TrimSpace(req.RefreshToken) != "":
+		err = h.sessions.RevokeRefresh(req.RefreshToken)
+	default:
+		BadRequest(c, "No session to log out", "authenticate with an access token or pass refresh_token")
+		return
+	}
+	if err != nil && !errors.Is(err, auth.ErrNotFound) {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			BadRequest(c, "Invalid refresh token", err.Error())
+			return
+		}
+		h.logger.Error("Failed to end session", zap.Error(err))
+		InternalServerError(c, "Failed to end session", err.Error())
+		return
+	}
+
+	if principal != nil {
+		h.logger.Info("User logged out", zap.String("user", principal.User))
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Logged out successfully"},
+		Timestamp: time.Now(),
+	})
+}
+
+// WebSocketTicket handles POST /api/auth/ws-ticket. The ticket authenticates
+// a single WebSocket upgrade through the ticket query parameter.
+func (h *AuthHandler) WebSocketTicket(c *gin.Context) {
+	principal := middleware.GetPrincipal(c)
+	if principal == nil {
+		Unauthorized(c, "Authentication required")
+		return
+	}
+
+	ticket, expiresAt, err := h.tickets.IssueTicket(principal)
+	if err != nil {
+		h.logger.Error("Failed to issue WebSocket ticket", zap.Error(err))
+		InternalServerError(c, "Failed to issue ticket", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success:   true,
+		Data:      WebSocketTicket{Ticket: ticket, ExpiresAt: expiresAt},
+		Timestamp: time.Now(),
+	})
+}
@@ -2,16 +2,31 @@ package api
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/gin-gonic/gin"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"go.uber.org/zap"
 
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/middleware"
 	"github.com/kubevision/kubevision/internal/utils"
 )
 
+// imagePullTimeout bounds pulling a missing image on container create
+const imagePullTimeout = 10 * time.Minute
+
 // ContainerControlHandler handles container control operations
 type ContainerControlHandler struct {
 	dockerClient interface {
@@ -20,20 +35,41 @@ type ContainerControlHandler struct {
 		ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error
 		ContainerPause(ctx context.Context, containerID string) error
 		ContainerUnpause(ctx context.Context, containerID string) error
+		ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error)
+		ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
+		ContainerRename(ctx context.Context, containerID, newContainerName string) error
+		ContainerUpdate(ctx context.Context, containerID string, updateConfig container.UpdateConfig) (container.UpdateResponse, error)
+		NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error
+		ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error)
+		VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error)
+	}
+	credentials interface {
+		RegistryAuth(imageRef string) (string, error)
 	}
 	logger *zap.Logger
 }
 
-// NewContainerControlHandler creates a new container control handler
+// NewContainerControlHandler creates a new container control handler.
+// Images missing on create are pulled with the stored registry credentials.
 func NewContainerControlHandler(dockerClient interface {
 	ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error
 	ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error
 	ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error
 	ContainerPause(ctx context.Context, containerID string) error
 	ContainerUnpause(ctx context.Context, containerID string) error
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error)
+	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
+	ContainerRename(ctx context.Context, containerID, newContainerName string) error
+	ContainerUpdate(ctx context.Context, containerID string, updateConfig container.UpdateConfig) (container.UpdateResponse, error)
+	NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error
+	ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error)
+	VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error)
+}, credentials interface {
+	RegistryAuth(imageRef string) (string, error)
 }, logger *zap.Logger) *ContainerControlHandler {
 	return &ContainerControlHandler{
 		dockerClient: dockerClient,
+		credentials:  credentials,
 		logger:       logger,
 	}
 }
@@ -215,3 +251,320 @@ func (h *ContainerControlHandler) UnpauseContainer(c *gin.Context) {
 	})
 }
 
+// RenameContainerRequest is the body of POST /api/containers/:id/rename
+type RenameContainerRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// CreatedContainer is returned by POST /api/containers
+type CreatedContainer struct {
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+	Started bool   `json:"started"`
+}
+
+// dockerError sends a structured error for a failed Docker call, mapping
+// Docker's error classes to HTTP statuses
+func dockerError(c *gin.Context, message string, err error) {
+	switch {
+	case cerrdefs.IsNotFound(err):
+		ErrorResponse(c, http.StatusNotFound, message, err.Error())
+	case cerrdefs.IsConflict(err), cerrdefs.IsAlreadyExists(err):
+		ErrorResponse(c, http.StatusConflict, message, err.Error())
+	case cerrdefs.IsInvalidArgument(err):
+		ErrorResponse(c, http.StatusBadRequest, message, err.Error())
+	case cerrdefs.IsPermissionDenied(err), cerrdefs.IsUnauthorized(err):
+		ErrorResponse(c, http.StatusForbidden, message, err.Error())
+	default:
+		InternalServerError(c, message, err.Error())
+	}
+}
+
+// validContainerParam reads and validates the :id path parameter
+func validContainerParam(c *gin.Context) (string, bool) {
+	containerID := c.Param("id")
+	if containerID == "" {
+		BadRequest(c, "Container ID is required")
+		return "", false
+	}
+	if !utils.ValidateContainerID(containerID) {
+		BadRequest(c, "Invalid container ID format")
+		return "", false
+	}
+	return containerID, true
+}
+
+// CreateContainer handles POST /api/containers. Principals whose control
+// permission is restricted by a label selector may only create containers
+// whose labels match it.
+func (h *ContainerControlHandler) CreateContainer(c *gin.Context) {
+	var spec ContainerSpec
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	config, hostConfig, networkingConfig, err := spec.toDocker()
+	if err != nil {
+		BadRequest(c, "Invalid container spec", err.Error())
+		return
+	}
+
+	if principal := middleware.GetPrincipal(c); principal != nil && !principal.CanOn(auth.PermContainersControl, spec.Labels) {
+		Forbidden(c, "Container labels do not match your permitted selector")
+		return
+	}
+	if paths := spec.hostPaths(); len(paths) > 0 && !canAccessHost(c) {
+		ErrorResponse(c, http.StatusForbidden, "Only admins may mount host paths", strings.Join(paths, ", "))
+		return
+	}
+	if names := spec.hostNetworks(); len(names) > 0 && !canAccessHost(c) {
+		ErrorResponse(c, http.StatusForbidden, "Only admins may use the host network", strings.Join(names, ", "))
+		return
+	}
+	if !canAccessHost(c) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		names, err := spec.hostVolumes(ctx, h.dockerClient)
+		cancel()
+		if err != nil {
+			dockerError(c, "Failed to inspect volume", err)
+			return
+		}
+		if len(names) > 0 {
+			ErrorResponse(c, http.StatusForbidden, "Only admins may mount host paths", "volumes backed by a host path: "+strings.Join(names, ", "))
+			return
+		}
+	}
+
+	// Pull missing images like docker run does
+	created, err := h.createContainer(config, hostConfig, networkingConfig, spec.Name)
+	if cerrdefs.IsNotFound(err) {
+		// The pull, and the network connects and start after it, may take
+		// longer than the server's write timeout allows
+		extendWriteDeadline(c, imagePullTimeout+time.Minute)
+		if pullErr := h.pullImage(spec.Image); pullErr != nil {
+			h.logger.Error("Failed to pull image for new container",
+				zap.String("image", spec.Image),
+				zap.Error(pullErr))
+			dockerError(c, "Failed to pull image "+spec.Image, pullErr)
+			return
+		}
+		created, err = h.createContainer(config, hostConfig, networkingConfig, spec.Name)
+	}
+	if err != nil {
+		h.logger.Error("Failed to create container",
+			zap.String("image", spec.Image),
+			zap.String("name", spec.Name),
+			zap.Error(err))
+		dockerError(c, "Failed to create container", err)
+		return
+	}
+	c.Set(middleware.AuditTargetKey, created.ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// Docker only attaches one network on create
+	for _, name := range spec.Networks[min(1, len(spec.Networks)):] {
+		if err := h.dockerClient.NetworkConnect(ctx, name, created.ID, nil); err != nil {
+			h.logger.Error("Failed to connect container to network",
+				zap.String("container_id", created.ID),
+				zap.String("network", name),
+				zap.Error(err))
+			if rmErr := h.dockerClient.ContainerRemove(ctx, created.ID, container.RemoveOptions{Force: true}); rmErr != nil {
+				h.logger.Warn("Failed to remove partially created container",
+					zap.String("container_id", created.ID),
+					zap.Error(rmErr))
+			}
+			dockerError(c, "Failed to connect network "+name, err)
+			return
+		}
+	}
+
+	if spec.Start {
+		if err := h.dockerClient.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+			h.logger.Error("Failed to start created container",
+				zap.String("container_id", created.ID),
+				zap.Error(err))
+			dockerError(c, "Container "+created.ID+" was created but failed to start", err)
+			return
+		}
+	}
+
+	h.logger.Info("Container created",
+		zap.String("container_id", created.ID),
+		zap.String("image", spec.Image),
+		zap.Bool("started", spec.Start))
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success:   true,
+		Data:      CreatedContainer{ID: created.ID, Name: spec.Name, Started: spec.Start},
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Warnings: created.Warnings,
+		},
+	})
+}
+
+// createContainer creates a container without starting it
+func (h *ContainerControlHandler) createContainer(config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (container.CreateResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	return h.dockerClient.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, name)
+}
+
+// containerAction starts, stops or restarts a container, with the same stop
+// timeout as the single-container routes
+func (h *ContainerControlHandler) containerAction(ctx context.Context, action, containerID string) error {
+	timeout := 10 // seconds
+	switch action {
+	case "start":
+		return h.dockerClient.ContainerStart(ctx, containerID, container.StartOptions{})
+	case "stop":
+		return h.dockerClient.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &timeout})
+	case "restart":
+		return h.dockerClient.ContainerRestart(ctx, containerID, container.StopOptions{Timeout: &timeout})
+	default:
+		return fmt.Errorf("%w: unknown action %q", cerrdefs.ErrInvalidArgument, action)
+	}
+}
+
+// pullImage pulls an image with the stored credentials of its registry
+func (h *ContainerControlHandler) pullImage(imageRef string) error {
+	ref, err := docker.NormalizeImageRef(imageRef)
+	if err != nil {
+		return fmt.Errorf("%w: %v", cerrdefs.ErrInvalidArgument, err)
+	}
+	registryAuth, err := h.credentials.RegistryAuth(ref)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), imagePullTimeout)
+	defer cancel()
+
+	h.logger.Info("Pulling missing image", zap.String("image", ref))
+	return docker.PullImage(ctx, h.dockerClient, ref, image.PullOptions{RegistryAuth: registryAuth}, func(docker.PullProgress) {})
+}
+
+// RemoveContainer handles DELETE /api/containers/:id?force=&volumes=
+func (h *ContainerControlHandler) RemoveContainer(c *gin.Context) {
+	containerID, ok := validContainerParam(c)
+	if !ok {
+		return
+	}
+
+	force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+	if err != nil {
+		BadRequest(c, "Invalid 'force' parameter", err.Error())
+		return
+	}
+	volumes, err := strconv.ParseBool(c.DefaultQuery("volumes", "false"))
+	if err != nil {
+		BadRequest(c, "Invalid 'volumes' parameter", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := h.dockerClient.ContainerRemove(ctx, containerID, container.RemoveOptions{
+		Force:         force,
+		RemoveVolumes: volumes,
+	}); err != nil {
+		h.logger.Error("Failed to remove container",
+			zap.String("container_id", containerID),
+			zap.Error(err))
+		dockerError(c, "Failed to remove container", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Container removed successfully"},
+		Timestamp: time.Now(),
+	})
+}
+
+// RenameContainer handles POST /api/containers/:id/rename
+func (h *ContainerControlHandler) RenameContainer(c *gin.Context) {
+	containerID, ok := validContainerParam(c)
+	if !ok {
+		return
+	}
+
+	var req RenameContainerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if err := validateContainerName(req.Name); err != nil {
+		BadRequest(c, "Invalid container name", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := h.dockerClient.ContainerRename(ctx, containerID, req.Name); err != nil {
+		h.logger.Error("Failed to rename container",
+			zap.String("container_id", containerID),
+			zap.String("name", req.Name),
+			zap.Error(err))
+		dockerError(c, "Failed to rename container", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Container renamed successfully", "name": req.Name},
+		Timestamp: time.Now(),
+	})
+}
+
+// UpdateResources handles PATCH /api/containers/:id/resources. Only the
+// limits present in the body are changed; running containers are updated
+// in place.
+func (h *ContainerControlHandler) UpdateResources(c *gin.Context) {
+	containerID, ok := validContainerParam(c)
+	if !ok {
+		return
+	}
+
+	var req ResourceLimits
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if req == (ResourceLimits{}) {
+		BadRequest(c, "No resource limits given", "set at least one of cpus, cpu_shares, memory, memory_swap, pids_limit")
+		return
+	}
+
+	resources, err := req.toResources()
+	if err != nil {
+		BadRequest(c, "Invalid resource limits", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	updated, err := h.dockerClient.ContainerUpdate(ctx, containerID, container.UpdateConfig{Resources: resources})
+	if err != nil {
+		h.logger.Error("Failed to update container resources",
+			zap.String("container_id", containerID),
+			zap.Error(err))
+		dockerError(c, "Failed to update container resources", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Container resources updated successfully"},
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Warnings: updated.Warnings,
+		},
+	})
+}
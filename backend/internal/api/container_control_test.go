@@ -0,0 +1,270 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/gin-gonic/gin"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// mockControlClient records lifecycle calls
+type mockControlClient struct {
+	created    *container.Config
+	hostConfig *container.HostConfig
+	networking *network.NetworkingConfig
+	names      []string
+	connected  []string
+	started    []string
+	stopped    []string
+	restarted  []string
+	removed    []container.RemoveOptions
+	removedIDs []string
+	updated    *container.UpdateConfig
+	removeErr  error
+	missing    bool
+	pulled     []image.PullOptions
+	pullErr    error
+	volumes    map[string]volume.Volume
+}
+
+func (m *mockControlClient) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	m.started = append(m.started, containerID)
+	return nil
+}
+
+func (m *mockControlClient) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	m.stopped = append(m.stopped, containerID)
+	return nil
+}
+
+func (m *mockControlClient) ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error {
+	m.restarted = append(m.restarted, containerID)
+	return nil
+}
+
+func (m *mockControlClient) ContainerPause(ctx context.Context, containerID string) error {
+	return nil
+}
+
+func (m *mockControlClient) ContainerUnpause(ctx context.Context, containerID string) error {
+	return nil
+}
+
+func (m *mockControlClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	if m.missing {
+		return container.CreateResponse{}, cerrdefs.ErrNotFound
+	}
+	m.created = config
+	m.hostConfig = hostConfig
+	m.networking = networkingConfig
+	m.names = append(m.names, containerName)
+	return container.CreateResponse{ID: "0123456789abcdef"}, nil
+}
+
+func (m *mockControlClient) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	m.removed = append(m.removed, options)
+	m.removedIDs = append(m.removedIDs, containerID)
+	return m.removeErr
+}
+
+func (m *mockControlClient) ContainerRename(ctx context.Context, containerID, newContainerName string) error {
+	return nil
+}
+
+func (m *mockControlClient) ContainerUpdate(ctx context.Context, containerID string, updateConfig container.UpdateConfig) (container.UpdateResponse, error) {
+	m.updated = &updateConfig
+	return container.UpdateResponse{}, nil
+}
+
+func (m *mockControlClient) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	m.connected = append(m.connected, networkID)
+	return nil
+}
+
+func (m *mockControlClient) ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
+	m.pulled = append(m.pulled, options)
+	if m.pullErr != nil {
+		return nil, m.pullErr
+	}
+	m.missing = false
+	return io.NopCloser(strings.NewReader(`{"status":"Downloaded newer image for ` + refStr + `"}`)), nil
+}
+
+func (m *mockControlClient) VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error) {
+	if vol, ok := m.volumes[volumeID]; ok {
+		return vol, nil
+	}
+	return volume.Volume{}, cerrdefs.ErrNotFound
+}
+
+// staticCredentials returns the same registry auth for every image
+type staticCredentials string
+
+func (s staticCredentials) RegistryAuth(imageRef string) (string, error) {
+	return string(s), nil
+}
+
+func newControlRouter(client *mockControlClient, principal *auth.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewContainerControlHandler(client, staticCredentials("stored-auth"), zap.NewNop())
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set(middleware.PrincipalKey, principal) })
+	router.POST("/containers", handler.CreateContainer)
+	router.DELETE("/containers/:id", handler.RemoveContainer)
+	router.POST("/containers/:id/rename", handler.RenameContainer)
+	router.PATCH("/containers/:id/resources", handler.UpdateResources)
+	return router
+}
+
+func TestContainerControlHandler_CreateContainer(t *testing.T) {
+	client := &mockControlClient{}
+	router := newControlRouter(client, auth.Anonymous)
+
+	body := `{
+		"name": "web",
+		"image": "nginx:1.27",
+		"env": ["MODE=prod"],
+		"ports": [{"container_port": 80, "host_port": 8080}],
+		"volumes": [{"source": "web-data", "target": "/data", "read_only": true}],
+		"networks": ["frontend", "backend"],
+		"restart_policy": {"name": "on-failure", "max_retries": 3},
+		"resources": {"cpus": 1.5, "memory": "256m"},
+		"start": true
+	}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/containers", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if client.hostConfig.NanoCPUs != 1_500_000_000 || client.hostConfig.Memory != 256*1024*1024 {
+		t.Errorf("Unexpected resources: %+v", client.hostConfig.Resources)
+	}
+	if len(client.hostConfig.Binds) != 1 || client.hostConfig.Binds[0] != "web-data:/data:ro" {
+		t.Errorf("Unexpected binds: %v", client.hostConfig.Binds)
+	}
+	if client.hostConfig.RestartPolicy.Name != container.RestartPolicyOnFailure || client.hostConfig.RestartPolicy.MaximumRetryCount != 3 {
+		t.Errorf("Unexpected restart policy: %+v", client.hostConfig.RestartPolicy)
+	}
+	if len(client.connected) != 1 || client.connected[0] != "backend" {
+		t.Errorf("Expected second network to be connected after create, got %v", client.connected)
+	}
+	if len(client.started) != 1 {
+		t.Errorf("Expected container to be started")
+	}
+}
+
+func TestContainerControlHandler_CreatePullsMissingImage(t *testing.T) {
+	client := &mockControlClient{missing: true}
+	router := newControlRouter(client, auth.Anonymous)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/containers", strings.NewReader(`{"image": "registry.example.com/team/app:1"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(client.pulled) != 1 || client.pulled[0].RegistryAuth != "stored-auth" {
+		t.Errorf("Expected one pull with stored credentials, got %+v", client.pulled)
+	}
+	if client.created == nil {
+		t.Errorf("Expected container to be created after the pull")
+	}
+}
+
+func TestContainerControlHandler_CreateValidation(t *testing.T) {
+	operator, _ := auth.NewPrincipal("ops", auth.RoleOperator, "t", map[auth.Permission]string{
+		auth.PermContainersControl: "team=payments",
+	})
+
+	testCases := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"missing image", `{"name": "web"}`, http.StatusBadRequest},
+		{"invalid name", `{"image": "nginx", "name": "../etc"}`, http.StatusBadRequest},
+		{"invalid env", `{"image": "nginx", "env": ["NOVALUE"]}`, http.StatusBadRequest},
+		{"invalid port", `{"image": "nginx", "ports": [{"container_port": 70000}]}`, http.StatusBadRequest},
+		{"relative volume target", `{"image": "nginx", "volumes": [{"source": "data", "target": "data"}]}`, http.StatusBadRequest},
+		{"relative bind source", `{"image": "nginx", "volumes": [{"source": "../etc", "target": "/etc"}]}`, http.StatusBadRequest},
+		{"unknown restart policy", `{"image": "nginx", "restart_policy": {"name": "sometimes"}}`, http.StatusBadRequest},
+		{"retries without on-failure", `{"image": "nginx", "restart_policy": {"name": "always", "max_retries": 2}}`, http.StatusBadRequest},
+		{"memory too small", `{"image": "nginx", "resources": {"memory": "1m"}}`, http.StatusBadRequest},
+		{"labels outside selector", `{"image": "nginx", "labels": {"team": "billing"}}`, http.StatusForbidden},
+		{"labels inside selector", `{"image": "nginx", "labels": {"team": "payments"}}`, http.StatusCreated},
+		{"host path", `{"image": "nginx", "labels": {"team": "payments"}, "volumes": [{"source": "/var/run/docker.sock", "target": "/var/run/docker.sock"}]}`, http.StatusForbidden},
+		{"host network", `{"image": "nginx", "labels": {"team": "payments"}, "networks": ["host"]}`, http.StatusForbidden},
+		{"host-backed volume", `{"image": "nginx", "labels": {"team": "payments"}, "volumes": [{"source": "host-root", "target": "/host"}]}`, http.StatusForbidden},
+		{"named volume", `{"image": "nginx", "labels": {"team": "payments"}, "volumes": [{"source": "data", "target": "/data"}]}`, http.StatusCreated},
+	}
+	volumes := map[string]volume.Volume{
+		"host-root": {Name: "host-root", Driver: "local", Options: map[string]string{"o": "bind", "device": "/"}},
+		"data":      {Name: "data", Driver: "local"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := newControlRouter(&mockControlClient{volumes: volumes}, operator)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/containers", strings.NewReader(tc.body)))
+			if w.Code != tc.status {
+				t.Errorf("Expected status %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestContainerControlHandler_RemoveAndUpdate(t *testing.T) {
+	client := &mockControlClient{}
+	router := newControlRouter(client, auth.Anonymous)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/containers/0123456789abcdef?force=true&volumes=true", nil))
+	if w.Code != http.StatusOK || !client.removed[0].Force || !client.removed[0].RemoveVolumes {
+		t.Errorf("Expected forced removal with volumes, got %d %+v", w.Code, client.removed)
+	}
+
+	client.removeErr = cerrdefs.ErrNotFound
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/containers/0123456789abcdef", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected Docker not found to map to 404, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/containers/0123456789abcdef/rename", bytes.NewBufferString(`{"name": "bad name"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid rename to fail, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/containers/0123456789abcdef/resources", bytes.NewBufferString(`{}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected empty update to fail, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/containers/0123456789abcdef/resources", bytes.NewBufferString(`{"memory": "1g", "memory_swap": "2g"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected update to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if client.updated.Memory != 1<<30 || client.updated.MemorySwap != 2<<30 || client.updated.NanoCPUs != 0 {
+		t.Errorf("Expected only memory limits to change, got %+v", client.updated.Resources)
+	}
+}
@@ -0,0 +1,356 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+var (
+	// containerNamePattern matches names Docker accepts
+	containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,127}$`)
+
+	// resourceNamePattern matches volume and network names
+	resourceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+	// envKeyPattern matches environment variable names
+	envKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]*$`)
+)
+
+const (
+	// minMemoryLimit is the smallest memory limit Docker accepts
+	minMemoryLimit = 6 * 1024 * 1024
+
+	// maxCPUs bounds the cpus resource limit
+	maxCPUs = 1024
+)
+
+// PortMapping publishes a container port on the host
+type PortMapping struct {
+	ContainerPort int    `json:"container_port"`
+	HostPort      int    `json:"host_port,omitempty"`
+	HostIP        string `json:"host_ip,omitempty"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+// VolumeMount mounts a named volume or an absolute host path. Host paths
+// may only be mounted by admins.
+type VolumeMount struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+// RestartPolicySpec is a container restart policy
+type RestartPolicySpec struct {
+	Name              string `json:"name"`
+	MaximumRetryCount int    `json:"max_retries,omitempty"`
+}
+
+// ResourceLimits are CPU and memory limits. Memory values accept units
+// such as "512m" or "2g". Nil fields are left unchanged on update.
+type ResourceLimits struct {
+	CPUs       *float64 `json:"cpus,omitempty"`
+	CPUShares  *int64   `json:"cpu_shares,omitempty"`
+	Memory     *string  `json:"memory,omitempty"`
+	MemorySwap *string  `json:"memory_swap,omitempty"`
+	PidsLimit  *int64   `json:"pids_limit,omitempty"`
+}
+
+// ContainerSpec is the body of POST /api/containers
+type ContainerSpec struct {
+	Name          string             `json:"name,omitempty"`
+	Image         string             `json:"image" binding:"required"`
+	Cmd           []string           `json:"cmd,omitempty"`
+	Entrypoint    []string           `json:"entrypoint,omitempty"`
+	Env           []string           `json:"env,omitempty"`
+	WorkingDir    string             `json:"working_dir,omitempty"`
+	User          string             `json:"user,omitempty"`
+	Ports         []PortMapping      `json:"ports,omitempty"`
+	Volumes       []VolumeMount      `json:"volumes,omitempty"`
+	Networks      []string           `json:"networks,omitempty"`
+	RestartPolicy *RestartPolicySpec `json:"restart_policy,omitempty"`
+	Labels        map[string]string  `json:"labels,omitempty"`
+	Resources     *ResourceLimits    `json:"resources,omitempty"`
+	Start         bool               `json:"start,omitempty"`
+}
+
+// canAccessHost reports whether the principal may bind mount host paths,
+// use volumes backed by them or join the host's network namespace. Each
+// gives access to the whole host, e.g. through /var/run/docker.sock or a
+// daemon listening on 127.0.0.1, so they are reserved for admins.
+func canAccessHost(c *gin.Context) bool {
+	principal := middleware.GetPrincipal(c)
+	return principal == nil || principal.Role == auth.RoleAdmin
+}
+
+// hostPaths returns the host paths a spec bind mounts
+func (s ContainerSpec) hostPaths() []string {
+	var paths []string
+	for _, volume := range s.Volumes {
+		if path.IsAbs(volume.Source) {
+			paths = append(paths, volume.Source)
+		}
+	}
+	return paths
+}
+
+// hostVolumes returns the named volumes a spec mounts that are backed by a
+// host path. Volumes that do not exist yet are created on use with the
+// default driver, so they are not.
+func (s ContainerSpec) hostVolumes(ctx context.Context, dockerClient interface {
+	VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error)
+}) ([]string, error) {
+	var names []string
+	for _, mount := range s.Volumes {
+		if path.IsAbs(mount.Source) {
+			continue
+		}
+		vol, err := dockerClient.VolumeInspect(ctx, mount.Source)
+		if cerrdefs.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if mountsHostPath(vol.Driver, vol.Options) {
+			names = append(names, mount.Source)
+		}
+	}
+	return names, nil
+}
+
+// hostNetworks returns the networks of a spec that share the network
+// namespace of the host or of another container instead of attaching one
+func (s ContainerSpec) hostNetworks() []string {
+	var names []string
+	for _, name := range s.Networks {
+		if name == "host" || strings.HasPrefix(name, "container:") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// mountsHostPath reports whether a volume driver's options mount a host
+// path or device, as the local driver does with o=bind or device=/path
+func mountsHostPath(driver string, opts map[string]string) bool {
+	if driver != "" && driver != "local" {
+		return false
+	}
+	if strings.HasPrefix(opts["device"], "/") {
+		return true
+	}
+	for _, option := range strings.Split(opts["o"], ",") {
+		if option = strings.TrimSpace(option); option == "bind" || option == "rbind" {
+			return true
+		}
+	}
+	return false
+}
+
+// validateContainerName checks a container name
+func validateContainerName(name string) error {
+	if !containerNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid container name %q", name)
+	}
+	return nil
+}
+
+// validateRestartPolicy converts a restart policy
+func validateRestartPolicy(spec RestartPolicySpec) (container.RestartPolicy, error) {
+	policy := container.RestartPolicy{Name: container.RestartPolicyMode(spec.Name)}
+	switch policy.Name {
+	case "", container.RestartPolicyDisabled, container.RestartPolicyAlways, container.RestartPolicyUnlessStopped:
+		if spec.MaximumRetryCount != 0 {
+			return policy, fmt.Errorf("max_retries is only valid with the on-failure restart policy")
+		}
+	case container.RestartPolicyOnFailure:
+		if spec.MaximumRetryCount < 0 {
+			return policy, fmt.Errorf("max_retries must not be negative")
+		}
+		policy.MaximumRetryCount = spec.MaximumRetryCount
+	default:
+		return policy, fmt.Errorf("unknown restart policy %q", spec.Name)
+	}
+	return policy, nil
+}
+
+// toResources converts resource limits. Fields that are not set stay zero,
+// which Docker treats as unlimited on create and unchanged on update.
+func (r ResourceLimits) toResources() (container.Resources, error) {
+	var resources container.Resources
+
+	if r.CPUs != nil {
+		if *r.CPUs <= 0 || *r.CPUs > maxCPUs {
+			return resources, fmt.Errorf("cpus must be between 0 and %d", maxCPUs)
+		}
+		resources.NanoCPUs = int64(*r.CPUs * 1e9)
+	}
+	if r.CPUShares != nil {
+		if *r.CPUShares < 2 || *r.CPUShares > 262144 {
+			return resources, fmt.Errorf("cpu_shares must be between 2 and 262144")
+		}
+		resources.CPUShares = *r.CPUShares
+	}
+	if r.Memory != nil {
+		memory, err := units.RAMInBytes(*r.Memory)
+		if err != nil {
+			return resources, fmt.Errorf("invalid memory: %w", err)
+		}
+		if memory < minMemoryLimit {
+			return resources, fmt.Errorf("memory must be at least 6m")
+		}
+		resources.Memory = memory
+	}
+	if r.MemorySwap != nil {
+		if *r.MemorySwap == "-1" {
+			resources.MemorySwap = -1
+		} else {
+			swap, err := units.RAMInBytes(*r.MemorySwap)
+			if err != nil {
+				return resources, fmt.Errorf("invalid memory_swap: %w", err)
+			}
+			if resources.Memory > 0 && swap < resources.Memory {
+				return resources, fmt.Errorf("memory_swap must be at least memory")
+			}
+			resources.MemorySwap = swap
+		}
+	}
+	if r.PidsLimit != nil {
+		if *r.PidsLimit < -1 || *r.PidsLimit == 0 {
+			return resources, fmt.Errorf("pids_limit must be positive or -1 for unlimited")
+		}
+		pids := *r.PidsLimit
+		resources.PidsLimit = &pids
+	}
+	return resources, nil
+}
+
+// toDocker validates the spec and converts it to Docker create options
+func (s ContainerSpec) toDocker() (*container.Config, *container.HostConfig, *network.NetworkingConfig, error) {
+	if s.Name != "" {
+		if err := validateContainerName(s.Name); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if strings.TrimSpace(s.Image) == "" || strings.ContainsAny(s.Image, " \t\n") {
+		return nil, nil, nil, fmt.Errorf("invalid image %q", s.Image)
+	}
+
+	for _, entry := range s.Env {
+		key, _, ok := strings.Cut(entry, "=")
+		if !ok || !envKeyPattern.MatchString(key) {
+			return nil, nil, nil, fmt.Errorf("invalid env entry %q, expected KEY=VALUE", entry)
+		}
+	}
+	if s.WorkingDir != "" && !path.IsAbs(s.WorkingDir) {
+		return nil, nil, nil, fmt.Errorf("working_dir must be an absolute path")
+	}
+	for key := range s.Labels {
+		if key == "" {
+			return nil, nil, nil, fmt.Errorf("label keys must not be empty")
+		}
+	}
+
+	config := &container.Config{
+		Image:        s.Image,
+		Cmd:          s.Cmd,
+		Entrypoint:   s.Entrypoint,
+		Env:          s.Env,
+		WorkingDir:   s.WorkingDir,
+		User:         s.User,
+		Labels:       s.Labels,
+		ExposedPorts: nat.PortSet{},
+	}
+	hostConfig := &container.HostConfig{
+		PortBindings: nat.PortMap{},
+	}
+
+	for _, mapping := range s.Ports {
+		protocol := mapping.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		if protocol != "tcp" && protocol != "udp" && protocol != "sctp" {
+			return nil, nil, nil, fmt.Errorf("invalid protocol %q", mapping.Protocol)
+		}
+		if mapping.ContainerPort < 1 || mapping.ContainerPort > 65535 {
+			return nil, nil, nil, fmt.Errorf("invalid container_port %d", mapping.ContainerPort)
+		}
+		if mapping.HostPort < 0 || mapping.HostPort > 65535 {
+			return nil, nil, nil, fmt.Errorf("invalid host_port %d", mapping.HostPort)
+		}
+
+		port, err := nat.NewPort(protocol, strconv.Itoa(mapping.ContainerPort))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		config.ExposedPorts[port] = struct{}{}
+		binding := nat.PortBinding{HostIP: mapping.HostIP}
+		if mapping.HostPort > 0 {
+			binding.HostPort = strconv.Itoa(mapping.HostPort)
+		}
+		hostConfig.PortBindings[port] = append(hostConfig.PortBindings[port], binding)
+	}
+
+	for _, volume := range s.Volumes {
+		if !path.IsAbs(volume.Target) {
+			return nil, nil, nil, fmt.Errorf("volume target %q must be an absolute path", volume.Target)
+		}
+		if !path.IsAbs(volume.Source) && !resourceNamePattern.MatchString(volume.Source) {
+			return nil, nil, nil, fmt.Errorf("volume source %q must be a volume name or an absolute path", volume.Source)
+		}
+		bind := volume.Source + ":" + path.Clean(volume.Target)
+		if volume.ReadOnly {
+			bind += ":ro"
+		}
+		hostConfig.Binds = append(hostConfig.Binds, bind)
+	}
+
+	if s.RestartPolicy != nil {
+		policy, err := validateRestartPolicy(*s.RestartPolicy)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		hostConfig.RestartPolicy = policy
+	}
+
+	if s.Resources != nil {
+		resources, err := s.Resources.toResources()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		hostConfig.Resources = resources
+	}
+
+	// The first network is attached on create; the rest are connected after
+	var networking *network.NetworkingConfig
+	for i, name := range s.Networks {
+		if !resourceNamePattern.MatchString(name) {
+			return nil, nil, nil, fmt.Errorf("invalid network name %q", name)
+		}
+		if i == 0 {
+			hostConfig.NetworkMode = container.NetworkMode(name)
+			networking = &network.NetworkingConfig{
+				EndpointsConfig: map[string]*network.EndpointSettings{name: {}},
+			}
+		}
+	}
+
+	return config, hostConfig, networking, nil
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/middleware"
 	"github.com/kubevision/kubevision/internal/utils"
 )
 
@@ -20,6 +22,7 @@ type ContainerHandler struct {
 		ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
 		ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
 	}
+	host   string
 	logger *zap.Logger
 }
 
@@ -28,8 +31,18 @@ func NewContainerHandler(dockerClient interface {
 	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
 	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
 }, logger *zap.Logger) *ContainerHandler {
+	return NewHostContainerHandler(dockerClient, "", logger)
+}
+
+// NewHostContainerHandler creates a container handler whose listed
+// containers are tagged with the given host name
+func NewHostContainerHandler(dockerClient interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+}, host string, logger *zap.Logger) *ContainerHandler {
 	return &ContainerHandler{
 		dockerClient: dockerClient,
+		host:         host,
 		logger:       logger,
 	}
 }
@@ -44,10 +57,12 @@ type ContainerInfo struct {
 	Created    time.Time `json:"created"`
 	Ports      []Port    `json:"ports"`
 	Labels     map[string]string `json:"labels"`
+	Host       string    `json:"host,omitempty"`
 }
 
 // Port represents a container port mapping
 type Port struct {
+	IP          string `json:"ip,omitempty"`
 	PrivatePort uint16 `json:"private_port"`
 	PublicPort  uint16 `json:"public_port"`
 	Type        string `json:"type"`
@@ -64,8 +79,9 @@ type APIResponse struct {
 
 // Meta contains metadata about the response
 type Meta struct {
-	Total int `json:"total,omitempty"`
-	Page  int `json:"page,omitempty"`
+	Total    int      `json:"total,omitempty"`
+	Page     int      `json:"page,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 // ListContainers handles GET /api/containers
@@ -88,35 +104,7 @@ func (h *ContainerHandler) ListContainers(c *gin.Context) {
 	}
 
 	// Convert to API format
-	containerInfos := make([]ContainerInfo, 0, len(containers))
-	for _, container := range containers {
-		// Get container name (first name in Names slice, remove leading /)
-		name := container.ID[:12] // Default to short ID if no name
-		if len(container.Names) > 0 && len(container.Names[0]) > 0 {
-			name = strings.TrimPrefix(container.Names[0], "/")
-		}
-
-		// Convert ports
-		ports := make([]Port, 0, len(container.Ports))
-		for _, p := range container.Ports {
-			ports = append(ports, Port{
-				PrivatePort: p.PrivatePort,
-				PublicPort:  p.PublicPort,
-				Type:        p.Type,
-			})
-		}
-
-		containerInfos = append(containerInfos, ContainerInfo{
-			ID:      container.ID,
-			Name:    name,
-			Image:   container.Image,
-			Status:  container.Status,
-			State:   container.State,
-			Created: time.Unix(container.Created, 0),
-			Ports:   ports,
-			Labels:  container.Labels,
-		})
-	}
+	containerInfos := toContainerInfos(readableContainers(c, containers), h.host)
 
 	c.JSON(http.StatusOK, APIResponse{
 		Success:   true,
@@ -163,3 +151,58 @@ func (h *ContainerHandler) GetContainer(c *gin.Context) {
 	})
 }
 
+// readableContainers drops the containers outside the principal's
+// containers:read selector
+func readableContainers(c *gin.Context, containers []container.Summary) []container.Summary {
+	principal := middleware.GetPrincipal(c)
+	if principal == nil {
+		return containers
+	}
+	if _, restricted := principal.Restricted(auth.PermContainersRead); !restricted {
+		return containers
+	}
+
+	readable := make([]container.Summary, 0, len(containers))
+	for _, ctr := range containers {
+		if principal.CanOn(auth.PermContainersRead, ctr.Labels) {
+			readable = append(readable, ctr)
+		}
+	}
+	return readable
+}
+
+// toContainerInfos converts Docker container summaries to the API format
+func toContainerInfos(containers []container.Summary, host string) []ContainerInfo {
+	containerInfos := make([]ContainerInfo, 0, len(containers))
+	for _, container := range containers {
+		// Get container name (first name in Names slice, remove leading /)
+		name := container.ID[:12] // Default to short ID if no name
+		if len(container.Names) > 0 && len(container.Names[0]) > 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+
+		// Convert ports
+		ports := make([]Port, 0, len(container.Ports))
+		for _, p := range container.Ports {
+			ports = append(ports, Port{
+				IP:          p.IP,
+				PrivatePort: p.PrivatePort,
+				PublicPort:  p.PublicPort,
+				Type:        p.Type,
+			})
+		}
+
+		containerInfos = append(containerInfos, ContainerInfo{
+			ID:      container.ID,
+			Name:    name,
+			Image:   container.Image,
+			Status:  container.Status,
+			State:   container.State,
+			Created: time.Unix(container.Created, 0),
+			Ports:   ports,
+			Labels:  container.Labels,
+			Host:    host,
+		})
+	}
+	return containerInfos
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeDeadlineMargin is added to a handler's time budget to leave time to
+// write the response once the work is done
+const writeDeadlineMargin = 30 * time.Second
+
+// extendWriteDeadline lets a handler that may run for up to budget write its
+// response despite the server's WriteTimeout, which is sized for ordinary
+// requests
+func extendWriteDeadline(c *gin.Context, budget time.Duration) {
+	setWriteDeadline(c, time.Now().Add(budget+writeDeadlineMargin))
+}
+
+// clearWriteDeadline removes the write deadline of a streamed response, which
+// takes as long as the data it streams
+func clearWriteDeadline(c *gin.Context) {
+	setWriteDeadline(c, time.Time{})
+}
+
+func setWriteDeadline(c *gin.Context, deadline time.Time) {
+	// Writers without deadline support, such as test recorders, are not
+	// subject to the server's WriteTimeout either
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(deadline)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/audit"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// discardRecorder drops audit records
+type discardRecorder struct{}
+
+func (discardRecorder) Record(record audit.Record) error { return nil }
+
+func TestExtendWriteDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	slow := func(setDeadline func(c *gin.Context)) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			setDeadline(c)
+			time.Sleep(200 * time.Millisecond)
+			c.String(http.StatusOK, "done")
+		}
+	}
+	// The audit middleware wraps the writer, which must not hide the deadline
+	audited := middleware.AuditMiddleware(discardRecorder{}, "test", "", zap.NewNop())
+	router.GET("/extended", audited, slow(func(c *gin.Context) { extendWriteDeadline(c, time.Second) }))
+	router.GET("/cleared", audited, slow(clearWriteDeadline))
+	router.GET("/plain", slow(func(c *gin.Context) {}))
+
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	for _, path := range []string{"/extended", "/cleared"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Expected %s to succeed: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "done" {
+			t.Errorf("%s: unexpected body %q", path, body)
+		}
+	}
+
+	if resp, err := http.Get(server.URL + "/plain"); err == nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil && string(body) == "done" {
+			t.Error("Expected the write timeout to cut off the plain request")
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/utils"
+)
+
+// defaultHistoryRange is the range returned when from is not specified
+const defaultHistoryRange = time.Hour
+
+// HistoryHandler serves historical container metrics
+type HistoryHandler struct {
+	dockerClient interface {
+		ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	}
+	store interface {
+		Query(containerID string, from, to time.Time, step time.Duration) ([]docker.ContainerStats, error)
+	}
+	logger *zap.Logger
+}
+
+// NewHistoryHandler creates a new history handler
+func NewHistoryHandler(dockerClient interface {
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+}, store interface {
+	Query(containerID string, from, to time.Time, step time.Duration) ([]docker.ContainerStats, error)
+}, logger *zap.Logger) *HistoryHandler {
+	return &HistoryHandler{
+		dockerClient: dockerClient,
+		store:        store,
+		logger:       logger,
+	}
+}
+
+// GetContainerMetrics handles GET /api/containers/:id/metrics?from=&to=&step=
+func (h *HistoryHandler) GetContainerMetrics(c *gin.Context) {
+	containerID := c.Param("id")
+	if containerID == "" {
+		BadRequest(c, "Container ID is required")
+		return
+	}
+
+	if !utils.ValidateContainerID(containerID) {
+		BadRequest(c, "Invalid container ID format")
+		return
+	}
+
+	now := time.Now()
+	to, err := parseTimeParam(c.Query("to"), now)
+	if err != nil {
+		BadRequest(c, "Invalid 'to' parameter", err.Error())
+		return
+	}
+	from, err := parseTimeParam(c.Query("from"), to.Add(-defaultHistoryRange))
+	if err != nil {
+		BadRequest(c, "Invalid 'from' parameter", err.Error())
+		return
+	}
+	if !to.After(from) {
+		BadRequest(c, "'to' must be after 'from'")
+		return
+	}
+	step, err := parseDurationParam(c.Query("step"))
+	if err != nil {
+		BadRequest(c, "Invalid 'step' parameter", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// History is stored under the full ID, so resolve short IDs and names
+	inspect, err := h.dockerClient.ContainerInspect(ctx, containerID)
+	if err != nil {
+		h.logger.Error("Failed to inspect container", zap.String("container_id", containerID), zap.Error(err))
+		NotFound(c, "Container not found")
+		return
+	}
+
+	points, err := h.store.Query(inspect.ID, from, to, step)
+	if err != nil {
+		h.logger.Error("Failed to query metrics history",
+			zap.String("container_id", inspect.ID),
+			zap.Error(err))
+		InternalServerError(c, "Failed to query metrics history", err.Error())
+		return
+	}
+	if points == nil {
+		points = []docker.ContainerStats{}
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      points,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total: len(points),
+		},
+	})
+}
+
+// parseTimeParam parses an RFC3339 timestamp or Unix seconds, returning
+// fallback when value is empty
+func parseTimeParam(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseDurationParam parses a Go duration ("5m") or a number of seconds
+func parseDurationParam(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+)
+
+// HostHandler handles host listing and cross-host aggregation
+type HostHandler struct {
+	registry interface {
+		Hosts() []*docker.Host
+	}
+	logger *zap.Logger
+}
+
+// NewHostHandler creates a new host handler
+func NewHostHandler(registry interface {
+	Hosts() []*docker.Host
+}, logger *zap.Logger) *HostHandler {
+	return &HostHandler{
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+// ListHosts handles GET /api/hosts
+func (h *HostHandler) ListHosts(c *gin.Context) {
+	hosts := h.registry.Hosts()
+	health := make([]docker.HostHealth, 0, len(hosts))
+	for _, host := range hosts {
+		health = append(health, host.Health())
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      health,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total: len(health),
+		},
+	})
+}
+
+// ListAllContainers handles GET /api/containers, merging containers from
+// every healthy host. Hosts that are down or fail are reported as warnings.
+func (h *HostHandler) ListAllContainers(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	hosts := h.registry.Hosts()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		all      []ContainerInfo
+		warnings []string
+	)
+
+	for _, host := range hosts {
+		if !host.Healthy() {
+			// Hosts listed earlier may already be appending warnings
+			mu.Lock()
+			warnings = append(warnings, fmt.Sprintf("host %s is unhealthy", host.Name()))
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(host *docker.Host) {
+			defer wg.Done()
+
+			containers, err := host.Client().ContainerList(ctx, container.ListOptions{All: true})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				h.logger.Error("Failed to list containers",
+					zap.String("host", host.Name()),
+					zap.Error(err))
+				warnings = append(warnings, fmt.Sprintf("host %s: %v", host.Name(), err))
+				return
+			}
+			all = append(all, toContainerInfos(readableContainers(c, containers), host.Name())...)
+		}(host)
+	}
+	wg.Wait()
+
+	if all == nil && len(warnings) == len(hosts) && len(hosts) > 0 {
+		InternalServerError(c, "Failed to list containers on any host")
+		return
+	}
+	if all == nil {
+		all = []ContainerInfo{}
+	}
+
+	// Keep the merged order stable across requests
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Host != all[j].Host {
+			return all[i].Host < all[j].Host
+		}
+		return all[i].Name < all[j].Name
+	})
+	sort.Strings(warnings)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      all,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total:    len(all),
+			Warnings: warnings,
+		},
+	})
+}
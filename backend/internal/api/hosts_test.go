@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+)
+
+// fakeDaemon serves Docker's ping and container list, or fails the list
+// with a server error when containers is nil
+func fakeDaemon(t *testing.T, containers []container.Summary) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			w.Header().Set("API-Version", "1.47")
+			_, _ = w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/containers/json") && containers != nil:
+			_ = json.NewEncoder(w).Encode(containers)
+		default:
+			http.Error(w, `{"message": "daemon error"}`, http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "tcp://" + strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestHostHandler_ListAllContainers_MixedHealth(t *testing.T) {
+	// Failing and unhealthy hosts interleave so their warnings are added
+	// concurrently; run with -race
+	registry, err := docker.NewHostRegistry([]docker.HostConfig{
+		{Name: "failing", URL: fakeDaemon(t, nil)},
+		{Name: "down", URL: "tcp://127.0.0.1:1"},
+		{Name: "prod", URL: fakeDaemon(t, []container.Summary{
+			{ID: "aaaaaaaaaaaa1111", Names: []string{"/web"}, Image: "nginx", State: container.StateRunning},
+		})},
+		{Name: "down2", URL: "tcp://127.0.0.1:2"},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewHostRegistry failed: %v", err)
+	}
+	defer registry.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/containers", NewHostHandler(registry, zap.NewNop()).ListAllContainers)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/containers", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data []ContainerInfo `json:"data"`
+		Meta Meta            `json:"meta"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].Name != "web" || response.Data[0].Host != "prod" {
+		t.Errorf("Expected the healthy host's container, got %+v", response.Data)
+	}
+	warnings := response.Meta.Warnings
+	if len(warnings) != 3 || warnings[0] != "host down is unhealthy" || warnings[1] != "host down2 is unhealthy" ||
+		!strings.HasPrefix(warnings[2], "host failing:") {
+		t.Errorf("Unexpected warnings: %v", warnings)
+	}
+}
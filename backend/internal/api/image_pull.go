@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/jobs"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// ImagePullHandler starts image pulls as background jobs
+type ImagePullHandler struct {
+	dockerClient interface {
+		ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error)
+	}
+	jobs interface {
+		Start(info jobs.Info, fn func(ctx context.Context, job *jobs.Job) error) *jobs.Job
+	}
+	credentials interface {
+		RegistryAuth(imageRef string) (string, error)
+	}
+	host   string
+	logger *zap.Logger
+}
+
+// NewImagePullHandler creates a new image pull handler for a host. Pulls
+// without explicit auth use the stored credentials of the image's registry.
+func NewImagePullHandler(dockerClient interface {
+	ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error)
+}, jobManager interface {
+	Start(info jobs.Info, fn func(ctx context.Context, job *jobs.Job) error) *jobs.Job
+}, credentials interface {
+	RegistryAuth(imageRef string) (string, error)
+}, host string, logger *zap.Logger) *ImagePullHandler {
+	return &ImagePullHandler{
+		dockerClient: dockerClient,
+		jobs:         jobManager,
+		credentials:  credentials,
+		host:         host,
+		logger:       logger,
+	}
+}
+
+// RegistryCredentials authenticate a single pull
+type RegistryCredentials struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identity_token,omitempty"`
+}
+
+// PullImageRequest is the body of POST /api/images/pull
+type PullImageRequest struct {
+	Image    string               `json:"image" binding:"required"`
+	Platform string               `json:"platform,omitempty"`
+	Auth     *RegistryCredentials `json:"auth,omitempty"`
+}
+
+// pullEvent converts pull progress to a job event
+func pullEvent(p docker.PullProgress) jobs.Event {
+	eventType := jobs.EventStatus
+	if p.Total > 0 {
+		eventType = jobs.EventProgress
+	}
+	return jobs.Event{
+		Type:    eventType,
+		Layer:   p.Layer,
+		Status:  p.Status,
+		Current: p.Current,
+		Total:   p.Total,
+	}
+}
+
+// PullImage handles POST /api/images/pull. The pull runs in the background;
+// progress is streamed on /ws/jobs/:id and the job can be cancelled with
+// DELETE /api/jobs/:id.
+func (h *ImagePullHandler) PullImage(c *gin.Context) {
+	var req PullImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	ref, err := docker.NormalizeImageRef(req.Image)
+	if err != nil {
+		BadRequest(c, "Invalid image reference", err.Error())
+		return
+	}
+	c.Set(middleware.AuditTargetKey, ref)
+
+	options := image.PullOptions{Platform: req.Platform}
+	if req.Auth != nil {
+		encoded, err := registry.EncodeAuthConfig(registry.AuthConfig{
+			Username:      req.Auth.Username,
+			Password:      req.Auth.Password,
+			IdentityToken: req.Auth.IdentityToken,
+		})
+		if err != nil {
+			BadRequest(c, "Invalid registry credentials", err.Error())
+			return
+		}
+		options.RegistryAuth = encoded
+	} else {
+		encoded, err := h.credentials.RegistryAuth(ref)
+		if err != nil {
+			InternalServerError(c, "Failed to load registry credentials", err.Error())
+			return
+		}
+		options.RegistryAuth = encoded
+	}
+
+	info := jobs.Info{Kind: "image.pull", Target: ref, Host: h.host}
+	if principal := middleware.GetPrincipal(c); principal != nil {
+		info.User = principal.User
+	}
+
+	job := h.jobs.Start(info, func(ctx context.Context, job *jobs.Job) error {
+		return docker.PullImage(ctx, h.dockerClient, ref, options, func(p docker.PullProgress) {
+			job.Emit(pullEvent(p))
+		})
+	})
+
+	h.logger.Info("Image pull started",
+		zap.String("job_id", job.Info().ID),
+		zap.String("image", ref),
+		zap.String("host", h.host))
+
+	c.JSON(http.StatusAccepted, APIResponse{
+		Success:   true,
+		Data:      job.Info(),
+		Timestamp: time.Now(),
+	})
+}
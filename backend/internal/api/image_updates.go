@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/updates"
+)
+
+// ImageUpdateHandler reports images whose registry tag has moved on
+type ImageUpdateHandler struct {
+	checker interface {
+		Report(host string) updates.Report
+	}
+	host   string
+	logger *zap.Logger
+}
+
+// NewImageUpdateHandler creates a new image update handler for a host
+func NewImageUpdateHandler(checker interface {
+	Report(host string) updates.Report
+}, host string, logger *zap.Logger) *ImageUpdateHandler {
+	return &ImageUpdateHandler{
+		checker: checker,
+		host:    host,
+		logger:  logger,
+	}
+}
+
+// ListUpdates handles GET /api/images/updates?outdated=. It returns the
+// result of the latest background check; outdated=true limits it to images
+// with a newer digest upstream.
+func (h *ImageUpdateHandler) ListUpdates(c *gin.Context) {
+	outdatedOnly, err := strconv.ParseBool(c.DefaultQuery("outdated", "false"))
+	if err != nil {
+		BadRequest(c, "Invalid 'outdated' parameter", err.Error())
+		return
+	}
+
+	report := h.checker.Report(h.host)
+	if outdatedOnly {
+		images := make([]updates.ImageUpdate, 0, report.Outdated)
+		for _, update := range report.Images {
+			if update.Status == updates.StatusOutdated {
+				images = append(images, update)
+			}
+		}
+		report.Images = images
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      report,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total: len(report.Images),
+		},
+	})
+}
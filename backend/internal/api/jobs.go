@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/jobs"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// JobHandler handles background job endpoints
+type JobHandler struct {
+	jobs interface {
+		Get(id string) (*jobs.Job, bool)
+		List() []jobs.Info
+		Cancel(id string) error
+	}
+	logger *zap.Logger
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(jobManager interface {
+	Get(id string) (*jobs.Job, bool)
+	List() []jobs.Info
+	Cancel(id string) error
+}, logger *zap.Logger) *JobHandler {
+	return &JobHandler{
+		jobs:   jobManager,
+		logger: logger,
+	}
+}
+
+// ListJobs handles GET /api/jobs
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	infos := h.jobs.List()
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      infos,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total: len(infos),
+		},
+	})
+}
+
+// GetJob handles GET /api/jobs/:id
+func (h *JobHandler) GetJob(c *gin.Context) {
+	job, ok := h.jobs.Get(c.Param("id"))
+	if !ok {
+		NotFound(c, "Job not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      job.Info(),
+		Timestamp: time.Now(),
+	})
+}
+
+// CancelJob handles DELETE /api/jobs/:id. Only the user who started a job,
+// or an admin, may cancel it.
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	id := c.Param("id")
+	job, ok := h.jobs.Get(id)
+	if !ok {
+		NotFound(c, "Job not found")
+		return
+	}
+	principal := middleware.GetPrincipal(c)
+	if principal != nil && principal.Role != auth.RoleAdmin && job.Info().User != principal.User {
+		Forbidden(c, "Only the user who started a job or an admin may cancel it")
+		return
+	}
+
+	switch err := h.jobs.Cancel(id); {
+	case errors.Is(err, jobs.ErrNotFound):
+		NotFound(c, "Job not found")
+		return
+	case errors.Is(err, jobs.ErrFinished):
+		ErrorResponse(c, http.StatusConflict, "Job already finished")
+		return
+	case err != nil:
+		InternalServerError(c, "Failed to cancel job", err.Error())
+		return
+	}
+
+	h.logger.Info("Job cancelled", zap.String("job_id", id))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Job cancellation requested"},
+		Timestamp: time.Now(),
+	})
+}
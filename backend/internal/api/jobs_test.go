@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/jobs"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+func TestJobHandler_CancelJobChecksOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager := jobs.NewManager(context.Background(), time.Minute, zap.NewNop())
+	job := manager.Start(jobs.Info{Kind: "image.pull", Target: "nginx", User: "alice"}, func(ctx context.Context, job *jobs.Job) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	handler := NewJobHandler(manager, zap.NewNop())
+
+	cancel := func(principal *auth.Principal) int {
+		router := gin.New()
+		router.Use(func(c *gin.Context) { c.Set(middleware.PrincipalKey, principal) })
+		router.DELETE("/jobs/:id", handler.CancelJob)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/jobs/"+job.Info().ID, nil))
+		return w.Code
+	}
+
+	bob, _ := auth.NewPrincipal("bob", auth.RoleOperator, "t", nil)
+	if status := cancel(bob); status != http.StatusForbidden {
+		t.Fatalf("Expected another operator to be denied, got %d", status)
+	}
+	alice, _ := auth.NewPrincipal("alice", auth.RoleOperator, "t", nil)
+	if status := cancel(alice); status != http.StatusOK {
+		t.Fatalf("Expected the owner to cancel the job, got %d", status)
+	}
+	select {
+	case <-job.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the job to be cancelled")
+	}
+	if status := cancel(auth.Anonymous); status != http.StatusConflict {
+		t.Errorf("Expected an admin to reach the finished job, got %d", status)
+	}
+}
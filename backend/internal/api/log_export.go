@@ -0,0 +1,359 @@
+package api
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/logparse"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// logExportBufferSize is the write buffer between Docker and the response
+const logExportBufferSize = 32 * 1024
+
+// LogExportHandler streams container logs as downloadable files
+type LogExportHandler struct {
+	dockerClient interface {
+		ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+		ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+		ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+	}
+	logger *zap.Logger
+}
+
+// NewLogExportHandler creates a new log export handler
+func NewLogExportHandler(dockerClient interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+}, logger *zap.Logger) *LogExportHandler {
+	return &LogExportHandler{
+		dockerClient: dockerClient,
+		logger:       logger,
+	}
+}
+
+// LogRecord is a line of an NDJSON log export
+type LogRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Stream    string    `json:"stream"`
+	Line      string    `json:"line"`
+}
+
+// logExport is a parsed export request
+type logExport struct {
+	options container.LogsOptions
+	ndjson  bool
+	gzip    bool
+}
+
+// extension is the file extension of an exported log
+func (e logExport) extension() string {
+	if e.ndjson {
+		return ".ndjson"
+	}
+	return ".log"
+}
+
+// parseLogExport reads the since, until, tail, stream, format and gzip
+// query parameters
+func parseLogExport(c *gin.Context) (logExport, bool) {
+	export := logExport{options: container.LogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true}}
+
+	since, err := parseTimeParam(c.Query("since"), time.Time{})
+	if err != nil {
+		BadRequest(c, "Invalid 'since' parameter", err.Error())
+		return export, false
+	}
+	until, err := parseTimeParam(c.Query("until"), time.Time{})
+	if err != nil {
+		BadRequest(c, "Invalid 'until' parameter", err.Error())
+		return export, false
+	}
+	if !since.IsZero() && !until.IsZero() && !until.After(since) {
+		BadRequest(c, "'until' must be after 'since'")
+		return export, false
+	}
+	if !since.IsZero() {
+		export.options.Since = dockerLogTime(since)
+	}
+	if !until.IsZero() {
+		export.options.Until = dockerLogTime(until)
+	}
+
+	export.options.Tail = c.DefaultQuery("tail", "all")
+	if export.options.Tail != "all" {
+		if n, err := strconv.Atoi(export.options.Tail); err != nil || n < 0 {
+			BadRequest(c, "Invalid 'tail' parameter", "must be a number of lines or 'all'")
+			return export, false
+		}
+	}
+
+	switch c.DefaultQuery("stream", "all") {
+	case "all":
+	case "stdout":
+		export.options.ShowStderr = false
+	case "stderr":
+		export.options.ShowStdout = false
+	default:
+		BadRequest(c, "Invalid 'stream' parameter", "must be stdout, stderr or all")
+		return export, false
+	}
+
+	switch c.DefaultQuery("format", "text") {
+	case "text":
+	case "ndjson":
+		export.ndjson = true
+	default:
+		BadRequest(c, "Invalid 'format' parameter", "must be text or ndjson")
+		return export, false
+	}
+	export.gzip = c.Query("gzip") == "true"
+	return export, true
+}
+
+// ExportContainerLogs handles GET /api/containers/:id/logs/export. The log is
+// streamed as it is read from Docker, as text lines prefixed with their
+// timestamp or as NDJSON records, optionally gzipped.
+func (h *LogExportHandler) ExportContainerLogs(c *gin.Context) {
+	containerID, ok := validContainerParam(c)
+	if !ok {
+		return
+	}
+	export, ok := parseLogExport(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	inspect, err := h.dockerClient.ContainerInspect(ctx, containerID)
+	if err != nil {
+		dockerError(c, "Failed to get container", err)
+		return
+	}
+	reader, err := h.dockerClient.ContainerLogs(ctx, containerID, export.options)
+	if err != nil {
+		h.logger.Error("Failed to get container logs", zap.String("container_id", containerID), zap.Error(err))
+		dockerError(c, "Failed to get container logs", err)
+		return
+	}
+	defer reader.Close()
+
+	filename := strings.TrimPrefix(inspect.Name, "/") + export.extension()
+	if export.gzip {
+		filename += ".gz"
+		c.Header("Content-Type", "application/gzip")
+	} else if export.ndjson {
+		c.Header("Content-Type", "application/x-ndjson")
+	} else {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	clearWriteDeadline(c)
+	c.Status(http.StatusOK)
+
+	var out io.Writer = c.Writer
+	var compressor *gzip.Writer
+	if export.gzip {
+		compressor = gzip.NewWriter(c.Writer)
+		out = compressor
+	}
+	buffered := bufio.NewWriterSize(out, logExportBufferSize)
+
+	err = writeLogExport(buffered, reader, isTTY(inspect), export.ndjson)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err == nil && compressor != nil {
+		err = compressor.Close()
+	}
+	if err != nil {
+		// Headers are already sent; the export is truncated
+		h.logger.Error("Failed to export container logs", zap.String("container_id", containerID), zap.Error(err))
+	}
+}
+
+// ExportStackLogs handles GET /api/stacks/:name/logs/export and streams a
+// tar.gz with one file per container of the stack. Each log is spooled to a
+// temporary file first since tar headers need its size. Users whose
+// logs:read is restricted by a label selector only get matching containers.
+func (h *LogExportHandler) ExportStackLogs(c *gin.Context) {
+	name := c.Param("name")
+	if !resourceNamePattern.MatchString(name) {
+		BadRequest(c, "Invalid stack name", name)
+		return
+	}
+	export, ok := parseLogExport(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	containers, err := h.dockerClient.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", composeProjectLabel+"="+name)),
+	})
+	if err != nil {
+		h.logger.Error("Failed to list stack containers", zap.String("stack", name), zap.Error(err))
+		dockerError(c, "Failed to get stack", err)
+		return
+	}
+	if len(containers) == 0 {
+		NotFound(c, "Stack not found")
+		return
+	}
+
+	principal := middleware.GetPrincipal(c)
+	permitted := containers[:0]
+	for _, ctr := range containers {
+		if principal == nil || principal.CanOn(auth.PermLogsRead, ctr.Labels) {
+			permitted = append(permitted, ctr)
+		}
+	}
+	if len(permitted) == 0 {
+		Forbidden(c, "Not allowed to read the logs of this stack")
+		return
+	}
+	sort.Slice(permitted, func(i, j int) bool { return containerName(&permitted[i]) < containerName(&permitted[j]) })
+
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"-logs.tar.gz"))
+	clearWriteDeadline(c)
+	c.Status(http.StatusOK)
+
+	compressor := gzip.NewWriter(c.Writer)
+	archive := tar.NewWriter(compressor)
+	for i := range permitted {
+		if err = h.archiveContainerLogs(ctx, archive, &permitted[i], export); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err == nil {
+		err = compressor.Close()
+	}
+	if err != nil {
+		// Headers are already sent; the archive is truncated
+		h.logger.Error("Failed to export stack logs", zap.String("stack", name), zap.Error(err))
+	}
+}
+
+// archiveContainerLogs adds a container's log to the archive. A log that
+// cannot be read is replaced by a file holding the error so the rest of the
+// stack is still exported; only archive write errors are returned.
+func (h *LogExportHandler) archiveContainerLogs(ctx context.Context, archive *tar.Writer, ctr *container.Summary, export logExport) error {
+	name := containerName(ctr)
+
+	spool, err := os.CreateTemp("", "kubevision-logs-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	if err := h.spoolContainerLogs(ctx, spool, ctr.ID, export); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		h.logger.Warn("Failed to export container logs", zap.String("container_id", ctr.ID), zap.Error(err))
+		message := []byte(err.Error() + "\n")
+		if err := archive.WriteHeader(logArchiveHeader(name+".error.txt", int64(len(message)))); err != nil {
+			return err
+		}
+		_, err = archive.Write(message)
+		return err
+	}
+
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := archive.WriteHeader(logArchiveHeader(name+export.extension(), size)); err != nil {
+		return err
+	}
+	_, err = io.Copy(archive, spool)
+	return err
+}
+
+// spoolContainerLogs writes a container's log to w
+func (h *LogExportHandler) spoolContainerLogs(ctx context.Context, w io.Writer, containerID string, export logExport) error {
+	inspect, err := h.dockerClient.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	reader, err := h.dockerClient.ContainerLogs(ctx, containerID, export.options)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	buffered := bufio.NewWriterSize(w, logExportBufferSize)
+	if err := writeLogExport(buffered, reader, isTTY(inspect), export.ndjson); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// logArchiveHeader is the tar header of a file in a log archive
+func logArchiveHeader(name string, size int64) *tar.Header {
+	return &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+}
+
+// writeLogExport copies a Docker log stream line by line, as text lines
+// keeping Docker's timestamp prefix or as NDJSON records
+func writeLogExport(w io.Writer, r io.Reader, tty bool, ndjson bool) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	return logparse.ReadLines(r, tty, func(stream string, line []byte) error {
+		line = bytes.TrimRight(line, "\r\n")
+		if !ndjson {
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+			_, err := w.Write([]byte{'\n'})
+			return err
+		}
+
+		record := LogRecord{Stream: stream, Line: string(line)}
+		if timestamp, text, ok := logparse.SplitTimestamp(line); ok {
+			record.Timestamp, record.Line = timestamp, string(text)
+		}
+		return encoder.Encode(record)
+	})
+}
+
+// isTTY reports whether a container's output is raw rather than multiplexed
+func isTTY(inspect container.InspectResponse) bool {
+	return inspect.Config != nil && inspect.Config.Tty
+}
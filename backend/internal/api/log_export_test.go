@@ -0,0 +1,188 @@
+package api
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+func newLogExportRouter(client *mockLogClient, principal *auth.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewLogExportHandler(client, zap.NewNop())
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set(middleware.PrincipalKey, principal) })
+	router.GET("/containers/:id/logs/export", handler.ExportContainerLogs)
+	router.GET("/stacks/:name/logs/export", handler.ExportStackLogs)
+	return router
+}
+
+func exportLogs(t *testing.T, router *gin.Engine, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	return w
+}
+
+func TestLogExportHandler_ExportContainerLogs(t *testing.T) {
+	client := newMockLogClient()
+	router := newLogExportRouter(client, auth.Anonymous)
+
+	w := exportLogs(t, router, "/containers/aaaaaaaaaaaa1111/logs/export")
+	want := "2025-03-01T12:00:01Z GET /health 200\n" +
+		"2025-03-01T12:00:02Z ERROR database timeout\n" +
+		"2025-03-01T12:00:04Z error: retrying\n" +
+		"2025-03-01T12:00:04Z ERROR twice in one second\n"
+	if w.Body.String() != want {
+		t.Errorf("Unexpected export:\n%s", w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "text/plain; charset=utf-8" ||
+		w.Header().Get("Content-Disposition") != `attachment; filename="api.log"` {
+		t.Errorf("Unexpected headers: %v", w.Header())
+	}
+
+	// NDJSON of one stream
+	w = exportLogs(t, router, "/containers/aaaaaaaaaaaa1111/logs/export?format=ndjson&stream=stderr")
+	var records []LogRecord
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var record LogRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 1 || records[0].Stream != "stderr" || records[0].Line != "ERROR database timeout" ||
+		!records[0].Timestamp.Equal(logBase.Add(2*time.Second)) {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+
+	// Gzipped, with the range and tail passed to Docker
+	until := logBase.Add(3 * time.Second).Format(time.RFC3339)
+	w = exportLogs(t, router, "/containers/aaaaaaaaaaaa1111/logs/export?gzip=true&tail=10&until="+until)
+	if w.Header().Get("Content-Disposition") != `attachment; filename="api.log.gz"` {
+		t.Errorf("Unexpected headers: %v", w.Header())
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Invalid gzip: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	if string(data) != want[:strings.Index(want, "2025-03-01T12:00:04Z")] {
+		t.Errorf("Unexpected gzipped export:\n%s", data)
+	}
+	options := client.options["aaaaaaaaaaaa1111"]
+	if options.Tail != "10" || options.Until == "" || !options.Timestamps || options.Follow {
+		t.Errorf("Unexpected log options: %+v", options)
+	}
+
+	// TTY output is exported as stdout
+	w = exportLogs(t, router, "/containers/cccccccccccc3333/logs/export?format=ndjson")
+	if !strings.Contains(w.Body.String(), `"stream":"stdout","line":"ERROR on a tty"`) {
+		t.Errorf("Unexpected TTY export: %s", w.Body.String())
+	}
+}
+
+func TestLogExportHandler_Errors(t *testing.T) {
+	router := newLogExportRouter(newMockLogClient(), auth.Anonymous)
+
+	for path, status := range map[string]int{
+		"/containers/aaaaaaaaaaaa1111/logs/export?tail=-1":        http.StatusBadRequest,
+		"/containers/aaaaaaaaaaaa1111/logs/export?format=csv":     http.StatusBadRequest,
+		"/containers/aaaaaaaaaaaa1111/logs/export?stream=both":    http.StatusBadRequest,
+		"/containers/aaaaaaaaaaaa1111/logs/export?since=tomorrow": http.StatusBadRequest,
+		"/containers/dddddddddddd4444/logs/export":                http.StatusNotFound,
+		"/stacks/missing/logs/export":                             http.StatusNotFound,
+		"/stacks/-bad/logs/export":                                http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != status {
+			t.Errorf("%s: expected status %d, got %d", path, status, w.Code)
+		}
+	}
+}
+
+// readLogArchive returns the files of a tar.gz
+func readLogArchive(t *testing.T, body io.Reader) map[string]string {
+	t.Helper()
+	compressed, err := gzip.NewReader(body)
+	if err != nil {
+		t.Fatalf("Invalid gzip: %v", err)
+	}
+	files := make(map[string]string)
+	archive := tar.NewReader(compressed)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("Invalid tar: %v", err)
+		}
+		data, _ := io.ReadAll(archive)
+		files[header.Name] = string(data)
+	}
+}
+
+func TestLogExportHandler_ExportStackLogs(t *testing.T) {
+	client := newMockLogClient()
+	router := newLogExportRouter(client, auth.Anonymous)
+
+	w := exportLogs(t, router, "/stacks/shop/logs/export?stream=stderr")
+	if w.Header().Get("Content-Disposition") != `attachment; filename="shop-logs.tar.gz"` {
+		t.Errorf("Unexpected headers: %v", w.Header())
+	}
+	files := readLogArchive(t, w.Body)
+	if len(files) != 2 || files["api.log"] != "2025-03-01T12:00:02Z ERROR database timeout\n" ||
+		files["worker.log"] != "2025-03-01T12:00:03Z ERROR job failed\n" {
+		t.Errorf("Unexpected archive: %v", files)
+	}
+
+	// A container whose logs cannot be read is replaced by its error
+	delete(client.logs, "bbbbbbbbbbbb2222")
+	files = readLogArchive(t, exportLogs(t, router, "/stacks/shop/logs/export?format=ndjson").Body)
+	if _, ok := files["api.ndjson"]; !ok || files["worker.error.txt"] == "" {
+		t.Errorf("Expected api.ndjson and worker.error.txt, got %v", files)
+	}
+
+	// Restricted principals only get the containers they may read
+	operator, _ := auth.NewPrincipal("ops", auth.RoleOperator, "t", map[auth.Permission]string{
+		auth.PermLogsRead: "team=payments",
+	})
+	files = readLogArchive(t, exportLogs(t, newLogExportRouter(client, operator), "/stacks/shop/logs/export").Body)
+	if len(files) != 1 || files["api.log"] == "" {
+		t.Errorf("Expected only api.log, got %v", files)
+	}
+
+	// Without a principal every container is exported
+	files = readLogArchive(t, exportLogs(t, newLogExportRouter(client, nil), "/stacks/shop/logs/export").Body)
+	if len(files) != 2 || files["api.log"] == "" {
+		t.Errorf("Expected every container without a principal, got %v", files)
+	}
+
+	denied, _ := auth.NewPrincipal("ops", auth.RoleOperator, "t", map[auth.Permission]string{
+		auth.PermLogsRead: "team=search",
+	})
+	w = httptest.NewRecorder()
+	newLogExportRouter(client, denied).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stacks/shop/logs/export", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
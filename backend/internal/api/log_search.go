@@ -0,0 +1,434 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/logparse"
+	"github.com/kubevision/kubevision/internal/middleware"
+	"github.com/kubevision/kubevision/internal/utils"
+)
+
+const (
+	// defaultLogSearchLimit is the number of matches returned when limit is not specified
+	defaultLogSearchLimit = 100
+
+	// maxLogSearchLimit caps the matches returned by a single page
+	maxLogSearchLimit = 1000
+
+	// maxLogSearchContainers bounds the containers scanned by one search
+	maxLogSearchContainers = 50
+
+	// logSearchConcurrency is the number of containers scanned at once
+	logSearchConcurrency = 4
+
+	// logSearchTimeout bounds a whole search
+	logSearchTimeout = 30 * time.Second
+
+	// maxLogPatternLength bounds the q parameter
+	maxLogPatternLength = 1024
+)
+
+// errSearchDone stops scanning a container once it has enough matches
+var errSearchDone = errors.New("search done")
+
+// LogSearchHandler searches container logs server-side
+type LogSearchHandler struct {
+	dockerClient interface {
+		ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+		ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+		ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+	}
+	host   string
+	logger *zap.Logger
+}
+
+// NewLogSearchHandler creates a new log search handler for a single host
+func NewLogSearchHandler(dockerClient interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+}, host string, logger *zap.Logger) *LogSearchHandler {
+	return &LogSearchHandler{
+		dockerClient: dockerClient,
+		host:         host,
+		logger:       logger,
+	}
+}
+
+// LogMatch is a log line matching a search
+type LogMatch struct {
+	ContainerID string    `json:"container_id"`
+	Container   string    `json:"container"`
+	Stream      string    `json:"stream"`
+	Timestamp   time.Time `json:"timestamp"`
+	Line        string    `json:"line"`
+
+	// seq orders lines of a container with the same timestamp
+	seq int
+}
+
+// LogSearchResult is a page of matches, oldest first. NextCursor is set
+// when there are more matches.
+type LogSearchResult struct {
+	Matches    []LogMatch `json:"matches"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	// Containers is the number of containers searched
+	Containers int `json:"containers"`
+}
+
+// logCursor is the position of the last match of a page
+type logCursor struct {
+	Time      int64  `json:"t"`
+	Container string `json:"c"`
+	Seq       int    `json:"n"`
+}
+
+func (cur logCursor) encode() string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeLogCursor(s string) (*logCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cur logCursor
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return nil, err
+	}
+	return &cur, nil
+}
+
+// before orders matches by time, then container ID, then position
+func (m LogMatch) before(cur logCursor) bool {
+	t := m.Timestamp.UnixNano()
+	if t != cur.Time {
+		return t < cur.Time
+	}
+	if m.ContainerID != cur.Container {
+		return m.ContainerID < cur.Container
+	}
+	return m.seq < cur.Seq
+}
+
+// after reports whether a match comes after the cursor
+func (m LogMatch) after(cur logCursor) bool {
+	return !m.before(cur) && m.cursor() != cur
+}
+
+func (m LogMatch) cursor() logCursor {
+	return logCursor{Time: m.Timestamp.UnixNano(), Container: m.ContainerID, Seq: m.seq}
+}
+
+// logQuery is a parsed search request
+type logQuery struct {
+	pattern *regexp.Regexp
+	stdout  bool
+	stderr  bool
+	since   time.Time
+	until   time.Time
+	limit   int
+	cursor  *logCursor
+}
+
+// parseLogQuery reads the q, regex, ignore_case, stream, since, until,
+// limit and cursor query parameters
+func parseLogQuery(c *gin.Context) (logQuery, bool) {
+	query := logQuery{stdout: true, stderr: true, limit: defaultLogSearchLimit}
+
+	q := c.Query("q")
+	if q == "" || len(q) > maxLogPatternLength {
+		BadRequest(c, "Invalid 'q' parameter", fmt.Sprintf("must be 1 to %d characters", maxLogPatternLength))
+		return query, false
+	}
+	if c.Query("regex") != "true" {
+		q = regexp.QuoteMeta(q)
+	}
+	if c.Query("ignore_case") == "true" {
+		q = "(?i)" + q
+	}
+	pattern, err := regexp.Compile(q)
+	if err != nil {
+		BadRequest(c, "Invalid regular expression", err.Error())
+		return query, false
+	}
+	query.pattern = pattern
+
+	switch c.DefaultQuery("stream", "all") {
+	case "all":
+	case "stdout":
+		query.stderr = false
+	case "stderr":
+		query.stdout = false
+	default:
+		BadRequest(c, "Invalid 'stream' parameter", "must be stdout, stderr or all")
+		return query, false
+	}
+
+	if query.since, err = parseTimeParam(c.Query("since"), time.Time{}); err != nil {
+		BadRequest(c, "Invalid 'since' parameter", err.Error())
+		return query, false
+	}
+	if query.until, err = parseTimeParam(c.Query("until"), time.Time{}); err != nil {
+		BadRequest(c, "Invalid 'until' parameter", err.Error())
+		return query, false
+	}
+	if !query.since.IsZero() && !query.until.IsZero() && !query.until.After(query.since) {
+		BadRequest(c, "'until' must be after 'since'")
+		return query, false
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxLogSearchLimit {
+			BadRequest(c, "Invalid 'limit' parameter", fmt.Sprintf("must be between 1 and %d", maxLogSearchLimit))
+			return query, false
+		}
+		query.limit = limit
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		if query.cursor, err = decodeLogCursor(raw); err != nil {
+			BadRequest(c, "Invalid 'cursor' parameter")
+			return query, false
+		}
+	}
+	return query, true
+}
+
+// dockerLogTime formats a time for the since and until log options
+func dockerLogTime(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// selectContainers picks the containers to search from the containers (IDs,
+// ID prefixes or names), selector and stack parameters, which all have to
+// match. Containers the
+// principal may not read logs of are left out.
+func (h *LogSearchHandler) selectContainers(c *gin.Context, containers []container.Summary) ([]container.Summary, bool) {
+	selected := containers
+
+	if raw := c.Query("containers"); raw != "" {
+		var picked []container.Summary
+		for _, ref := range strings.Split(raw, ",") {
+			ref = strings.TrimSpace(ref)
+			if !utils.ValidateContainerID(ref) && !containerNamePattern.MatchString(ref) {
+				BadRequest(c, "Invalid container", ref)
+				return nil, false
+			}
+			found := false
+			for _, ctr := range containers {
+				if ctr.ID == ref || (len(ref) >= 12 && strings.HasPrefix(ctr.ID, ref)) || containerName(&ctr) == ref {
+					picked = append(picked, ctr)
+					found = true
+					break
+				}
+			}
+			if !found {
+				NotFound(c, "Container not found: "+ref)
+				return nil, false
+			}
+		}
+		selected = picked
+	}
+
+	filters := utils.LabelSelector{}
+	if raw := c.Query("selector"); raw != "" {
+		selector, err := utils.ParseLabelSelector(raw)
+		if err != nil {
+			BadRequest(c, "Invalid 'selector' parameter", err.Error())
+			return nil, false
+		}
+		filters = append(filters, selector...)
+	}
+	if stack := c.Query("stack"); stack != "" {
+		filters = append(filters, utils.LabelRequirement{Key: composeProjectLabel, Operator: "=", Value: stack})
+	}
+
+	principal := middleware.GetPrincipal(c)
+	out := make([]container.Summary, 0, len(selected))
+	seen := make(map[string]bool, len(selected))
+	for _, ctr := range selected {
+		if seen[ctr.ID] || !filters.Matches(ctr.Labels) {
+			continue
+		}
+		if principal != nil && !principal.CanOn(auth.PermLogsRead, ctr.Labels) {
+			continue
+		}
+		seen[ctr.ID] = true
+		out = append(out, ctr)
+	}
+	if len(out) > maxLogSearchContainers {
+		BadRequest(c, "Too many containers to search",
+			fmt.Sprintf("%d containers match; narrow the search to at most %d with containers, selector or stack", len(out), maxLogSearchContainers))
+		return nil, false
+	}
+	return out, true
+}
+
+// SearchLogs handles GET /api/logs/search?q=&regex=&ignore_case=
+// &containers=&selector=&stack=&stream=&since=&until=&limit=&cursor=.
+// Matches are returned oldest first; pass next_cursor as cursor for the
+// next page.
+func (h *LogSearchHandler) SearchLogs(c *gin.Context) {
+	query, ok := parseLogQuery(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), logSearchTimeout)
+	defer cancel()
+	extendWriteDeadline(c, logSearchTimeout)
+
+	containers, err := h.dockerClient.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		h.logger.Error("Failed to list containers", zap.Error(err))
+		dockerError(c, "Failed to list containers", err)
+		return
+	}
+	selected, ok := h.selectContainers(c, containers)
+	if !ok {
+		return
+	}
+
+	// Scan containers with bounded concurrency
+	type scanResult struct {
+		matches []LogMatch
+		err     error
+	}
+	results := make([]scanResult, len(selected))
+	sem := make(chan struct{}, logSearchConcurrency)
+	var wg sync.WaitGroup
+	for i := range selected {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i].matches, results[i].err = h.scanContainer(ctx, selected[i], query)
+		}(i)
+	}
+	wg.Wait()
+
+	var matches []LogMatch
+	var warnings []string
+	for i, result := range results {
+		if result.err != nil {
+			h.logger.Warn("Failed to search container logs",
+				zap.String("container_id", selected[i].ID),
+				zap.Error(result.err))
+			warnings = append(warnings, fmt.Sprintf("container %s: %v", containerName(&selected[i]), result.err))
+			continue
+		}
+		matches = append(matches, result.matches...)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].before(matches[j].cursor()) })
+
+	result := LogSearchResult{Matches: []LogMatch{}, Containers: len(selected)}
+	if len(matches) > query.limit {
+		matches = matches[:query.limit]
+		result.NextCursor = matches[len(matches)-1].cursor().encode()
+	}
+	result.Matches = append(result.Matches, matches...)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      result,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total:    len(result.Matches),
+			Warnings: warnings,
+		},
+	})
+}
+
+// scanContainer returns up to limit+1 matches of a container after the
+// cursor, so the caller knows whether there is another page
+func (h *LogSearchHandler) scanContainer(ctx context.Context, ctr container.Summary, query logQuery) ([]LogMatch, error) {
+	inspect, err := h.dockerClient.ContainerInspect(ctx, ctr.ID)
+	if err != nil {
+		return nil, err
+	}
+	tty := isTTY(inspect)
+	if tty && !query.stdout {
+		// TTY output has no separate stderr
+		return nil, nil
+	}
+
+	options := container.LogsOptions{
+		ShowStdout: query.stdout,
+		ShowStderr: query.stderr,
+		Timestamps: true,
+	}
+	since := query.since
+	if query.cursor != nil {
+		if cursorTime := time.Unix(0, query.cursor.Time); cursorTime.After(since) {
+			since = cursorTime
+		}
+	}
+	if !since.IsZero() {
+		options.Since = dockerLogTime(since)
+	}
+	if !query.until.IsZero() {
+		options.Until = dockerLogTime(query.until)
+	}
+
+	reader, err := h.dockerClient.ContainerLogs(ctx, ctr.ID, options)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	name := containerName(&ctr)
+	var matches []LogMatch
+	var lastTime int64
+	seq := 0
+	onLine := func(stream string, line []byte) error {
+		timestamp, text, ok := logparse.SplitTimestamp(line)
+		if !ok {
+			return nil
+		}
+		if t := timestamp.UnixNano(); t == lastTime {
+			seq++
+		} else {
+			lastTime, seq = t, 0
+		}
+
+		match := LogMatch{ContainerID: ctr.ID, Container: name, Stream: stream, Timestamp: timestamp, seq: seq}
+		if query.cursor != nil && !match.after(*query.cursor) {
+			return nil
+		}
+		if !query.pattern.Match(text) {
+			return nil
+		}
+		match.Line = string(text)
+		matches = append(matches, match)
+		if len(matches) > query.limit {
+			return errSearchDone
+		}
+		return nil
+	}
+
+	if err := logparse.ReadLines(reader, tty, onLine); err != nil && !errors.Is(err, errSearchDone) {
+		return nil, err
+	}
+	return matches, nil
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// logEntry is a line a mock container logged
+type logEntry struct {
+	stream string
+	at     time.Time
+	text   string
+}
+
+// mockLogClient serves logs like Docker: multiplexed unless the container
+// has a TTY, filtered by stream and since
+type mockLogClient struct {
+	containers []container.Summary
+	tty        map[string]bool
+	logs       map[string][]logEntry
+
+	mu      sync.Mutex
+	options map[string]container.LogsOptions
+}
+
+func (m *mockLogClient) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	var result []container.Summary
+	for _, ctr := range m.containers {
+		matches := true
+		for _, label := range options.Filters.Get("label") {
+			key, value, _ := strings.Cut(label, "=")
+			matches = matches && ctr.Labels[key] == value
+		}
+		if matches {
+			result = append(result, ctr)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockLogClient) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	for _, ctr := range m.containers {
+		if ctr.ID == containerID {
+			return container.InspectResponse{
+				ContainerJSONBase: &container.ContainerJSONBase{ID: ctr.ID, Name: ctr.Names[0]},
+				Config:            &container.Config{Tty: m.tty[containerID]},
+			}, nil
+		}
+	}
+	return container.InspectResponse{}, cerrdefs.ErrNotFound
+}
+
+// parseLogTime parses the seconds.nanoseconds timestamps of log options
+func parseLogTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	sec, nsec, _ := strings.Cut(value, ".")
+	s, _ := strconv.ParseInt(sec, 10, 64)
+	n, _ := strconv.ParseInt(nsec, 10, 64)
+	return time.Unix(s, n)
+}
+
+func (m *mockLogClient) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+	m.mu.Lock()
+	if m.options == nil {
+		m.options = make(map[string]container.LogsOptions)
+	}
+	m.options[containerID] = options
+	m.mu.Unlock()
+
+	entries, ok := m.logs[containerID]
+	if !ok {
+		return nil, cerrdefs.ErrNotImplemented
+	}
+	since, until := parseLogTime(options.Since), parseLogTime(options.Until)
+	if tail, err := strconv.Atoi(options.Tail); err == nil && tail < len(entries) {
+		entries = entries[len(entries)-tail:]
+	}
+
+	var buf bytes.Buffer
+	stdout, stderr := stdcopy.NewStdWriter(&buf, stdcopy.Stdout), stdcopy.NewStdWriter(&buf, stdcopy.Stderr)
+	for _, entry := range entries {
+		if entry.at.Before(since) || (!until.IsZero() && entry.at.After(until)) {
+			continue
+		}
+		line := entry.at.UTC().Format(time.RFC3339Nano) + " " + entry.text + "\n"
+		switch {
+		case m.tty[containerID]:
+			buf.WriteString(line)
+		case entry.stream == "stdout" && options.ShowStdout:
+			_, _ = stdout.Write([]byte(line))
+		case entry.stream == "stderr" && options.ShowStderr:
+			_, _ = stderr.Write([]byte(line))
+		}
+	}
+	return io.NopCloser(&buf), nil
+}
+
+var logBase = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newMockLogClient() *mockLogClient {
+	at := func(seconds int) time.Time { return logBase.Add(time.Duration(seconds) * time.Second) }
+	return &mockLogClient{
+		containers: []container.Summary{
+			{ID: "aaaaaaaaaaaa1111", Names: []string{"/api"}, Labels: map[string]string{composeProjectLabel: "shop", "team": "payments"}},
+			{ID: "bbbbbbbbbbbb2222", Names: []string{"/worker"}, Labels: map[string]string{composeProjectLabel: "shop"}},
+			{ID: "cccccccccccc3333", Names: []string{"/console"}, Labels: map[string]string{}},
+		},
+		tty: map[string]bool{"cccccccccccc3333": true},
+		logs: map[string][]logEntry{
+			"aaaaaaaaaaaa1111": {
+				{"stdout", at(1), "GET /health 200"},
+				{"stderr", at(2), "ERROR database timeout"},
+				{"stdout", at(4), "error: retrying"},
+				{"stdout", at(4), "ERROR twice in one second"},
+			},
+			"bbbbbbbbbbbb2222": {
+				{"stderr", at(3), "ERROR job failed"},
+				{"stdout", at(5), "job done"},
+			},
+			"cccccccccccc3333": {
+				{"stdout", at(6), "ERROR on a tty"},
+			},
+		},
+	}
+}
+
+func newLogSearchRouter(client *mockLogClient, principal *auth.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewLogSearchHandler(client, "local", zap.NewNop())
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set(middleware.PrincipalKey, principal) })
+	router.GET("/logs/search", handler.SearchLogs)
+	return router
+}
+
+func searchLogs(t *testing.T, router *gin.Engine, query string) (LogSearchResult, []string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logs/search?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data LogSearchResult `json:"data"`
+		Meta Meta            `json:"meta"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp.Data, resp.Meta.Warnings
+}
+
+func matchLines(matches []LogMatch) string {
+	lines := make([]string, 0, len(matches))
+	for _, m := range matches {
+		lines = append(lines, fmt.Sprintf("%s/%s:%s", m.Container, m.Stream, m.Line))
+	}
+	return strings.Join(lines, " | ")
+}
+
+func TestLogSearchHandler_Search(t *testing.T) {
+	router := newLogSearchRouter(newMockLogClient(), auth.Anonymous)
+
+	result, _ := searchLogs(t, router, "q=ERROR")
+	want := "api/stderr:ERROR database timeout | worker/stderr:ERROR job failed | api/stdout:ERROR twice in one second | console/stdout:ERROR on a tty"
+	if got := matchLines(result.Matches); got != want || result.Containers != 3 || result.NextCursor != "" {
+		t.Errorf("Unexpected matches:\n got %s\nwant %s", got, want)
+	}
+	if !result.Matches[0].Timestamp.Equal(logBase.Add(2 * time.Second)) {
+		t.Errorf("Unexpected timestamp: %v", result.Matches[0].Timestamp)
+	}
+
+	result, _ = searchLogs(t, router, "q=error&ignore_case=true&stack=shop&stream=stdout")
+	if got := matchLines(result.Matches); got != "api/stdout:error: retrying | api/stdout:ERROR twice in one second" {
+		t.Errorf("Unexpected case-insensitive stdout matches: %s", got)
+	}
+
+	result, _ = searchLogs(t, router, "q="+url.QueryEscape("^ERROR (job|database)")+"&regex=true&containers=worker,aaaaaaaaaaaa")
+	if len(result.Matches) != 2 || result.Containers != 2 {
+		t.Errorf("Unexpected regex matches: %s", matchLines(result.Matches))
+	}
+
+	result, _ = searchLogs(t, router, "q=ERROR&selector=team=payments&since="+strconv.FormatInt(logBase.Add(3*time.Second).Unix(), 10))
+	if got := matchLines(result.Matches); got != "api/stdout:ERROR twice in one second" {
+		t.Errorf("Unexpected matches since: %s", got)
+	}
+}
+
+func TestLogSearchHandler_Pagination(t *testing.T) {
+	router := newLogSearchRouter(newMockLogClient(), auth.Anonymous)
+
+	var pages []string
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		result, _ := searchLogs(t, router, "q=e&limit=2&cursor="+cursor)
+		pages = append(pages, matchLines(result.Matches))
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+	}
+	want := []string{
+		"api/stdout:GET /health 200 | api/stderr:ERROR database timeout",
+		"worker/stderr:ERROR job failed | api/stdout:error: retrying",
+		"api/stdout:ERROR twice in one second | worker/stdout:job done",
+	}
+	if strings.Join(pages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected pages:\n%s", strings.Join(pages, "\n"))
+	}
+}
+
+func TestLogSearchHandler_Errors(t *testing.T) {
+	client := newMockLogClient()
+	router := newLogSearchRouter(client, auth.Anonymous)
+
+	for query, status := range map[string]int{
+		"":                  http.StatusBadRequest,
+		"q=(&regex=true":    http.StatusBadRequest,
+		"q=x&stream=both":   http.StatusBadRequest,
+		"q=x&limit=0":       http.StatusBadRequest,
+		"q=x&cursor=%21%21": http.StatusBadRequest,
+		"q=x&since=2025-03-02T00:00:00Z&until=2025-03-01T00:00:00Z": http.StatusBadRequest,
+		"q=x&containers=missing":                                    http.StatusNotFound,
+		"q=x&containers=../etc":                                     http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logs/search?"+query, nil))
+		if w.Code != status {
+			t.Errorf("%q: expected status %d, got %d", query, status, w.Code)
+		}
+	}
+
+	// Containers whose logs cannot be read are reported as warnings
+	delete(client.logs, "bbbbbbbbbbbb2222")
+	result, warnings := searchLogs(t, router, "q=ERROR&stack=shop")
+	if len(result.Matches) != 2 || len(warnings) != 1 || !strings.HasPrefix(warnings[0], "container worker:") {
+		t.Errorf("Expected a warning for worker, got %v, %s", warnings, matchLines(result.Matches))
+	}
+}
+
+func TestLogSearchHandler_RespectsSelector(t *testing.T) {
+	operator, _ := auth.NewPrincipal("ops", auth.RoleOperator, "t", map[auth.Permission]string{
+		auth.PermLogsRead: "team=payments",
+	})
+	router := newLogSearchRouter(newMockLogClient(), operator)
+
+	result, _ := searchLogs(t, router, "q=ERROR")
+	for _, m := range result.Matches {
+		if m.Container != "api" {
+			t.Errorf("Restricted principal must only see permitted containers, got %s", m.Container)
+		}
+	}
+	if result.Containers != 1 {
+		t.Errorf("Expected 1 searchable container, got %d", result.Containers)
+	}
+}
@@ -0,0 +1,495 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// NetworkHandler handles network endpoints and the topology graph
+type NetworkHandler struct {
+	dockerClient interface {
+		NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error)
+		NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error)
+		NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error)
+		NetworkRemove(ctx context.Context, networkID string) error
+		NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error
+		NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error
+		ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	}
+	host   string
+	logger *zap.Logger
+}
+
+// NewNetworkHandler creates a new network handler for a single host
+func NewNetworkHandler(dockerClient interface {
+	NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error)
+	NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error)
+	NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error)
+	NetworkRemove(ctx context.Context, networkID string) error
+	NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error
+	NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+}, host string, logger *zap.Logger) *NetworkHandler {
+	return &NetworkHandler{
+		dockerClient: dockerClient,
+		host:         host,
+		logger:       logger,
+	}
+}
+
+// NetworkSubnet is one IPAM pool of a network
+type NetworkSubnet struct {
+	Subnet  string `json:"subnet"`
+	Gateway string `json:"gateway,omitempty"`
+	IPRange string `json:"ip_range,omitempty"`
+}
+
+// NetworkEndpoint is a container attached to a network
+type NetworkEndpoint struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	State       string   `json:"state"`
+	IPv4Address string   `json:"ipv4_address,omitempty"`
+	IPv6Address string   `json:"ipv6_address,omitempty"`
+	MacAddress  string   `json:"mac_address,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+}
+
+// NetworkInfo represents a network in the API response
+type NetworkInfo struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Scope      string            `json:"scope"`
+	Internal   bool              `json:"internal"`
+	Attachable bool              `json:"attachable"`
+	EnableIPv6 bool              `json:"enable_ipv6"`
+	Subnets    []NetworkSubnet   `json:"subnets"`
+	Labels     map[string]string `json:"labels"`
+	Options    map[string]string `json:"options,omitempty"`
+	Created    time.Time         `json:"created"`
+	Containers []NetworkEndpoint `json:"containers"`
+	// Predefined networks are created by the daemon and cannot be removed
+	Predefined bool `json:"predefined"`
+}
+
+// CreateNetworkRequest is the body of POST /api/networks
+type CreateNetworkRequest struct {
+	Name       string            `json:"name" binding:"required"`
+	Driver     string            `json:"driver,omitempty"`
+	Internal   bool              `json:"internal,omitempty"`
+	Attachable bool              `json:"attachable,omitempty"`
+	EnableIPv6 *bool             `json:"enable_ipv6,omitempty"`
+	Subnets    []NetworkSubnet   `json:"subnets,omitempty"`
+	Options    map[string]string `json:"options,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// ConnectNetworkRequest is the optional body of
+// POST /api/networks/:name/containers/:id
+type ConnectNetworkRequest struct {
+	Aliases     []string `json:"aliases,omitempty"`
+	IPv4Address string   `json:"ipv4_address,omitempty"`
+	IPv6Address string   `json:"ipv6_address,omitempty"`
+}
+
+// validNetworkParam reads and validates the :name path parameter, which may
+// be a network name or ID
+func validNetworkParam(c *gin.Context) (string, bool) {
+	name := c.Param("name")
+	if !resourceNamePattern.MatchString(name) {
+		BadRequest(c, "Invalid network name", name)
+		return "", false
+	}
+	return name, true
+}
+
+// endpointAliases merges the user-specified aliases and DNS names of an
+// endpoint
+func endpointAliases(endpoint *network.EndpointSettings) []string {
+	seen := make(map[string]bool)
+	var aliases []string
+	for _, names := range [][]string{endpoint.Aliases, endpoint.DNSNames} {
+		for _, alias := range names {
+			if alias != "" && !seen[alias] {
+				seen[alias] = true
+				aliases = append(aliases, alias)
+			}
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// networkEndpoints cross-references container attachments by network ID
+func networkEndpoints(containers []container.Summary) map[string][]NetworkEndpoint {
+	endpoints := make(map[string][]NetworkEndpoint)
+	for i := range containers {
+		ctr := &containers[i]
+		if ctr.NetworkSettings == nil {
+			continue
+		}
+		for _, endpoint := range ctr.NetworkSettings.Networks {
+			if endpoint == nil || endpoint.NetworkID == "" {
+				continue
+			}
+			endpoints[endpoint.NetworkID] = append(endpoints[endpoint.NetworkID], NetworkEndpoint{
+				ID:          ctr.ID,
+				Name:        containerName(ctr),
+				State:       string(ctr.State),
+				IPv4Address: endpoint.IPAddress,
+				IPv6Address: endpoint.GlobalIPv6Address,
+				MacAddress:  endpoint.MacAddress,
+				Aliases:     endpointAliases(endpoint),
+			})
+		}
+	}
+	for _, list := range endpoints {
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	}
+	return endpoints
+}
+
+// toNetworkInfo converts a network for the API response
+func toNetworkInfo(nw network.Inspect, endpoints []NetworkEndpoint) NetworkInfo {
+	info := NetworkInfo{
+		ID:         nw.ID,
+		Name:       nw.Name,
+		Driver:     nw.Driver,
+		Scope:      nw.Scope,
+		Internal:   nw.Internal,
+		Attachable: nw.Attachable,
+		EnableIPv6: nw.EnableIPv6,
+		Subnets:    make([]NetworkSubnet, 0, len(nw.IPAM.Config)),
+		Labels:     nw.Labels,
+		Options:    nw.Options,
+		Created:    nw.Created,
+		Containers: endpoints,
+		Predefined: predefinedNetworks[nw.Name],
+	}
+	for _, pool := range nw.IPAM.Config {
+		info.Subnets = append(info.Subnets, NetworkSubnet{
+			Subnet:  pool.Subnet,
+			Gateway: pool.Gateway,
+			IPRange: pool.IPRange,
+		})
+	}
+	if info.Containers == nil {
+		info.Containers = []NetworkEndpoint{}
+	}
+	return info
+}
+
+// validateSubnets checks that every pool is a CIDR and its gateway and IP
+// range fall inside it
+func validateSubnets(subnets []NetworkSubnet) (string, bool) {
+	for _, pool := range subnets {
+		_, subnet, err := net.ParseCIDR(pool.Subnet)
+		if err != nil {
+			return "invalid subnet " + strconv.Quote(pool.Subnet), false
+		}
+		if pool.Gateway != "" {
+			gateway := net.ParseIP(pool.Gateway)
+			if gateway == nil || !subnet.Contains(gateway) {
+				return "gateway " + strconv.Quote(pool.Gateway) + " is not in subnet " + pool.Subnet, false
+			}
+		}
+		if pool.IPRange != "" {
+			rangeIP, _, err := net.ParseCIDR(pool.IPRange)
+			if err != nil || !subnet.Contains(rangeIP) {
+				return "ip range " + strconv.Quote(pool.IPRange) + " is not in subnet " + pool.Subnet, false
+			}
+		}
+	}
+	return "", true
+}
+
+// ListNetworks handles GET /api/networks
+func (h *NetworkHandler) ListNetworks(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	networks, err := h.dockerClient.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		h.logger.Error("Failed to list networks", zap.Error(err))
+		dockerError(c, "Failed to list networks", err)
+		return
+	}
+	containers, err := h.dockerClient.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		h.logger.Error("Failed to list network containers", zap.Error(err))
+		dockerError(c, "Failed to list networks", err)
+		return
+	}
+	endpoints := networkEndpoints(containers)
+
+	infos := make([]NetworkInfo, 0, len(networks))
+	for _, nw := range networks {
+		infos = append(infos, toNetworkInfo(nw, endpoints[nw.ID]))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      infos,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total: len(infos),
+		},
+	})
+}
+
+// GetNetwork handles GET /api/networks/:name
+func (h *NetworkHandler) GetNetwork(c *gin.Context) {
+	name, ok := validNetworkParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	nw, err := h.dockerClient.NetworkInspect(ctx, name, network.InspectOptions{})
+	if err != nil {
+		dockerError(c, "Failed to inspect network", err)
+		return
+	}
+	containers, err := h.dockerClient.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		h.logger.Error("Failed to list network containers", zap.Error(err))
+		dockerError(c, "Failed to inspect network", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      toNetworkInfo(nw, networkEndpoints(containers)[nw.ID]),
+		Timestamp: time.Now(),
+	})
+}
+
+// CreateNetwork handles POST /api/networks
+func (h *NetworkHandler) CreateNetwork(c *gin.Context) {
+	var req CreateNetworkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if !resourceNamePattern.MatchString(req.Name) {
+		BadRequest(c, "Invalid network name", req.Name)
+		return
+	}
+	if predefinedNetworks[req.Name] {
+		BadRequest(c, "Network name is reserved", req.Name)
+		return
+	}
+	if req.Driver != "" && !resourceNamePattern.MatchString(req.Driver) {
+		BadRequest(c, "Invalid network driver", req.Driver)
+		return
+	}
+	if msg, ok := validateSubnets(req.Subnets); !ok {
+		BadRequest(c, "Invalid subnets", msg)
+		return
+	}
+	c.Set(middleware.AuditTargetKey, req.Name)
+
+	options := network.CreateOptions{
+		Driver:     req.Driver,
+		Internal:   req.Internal,
+		Attachable: req.Attachable,
+		EnableIPv6: req.EnableIPv6,
+		Options:    req.Options,
+		Labels:     req.Labels,
+	}
+	if len(req.Subnets) > 0 {
+		options.IPAM = &network.IPAM{Driver: "default"}
+		for _, pool := range req.Subnets {
+			options.IPAM.Config = append(options.IPAM.Config, network.IPAMConfig{
+				Subnet:  pool.Subnet,
+				Gateway: pool.Gateway,
+				IPRange: pool.IPRange,
+			})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	resp, err := h.dockerClient.NetworkCreate(ctx, req.Name, options)
+	if err != nil {
+		h.logger.Error("Failed to create network", zap.String("name", req.Name), zap.Error(err))
+		dockerError(c, "Failed to create network", err)
+		return
+	}
+
+	h.logger.Info("Network created",
+		zap.String("name", req.Name),
+		zap.String("id", resp.ID),
+		zap.String("driver", req.Driver))
+
+	var warnings []string
+	if resp.Warning != "" {
+		warnings = append(warnings, resp.Warning)
+	}
+	c.JSON(http.StatusCreated, APIResponse{
+		Success:   true,
+		Data:      gin.H{"id": resp.ID, "name": req.Name},
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Warnings: warnings,
+		},
+	})
+}
+
+// RemoveNetwork handles DELETE /api/networks/:name. Docker refuses to
+// remove networks with attached containers.
+func (h *NetworkHandler) RemoveNetwork(c *gin.Context) {
+	name, ok := validNetworkParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Resolve IDs too, so predefined networks cannot be removed by ID
+	nw, err := h.dockerClient.NetworkInspect(ctx, name, network.InspectOptions{})
+	if err != nil {
+		dockerError(c, "Failed to remove network", err)
+		return
+	}
+	if predefinedNetworks[nw.Name] {
+		Forbidden(c, "Predefined networks cannot be removed")
+		return
+	}
+
+	if err := h.dockerClient.NetworkRemove(ctx, nw.ID); err != nil {
+		h.logger.Error("Failed to remove network", zap.String("name", nw.Name), zap.Error(err))
+		dockerError(c, "Failed to remove network", err)
+		return
+	}
+
+	h.logger.Info("Network removed", zap.String("name", nw.Name), zap.String("id", nw.ID))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Network removed successfully"},
+		Timestamp: time.Now(),
+	})
+}
+
+// ConnectContainer handles POST /api/networks/:name/containers/:id with an
+// optional ConnectNetworkRequest body
+func (h *NetworkHandler) ConnectContainer(c *gin.Context) {
+	name, ok := validNetworkParam(c)
+	if !ok {
+		return
+	}
+	containerID, ok := validContainerParam(c)
+	if !ok {
+		return
+	}
+
+	var req ConnectNetworkRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "Invalid request body", err.Error())
+			return
+		}
+	}
+	for _, alias := range req.Aliases {
+		if !resourceNamePattern.MatchString(alias) {
+			BadRequest(c, "Invalid alias", alias)
+			return
+		}
+	}
+	if req.IPv4Address != "" && net.ParseIP(req.IPv4Address).To4() == nil {
+		BadRequest(c, "Invalid IPv4 address", req.IPv4Address)
+		return
+	}
+	if req.IPv6Address != "" && (net.ParseIP(req.IPv6Address) == nil || net.ParseIP(req.IPv6Address).To4() != nil) {
+		BadRequest(c, "Invalid IPv6 address", req.IPv6Address)
+		return
+	}
+
+	settings := &network.EndpointSettings{Aliases: req.Aliases}
+	if req.IPv4Address != "" || req.IPv6Address != "" {
+		settings.IPAMConfig = &network.EndpointIPAMConfig{
+			IPv4Address: req.IPv4Address,
+			IPv6Address: req.IPv6Address,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := h.dockerClient.NetworkConnect(ctx, name, containerID, settings); err != nil {
+		h.logger.Error("Failed to connect container",
+			zap.String("network", name),
+			zap.String("container_id", containerID),
+			zap.Error(err))
+		dockerError(c, "Failed to connect container", err)
+		return
+	}
+
+	h.logger.Info("Container connected to network",
+		zap.String("network", name),
+		zap.String("container_id", containerID))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Container connected successfully"},
+		Timestamp: time.Now(),
+	})
+}
+
+// DisconnectContainer handles DELETE /api/networks/:name/containers/:id?force=
+func (h *NetworkHandler) DisconnectContainer(c *gin.Context) {
+	name, ok := validNetworkParam(c)
+	if !ok {
+		return
+	}
+	containerID, ok := validContainerParam(c)
+	if !ok {
+		return
+	}
+	force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+	if err != nil {
+		BadRequest(c, "Invalid 'force' parameter", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := h.dockerClient.NetworkDisconnect(ctx, name, containerID, force); err != nil {
+		h.logger.Error("Failed to disconnect container",
+			zap.String("network", name),
+			zap.String("container_id", containerID),
+			zap.Error(err))
+		dockerError(c, "Failed to disconnect container", err)
+		return
+	}
+
+	h.logger.Info("Container disconnected from network",
+		zap.String("network", name),
+		zap.String("container_id", containerID),
+		zap.Bool("force", force))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Container disconnected successfully"},
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,303 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// mockNetworkClient serves fixed networks and containers and records changes
+type mockNetworkClient struct {
+	networks    []network.Summary
+	containers  []container.Summary
+	listFilters []string
+	created     *network.CreateOptions
+	removed     string
+	connected   *network.EndpointSettings
+	removeErr   error
+}
+
+func (m *mockNetworkClient) NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error) {
+	return m.networks, nil
+}
+
+func (m *mockNetworkClient) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error) {
+	for _, nw := range m.networks {
+		if nw.ID == networkID || nw.Name == networkID {
+			return nw, nil
+		}
+	}
+	return network.Inspect{}, cerrdefs.ErrNotFound
+}
+
+func (m *mockNetworkClient) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	m.created = &options
+	return network.CreateResponse{ID: "net-" + name}, nil
+}
+
+func (m *mockNetworkClient) NetworkRemove(ctx context.Context, networkID string) error {
+	if m.removeErr != nil {
+		return m.removeErr
+	}
+	m.removed = networkID
+	return nil
+}
+
+func (m *mockNetworkClient) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	m.connected = config
+	return nil
+}
+
+func (m *mockNetworkClient) NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error {
+	return nil
+}
+
+func (m *mockNetworkClient) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	m.listFilters = options.Filters.Get("label")
+	return m.containers, nil
+}
+
+func newMockNetworkClient() *mockNetworkClient {
+	return &mockNetworkClient{
+		networks: []network.Summary{
+			{ID: "n-bridge", Name: "bridge", Driver: "bridge", Scope: "local"},
+			{ID: "n-shop", Name: "shop_default", Driver: "bridge", Scope: "local",
+				IPAM: network.IPAM{Config: []network.IPAMConfig{{Subnet: "172.20.0.0/16", Gateway: "172.20.0.1"}}}},
+			{ID: "n-empty", Name: "empty", Driver: "bridge", Scope: "local"},
+		},
+		containers: []container.Summary{
+			{ID: "c-web-000000", Names: []string{"/shop-web-1"}, Image: "nginx", State: container.StateRunning,
+				Labels: map[string]string{composeProjectLabel: "shop"},
+				Ports: []container.Port{
+					{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 8080, Type: "tcp"},
+					{IP: "::", PrivatePort: 80, PublicPort: 8080, Type: "tcp"},
+					{IP: "127.0.0.1", PrivatePort: 9000, PublicPort: 9000, Type: "tcp"},
+					{PrivatePort: 443, Type: "tcp"},
+				},
+				NetworkSettings: &container.NetworkSettingsSummary{Networks: map[string]*network.EndpointSettings{
+					"shop_default": {NetworkID: "n-shop", IPAddress: "172.20.0.2", Aliases: []string{"web"}, DNSNames: []string{"shop-web-1", "web"}},
+				}}},
+			{ID: "c-db-0000000", Names: []string{"/shop-db-1"}, Image: "postgres", State: container.StateRunning,
+				Labels: map[string]string{composeProjectLabel: "shop"},
+				NetworkSettings: &container.NetworkSettingsSummary{Networks: map[string]*network.EndpointSettings{
+					"shop_default": {NetworkID: "n-shop", IPAddress: "172.20.0.3", Aliases: []string{"db"}},
+				}}},
+		},
+	}
+}
+
+func newNetworkRouter(client *mockNetworkClient) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewNetworkHandler(client, "local", zap.NewNop())
+
+	router := gin.New()
+	router.GET("/networks", handler.ListNetworks)
+	router.GET("/networks/:name", handler.GetNetwork)
+	router.POST("/networks", handler.CreateNetwork)
+	router.DELETE("/networks/:name", handler.RemoveNetwork)
+	router.POST("/networks/:name/containers/:id", handler.ConnectContainer)
+	router.DELETE("/networks/:name/containers/:id", handler.DisconnectContainer)
+	router.GET("/topology", handler.Topology)
+	return router
+}
+
+func TestNetworkHandler_ListNetworks(t *testing.T) {
+	router := newNetworkRouter(newMockNetworkClient())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/networks", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data []NetworkInfo `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Data) != 3 || resp.Data[0].Name != "bridge" || !resp.Data[0].Predefined {
+		t.Fatalf("Expected 3 sorted networks with bridge predefined, got %+v", resp.Data)
+	}
+	shop := resp.Data[2]
+	if len(shop.Subnets) != 1 || shop.Subnets[0].Gateway != "172.20.0.1" {
+		t.Errorf("Unexpected subnets: %+v", shop.Subnets)
+	}
+	if len(shop.Containers) != 2 || shop.Containers[0].Name != "shop-db-1" || shop.Containers[1].IPv4Address != "172.20.0.2" {
+		t.Errorf("Unexpected endpoints: %+v", shop.Containers)
+	}
+	if got := strings.Join(shop.Containers[1].Aliases, ","); got != "shop-web-1,web" {
+		t.Errorf("Expected merged aliases, got %s", got)
+	}
+}
+
+func TestNetworkHandler_CreateNetwork(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"valid", `{"name": "backend", "internal": true, "subnets": [{"subnet": "10.10.0.0/24", "gateway": "10.10.0.1"}]}`, http.StatusCreated},
+		{"missing name", `{"driver": "bridge"}`, http.StatusBadRequest},
+		{"invalid name", `{"name": "../net"}`, http.StatusBadRequest},
+		{"reserved name", `{"name": "host"}`, http.StatusBadRequest},
+		{"invalid subnet", `{"name": "backend", "subnets": [{"subnet": "10.10.0.0"}]}`, http.StatusBadRequest},
+		{"gateway outside subnet", `{"name": "backend", "subnets": [{"subnet": "10.10.0.0/24", "gateway": "10.20.0.1"}]}`, http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newMockNetworkClient()
+			w := httptest.NewRecorder()
+			newNetworkRouter(client).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/networks", strings.NewReader(tc.body)))
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusCreated {
+				if client.created != nil {
+					t.Error("Invalid request must not create a network")
+				}
+				return
+			}
+			if !client.created.Internal || client.created.IPAM == nil || client.created.IPAM.Config[0].Gateway != "10.10.0.1" {
+				t.Errorf("Unexpected create options: %+v", client.created)
+			}
+		})
+	}
+}
+
+func TestNetworkHandler_RemoveNetwork(t *testing.T) {
+	client := newMockNetworkClient()
+	router := newNetworkRouter(client)
+
+	// Predefined networks are protected by name and by ID
+	for _, target := range []string{"bridge", "n-bridge"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/networks/"+target, nil))
+		if w.Code != http.StatusForbidden || client.removed != "" {
+			t.Errorf("Expected removing %s to be forbidden, got %d", target, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/networks/empty", nil))
+	if w.Code != http.StatusOK || client.removed != "n-empty" {
+		t.Errorf("Expected network to be removed by ID, got %d, removed %q", w.Code, client.removed)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/networks/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown network to return 404, got %d", w.Code)
+	}
+
+	client.removeErr = cerrdefs.ErrPermissionDenied
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/networks/shop_default", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected network with active endpoints to return 403, got %d", w.Code)
+	}
+}
+
+func TestNetworkHandler_ConnectContainer(t *testing.T) {
+	client := newMockNetworkClient()
+	router := newNetworkRouter(client)
+
+	body := `{"aliases": ["api"], "ipv4_address": "172.20.0.10"}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/networks/shop_default/containers/abc123def456", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if client.connected == nil || client.connected.Aliases[0] != "api" || client.connected.IPAMConfig.IPv4Address != "172.20.0.10" {
+		t.Errorf("Unexpected endpoint settings: %+v", client.connected)
+	}
+
+	// The body is optional
+	client.connected = nil
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/networks/shop_default/containers/abc123def456", nil))
+	if w.Code != http.StatusOK || client.connected == nil || client.connected.IPAMConfig != nil {
+		t.Errorf("Expected connect without body to succeed, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/networks/shop_default/containers/abc123def456", strings.NewReader(`{"ipv4_address": "fe80::1"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected IPv6 address as IPv4 to fail, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/networks/shop_default/containers/abc123def456?force=true", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected disconnect to succeed, got %d", w.Code)
+	}
+}
+
+func TestNetworkHandler_Topology(t *testing.T) {
+	client := newMockNetworkClient()
+	router := newNetworkRouter(client)
+
+	getTopology := func(query string) Topology {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/topology"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Data Topology `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Data
+	}
+
+	topology := getTopology("")
+	var nodes []string
+	for _, node := range topology.Nodes {
+		nodes = append(nodes, node.ID)
+	}
+	want := "container:c-db-0000000,container:c-web-000000,network:n-bridge,network:n-empty,network:n-shop,port:127.0.0.1:9000/tcp,port:*:8080/tcp"
+	if got := strings.Join(nodes, ","); got != want {
+		t.Errorf("Unexpected nodes:\n got %s\nwant %s", got, want)
+	}
+
+	// Wildcard mappings are listed once per address family but yield a
+	// single edge; unpublished ports yield none
+	var attachments, publishes []TopologyEdge
+	for _, edge := range topology.Edges {
+		switch edge.Type {
+		case TopologyEdgeAttachment:
+			attachments = append(attachments, edge)
+		case TopologyEdgePublish:
+			publishes = append(publishes, edge)
+		}
+	}
+	if len(attachments) != 2 || attachments[1].Source != "container:c-web-000000" || attachments[1].IPv4Address != "172.20.0.2" {
+		t.Errorf("Unexpected attachments: %+v", attachments)
+	}
+	if len(publishes) != 2 || publishes[0].Target != "port:*:8080/tcp" || publishes[0].Port.PrivatePort != 80 || publishes[0].Port.IP != "" {
+		t.Errorf("Unexpected publish edges: %+v", publishes)
+	}
+
+	// A stack only keeps the networks its containers use
+	topology = getTopology("?stack=shop")
+	if len(client.listFilters) != 1 || client.listFilters[0] != composeProjectLabel+"=shop" {
+		t.Errorf("Expected containers to be filtered by project, got %v", client.listFilters)
+	}
+	networks := 0
+	for _, node := range topology.Nodes {
+		if node.Type == TopologyNodeNetwork {
+			networks++
+		}
+	}
+	if networks != 1 {
+		t.Errorf("Expected only the stack network, got %d networks", networks)
+	}
+}
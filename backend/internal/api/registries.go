@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/registry"
+)
+
+// RegistryHandler manages stored registry credentials
+type RegistryHandler struct {
+	store interface {
+		List() []registry.Credential
+		Get(host string) (registry.Credential, error)
+		Put(cred registry.Credential) (bool, error)
+		Delete(host string) error
+	}
+	client interface {
+		CheckLogin(ctx context.Context, cred registry.Credential) error
+	}
+	logger *zap.Logger
+}
+
+// NewRegistryHandler creates a new registry handler
+func NewRegistryHandler(store interface {
+	List() []registry.Credential
+	Get(host string) (registry.Credential, error)
+	Put(cred registry.Credential) (bool, error)
+	Delete(host string) error
+}, client interface {
+	CheckLogin(ctx context.Context, cred registry.Credential) error
+}, logger *zap.Logger) *RegistryHandler {
+	return &RegistryHandler{
+		store:  store,
+		client: client,
+		logger: logger,
+	}
+}
+
+// RegistryCredentialRequest is the body of PUT /api/registries/:name and,
+// optionally, POST /api/registries/:name/test
+type RegistryCredentialRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// RegistryLoginResult is returned by POST /api/registries/:name/test
+type RegistryLoginResult struct {
+	Registry string `json:"registry"`
+	Valid    bool   `json:"valid"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ListRegistries handles GET /api/registries. Passwords are never returned.
+func (h *RegistryHandler) ListRegistries(c *gin.Context) {
+	registries := h.store.List()
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      registries,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total: len(registries),
+		},
+	})
+}
+
+// GetRegistry handles GET /api/registries/:name
+func (h *RegistryHandler) GetRegistry(c *gin.Context) {
+	cred, err := h.store.Get(c.Param("name"))
+	if err != nil {
+		h.storeError(c, "Failed to get registry", err)
+		return
+	}
+	cred.Password = ""
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      cred,
+		Timestamp: time.Now(),
+	})
+}
+
+// PutRegistry handles PUT /api/registries/:name, creating or replacing the
+// credential of a registry host
+func (h *RegistryHandler) PutRegistry(c *gin.Context) {
+	var req RegistryCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	cred := registry.Credential{
+		Registry: c.Param("name"),
+		Username: req.Username,
+		Password: req.Password,
+		Insecure: req.Insecure,
+	}
+	created, err := h.store.Put(cred)
+	if err != nil {
+		h.storeError(c, "Failed to store registry credentials", err)
+		return
+	}
+
+	stored, _ := h.store.Get(cred.Registry)
+	stored.Password = ""
+	h.logger.Info("Registry credentials stored",
+		zap.String("registry", stored.Registry),
+		zap.String("username", stored.Username))
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, APIResponse{
+		Success:   true,
+		Data:      stored,
+		Timestamp: time.Now(),
+	})
+}
+
+// DeleteRegistry handles DELETE /api/registries/:name
+func (h *RegistryHandler) DeleteRegistry(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.store.Delete(name); err != nil {
+		h.storeError(c, "Failed to delete registry credentials", err)
+		return
+	}
+
+	h.logger.Info("Registry credentials deleted", zap.String("registry", name))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Registry credentials deleted successfully"},
+		Timestamp: time.Now(),
+	})
+}
+
+// TestRegistry handles POST /api/registries/:name/test. It logs in to the
+// registry's /v2/ endpoint with the credentials in the body, or with the
+// stored ones when the body is empty.
+func (h *RegistryHandler) TestRegistry(c *gin.Context) {
+	var req RegistryCredentialRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "Invalid request body", err.Error())
+			return
+		}
+	}
+
+	var cred registry.Credential
+	if req.Username != "" || req.Password != "" {
+		host, err := registry.NormalizeHost(c.Param("name"))
+		if err != nil {
+			BadRequest(c, "Invalid registry", err.Error())
+			return
+		}
+		cred = registry.Credential{Registry: host, Username: req.Username, Password: req.Password, Insecure: req.Insecure}
+	} else {
+		stored, err := h.store.Get(c.Param("name"))
+		if err != nil {
+			h.storeError(c, "Failed to get registry", err)
+			return
+		}
+		cred = stored
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	result := RegistryLoginResult{Registry: cred.Registry, Valid: true}
+	if err := h.client.CheckLogin(ctx, cred); err != nil {
+		h.logger.Info("Registry login test failed",
+			zap.String("registry", cred.Registry),
+			zap.Error(err))
+		result.Valid = false
+		result.Error = err.Error()
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      result,
+		Timestamp: time.Now(),
+	})
+}
+
+// storeError maps credential store errors to responses
+func (h *RegistryHandler) storeError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, registry.ErrNotFound):
+		NotFound(c, message+": not found")
+	case errors.Is(err, registry.ErrNoKey):
+		ErrorResponse(c, http.StatusServiceUnavailable, message, "registry credentials are disabled because "+err.Error())
+	default:
+		h.logger.Warn(message, zap.Error(err))
+		BadRequest(c, message, err.Error())
+	}
+}
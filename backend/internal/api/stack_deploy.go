@@ -0,0 +1,725 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/compose"
+	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/jobs"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// maxComposeSize bounds the compose file of a deploy
+const maxComposeSize = 1 << 20
+
+// Planned changes to a service in a deploy
+const (
+	changeCreate    = "create"
+	changeRecreate  = "recreate"
+	changeScale     = "scale"
+	changeUnchanged = "unchanged"
+	changeRemove    = "remove"
+)
+
+// StackDeployRequest is the body of POST /api/stacks and PUT
+// /api/stacks/:name. Name overrides the compose file's top-level name and
+// Env provides the variables the file interpolates.
+type StackDeployRequest struct {
+	Name          string            `json:"name,omitempty"`
+	Compose       string            `json:"compose" binding:"required"`
+	Env           map[string]string `json:"env,omitempty"`
+	RemoveOrphans bool              `json:"remove_orphans,omitempty"`
+}
+
+// StackChange is the planned change to one service
+type StackChange struct {
+	Service string `json:"service"`
+	// Action is create, recreate, scale, unchanged or remove
+	Action   string `json:"action"`
+	Replicas int    `json:"replicas"`
+	// Existing is the number of containers the service had before
+	Existing int `json:"existing"`
+}
+
+// StackDeployment is returned when a deploy starts
+type StackDeployment struct {
+	Stack   string        `json:"stack"`
+	Job     jobs.Info     `json:"job"`
+	Changes []StackChange `json:"changes"`
+}
+
+// stackPlan is a deploy of a project over the stack's existing containers
+type stackPlan struct {
+	project *compose.Project
+	changes []StackChange
+	// existing holds the containers of each service by container number
+	existing map[string][]container.Summary
+	orphans  []container.Summary
+}
+
+// containerNumber returns the Compose container number, or 0 if unset
+func containerNumber(ctr container.Summary) int {
+	number, _ := strconv.Atoi(ctr.Labels[compose.ContainerNumberLabel])
+	return number
+}
+
+// planStack compares a project with the stack's containers. A service is
+// recreated when any of its containers has a different config hash, and
+// scaled when only its number of containers differs. Services that are no
+// longer in the project are removed with removeOrphans, and reported in
+// the returned warnings otherwise.
+func planStack(project *compose.Project, containers []container.Summary, removeOrphans bool) (*stackPlan, []string) {
+	plan := &stackPlan{
+		project:  project,
+		changes:  []StackChange{},
+		existing: make(map[string][]container.Summary),
+	}
+	for _, ctr := range containers {
+		if strings.EqualFold(ctr.Labels[composeOneoffLabel], "true") {
+			continue
+		}
+		service := ctr.Labels[composeServiceLabel]
+		plan.existing[service] = append(plan.existing[service], ctr)
+	}
+	for _, members := range plan.existing {
+		sort.Slice(members, func(i, j int) bool { return containerNumber(members[i]) < containerNumber(members[j]) })
+	}
+
+	for _, name := range project.ServiceNames() {
+		service := project.Services[name]
+		current := plan.existing[name]
+		change := StackChange{Service: name, Action: changeUnchanged, Replicas: service.Replicas, Existing: len(current)}
+
+		hash := project.ConfigHash(service)
+		switch {
+		case len(current) == 0 && service.Replicas > 0:
+			change.Action = changeCreate
+		case len(current) != service.Replicas:
+			change.Action = changeScale
+		}
+		for _, ctr := range current {
+			if ctr.Labels[compose.ConfigHashLabel] != hash {
+				change.Action = changeRecreate
+				break
+			}
+		}
+		plan.changes = append(plan.changes, change)
+	}
+
+	var warnings []string
+	orphans := make([]string, 0)
+	for name := range plan.existing {
+		if _, ok := project.Services[name]; !ok {
+			orphans = append(orphans, name)
+		}
+	}
+	sort.Strings(orphans)
+	for _, name := range orphans {
+		if !removeOrphans {
+			warnings = append(warnings, fmt.Sprintf("service %s is not in the compose file; set remove_orphans to remove it", name))
+			continue
+		}
+		plan.changes = append(plan.changes, StackChange{Service: name, Action: changeRemove, Existing: len(plan.existing[name])})
+		plan.orphans = append(plan.orphans, plan.existing[name]...)
+	}
+	return plan, warnings
+}
+
+// serviceNetworks returns the keys of a service's networks, sorted. The
+// first one is attached on create.
+func serviceNetworks(service *compose.Service) []string {
+	keys := make([]string, 0, len(service.Networks))
+	for key := range service.Networks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// serviceSpec converts a service to the spec of its container with the
+// given number, so it goes through the same validation as POST
+// /api/containers. Compose-only options are added by applyServiceOptions.
+func serviceSpec(project *compose.Project, service *compose.Service, number int) ContainerSpec {
+	spec := ContainerSpec{
+		Name:       project.ContainerName(service, number),
+		Image:      service.Image,
+		Cmd:        service.Command,
+		Entrypoint: service.Entrypoint,
+		WorkingDir: service.WorkingDir,
+		User:       service.User,
+		Labels:     project.ContainerLabels(service, number),
+	}
+
+	for key, value := range service.Environment {
+		spec.Env = append(spec.Env, key+"="+value)
+	}
+	sort.Strings(spec.Env)
+
+	for _, port := range service.Ports {
+		spec.Ports = append(spec.Ports, PortMapping{
+			ContainerPort: port.Target,
+			HostPort:      port.Published,
+			HostIP:        port.HostIP,
+			Protocol:      port.Protocol,
+		})
+	}
+
+	for _, mount := range service.Volumes {
+		switch {
+		case mount.Type == compose.MountBind:
+			spec.Volumes = append(spec.Volumes, VolumeMount{Source: mount.Source, Target: mount.Target, ReadOnly: mount.ReadOnly})
+		case mount.Source != "":
+			spec.Volumes = append(spec.Volumes, VolumeMount{Source: project.Volumes[mount.Source].Name, Target: mount.Target, ReadOnly: mount.ReadOnly})
+		}
+	}
+
+	for _, key := range serviceNetworks(service) {
+		spec.Networks = append(spec.Networks, project.Networks[key].Name)
+	}
+
+	if service.Restart != "" {
+		name, retries, _ := strings.Cut(service.Restart, ":")
+		spec.RestartPolicy = &RestartPolicySpec{Name: name}
+		spec.RestartPolicy.MaximumRetryCount, _ = strconv.Atoi(retries)
+	}
+
+	if service.CPUs > 0 || service.MemLimit != "" || service.PidsLimit != 0 {
+		spec.Resources = &ResourceLimits{}
+		if service.CPUs > 0 {
+			spec.Resources.CPUs = &service.CPUs
+		}
+		if service.MemLimit != "" {
+			spec.Resources.Memory = &service.MemLimit
+		}
+		if service.PidsLimit != 0 {
+			spec.Resources.PidsLimit = &service.PidsLimit
+		}
+	}
+	return spec
+}
+
+// endpointSettings returns a service's settings on one of its networks.
+// The service name is always an alias, so services can reach each other by
+// name.
+func endpointSettings(service *compose.Service, key string) *network.EndpointSettings {
+	attachment := service.Networks[key]
+	settings := &network.EndpointSettings{
+		Aliases: append([]string{service.Name}, attachment.Aliases...),
+	}
+	if attachment.IPv4Address != "" || attachment.IPv6Address != "" {
+		settings.IPAMConfig = &network.EndpointIPAMConfig{
+			IPv4Address: attachment.IPv4Address,
+			IPv6Address: attachment.IPv6Address,
+		}
+	}
+	return settings
+}
+
+// applyServiceOptions adds the options ContainerSpec has no field for
+func applyServiceOptions(config *container.Config, networking *network.NetworkingConfig, project *compose.Project, service *compose.Service) {
+	config.Hostname = service.Hostname
+
+	if hc := service.Healthcheck; hc != nil {
+		config.Healthcheck = &container.HealthConfig{
+			Test:          hc.Test,
+			Interval:      hc.Interval,
+			Timeout:       hc.Timeout,
+			StartPeriod:   hc.StartPeriod,
+			StartInterval: hc.StartInterval,
+			Retries:       hc.Retries,
+		}
+		if hc.Disable {
+			config.Healthcheck = &container.HealthConfig{Test: []string{"NONE"}}
+		}
+	}
+
+	if service.StopGracePeriod != nil {
+		seconds := int(service.StopGracePeriod.Seconds())
+		config.StopTimeout = &seconds
+	}
+
+	// Anonymous volumes are created by Docker with the container
+	for _, mount := range service.Volumes {
+		if mount.Type == compose.MountVolume && mount.Source == "" {
+			if config.Volumes == nil {
+				config.Volumes = make(map[string]struct{})
+			}
+			config.Volumes[mount.Target] = struct{}{}
+		}
+	}
+
+	if networking != nil {
+		key := serviceNetworks(service)[0]
+		networking.EndpointsConfig = map[string]*network.EndpointSettings{
+			project.Networks[key].Name: endpointSettings(service, key),
+		}
+	}
+}
+
+// DeployStack handles POST /api/stacks. The compose file is validated up
+// front; the deploy runs in the background with progress streamed on
+// /ws/jobs/:id.
+func (h *StackHandler) DeployStack(c *gin.Context) {
+	h.deploy(c, "", false)
+}
+
+// UpdateStack handles PUT /api/stacks/:name. Only services whose
+// configuration changed are recreated; others are scaled or started as
+// needed.
+func (h *StackHandler) UpdateStack(c *gin.Context) {
+	name := c.Param("name")
+	if !resourceNamePattern.MatchString(name) {
+		BadRequest(c, "Invalid stack name", name)
+		return
+	}
+	h.deploy(c, name, true)
+}
+
+// deploy validates a deploy request, plans it against the stack's existing
+// containers and starts it as a job. Principals whose control permission is
+// restricted by a label selector must be allowed to control every
+// container the deploy creates or replaces.
+func (h *StackHandler) deploy(c *gin.Context, name string, update bool) {
+	var req StackDeployRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if len(req.Compose) > maxComposeSize {
+		BadRequest(c, "Compose file too large", fmt.Sprintf("the limit is %d bytes", maxComposeSize))
+		return
+	}
+	if name == "" {
+		name = req.Name
+	} else if req.Name != "" && req.Name != name {
+		BadRequest(c, "Stack name does not match the URL", req.Name)
+		return
+	}
+
+	project, warnings, err := compose.Load([]byte(req.Compose), name, req.Env)
+	if err != nil {
+		BadRequest(c, "Invalid compose file", err.Error())
+		return
+	}
+	c.Set(middleware.AuditTargetKey, project.Name)
+
+	for key, nw := range project.Networks {
+		if nw.IPAM == nil {
+			continue
+		}
+		subnets := make([]NetworkSubnet, 0, len(nw.IPAM.Config))
+		for _, cfg := range nw.IPAM.Config {
+			subnets = append(subnets, NetworkSubnet{Subnet: cfg.Subnet, Gateway: cfg.Gateway, IPRange: cfg.IPRange})
+		}
+		if msg, ok := validateSubnets(subnets); !ok {
+			BadRequest(c, "Invalid network "+key, msg)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	principal := middleware.GetPrincipal(c)
+	for _, serviceName := range project.ServiceNames() {
+		spec := serviceSpec(project, project.Services[serviceName], 1)
+		if _, _, _, err := spec.toDocker(); err != nil {
+			BadRequest(c, "Invalid service "+serviceName, err.Error())
+			return
+		}
+		if principal != nil && !principal.CanOn(auth.PermContainersControl, spec.Labels) {
+			Forbidden(c, "Service "+serviceName+" labels do not match your permitted selector")
+			return
+		}
+		if paths := spec.hostPaths(); len(paths) > 0 && !canAccessHost(c) {
+			ErrorResponse(c, http.StatusForbidden, "Only admins may mount host paths", "service "+serviceName+" mounts "+strings.Join(paths, ", "))
+			return
+		}
+		if names := spec.hostNetworks(); len(names) > 0 && !canAccessHost(c) {
+			ErrorResponse(c, http.StatusForbidden, "Only admins may use the host network", "service "+serviceName+" joins "+strings.Join(names, ", "))
+			return
+		}
+		if canAccessHost(c) {
+			continue
+		}
+		// Existing volumes, external or not, are used as they are
+		names, err := spec.hostVolumes(ctx, h.dockerClient)
+		if err != nil {
+			dockerError(c, "Failed to inspect volume", err)
+			return
+		}
+		if len(names) > 0 {
+			ErrorResponse(c, http.StatusForbidden, "Only admins may mount host paths", "service "+serviceName+" mounts volumes backed by a host path: "+strings.Join(names, ", "))
+			return
+		}
+	}
+	for key, vol := range project.Volumes {
+		if !vol.External && mountsHostPath(vol.Driver, vol.DriverOpts) && !canAccessHost(c) {
+			ErrorResponse(c, http.StatusForbidden, "Only admins may mount host paths", "volume "+key+" is backed by a host path")
+			return
+		}
+	}
+
+	containers, err := h.listStackContainers(ctx, project.Name)
+	if err != nil {
+		h.logger.Error("Failed to list stack containers", zap.String("stack", project.Name), zap.Error(err))
+		dockerError(c, "Failed to get stack", err)
+		return
+	}
+	exists := len(h.groupStacks(containers)) > 0
+	if !update && exists {
+		ErrorResponse(c, http.StatusConflict, "Stack already exists", "use PUT /api/stacks/"+project.Name+" to update it")
+		return
+	}
+	if update && !exists {
+		NotFound(c, "Stack not found")
+		return
+	}
+	if principal != nil {
+		for _, ctr := range containers {
+			if !principal.CanOn(auth.PermContainersControl, ctr.Labels) {
+				Forbidden(c, "Stack containers do not match your permitted selector")
+				return
+			}
+		}
+	}
+
+	plan, planWarnings := planStack(project, containers, req.RemoveOrphans)
+	warnings = append(warnings, planWarnings...)
+
+	h.mu.Lock()
+	if h.deploying[project.Name] {
+		h.mu.Unlock()
+		ErrorResponse(c, http.StatusConflict, "Stack deploy already running", project.Name)
+		return
+	}
+	h.deploying[project.Name] = true
+	h.mu.Unlock()
+
+	info := jobs.Info{Kind: "stack.deploy", Target: project.Name, Host: h.host}
+	if update {
+		info.Kind = "stack.update"
+	}
+	if principal != nil {
+		info.User = principal.User
+	}
+	job := h.jobs.Start(info, func(ctx context.Context, job *jobs.Job) error {
+		defer func() {
+			h.mu.Lock()
+			delete(h.deploying, project.Name)
+			h.mu.Unlock()
+		}()
+		return h.runDeploy(ctx, job, plan)
+	})
+
+	h.logger.Info("Stack deploy started",
+		zap.String("job_id", job.Info().ID),
+		zap.String("stack", project.Name),
+		zap.Bool("update", update),
+		zap.String("host", h.host))
+
+	c.JSON(http.StatusAccepted, APIResponse{
+		Success:   true,
+		Data:      StackDeployment{Stack: project.Name, Job: job.Info(), Changes: plan.changes},
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Warnings: warnings,
+		},
+	})
+}
+
+// runDeploy creates the project's networks and volumes, then deploys its
+// services in dependency order, waiting for depends_on conditions
+func (h *StackHandler) runDeploy(ctx context.Context, job *jobs.Job, plan *stackPlan) error {
+	project := plan.project
+	status := func(format string, args ...any) {
+		job.Emit(jobs.Event{Type: jobs.EventStatus, Status: fmt.Sprintf(format, args...)})
+	}
+
+	networks := make(map[string]bool)
+	volumes := make(map[string]bool)
+	for _, service := range project.Services {
+		for key := range service.Networks {
+			networks[key] = true
+		}
+		for _, mount := range service.Volumes {
+			if mount.Type == compose.MountVolume && mount.Source != "" {
+				volumes[mount.Source] = true
+			}
+		}
+	}
+	for _, key := range sortedKeys(networks) {
+		if err := h.ensureNetwork(ctx, project, key, status); err != nil {
+			return fmt.Errorf("network %s: %w", key, err)
+		}
+	}
+	for _, key := range sortedKeys(volumes) {
+		if err := h.ensureVolume(ctx, project, key, status); err != nil {
+			return fmt.Errorf("volume %s: %w", key, err)
+		}
+	}
+
+	levels, err := project.Order()
+	if err != nil {
+		return err
+	}
+	changes := make(map[string]StackChange, len(plan.changes))
+	for _, change := range plan.changes {
+		changes[change.Service] = change
+	}
+
+	deployed := make(map[string]StackService, len(project.Services))
+	for _, level := range levels {
+		for _, name := range level {
+			service := project.Services[name]
+			deps := make([]string, 0, len(service.DependsOn))
+			for dep := range service.DependsOn {
+				deps = append(deps, dep)
+			}
+			sort.Strings(deps)
+			for _, dep := range deps {
+				waitCtx, cancel := context.WithTimeout(ctx, stackActionTimeout)
+				err := h.waitForCondition(waitCtx, deployed[dep], service.DependsOn[dep].Condition)
+				cancel()
+				if err != nil {
+					return fmt.Errorf("service %s: %w", name, err)
+				}
+			}
+
+			containers, err := h.deployService(ctx, job, plan, service, changes[name], status)
+			if err != nil {
+				return fmt.Errorf("service %s: %w", name, err)
+			}
+			deployed[name] = StackService{Name: name, Containers: containers}
+		}
+	}
+
+	for _, ctr := range plan.orphans {
+		status("Removing orphan container %s", containerName(&ctr))
+		if err := h.removeServiceContainer(ctx, ctr.ID); err != nil {
+			return fmt.Errorf("removing orphan %s: %w", containerName(&ctr), err)
+		}
+	}
+
+	h.logger.Info("Stack deployed",
+		zap.String("stack", project.Name),
+		zap.Int("services", len(project.Services)),
+		zap.String("host", h.host))
+	status("Stack %s deployed", project.Name)
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ensureNetwork creates a project network unless it exists. External
+// networks must exist already.
+func (h *StackHandler) ensureNetwork(ctx context.Context, project *compose.Project, key string, status func(string, ...any)) error {
+	nw := project.Networks[key]
+	_, err := h.dockerClient.NetworkInspect(ctx, nw.Name, network.InspectOptions{})
+	if err == nil || !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	if nw.External {
+		return fmt.Errorf("external network %s not found", nw.Name)
+	}
+
+	options := network.CreateOptions{
+		Driver:     nw.Driver,
+		Options:    nw.DriverOpts,
+		Internal:   nw.Internal,
+		Attachable: nw.Attachable,
+		Labels:     project.NetworkLabels(key),
+	}
+	if nw.EnableIPv6 {
+		options.EnableIPv6 = &nw.EnableIPv6
+	}
+	if nw.IPAM != nil {
+		options.IPAM = &network.IPAM{Driver: nw.IPAM.Driver}
+		for _, cfg := range nw.IPAM.Config {
+			options.IPAM.Config = append(options.IPAM.Config, network.IPAMConfig{
+				Subnet:  cfg.Subnet,
+				Gateway: cfg.Gateway,
+				IPRange: cfg.IPRange,
+			})
+		}
+	}
+
+	status("Creating network %s", nw.Name)
+	_, err = h.dockerClient.NetworkCreate(ctx, nw.Name, options)
+	return err
+}
+
+// ensureVolume creates a project volume unless it exists. External volumes
+// must exist already.
+func (h *StackHandler) ensureVolume(ctx context.Context, project *compose.Project, key string, status func(string, ...any)) error {
+	vol := project.Volumes[key]
+	_, err := h.dockerClient.VolumeInspect(ctx, vol.Name)
+	if err == nil || !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	if vol.External {
+		return fmt.Errorf("external volume %s not found", vol.Name)
+	}
+
+	status("Creating volume %s", vol.Name)
+	_, err = h.dockerClient.VolumeCreate(ctx, volume.CreateOptions{
+		Name:       vol.Name,
+		Driver:     vol.Driver,
+		DriverOpts: vol.DriverOpts,
+		Labels:     project.VolumeLabels(key),
+	})
+	return err
+}
+
+// ensureImage pulls a missing image with the stored credentials of its
+// registry, forwarding pull progress to the job
+func (h *StackHandler) ensureImage(ctx context.Context, job *jobs.Job, imageRef string) error {
+	_, _, err := h.dockerClient.ImageInspectWithRaw(ctx, imageRef)
+	if err == nil || !cerrdefs.IsNotFound(err) {
+		return err
+	}
+
+	ref, err := docker.NormalizeImageRef(imageRef)
+	if err != nil {
+		return err
+	}
+	registryAuth, err := h.control.credentials.RegistryAuth(ref)
+	if err != nil {
+		return err
+	}
+
+	job.Emit(jobs.Event{Type: jobs.EventStatus, Status: "Pulling image " + ref})
+	return docker.PullImage(ctx, h.control.dockerClient, ref, image.PullOptions{RegistryAuth: registryAuth}, func(p docker.PullProgress) {
+		job.Emit(pullEvent(p))
+	})
+}
+
+// deployService applies a service's planned change and returns its
+// containers. Containers of unchanged services are started if stopped. The
+// image is pulled before any container is removed, so a failing pull leaves
+// the service running.
+func (h *StackHandler) deployService(ctx context.Context, job *jobs.Job, plan *stackPlan, service *compose.Service, change StackChange, status func(string, ...any)) ([]ContainerInfo, error) {
+	current := plan.existing[service.Name]
+	var remove, keep []container.Summary
+	if change.Action == changeRecreate {
+		remove, current = current, nil
+	}
+
+	have := make(map[int]bool, len(current))
+	for _, ctr := range current {
+		number := containerNumber(ctr)
+		if number < 1 || number > service.Replicas || have[number] {
+			remove = append(remove, ctr)
+			continue
+		}
+		have[number] = true
+		keep = append(keep, ctr)
+	}
+
+	if len(have) < service.Replicas {
+		if err := h.ensureImage(ctx, job, service.Image); err != nil {
+			return nil, fmt.Errorf("pulling image %s: %w", service.Image, err)
+		}
+	}
+
+	for _, ctr := range remove {
+		status("Removing container %s", containerName(&ctr))
+		if err := h.removeServiceContainer(ctx, ctr.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	containers := make([]ContainerInfo, 0, service.Replicas)
+	for _, ctr := range keep {
+		if ctr.State != container.StateRunning && ctr.State != container.StateRestarting && ctr.State != container.StatePaused {
+			status("Starting container %s", containerName(&ctr))
+			if err := h.control.containerAction(ctx, "start", ctr.ID); err != nil {
+				return nil, err
+			}
+		}
+		containers = append(containers, ContainerInfo{ID: ctr.ID, Name: containerName(&ctr)})
+	}
+
+	for number := 1; number <= service.Replicas; number++ {
+		if have[number] {
+			continue
+		}
+		name := plan.project.ContainerName(service, number)
+		status("Creating container %s", name)
+		id, err := h.createServiceContainer(ctx, plan.project, service, number)
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", name, err)
+		}
+		containers = append(containers, ContainerInfo{ID: id, Name: name})
+	}
+	return containers, nil
+}
+
+// createServiceContainer creates, connects and starts one container of a
+// service. A container that fails to connect is removed again.
+func (h *StackHandler) createServiceContainer(ctx context.Context, project *compose.Project, service *compose.Service, number int) (string, error) {
+	spec := serviceSpec(project, service, number)
+	config, hostConfig, networking, err := spec.toDocker()
+	if err != nil {
+		return "", err
+	}
+	applyServiceOptions(config, networking, project, service)
+
+	created, err := h.control.dockerClient.ContainerCreate(ctx, config, hostConfig, networking, nil, spec.Name)
+	if err != nil {
+		return "", err
+	}
+
+	// Docker only attaches one network on create
+	keys := serviceNetworks(service)
+	for _, key := range keys[min(1, len(keys)):] {
+		name := project.Networks[key].Name
+		if err := h.control.dockerClient.NetworkConnect(ctx, name, created.ID, endpointSettings(service, key)); err != nil {
+			if rmErr := h.control.dockerClient.ContainerRemove(ctx, created.ID, container.RemoveOptions{Force: true}); rmErr != nil {
+				h.logger.Warn("Failed to remove partially created container",
+					zap.String("container_id", created.ID),
+					zap.Error(rmErr))
+			}
+			return "", fmt.Errorf("connecting network %s: %w", name, err)
+		}
+	}
+
+	if err := h.control.containerAction(ctx, "start", created.ID); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// removeServiceContainer stops a container gracefully and removes it
+func (h *StackHandler) removeServiceContainer(ctx context.Context, containerID string) error {
+	if err := h.control.containerAction(ctx, "stop", containerID); err != nil && !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	err := h.control.dockerClient.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true})
+	if cerrdefs.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/compose"
+	"github.com/kubevision/kubevision/internal/jobs"
+)
+
+const storeCompose = `
+name: store
+services:
+  web:
+    image: nginx:1.27
+    ports: ["8080:80"]
+    networks: [front, back]
+    depends_on: [api]
+  api:
+    image: store/api:1
+    volumes: [data:/data]
+    environment:
+      MODE: ${MODE:-dev}
+    healthcheck:
+      test: ["CMD", "true"]
+      interval: 5s
+    networks:
+      back:
+        aliases: [backend]
+networks:
+  front:
+  back:
+volumes:
+  data:
+`
+
+// deployStack sends a deploy request and waits for its job to finish
+func deployStack(t *testing.T, router *gin.Engine, manager *jobs.Manager, method, path string, req StackDeployRequest) (StackDeployment, jobs.Info) {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(string(body))))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data StackDeployment `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	job, ok := manager.Get(resp.Data.Job.ID)
+	if !ok {
+		t.Fatalf("Job %s not found", resp.Data.Job.ID)
+	}
+	select {
+	case <-job.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Deploy job did not finish")
+	}
+	return resp.Data, job.Info()
+}
+
+func TestStackHandler_DeployStack(t *testing.T) {
+	client := newMockStackClient()
+	client.images["nginx:1.27"] = true
+	control := &mockControlClient{}
+	router, manager := newStackRouter(client, control, auth.Anonymous)
+
+	deployment, info := deployStack(t, router, manager, http.MethodPost, "/stacks", StackDeployRequest{
+		Compose: storeCompose,
+		Env:     map[string]string{"MODE": "prod"},
+	})
+	if info.State != jobs.StateSucceeded || info.Kind != "stack.deploy" || info.Target != "store" {
+		t.Fatalf("Unexpected job: %+v", info)
+	}
+	if len(deployment.Changes) != 2 || deployment.Changes[0] != (StackChange{Service: "api", Action: changeCreate, Replicas: 1}) {
+		t.Errorf("Unexpected changes: %+v", deployment.Changes)
+	}
+
+	if _, ok := client.networks["store_front"]; !ok || client.networks["store_back"].Labels[compose.NetworkLabel] != "back" {
+		t.Errorf("Expected project networks to be created, got %v", client.networks)
+	}
+	if client.volumes["store_data"].Labels[composeProjectLabel] != "store" {
+		t.Errorf("Expected project volume to be created, got %v", client.volumes)
+	}
+	if len(control.pulled) != 1 {
+		t.Errorf("Expected only the missing image to be pulled, got %d pulls", len(control.pulled))
+	}
+
+	// api is created first since web depends on it
+	if strings.Join(control.names, ",") != "store-api-1,store-web-1" || len(control.started) != 2 {
+		t.Errorf("Unexpected containers created %v, started %v", control.names, control.started)
+	}
+	// web attaches back on create and connects front afterwards
+	if strings.Join(control.connected, ",") != "store_front" {
+		t.Errorf("Unexpected connected networks: %v", control.connected)
+	}
+	endpoint := control.networking.EndpointsConfig["store_back"]
+	if endpoint == nil || endpoint.Aliases[0] != "web" {
+		t.Errorf("Expected service name alias, got %+v", control.networking.EndpointsConfig)
+	}
+	labels := control.created.Labels
+	if labels[composeProjectLabel] != "store" || labels[composeServiceLabel] != "web" ||
+		labels[compose.ConfigHashLabel] == "" || labels[composeDependsOnLabel] != "api:service_started:false" {
+		t.Errorf("Unexpected labels: %v", labels)
+	}
+}
+
+func TestStackHandler_UpdateStack(t *testing.T) {
+	v1 := "services:\n  app:\n    image: app:1\n  worker:\n    image: worker:1\n"
+	project, _, err := compose.Load([]byte(v1), "blog", nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	client := newMockStackClient()
+	client.images["app:2"] = true
+	client.images["worker:1"] = true
+	client.containers = []container.Summary{
+		{ID: "app000000001", Names: []string{"/blog-app-1"}, State: container.StateRunning, Labels: project.ContainerLabels(project.Services["app"], 1)},
+		{ID: "worker000001", Names: []string{"/blog-worker-1"}, State: container.StateExited, Labels: project.ContainerLabels(project.Services["worker"], 1)},
+		stackContainer("old000000001", "blog", "old", "", container.StateRunning),
+	}
+	control := &mockControlClient{}
+	router, manager := newStackRouter(client, control, auth.Anonymous)
+
+	v2 := "services:\n  app:\n    image: app:2\n  worker:\n    image: worker:1\n    scale: 2\n"
+	deployment, info := deployStack(t, router, manager, http.MethodPut, "/stacks/blog", StackDeployRequest{Compose: v2, RemoveOrphans: true})
+	if info.State != jobs.StateSucceeded {
+		t.Fatalf("Deploy failed: %+v", info)
+	}
+
+	var actions []string
+	for _, change := range deployment.Changes {
+		actions = append(actions, change.Service+":"+change.Action)
+	}
+	if strings.Join(actions, ",") != "app:recreate,worker:scale,old:remove" {
+		t.Errorf("Unexpected changes: %v", actions)
+	}
+	if strings.Join(control.removedIDs, ",") != "app000000001,old000000001" {
+		t.Errorf("Expected changed and orphaned containers to be removed, got %v", control.removedIDs)
+	}
+	// The unchanged worker is started and a second replica added
+	if strings.Join(control.names, ",") != "blog-app-1,blog-worker-2" {
+		t.Errorf("Unexpected containers created: %v", control.names)
+	}
+	if len(control.started) != 3 || control.started[1] != "worker000001" {
+		t.Errorf("Unexpected started containers: %v", control.started)
+	}
+}
+
+func TestStackHandler_UpdateKeepsContainersWhenPullFails(t *testing.T) {
+	v1 := "services:\n  app:\n    image: app:1\n"
+	project, _, err := compose.Load([]byte(v1), "blog", nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	client := newMockStackClient()
+	client.containers = []container.Summary{
+		{ID: "app000000001", Names: []string{"/blog-app-1"}, State: container.StateRunning, Labels: project.ContainerLabels(project.Services["app"], 1)},
+	}
+	control := &mockControlClient{pullErr: errors.New("pull access denied")}
+	router, manager := newStackRouter(client, control, auth.Anonymous)
+
+	_, info := deployStack(t, router, manager, http.MethodPut, "/stacks/blog", StackDeployRequest{Compose: "services:\n  app:\n    image: app:2\n"})
+	if info.State != jobs.StateFailed || !strings.Contains(info.Error, "app:2") {
+		t.Fatalf("Expected the deploy to fail pulling app:2, got %+v", info)
+	}
+	if len(control.removedIDs) != 0 {
+		t.Errorf("Expected the running container to be kept, removed %v", control.removedIDs)
+	}
+}
+
+func TestStackHandler_DeployStackErrors(t *testing.T) {
+	client := newMockStackClient()
+	router, _ := newStackRouter(client, &mockControlClient{}, auth.Anonymous)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		req    StackDeployRequest
+		status int
+	}{
+		{"existing stack", http.MethodPost, "/stacks", StackDeployRequest{Name: "shop", Compose: storeCompose}, http.StatusConflict},
+		{"missing stack", http.MethodPut, "/stacks/store", StackDeployRequest{Compose: storeCompose}, http.StatusNotFound},
+		{"name mismatch", http.MethodPut, "/stacks/shop", StackDeployRequest{Name: "store", Compose: storeCompose}, http.StatusBadRequest},
+		{"invalid compose", http.MethodPost, "/stacks", StackDeployRequest{Compose: "services:\n  web:\n    build: .\n"}, http.StatusBadRequest},
+		{"invalid service", http.MethodPost, "/stacks", StackDeployRequest{Name: "x", Compose: "services:\n  web:\n    image: a\n    working_dir: relative\n"}, http.StatusBadRequest},
+		{"invalid subnet", http.MethodPost, "/stacks", StackDeployRequest{Name: "x", Compose: "services:\n  web:\n    image: a\nnetworks:\n  default:\n    ipam:\n      config:\n        - subnet: nope\n"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.req)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(string(body))))
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	operator, _ := auth.NewPrincipal("ops", auth.RoleOperator, "t", map[auth.Permission]string{
+		auth.PermContainersControl: "team=payments",
+	})
+	router, _ = newStackRouter(client, &mockControlClient{}, operator)
+	body, _ := json.Marshal(StackDeployRequest{Compose: storeCompose})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stacks", strings.NewReader(string(body))))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected restricted principal to be denied, got %d", w.Code)
+	}
+
+	// Host paths and the host network are reserved for admins
+	operator, _ = auth.NewPrincipal("ops", auth.RoleOperator, "t", nil)
+	client.volumes["host-root"] = volume.CreateOptions{Name: "host-root", Driver: "local", DriverOpts: map[string]string{"o": "bind", "device": "/"}}
+	router, _ = newStackRouter(client, &mockControlClient{}, operator)
+	for _, compose := range []string{
+		"services:\n  web:\n    image: a\n    volumes:\n      - /var/run/docker.sock:/var/run/docker.sock\n",
+		"services:\n  web:\n    image: a\n    volumes:\n      - root:/host\nvolumes:\n  root:\n    driver_opts:\n      o: bind\n      device: /\n",
+		"services:\n  web:\n    image: a\n    networks:\n      - host\nnetworks:\n  host:\n    external: true\n",
+		"services:\n  web:\n    image: a\n    volumes:\n      - root:/host\nvolumes:\n  root:\n    name: host-root\n    external: true\n",
+	} {
+		body, _ := json.Marshal(StackDeployRequest{Name: "x", Compose: compose})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stacks", strings.NewReader(string(body))))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected host access to be denied, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+}
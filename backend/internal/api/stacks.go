@@ -0,0 +1,524 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/compose"
+	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/jobs"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// Labels Compose sets on project containers besides composeProjectLabel
+const (
+	composeServiceLabel     = compose.ServiceLabel
+	composeDependsOnLabel   = compose.DependsOnLabel
+	composeWorkingDirLabel  = "com.docker.compose.project.working_dir"
+	composeConfigFilesLabel = "com.docker.compose.project.config_files"
+	composeOneoffLabel      = compose.OneoffLabel
+)
+
+// Dependency conditions of com.docker.compose.depends_on
+const (
+	conditionStarted   = compose.ConditionStarted
+	conditionHealthy   = compose.ConditionHealthy
+	conditionCompleted = compose.ConditionCompleted
+)
+
+// stackActionTimeout bounds a whole stack start, stop or restart
+const stackActionTimeout = 5 * time.Minute
+
+// stackPollInterval is how often dependency conditions are re-checked
+var stackPollInterval = time.Second
+
+// StackHandler groups Compose project containers into stacks and runs
+// stack-wide actions through the container control handler
+type StackHandler struct {
+	dockerClient interface {
+		ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+		ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+		NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error)
+		NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error)
+		VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error)
+		VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error)
+		ImageInspectWithRaw(ctx context.Context, imageID string) (image.InspectResponse, []byte, error)
+	}
+	control *ContainerControlHandler
+	stats   interface {
+		Get(host, containerID string) (docker.ContainerStats, bool)
+	}
+	jobs interface {
+		Start(info jobs.Info, fn func(ctx context.Context, job *jobs.Job) error) *jobs.Job
+	}
+	host   string
+	logger *zap.Logger
+
+	// deploying holds the stacks with a deploy job running
+	mu        sync.Mutex
+	deploying map[string]bool
+}
+
+// NewStackHandler creates a new stack handler for a single host. Resource
+// usage is aggregated from the latest samples in stats; deploys run as
+// background jobs.
+func NewStackHandler(dockerClient interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error)
+	NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error)
+	VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error)
+	VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error)
+	ImageInspectWithRaw(ctx context.Context, imageID string) (image.InspectResponse, []byte, error)
+}, control *ContainerControlHandler, stats interface {
+	Get(host, containerID string) (docker.ContainerStats, bool)
+}, jobManager interface {
+	Start(info jobs.Info, fn func(ctx context.Context, job *jobs.Job) error) *jobs.Job
+}, host string, logger *zap.Logger) *StackHandler {
+	return &StackHandler{
+		dockerClient: dockerClient,
+		control:      control,
+		stats:        stats,
+		jobs:         jobManager,
+		host:         host,
+		logger:       logger,
+		deploying:    make(map[string]bool),
+	}
+}
+
+// ServiceDependency is a service another service depends on
+type ServiceDependency struct {
+	Service   string `json:"service"`
+	Condition string `json:"condition"`
+}
+
+// StackUsage is the summed resource usage of running containers
+type StackUsage struct {
+	CPUPercent  float64 `json:"cpu_percent"`
+	MemoryUsage uint64  `json:"memory_usage"`
+	MemoryLimit uint64  `json:"memory_limit"`
+	// Sampled is the number of containers with a recent stats sample
+	Sampled int `json:"sampled"`
+}
+
+// StackService is a Compose service and its containers
+type StackService struct {
+	Name       string              `json:"name"`
+	Image      string              `json:"image"`
+	DependsOn  []ServiceDependency `json:"depends_on"`
+	States     map[string]int      `json:"states"`
+	Containers []ContainerInfo     `json:"containers"`
+	Usage      *StackUsage         `json:"usage,omitempty"`
+}
+
+// Stack is a Compose project. Status is running when every container runs,
+// stopped when none does and partial otherwise.
+type Stack struct {
+	Name        string         `json:"name"`
+	Host        string         `json:"host"`
+	Status      string         `json:"status"`
+	WorkingDir  string         `json:"working_dir,omitempty"`
+	ConfigFiles []string       `json:"config_files,omitempty"`
+	Containers  int            `json:"containers"`
+	States      map[string]int `json:"states"`
+	Services    []StackService `json:"services"`
+	Usage       *StackUsage    `json:"usage,omitempty"`
+}
+
+// StackActionStep is the outcome of a stack action for one container
+type StackActionStep struct {
+	Service     string `json:"service"`
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name"`
+	// Result is "done", or "skipped" when the container already was in the
+	// requested state
+	Result string `json:"result"`
+}
+
+// StackActionResult is returned by the stack action routes, with steps in
+// the order they ran
+type StackActionResult struct {
+	Stack  string            `json:"stack"`
+	Action string            `json:"action"`
+	Steps  []StackActionStep `json:"steps"`
+}
+
+// parseDependsOn parses com.docker.compose.depends_on, a comma-separated
+// list of "service:condition:restart" entries. Older Compose versions only
+// list service names.
+func parseDependsOn(label string) []ServiceDependency {
+	deps := []ServiceDependency{}
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(label, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if parts[0] == "" || seen[parts[0]] {
+			continue
+		}
+		seen[parts[0]] = true
+		dep := ServiceDependency{Service: parts[0], Condition: conditionStarted}
+		if len(parts) > 1 && parts[1] != "" {
+			dep.Condition = parts[1]
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
+// addUsage adds a container's latest sample to usage, allocating it on the
+// first sample
+func addUsage(usage **StackUsage, stats docker.ContainerStats) {
+	if *usage == nil {
+		*usage = &StackUsage{}
+	}
+	(*usage).CPUPercent += stats.CPUPercent
+	(*usage).MemoryUsage += stats.MemoryUsage
+	(*usage).MemoryLimit += stats.MemoryLimit
+	(*usage).Sampled++
+}
+
+// groupStacks groups Compose containers by project and service. One-off
+// containers from `docker compose run` are left out, as `docker compose ps`
+// does.
+func (h *StackHandler) groupStacks(containers []container.Summary) []Stack {
+	projects := make(map[string]map[string][]container.Summary)
+	for _, ctr := range containers {
+		project := ctr.Labels[composeProjectLabel]
+		if project == "" || strings.EqualFold(ctr.Labels[composeOneoffLabel], "true") {
+			continue
+		}
+		if projects[project] == nil {
+			projects[project] = make(map[string][]container.Summary)
+		}
+		service := ctr.Labels[composeServiceLabel]
+		projects[project][service] = append(projects[project][service], ctr)
+	}
+
+	stacks := make([]Stack, 0, len(projects))
+	for project, services := range projects {
+		stack := Stack{
+			Name:     project,
+			Host:     h.host,
+			States:   make(map[string]int),
+			Services: make([]StackService, 0, len(services)),
+		}
+		running := 0
+		for name, members := range services {
+			sort.Slice(members, func(i, j int) bool { return containerName(&members[i]) < containerName(&members[j]) })
+			first := members[0]
+			if stack.WorkingDir == "" {
+				stack.WorkingDir = first.Labels[composeWorkingDirLabel]
+			}
+			if stack.ConfigFiles == nil && first.Labels[composeConfigFilesLabel] != "" {
+				stack.ConfigFiles = strings.Split(first.Labels[composeConfigFilesLabel], ",")
+			}
+
+			service := StackService{
+				Name:       name,
+				Image:      first.Image,
+				DependsOn:  parseDependsOn(first.Labels[composeDependsOnLabel]),
+				States:     make(map[string]int),
+				Containers: toContainerInfos(members, h.host),
+			}
+			for _, ctr := range members {
+				service.States[string(ctr.State)]++
+				stack.States[string(ctr.State)]++
+				if ctr.State != container.StateRunning {
+					continue
+				}
+				running++
+				if h.stats == nil {
+					continue
+				}
+				if stats, ok := h.stats.Get(h.host, ctr.ID); ok {
+					addUsage(&service.Usage, stats)
+					addUsage(&stack.Usage, stats)
+				}
+			}
+			stack.Containers += len(members)
+			stack.Services = append(stack.Services, service)
+		}
+		sort.Slice(stack.Services, func(i, j int) bool { return stack.Services[i].Name < stack.Services[j].Name })
+
+		switch running {
+		case stack.Containers:
+			stack.Status = "running"
+		case 0:
+			stack.Status = "stopped"
+		default:
+			stack.Status = "partial"
+		}
+		stacks = append(stacks, stack)
+	}
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i].Name < stacks[j].Name })
+	return stacks
+}
+
+// dependencyOrder sorts services into levels where every service only
+// depends on services in earlier levels. Dependencies on services without
+// containers are ignored.
+func dependencyOrder(services []StackService) ([][]string, error) {
+	deps := make(map[string][]string, len(services))
+	for _, service := range services {
+		deps[service.Name] = make([]string, 0, len(service.DependsOn))
+		for _, dep := range service.DependsOn {
+			deps[service.Name] = append(deps[service.Name], dep.Service)
+		}
+	}
+	return compose.Order(deps)
+}
+
+// listStackContainers lists the containers of one project, or of all
+// projects when project is empty
+func (h *StackHandler) listStackContainers(ctx context.Context, project string) ([]container.Summary, error) {
+	label := composeProjectLabel
+	if project != "" {
+		label += "=" + project
+	}
+	return h.dockerClient.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", label)),
+	})
+}
+
+// ListStacks handles GET /api/stacks
+func (h *StackHandler) ListStacks(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	containers, err := h.listStackContainers(ctx, "")
+	if err != nil {
+		h.logger.Error("Failed to list stack containers", zap.Error(err))
+		dockerError(c, "Failed to list stacks", err)
+		return
+	}
+	stacks := h.groupStacks(readableContainers(c, containers))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      stacks,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total: len(stacks),
+		},
+	})
+}
+
+// loadStack reads and validates the :name parameter and returns the stack's
+// containers, sending an error response if there are none
+func (h *StackHandler) loadStack(ctx context.Context, c *gin.Context) (string, []container.Summary, bool) {
+	name := c.Param("name")
+	if !resourceNamePattern.MatchString(name) {
+		BadRequest(c, "Invalid stack name", name)
+		return "", nil, false
+	}
+
+	containers, err := h.listStackContainers(ctx, name)
+	if err != nil {
+		h.logger.Error("Failed to list stack containers", zap.String("stack", name), zap.Error(err))
+		dockerError(c, "Failed to get stack", err)
+		return "", nil, false
+	}
+	if len(h.groupStacks(containers)) == 0 {
+		NotFound(c, "Stack not found")
+		return "", nil, false
+	}
+	return name, containers, true
+}
+
+// GetStack handles GET /api/stacks/:name
+func (h *StackHandler) GetStack(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, containers, ok := h.loadStack(ctx, c)
+	if !ok {
+		return
+	}
+	// Stacks without a readable container are hidden like missing ones
+	stacks := h.groupStacks(readableContainers(c, containers))
+	if len(stacks) == 0 {
+		NotFound(c, "Stack not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      stacks[0],
+		Timestamp: time.Now(),
+	})
+}
+
+// StartStack handles POST /api/stacks/:name/start. Services start after the
+// services they depend on, waiting for service_healthy and
+// service_completed_successfully conditions.
+func (h *StackHandler) StartStack(c *gin.Context) {
+	h.stackAction(c, "start")
+}
+
+// StopStack handles POST /api/stacks/:name/stop. Services stop before the
+// services they depend on.
+func (h *StackHandler) StopStack(c *gin.Context) {
+	h.stackAction(c, "stop")
+}
+
+// RestartStack handles POST /api/stacks/:name/restart, in start order
+func (h *StackHandler) RestartStack(c *gin.Context) {
+	h.stackAction(c, "restart")
+}
+
+// stackAction runs a container action on every service of a stack in
+// dependency order. Principals whose control permission is restricted by a
+// label selector must be allowed to control every container of the stack.
+func (h *StackHandler) stackAction(c *gin.Context, action string) {
+	ctx, cancel := context.WithTimeout(context.Background(), stackActionTimeout)
+	defer cancel()
+	// Waiting for dependencies may outlast the server's write timeout
+	extendWriteDeadline(c, stackActionTimeout)
+
+	name, containers, ok := h.loadStack(ctx, c)
+	if !ok {
+		return
+	}
+	if principal := middleware.GetPrincipal(c); principal != nil {
+		for _, ctr := range containers {
+			if !principal.CanOn(auth.PermContainersControl, ctr.Labels) {
+				Forbidden(c, "Stack containers do not match your permitted selector")
+				return
+			}
+		}
+	}
+
+	stack := h.groupStacks(containers)[0]
+	levels, err := dependencyOrder(stack.Services)
+	if err != nil {
+		ErrorResponse(c, http.StatusConflict, "Failed to "+action+" stack", err.Error())
+		return
+	}
+	if action == "stop" {
+		for i, j := 0, len(levels)-1; i < j; i, j = i+1, j-1 {
+			levels[i], levels[j] = levels[j], levels[i]
+		}
+	}
+	services := make(map[string]StackService, len(stack.Services))
+	for _, service := range stack.Services {
+		services[service.Name] = service
+	}
+
+	result := StackActionResult{Stack: name, Action: action, Steps: []StackActionStep{}}
+	for _, level := range levels {
+		for _, serviceName := range level {
+			service := services[serviceName]
+			if action != "stop" {
+				for _, dep := range service.DependsOn {
+					if depService, ok := services[dep.Service]; ok {
+						if err := h.waitForCondition(ctx, depService, dep.Condition); err != nil {
+							h.logger.Error("Stack dependency not met",
+								zap.String("stack", name),
+								zap.String("service", serviceName),
+								zap.String("dependency", dep.Service),
+								zap.Error(err))
+							ErrorResponse(c, http.StatusConflict,
+								fmt.Sprintf("Failed to %s service %s", action, serviceName), err.Error())
+							return
+						}
+					}
+				}
+			}
+
+			for _, ctr := range service.Containers {
+				step := StackActionStep{Service: serviceName, ContainerID: ctr.ID, Name: ctr.Name, Result: "done"}
+				running := ctr.State == string(container.StateRunning) || ctr.State == string(container.StateRestarting) ||
+					ctr.State == string(container.StatePaused)
+				if (action == "start" && running) || (action == "stop" && !running) {
+					step.Result = "skipped"
+					result.Steps = append(result.Steps, step)
+					continue
+				}
+				if err := h.control.containerAction(ctx, action, ctr.ID); err != nil {
+					h.logger.Error("Failed to run stack action",
+						zap.String("stack", name),
+						zap.String("action", action),
+						zap.String("container_id", ctr.ID),
+						zap.Error(err))
+					dockerError(c, fmt.Sprintf("Failed to %s service %s", action, serviceName), err)
+					return
+				}
+				result.Steps = append(result.Steps, step)
+			}
+		}
+	}
+
+	h.logger.Info("Stack action completed",
+		zap.String("stack", name),
+		zap.String("action", action),
+		zap.Int("containers", len(result.Steps)))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      result,
+		Timestamp: time.Now(),
+	})
+}
+
+// waitForCondition waits until every container of a dependency meets a
+// depends_on condition. service_healthy falls back to running for
+// containers without a health check.
+func (h *StackHandler) waitForCondition(ctx context.Context, dep StackService, condition string) error {
+	if condition != conditionHealthy && condition != conditionCompleted {
+		return nil
+	}
+
+	for {
+		met := true
+		for _, ctr := range dep.Containers {
+			inspect, err := h.dockerClient.ContainerInspect(ctx, ctr.ID)
+			if err != nil {
+				return err
+			}
+			state := inspect.State
+			if state == nil {
+				met = false
+				continue
+			}
+			switch condition {
+			case conditionHealthy:
+				if state.Health != nil {
+					if state.Health.Status == container.Unhealthy {
+						return fmt.Errorf("dependency %s is unhealthy", dep.Name)
+					}
+					met = met && state.Health.Status == container.Healthy
+				} else {
+					met = met && state.Running
+				}
+			case conditionCompleted:
+				if !state.Running && state.ExitCode != 0 {
+					return fmt.Errorf("dependency %s exited with code %d", dep.Name, state.ExitCode)
+				}
+				met = met && !state.Running && state.Status == container.StateExited
+			}
+		}
+		if met {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s to be %s: %w", dep.Name, strings.TrimPrefix(condition, "service_"), ctx.Err())
+		case <-time.After(stackPollInterval):
+		}
+	}
+}
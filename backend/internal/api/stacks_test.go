@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/jobs"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// mockStackClient serves fixed containers, filtered by label like Docker,
+// and records created networks and volumes
+type mockStackClient struct {
+	containers []container.Summary
+	health     map[string]string
+	networks   map[string]network.CreateOptions
+	volumes    map[string]volume.CreateOptions
+	images     map[string]bool
+}
+
+func (m *mockStackClient) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	var out []container.Summary
+	for _, ctr := range m.containers {
+		match := true
+		for _, label := range options.Filters.Get("label") {
+			key, value, hasValue := strings.Cut(label, "=")
+			actual, ok := ctr.Labels[key]
+			if !ok || (hasValue && actual != value) {
+				match = false
+			}
+		}
+		if match {
+			out = append(out, ctr)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockStackClient) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	for _, ctr := range m.containers {
+		if ctr.ID != containerID {
+			continue
+		}
+		state := &container.State{Status: ctr.State, Running: ctr.State == container.StateRunning}
+		if status, ok := m.health[containerID]; ok {
+			state.Health = &container.Health{Status: status}
+		}
+		return container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{ID: containerID, State: state}}, nil
+	}
+	return container.InspectResponse{}, cerrdefs.ErrNotFound
+}
+
+func (m *mockStackClient) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error) {
+	if _, ok := m.networks[networkID]; ok {
+		return network.Inspect{Name: networkID}, nil
+	}
+	return network.Inspect{}, cerrdefs.ErrNotFound
+}
+
+func (m *mockStackClient) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	m.networks[name] = options
+	return network.CreateResponse{ID: name}, nil
+}
+
+func (m *mockStackClient) VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error) {
+	if options, ok := m.volumes[volumeID]; ok {
+		return volume.Volume{Name: volumeID, Driver: options.Driver, Options: options.DriverOpts}, nil
+	}
+	return volume.Volume{}, cerrdefs.ErrNotFound
+}
+
+func (m *mockStackClient) VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error) {
+	m.volumes[options.Name] = options
+	return volume.Volume{Name: options.Name}, nil
+}
+
+func (m *mockStackClient) ImageInspectWithRaw(ctx context.Context, imageID string) (image.InspectResponse, []byte, error) {
+	if m.images[imageID] {
+		return image.InspectResponse{ID: imageID}, nil, nil
+	}
+	return image.InspectResponse{}, nil, cerrdefs.ErrNotFound
+}
+
+// staticStats returns fixed samples by container ID
+type staticStats map[string]docker.ContainerStats
+
+func (s staticStats) Get(host, containerID string) (docker.ContainerStats, bool) {
+	stats, ok := s[containerID]
+	return stats, ok
+}
+
+func stackContainer(id, project, service, dependsOn string, state container.ContainerState) container.Summary {
+	labels := map[string]string{
+		composeProjectLabel: project,
+		composeServiceLabel: service,
+	}
+	if dependsOn != "" {
+		labels[composeDependsOnLabel] = dependsOn
+	}
+	return container.Summary{ID: id, Names: []string{"/" + project + "-" + service + "-1"}, Image: service + ":latest", State: state, Labels: labels}
+}
+
+func newMockStackClient() *mockStackClient {
+	return &mockStackClient{
+		containers: []container.Summary{
+			stackContainer("web000000001", "shop", "web", "api:service_started:false", container.StateRunning),
+			stackContainer("api000000001", "shop", "api", "db:service_healthy:true,cache", container.StateRunning),
+			stackContainer("db0000000001", "shop", "db", "", container.StateExited),
+			stackContainer("cache0000001", "shop", "cache", "", container.StateRunning),
+			stackContainer("blog00000001", "blog", "app", "", container.StateRunning),
+			{ID: "standalone01", Names: []string{"/standalone"}, State: container.StateRunning},
+		},
+		health:   map[string]string{"db0000000001": container.Healthy},
+		networks: make(map[string]network.CreateOptions),
+		volumes:  make(map[string]volume.CreateOptions),
+		images:   make(map[string]bool),
+	}
+}
+
+func newStackRouter(client *mockStackClient, control *mockControlClient, principal *auth.Principal) (*gin.Engine, *jobs.Manager) {
+	gin.SetMode(gin.TestMode)
+	stats := staticStats{
+		"web000000001": {CPUPercent: 10, MemoryUsage: 100, MemoryLimit: 1000},
+		"api000000001": {CPUPercent: 5, MemoryUsage: 50, MemoryLimit: 1000},
+	}
+	jobManager := jobs.NewManager(context.Background(), time.Minute, zap.NewNop())
+	handler := NewStackHandler(client, NewContainerControlHandler(control, staticCredentials(""), zap.NewNop()), stats, jobManager, "local", zap.NewNop())
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set(middleware.PrincipalKey, principal) })
+	router.GET("/stacks", handler.ListStacks)
+	router.POST("/stacks", handler.DeployStack)
+	router.PUT("/stacks/:name", handler.UpdateStack)
+	router.GET("/stacks/:name", handler.GetStack)
+	router.POST("/stacks/:name/start", handler.StartStack)
+	router.POST("/stacks/:name/stop", handler.StopStack)
+	router.POST("/stacks/:name/restart", handler.RestartStack)
+	return router, jobManager
+}
+
+func TestStackHandler_ListStacks(t *testing.T) {
+	admin, _ := auth.NewPrincipal("admin", auth.RoleAdmin, "", nil)
+	router, _ := newStackRouter(newMockStackClient(), &mockControlClient{}, admin)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stacks", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data []Stack `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Data) != 2 || resp.Data[0].Name != "blog" || resp.Data[0].Status != "running" {
+		t.Fatalf("Expected blog and shop stacks, got %+v", resp.Data)
+	}
+
+	shop := resp.Data[1]
+	if shop.Status != "partial" || shop.Containers != 4 || shop.States["running"] != 3 || shop.States["exited"] != 1 {
+		t.Errorf("Unexpected shop summary: %+v", shop)
+	}
+	if shop.Usage == nil || shop.Usage.CPUPercent != 15 || shop.Usage.MemoryUsage != 150 || shop.Usage.Sampled != 2 {
+		t.Errorf("Unexpected shop usage: %+v", shop.Usage)
+	}
+	if len(shop.Services) != 4 || shop.Services[1].Name != "cache" || shop.Services[1].Usage != nil {
+		t.Errorf("Unexpected services: %+v", shop.Services)
+	}
+	api := shop.Services[0]
+	if len(api.DependsOn) != 2 || api.DependsOn[0] != (ServiceDependency{"db", conditionHealthy}) || api.DependsOn[1] != (ServiceDependency{"cache", conditionStarted}) {
+		t.Errorf("Unexpected dependencies: %+v", api.DependsOn)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stacks/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown stack to return 404, got %d", w.Code)
+	}
+}
+
+func TestStackHandler_ListStacksRespectsReadSelector(t *testing.T) {
+	operator, _ := auth.NewPrincipal("ops", auth.RoleOperator, "t", map[auth.Permission]string{
+		auth.PermContainersRead: composeProjectLabel + "=blog",
+	})
+	router, _ := newStackRouter(newMockStackClient(), &mockControlClient{}, operator)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stacks", nil))
+	var resp struct {
+		Data []Stack `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || len(resp.Data) != 1 || resp.Data[0].Name != "blog" {
+		t.Fatalf("Expected only the blog stack, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stacks/shop", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected unreadable stack to return 404, got %d", w.Code)
+	}
+}
+
+func TestDependencyOrder(t *testing.T) {
+	services := []StackService{
+		{Name: "web", DependsOn: parseDependsOn("api")},
+		{Name: "api", DependsOn: parseDependsOn("db:service_healthy:true,cache:service_started:false,gone")},
+		{Name: "db", DependsOn: parseDependsOn("")},
+		{Name: "cache"},
+	}
+	levels, err := dependencyOrder(services)
+	if err != nil {
+		t.Fatalf("dependencyOrder failed: %v", err)
+	}
+	var got []string
+	for _, level := range levels {
+		got = append(got, strings.Join(level, ","))
+	}
+	if strings.Join(got, " | ") != "cache,db | api | web" {
+		t.Errorf("Unexpected order: %v", got)
+	}
+
+	services[2].DependsOn = parseDependsOn("web")
+	if _, err := dependencyOrder(services); err == nil || !strings.Contains(err.Error(), "api, db, web") {
+		t.Errorf("Expected cycle error naming the services, got %v", err)
+	}
+}
+
+func TestStackHandler_Actions(t *testing.T) {
+	admin, _ := auth.NewPrincipal("admin", auth.RoleAdmin, "", nil)
+	client := newMockStackClient()
+	control := &mockControlClient{}
+	router, _ := newStackRouter(client, control, admin)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stacks/shop/start", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	// Only the stopped db needs starting; the others are skipped
+	if strings.Join(control.started, ",") != "db0000000001" {
+		t.Errorf("Unexpected started containers: %v", control.started)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stacks/shop/restart", nil))
+	if strings.Join(control.restarted, ",") != "cache0000001,db0000000001,api000000001,web000000001" {
+		t.Errorf("Expected restart in dependency order, got %v", control.restarted)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stacks/shop/stop", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data StackActionResult `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if strings.Join(control.stopped, ",") != "web000000001,api000000001,cache0000001" {
+		t.Errorf("Expected stop in reverse dependency order, got %v", control.stopped)
+	}
+	if len(resp.Data.Steps) != 4 || resp.Data.Steps[3].Service != "db" || resp.Data.Steps[3].Result != "skipped" {
+		t.Errorf("Unexpected steps: %+v", resp.Data.Steps)
+	}
+}
+
+func TestStackHandler_StartWaitsForHealthyDependency(t *testing.T) {
+	defer func(interval time.Duration) { stackPollInterval = interval }(stackPollInterval)
+	stackPollInterval = time.Millisecond
+
+	admin, _ := auth.NewPrincipal("admin", auth.RoleAdmin, "", nil)
+	client := newMockStackClient()
+	client.health["db0000000001"] = container.Unhealthy
+	control := &mockControlClient{}
+	router, _ := newStackRouter(client, control, admin)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stacks/shop/start", nil))
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "db is unhealthy") {
+		t.Errorf("Expected unhealthy dependency to fail the start, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestStackHandler_ActionRespectsSelector(t *testing.T) {
+	operator, _ := auth.NewPrincipal("ops", auth.RoleOperator, "t", map[auth.Permission]string{
+		auth.PermContainersControl: "team=payments",
+	})
+	control := &mockControlClient{}
+	router, _ := newStackRouter(newMockStackClient(), control, operator)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stacks/shop/stop", nil))
+	if w.Code != http.StatusForbidden || len(control.stopped) != 0 {
+		t.Errorf("Expected restricted principal to be denied, got %d, stopped %v", w.Code, control.stopped)
+	}
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// diskUsageTimeout bounds computing disk usage
+const diskUsageTimeout = 2 * time.Minute
+
+// SystemHandler reports disk usage and prunes unused Docker objects
+type SystemHandler struct {
+	dockerClient interface {
+		DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error)
+		NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error)
+		ContainersPrune(ctx context.Context, pruneFilters filters.Args) (container.PruneReport, error)
+		ImagesPrune(ctx context.Context, pruneFilters filters.Args) (image.PruneReport, error)
+		VolumesPrune(ctx context.Context, pruneFilters filters.Args) (volume.PruneReport, error)
+		NetworksPrune(ctx context.Context, pruneFilters filters.Args) (network.PruneReport, error)
+		BuildCachePrune(ctx context.Context, opts build.CachePruneOptions) (*build.CachePruneReport, error)
+	}
+	logger *zap.Logger
+}
+
+// NewSystemHandler creates a new system handler
+func NewSystemHandler(dockerClient interface {
+	DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error)
+	NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error)
+	ContainersPrune(ctx context.Context, pruneFilters filters.Args) (container.PruneReport, error)
+	ImagesPrune(ctx context.Context, pruneFilters filters.Args) (image.PruneReport, error)
+	VolumesPrune(ctx context.Context, pruneFilters filters.Args) (volume.PruneReport, error)
+	NetworksPrune(ctx context.Context, pruneFilters filters.Args) (network.PruneReport, error)
+	BuildCachePrune(ctx context.Context, opts build.CachePruneOptions) (*build.CachePruneReport, error)
+}, logger *zap.Logger) *SystemHandler {
+	return &SystemHandler{
+		dockerClient: dockerClient,
+		logger:       logger,
+	}
+}
+
+// DiskUsageItem is a single object in a disk usage category
+type DiskUsageItem struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Size    int64     `json:"size"`
+	InUse   bool      `json:"in_use"`
+	Created time.Time `json:"created,omitempty"`
+}
+
+// DiskUsageCategory summarizes one kind of Docker object
+type DiskUsageCategory struct {
+	Total       int             `json:"total"`
+	Active      int             `json:"active"`
+	Size        int64           `json:"size"`
+	Reclaimable int64           `json:"reclaimable"`
+	Items       []DiskUsageItem `json:"items,omitempty"`
+}
+
+// DiskUsageReport is returned by GET /api/system/df
+type DiskUsageReport struct {
+	Images           DiskUsageCategory `json:"images"`
+	Containers       DiskUsageCategory `json:"containers"`
+	Volumes          DiskUsageCategory `json:"volumes"`
+	BuildCache       DiskUsageCategory `json:"build_cache"`
+	TotalSize        int64             `json:"total_size"`
+	TotalReclaimable int64             `json:"total_reclaimable"`
+}
+
+// imageName returns the first tag of an image, if any
+func imageName(img *image.Summary) string {
+	for _, tag := range img.RepoTags {
+		if tag != "<none>:<none>" {
+			return tag
+		}
+	}
+	return ""
+}
+
+// containerName returns a container's name without the leading slash
+func containerName(ctr *container.Summary) string {
+	if len(ctr.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(ctr.Names[0], "/")
+}
+
+// newDiskUsageReport summarizes Docker's disk usage the way
+// `docker system df` does
+func newDiskUsageReport(usage types.DiskUsage, verbose bool) DiskUsageReport {
+	var report DiskUsageReport
+
+	// Images share layers, so the total is the layer size and only the
+	// unshared part of images in use is not reclaimable
+	report.Images.Size = usage.LayersSize
+	var imagesInUse int64
+	for _, img := range usage.Images {
+		report.Images.Total++
+		inUse := img.Containers > 0
+		if inUse {
+			report.Images.Active++
+			if img.SharedSize >= 0 {
+				imagesInUse += img.Size - img.SharedSize
+			}
+		}
+		if verbose {
+			report.Images.Items = append(report.Images.Items, DiskUsageItem{
+				ID:      img.ID,
+				Name:    imageName(img),
+				Size:    img.Size,
+				InUse:   inUse,
+				Created: time.Unix(img.Created, 0),
+			})
+		}
+	}
+	report.Images.Reclaimable = max(usage.LayersSize-imagesInUse, 0)
+
+	for _, ctr := range usage.Containers {
+		report.Containers.Total++
+		report.Containers.Size += ctr.SizeRw
+		running := ctr.State == container.StateRunning || ctr.State == container.StatePaused || ctr.State == container.StateRestarting
+		if running {
+			report.Containers.Active++
+		} else {
+			report.Containers.Reclaimable += ctr.SizeRw
+		}
+		if verbose {
+			report.Containers.Items = append(report.Containers.Items, DiskUsageItem{
+				ID:      ctr.ID,
+				Name:    containerName(ctr),
+				Size:    ctr.SizeRw,
+				InUse:   running,
+				Created: time.Unix(ctr.Created, 0),
+			})
+		}
+	}
+
+	for _, vol := range usage.Volumes {
+		report.Volumes.Total++
+		var size int64
+		inUse := false
+		if vol.UsageData != nil {
+			size = max(vol.UsageData.Size, 0)
+			inUse = vol.UsageData.RefCount > 0
+		}
+		report.Volumes.Size += size
+		if inUse {
+			report.Volumes.Active++
+		} else {
+			report.Volumes.Reclaimable += size
+		}
+		if verbose {
+			item := DiskUsageItem{ID: vol.Name, Name: vol.Name, Size: size, InUse: inUse}
+			if created, err := time.Parse(time.RFC3339, vol.CreatedAt); err == nil {
+				item.Created = created
+			}
+			report.Volumes.Items = append(report.Volumes.Items, item)
+		}
+	}
+
+	for _, record := range usage.BuildCache {
+		report.BuildCache.Total++
+		if record.InUse {
+			report.BuildCache.Active++
+		}
+		if !record.Shared {
+			report.BuildCache.Size += record.Size
+			if !record.InUse {
+				report.BuildCache.Reclaimable += record.Size
+			}
+		}
+		if verbose {
+			report.BuildCache.Items = append(report.BuildCache.Items, DiskUsageItem{
+				ID:      record.ID,
+				Name:    record.Description,
+				Size:    record.Size,
+				InUse:   record.InUse,
+				Created: record.CreatedAt,
+			})
+		}
+	}
+
+	report.TotalSize = report.Images.Size + report.Containers.Size + report.Volumes.Size + report.BuildCache.Size
+	report.TotalReclaimable = report.Images.Reclaimable + report.Containers.Reclaimable +
+		report.Volumes.Reclaimable + report.BuildCache.Reclaimable
+	return report
+}
+
+// DiskUsage handles GET /api/system/df?verbose=. verbose=true lists every
+// object in each category.
+func (h *SystemHandler) DiskUsage(c *gin.Context) {
+	verbose, err := strconv.ParseBool(c.DefaultQuery("verbose", "false"))
+	if err != nil {
+		BadRequest(c, "Invalid 'verbose' parameter", err.Error())
+		return
+	}
+
+	// Disk usage walks every layer and volume, which can take a while
+	ctx, cancel := context.WithTimeout(context.Background(), diskUsageTimeout)
+	defer cancel()
+	extendWriteDeadline(c, diskUsageTimeout)
+
+	usage, err := h.dockerClient.DiskUsage(ctx, types.DiskUsageOptions{})
+	if err != nil {
+		h.logger.Error("Failed to get disk usage", zap.Error(err))
+		dockerError(c, "Failed to get disk usage", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      newDiskUsageReport(usage, verbose),
+		Timestamp: time.Now(),
+	})
+}
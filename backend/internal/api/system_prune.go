@@ -0,0 +1,428 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+const (
+	// anonymousVolumeLabel marks volumes Docker created without a name
+	anonymousVolumeLabel = "com.docker.volume.anonymous"
+
+	// pruneTimeout bounds a prune, which removes objects one kind at a time
+	pruneTimeout = 10 * time.Minute
+)
+
+// predefinedNetworks are created by the daemon and are never pruned or
+// removed
+var predefinedNetworks = map[string]bool{"bridge": true, "host": true, "none": true}
+
+// PruneRequest is the body of POST /api/system/prune. All extends images
+// and build cache to everything unused, not just dangling objects, and
+// volumes to named ones. Labels are "key", "key=value", or either prefixed
+// with "!" to exclude matches. Until is a duration ("24h") or a timestamp.
+type PruneRequest struct {
+	Containers bool     `json:"containers"`
+	Images     bool     `json:"images"`
+	Volumes    bool     `json:"volumes"`
+	Networks   bool     `json:"networks"`
+	BuildCache bool     `json:"build_cache"`
+	All        bool     `json:"all"`
+	Labels     []string `json:"labels,omitempty"`
+	Until      string   `json:"until,omitempty"`
+	DryRun     bool     `json:"dry_run"`
+}
+
+// PrunedItem is an object that was, or would be, removed
+type PrunedItem struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// PruneReport is returned by POST /api/system/prune. SpaceReclaimed is an
+// estimate for dry runs.
+type PruneReport struct {
+	DryRun         bool         `json:"dry_run"`
+	Containers     []PrunedItem `json:"containers"`
+	Images         []PrunedItem `json:"images"`
+	Volumes        []PrunedItem `json:"volumes"`
+	Networks       []PrunedItem `json:"networks"`
+	BuildCache     []PrunedItem `json:"build_cache"`
+	SpaceReclaimed uint64       `json:"space_reclaimed"`
+}
+
+// labelFilter is a parsed label filter
+type labelFilter struct {
+	key, value string
+	hasValue   bool
+	negate     bool
+}
+
+// matches reports whether labels pass the filter
+func (f labelFilter) matches(labels map[string]string) bool {
+	value, ok := labels[f.key]
+	found := ok && (!f.hasValue || value == f.value)
+	return found != f.negate
+}
+
+// pruneFilters is the validated filter set of a prune request
+type pruneFilters struct {
+	labels []labelFilter
+	until  time.Time
+}
+
+// parsePruneFilters validates the label and until filters
+func parsePruneFilters(req PruneRequest) (pruneFilters, error) {
+	var parsed pruneFilters
+	for _, entry := range req.Labels {
+		var f labelFilter
+		entry, f.negate = strings.CutPrefix(entry, "!")
+		f.key, f.value, f.hasValue = strings.Cut(entry, "=")
+		if f.key == "" {
+			return parsed, fmt.Errorf("invalid label filter %q", entry)
+		}
+		parsed.labels = append(parsed.labels, f)
+	}
+
+	if req.Until != "" {
+		if d, err := time.ParseDuration(req.Until); err == nil {
+			if d <= 0 {
+				return parsed, fmt.Errorf("until must be a positive duration")
+			}
+			parsed.until = time.Now().Add(-d)
+		} else {
+			until, err := parseTimeParam(req.Until, time.Time{})
+			if err != nil {
+				return parsed, fmt.Errorf("invalid until %q, expected a duration or timestamp", req.Until)
+			}
+			parsed.until = until
+		}
+	}
+
+	// Docker rejects until for volumes, and build cache has no labels
+	if req.Volumes && !parsed.until.IsZero() {
+		return parsed, fmt.Errorf("the until filter cannot be combined with volumes")
+	}
+	if req.BuildCache && len(parsed.labels) > 0 {
+		return parsed, fmt.Errorf("label filters cannot be combined with build_cache")
+	}
+	return parsed, nil
+}
+
+// match reports whether an object with the given labels and creation time
+// passes the filters. A zero created time skips the until filter.
+func (f pruneFilters) match(labels map[string]string, created time.Time) bool {
+	for _, label := range f.labels {
+		if !label.matches(labels) {
+			return false
+		}
+	}
+	return f.until.IsZero() || created.IsZero() || created.Before(f.until)
+}
+
+// args converts the filters for Docker's prune endpoints
+func (f pruneFilters) args(withUntil bool) filters.Args {
+	args := filters.NewArgs()
+	for _, label := range f.labels {
+		value := label.key
+		if label.hasValue {
+			value += "=" + label.value
+		}
+		if label.negate {
+			args.Add("label!", value)
+		} else {
+			args.Add("label", value)
+		}
+	}
+	if withUntil && !f.until.IsZero() {
+		args.Add("until", strconv.FormatInt(f.until.Unix(), 10))
+	}
+	return args
+}
+
+// resources lists the toggled resource kinds for logs and the audit log
+func (req PruneRequest) resources() string {
+	var kinds []string
+	for _, kind := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"containers", req.Containers},
+		{"images", req.Images},
+		{"volumes", req.Volumes},
+		{"networks", req.Networks},
+		{"build_cache", req.BuildCache},
+	} {
+		if kind.enabled {
+			kinds = append(kinds, kind.name)
+		}
+	}
+	return strings.Join(kinds, ",")
+}
+
+// Prune handles POST /api/system/prune. Objects are pruned in the order of
+// `docker system prune`, so images and volumes freed by pruned containers
+// are removed too. A dry run lists what would be removed without removing
+// anything.
+func (h *SystemHandler) Prune(c *gin.Context) {
+	var req PruneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	resources := req.resources()
+	if resources == "" {
+		BadRequest(c, "Nothing to prune", "enable at least one of containers, images, volumes, networks or build_cache")
+		return
+	}
+	pf, err := parsePruneFilters(req)
+	if err != nil {
+		BadRequest(c, "Invalid prune filters", err.Error())
+		return
+	}
+
+	target := resources
+	if req.DryRun {
+		target += " (dry run)"
+	}
+	c.Set(middleware.AuditTargetKey, target)
+
+	ctx, cancel := context.WithTimeout(context.Background(), pruneTimeout)
+	defer cancel()
+	extendWriteDeadline(c, pruneTimeout)
+
+	var report *PruneReport
+	if req.DryRun {
+		report, err = h.planPrune(ctx, req, pf)
+	} else {
+		report, err = h.prune(ctx, req, pf)
+	}
+	if err != nil {
+		h.logger.Error("Failed to prune", zap.String("resources", resources), zap.Error(err))
+		dockerError(c, "Failed to prune", err)
+		return
+	}
+
+	h.logger.Info("System pruned",
+		zap.String("resources", resources),
+		zap.Bool("dry_run", req.DryRun),
+		zap.Uint64("space_reclaimed", report.SpaceReclaimed))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      report,
+		Timestamp: time.Now(),
+	})
+}
+
+// newPruneReport returns a report with empty, non-nil lists
+func newPruneReport(dryRun bool) *PruneReport {
+	return &PruneReport{
+		DryRun:     dryRun,
+		Containers: []PrunedItem{},
+		Images:     []PrunedItem{},
+		Volumes:    []PrunedItem{},
+		Networks:   []PrunedItem{},
+		BuildCache: []PrunedItem{},
+	}
+}
+
+// prune removes the selected objects
+func (h *SystemHandler) prune(ctx context.Context, req PruneRequest, pf pruneFilters) (*PruneReport, error) {
+	report := newPruneReport(false)
+
+	if req.Containers {
+		pruned, err := h.dockerClient.ContainersPrune(ctx, pf.args(true))
+		if err != nil {
+			return nil, fmt.Errorf("containers: %w", err)
+		}
+		for _, id := range pruned.ContainersDeleted {
+			report.Containers = append(report.Containers, PrunedItem{ID: id})
+		}
+		report.SpaceReclaimed += pruned.SpaceReclaimed
+	}
+
+	if req.Networks {
+		pruned, err := h.dockerClient.NetworksPrune(ctx, pf.args(true))
+		if err != nil {
+			return nil, fmt.Errorf("networks: %w", err)
+		}
+		for _, name := range pruned.NetworksDeleted {
+			report.Networks = append(report.Networks, PrunedItem{ID: name, Name: name})
+		}
+	}
+
+	if req.Volumes {
+		args := pf.args(false)
+		if req.All {
+			args.Add("all", "true")
+		}
+		pruned, err := h.dockerClient.VolumesPrune(ctx, args)
+		if err != nil {
+			return nil, fmt.Errorf("volumes: %w", err)
+		}
+		for _, name := range pruned.VolumesDeleted {
+			report.Volumes = append(report.Volumes, PrunedItem{ID: name, Name: name})
+		}
+		report.SpaceReclaimed += pruned.SpaceReclaimed
+	}
+
+	if req.Images {
+		args := pf.args(true)
+		if req.All {
+			args.Add("dangling", "false")
+		}
+		pruned, err := h.dockerClient.ImagesPrune(ctx, args)
+		if err != nil {
+			return nil, fmt.Errorf("images: %w", err)
+		}
+		for _, deleted := range pruned.ImagesDeleted {
+			if deleted.Deleted != "" {
+				report.Images = append(report.Images, PrunedItem{ID: deleted.Deleted})
+			}
+		}
+		report.SpaceReclaimed += pruned.SpaceReclaimed
+	}
+
+	if req.BuildCache {
+		pruned, err := h.dockerClient.BuildCachePrune(ctx, build.CachePruneOptions{All: req.All, Filters: pf.args(true)})
+		if err != nil {
+			return nil, fmt.Errorf("build cache: %w", err)
+		}
+		if pruned != nil {
+			for _, id := range pruned.CachesDeleted {
+				report.BuildCache = append(report.BuildCache, PrunedItem{ID: id})
+			}
+			report.SpaceReclaimed += pruned.SpaceReclaimed
+		}
+	}
+
+	return report, nil
+}
+
+// planPrune lists what prune would remove from a disk usage snapshot. Like
+// the real prune, objects only used by containers that would be pruned
+// count as unused. Build cache results are an estimate since BuildKit
+// applies its own rules to dangling records.
+func (h *SystemHandler) planPrune(ctx context.Context, req PruneRequest, pf pruneFilters) (*PruneReport, error) {
+	usage, err := h.dockerClient.DiskUsage(ctx, types.DiskUsageOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("disk usage: %w", err)
+	}
+	report := newPruneReport(true)
+
+	usedImages := make(map[string]bool)
+	usedVolumes := make(map[string]bool)
+	usedNetworks := make(map[string]bool)
+	for _, ctr := range usage.Containers {
+		stopped := ctr.State != container.StateRunning && ctr.State != container.StatePaused && ctr.State != container.StateRestarting
+		if req.Containers && stopped && pf.match(ctr.Labels, time.Unix(ctr.Created, 0)) {
+			report.Containers = append(report.Containers, PrunedItem{ID: ctr.ID, Name: containerName(ctr), Size: ctr.SizeRw})
+			report.SpaceReclaimed += uint64(max(ctr.SizeRw, 0))
+			continue
+		}
+
+		usedImages[ctr.ImageID] = true
+		for _, mount := range ctr.Mounts {
+			if mount.Name != "" {
+				usedVolumes[mount.Name] = true
+			}
+		}
+		if ctr.NetworkSettings != nil {
+			for name, endpoint := range ctr.NetworkSettings.Networks {
+				usedNetworks[name] = true
+				if endpoint != nil {
+					usedNetworks[endpoint.NetworkID] = true
+				}
+			}
+		}
+		usedNetworks[ctr.HostConfig.NetworkMode] = true
+	}
+
+	if req.Networks {
+		networks, err := h.dockerClient.NetworkList(ctx, network.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("networks: %w", err)
+		}
+		for _, n := range networks {
+			if predefinedNetworks[n.Name] || n.Scope != "local" || usedNetworks[n.Name] || usedNetworks[n.ID] {
+				continue
+			}
+			if pf.match(n.Labels, n.Created) {
+				report.Networks = append(report.Networks, PrunedItem{ID: n.ID, Name: n.Name})
+			}
+		}
+	}
+
+	if req.Volumes {
+		for _, vol := range usage.Volumes {
+			if usedVolumes[vol.Name] {
+				continue
+			}
+			if _, anonymous := vol.Labels[anonymousVolumeLabel]; !req.All && !anonymous {
+				continue
+			}
+			if !pf.match(vol.Labels, time.Time{}) {
+				continue
+			}
+			item := PrunedItem{ID: vol.Name, Name: vol.Name}
+			if vol.UsageData != nil && vol.UsageData.Size > 0 {
+				item.Size = vol.UsageData.Size
+			}
+			report.Volumes = append(report.Volumes, item)
+			report.SpaceReclaimed += uint64(item.Size)
+		}
+	}
+
+	if req.Images {
+		for _, img := range usage.Images {
+			name := imageName(img)
+			if usedImages[img.ID] || (!req.All && name != "") {
+				continue
+			}
+			if !pf.match(img.Labels, time.Unix(img.Created, 0)) {
+				continue
+			}
+			item := PrunedItem{ID: img.ID, Name: name, Size: img.Size}
+			if img.SharedSize > 0 {
+				item.Size -= img.SharedSize
+			}
+			report.Images = append(report.Images, item)
+			report.SpaceReclaimed += uint64(max(item.Size, 0))
+		}
+	}
+
+	if req.BuildCache {
+		for _, record := range usage.BuildCache {
+			if record.InUse || (!req.All && (record.Shared || record.Type == "internal" || record.Type == "frontend")) {
+				continue
+			}
+			lastUsed := record.CreatedAt
+			if record.LastUsedAt != nil {
+				lastUsed = *record.LastUsedAt
+			}
+			if !pf.match(nil, lastUsed) {
+				continue
+			}
+			report.BuildCache = append(report.BuildCache, PrunedItem{ID: record.ID, Name: record.Description, Size: record.Size})
+			report.SpaceReclaimed += uint64(max(record.Size, 0))
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// mockSystemClient serves a fixed disk usage snapshot and records prunes
+type mockSystemClient struct {
+	usage        types.DiskUsage
+	networks     []network.Summary
+	imageFilters *filters.Args
+	pruned       []string
+}
+
+func (m *mockSystemClient) DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error) {
+	return m.usage, nil
+}
+
+func (m *mockSystemClient) NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error) {
+	return m.networks, nil
+}
+
+func (m *mockSystemClient) ContainersPrune(ctx context.Context, pruneFilters filters.Args) (container.PruneReport, error) {
+	m.pruned = append(m.pruned, "containers")
+	return container.PruneReport{ContainersDeleted: []string{"c-stopped"}, SpaceReclaimed: 10}, nil
+}
+
+func (m *mockSystemClient) ImagesPrune(ctx context.Context, pruneFilters filters.Args) (image.PruneReport, error) {
+	m.pruned = append(m.pruned, "images")
+	m.imageFilters = &pruneFilters
+	return image.PruneReport{ImagesDeleted: []image.DeleteResponse{{Untagged: "old:1"}, {Deleted: "sha256:old"}}, SpaceReclaimed: 100}, nil
+}
+
+func (m *mockSystemClient) VolumesPrune(ctx context.Context, pruneFilters filters.Args) (volume.PruneReport, error) {
+	m.pruned = append(m.pruned, "volumes")
+	return volume.PruneReport{}, nil
+}
+
+func (m *mockSystemClient) NetworksPrune(ctx context.Context, pruneFilters filters.Args) (network.PruneReport, error) {
+	m.pruned = append(m.pruned, "networks")
+	return network.PruneReport{}, nil
+}
+
+func (m *mockSystemClient) BuildCachePrune(ctx context.Context, opts build.CachePruneOptions) (*build.CachePruneReport, error) {
+	m.pruned = append(m.pruned, "build_cache")
+	return &build.CachePruneReport{}, nil
+}
+
+func newMockSystemClient() *mockSystemClient {
+	return &mockSystemClient{
+		usage: types.DiskUsage{
+			LayersSize: 1000,
+			Images: []*image.Summary{
+				{ID: "sha256:web", RepoTags: []string{"web:1"}, Size: 600, SharedSize: 100, Containers: 1},
+				{ID: "sha256:old", RepoTags: []string{"old:1"}, Size: 300, SharedSize: 100, Containers: 1},
+				{ID: "sha256:dangling", RepoTags: []string{"<none>:<none>"}, Size: 100, SharedSize: 0},
+			},
+			Containers: []*container.Summary{
+				{ID: "c-web", Names: []string{"/web"}, ImageID: "sha256:web", State: container.StateRunning, SizeRw: 5,
+					Mounts: []container.MountPoint{{Type: "volume", Name: "web-data"}}},
+				{ID: "c-stopped", Names: []string{"/old"}, ImageID: "sha256:old", State: container.StateExited, SizeRw: 10,
+					Labels: map[string]string{"team": "legacy"},
+					Mounts: []container.MountPoint{{Type: "volume", Name: "old-data"}}},
+			},
+			Volumes: []*volume.Volume{
+				{Name: "web-data", UsageData: &volume.UsageData{Size: 50, RefCount: 1}},
+				{Name: "old-data", UsageData: &volume.UsageData{Size: 20, RefCount: 1}},
+				{Name: "abc123", Labels: map[string]string{anonymousVolumeLabel: ""}, UsageData: &volume.UsageData{Size: 7, RefCount: 0}},
+			},
+			BuildCache: []*build.CacheRecord{
+				{ID: "cache-1", Type: "regular", Size: 40},
+				{ID: "cache-2", Type: "regular", Size: 60, InUse: true},
+			},
+		},
+		networks: []network.Summary{
+			{ID: "n1", Name: "bridge", Scope: "local"},
+			{ID: "n2", Name: "unused-net", Scope: "local"},
+		},
+	}
+}
+
+func newSystemRouter(client *mockSystemClient) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewSystemHandler(client, zap.NewNop())
+
+	router := gin.New()
+	router.GET("/system/df", handler.DiskUsage)
+	router.POST("/system/prune", handler.Prune)
+	return router
+}
+
+func TestSystemHandler_DiskUsage(t *testing.T) {
+	router := newSystemRouter(newMockSystemClient())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/system/df?verbose=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data DiskUsageReport `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	report := resp.Data
+
+	// 1000 bytes of layers minus the unshared parts of both used images
+	if report.Images.Size != 1000 || report.Images.Reclaimable != 300 || report.Images.Active != 2 {
+		t.Errorf("Unexpected images summary: %+v", report.Images)
+	}
+	if report.Containers.Size != 15 || report.Containers.Reclaimable != 10 || report.Containers.Active != 1 {
+		t.Errorf("Unexpected containers summary: %+v", report.Containers)
+	}
+	if report.Volumes.Size != 77 || report.Volumes.Reclaimable != 7 {
+		t.Errorf("Unexpected volumes summary: %+v", report.Volumes)
+	}
+	if report.BuildCache.Size != 100 || report.BuildCache.Reclaimable != 40 {
+		t.Errorf("Unexpected build cache summary: %+v", report.BuildCache)
+	}
+	if report.TotalReclaimable != 357 || len(report.Images.Items) != 3 {
+		t.Errorf("Unexpected totals: %d reclaimable, %d image items", report.TotalReclaimable, len(report.Images.Items))
+	}
+}
+
+func TestSystemHandler_PruneDryRun(t *testing.T) {
+	client := newMockSystemClient()
+	router := newSystemRouter(client)
+
+	body := `{"containers": true, "images": true, "volumes": true, "networks": true, "build_cache": true, "all": true, "dry_run": true}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/system/prune", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(client.pruned) != 0 {
+		t.Fatalf("Dry run must not prune, got %v", client.pruned)
+	}
+
+	var resp struct {
+		Data PruneReport `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	report := resp.Data
+
+	ids := func(items []PrunedItem) string {
+		var out []string
+		for _, item := range items {
+			out = append(out, item.ID)
+		}
+		return strings.Join(out, ",")
+	}
+	// The stopped container frees its image and volume
+	if got := ids(report.Containers); got != "c-stopped" {
+		t.Errorf("Unexpected containers: %s", got)
+	}
+	if got := ids(report.Images); got != "sha256:old,sha256:dangling" {
+		t.Errorf("Unexpected images: %s", got)
+	}
+	if got := ids(report.Volumes); got != "old-data,abc123" {
+		t.Errorf("Unexpected volumes: %s", got)
+	}
+	if got := ids(report.Networks); got != "n2" {
+		t.Errorf("Unexpected networks: %s", got)
+	}
+	if got := ids(report.BuildCache); got != "cache-1" {
+		t.Errorf("Unexpected build cache: %s", got)
+	}
+
+	// Without all, only dangling images and anonymous volumes go; label
+	// filters keep the labelled container
+	body = `{"containers": true, "images": true, "volumes": true, "labels": ["!team=legacy"], "dry_run": true}`
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/system/prune", strings.NewReader(body)))
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	report = resp.Data
+	if len(report.Containers) != 0 || ids(report.Images) != "sha256:dangling" || ids(report.Volumes) != "abc123" {
+		t.Errorf("Unexpected filtered dry run: %+v", report)
+	}
+}
+
+func TestSystemHandler_Prune(t *testing.T) {
+	client := newMockSystemClient()
+	router := newSystemRouter(client)
+
+	body := `{"containers": true, "images": true, "all": true, "labels": ["env=dev"], "until": "24h"}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/system/prune", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Join(client.pruned, ",") != "containers,images" {
+		t.Errorf("Unexpected prune order: %v", client.pruned)
+	}
+	if !client.imageFilters.ExactMatch("dangling", "false") || !client.imageFilters.ExactMatch("label", "env=dev") || !client.imageFilters.Contains("until") {
+		t.Errorf("Unexpected image prune filters: %+v", client.imageFilters)
+	}
+
+	var resp struct {
+		Data PruneReport `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Data.SpaceReclaimed != 110 || len(resp.Data.Images) != 1 {
+		t.Errorf("Unexpected prune report: %+v", resp.Data)
+	}
+}
+
+func TestSystemHandler_PruneValidation(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{"nothing selected", `{"dry_run": true}`},
+		{"until with volumes", `{"volumes": true, "until": "24h"}`},
+		{"labels with build cache", `{"build_cache": true, "labels": ["a=b"]}`},
+		{"invalid until", `{"images": true, "until": "yesterday"}`},
+		{"empty label", `{"images": true, "labels": ["!"]}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newMockSystemClient()
+			w := httptest.NewRecorder()
+			newSystemRouter(client).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/system/prune", strings.NewReader(tc.body)))
+			if w.Code != http.StatusBadRequest || len(client.pruned) != 0 {
+				t.Errorf("Expected 400 without pruning, got %d, pruned %v", w.Code, client.pruned)
+			}
+		})
+	}
+}
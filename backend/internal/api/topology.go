@@ -0,0 +1,251 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Topology node and edge types
+const (
+	TopologyNodeContainer = "container"
+	TopologyNodeNetwork   = "network"
+	TopologyNodePort      = "port"
+
+	TopologyEdgeAttachment = "attachment"
+	TopologyEdgePublish    = "publish"
+)
+
+// TopologyNode is a container, network or published host port. Only the
+// fields of the node's type are set.
+type TopologyNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+
+	// Container nodes
+	State string `json:"state,omitempty"`
+	Image string `json:"image,omitempty"`
+	Stack string `json:"stack,omitempty"`
+
+	// Network nodes
+	Driver   string   `json:"driver,omitempty"`
+	Internal bool     `json:"internal,omitempty"`
+	Subnets  []string `json:"subnets,omitempty"`
+
+	// Port nodes; HostIP is empty for ports published on all addresses
+	HostIP   string `json:"host_ip,omitempty"`
+	Port     uint16 `json:"port,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// TopologyEdge links a container to a network it is attached to, or to a
+// host port it publishes
+type TopologyEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+
+	// Attachment edges
+	IPv4Address string   `json:"ipv4_address,omitempty"`
+	IPv6Address string   `json:"ipv6_address,omitempty"`
+	MacAddress  string   `json:"mac_address,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+
+	// Publish edges
+	Port *Port `json:"port,omitempty"`
+}
+
+// Topology is returned by GET /api/topology
+type Topology struct {
+	Host  string         `json:"host"`
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+}
+
+// isWildcardIP reports whether a port is published on all host addresses
+func isWildcardIP(ip string) bool {
+	return ip == "" || ip == "0.0.0.0" || ip == "::"
+}
+
+// portNode returns the host port node a mapping publishes to. Docker lists
+// ports published on all addresses once per address family, so those share
+// a node.
+func portNode(port Port) TopologyNode {
+	node := TopologyNode{
+		Type:     TopologyNodePort,
+		Port:     port.PublicPort,
+		Protocol: port.Type,
+	}
+	portProto := strconv.Itoa(int(port.PublicPort)) + "/" + port.Type
+	if isWildcardIP(port.IP) {
+		node.ID = "port:*:" + portProto
+		node.Label = portProto
+		return node
+	}
+	node.HostIP = port.IP
+	node.ID = "port:" + net.JoinHostPort(port.IP, portProto)
+	node.Label = net.JoinHostPort(port.IP, strconv.Itoa(int(port.PublicPort))) + "/" + port.Type
+	return node
+}
+
+// buildTopology builds the graph of containers, their networks and their
+// published ports. Without onlyAttached every network is included, even
+// those without containers.
+func buildTopology(host string, containers []container.Summary, networks []network.Summary, onlyAttached bool) Topology {
+	topology := Topology{
+		Host:  host,
+		Nodes: []TopologyNode{},
+		Edges: []TopologyEdge{},
+	}
+
+	networkNodes := make(map[string]TopologyNode, len(networks))
+	for _, nw := range networks {
+		node := TopologyNode{
+			ID:       "network:" + nw.ID,
+			Type:     TopologyNodeNetwork,
+			Label:    nw.Name,
+			Driver:   nw.Driver,
+			Internal: nw.Internal,
+		}
+		for _, pool := range nw.IPAM.Config {
+			node.Subnets = append(node.Subnets, pool.Subnet)
+		}
+		networkNodes[nw.ID] = node
+	}
+
+	usedNetworks := make(map[string]bool)
+	portNodes := make(map[string]TopologyNode)
+	infos := toContainerInfos(containers, host)
+	for i, info := range infos {
+		ctr := &containers[i]
+		containerNode := TopologyNode{
+			ID:    "container:" + info.ID,
+			Type:  TopologyNodeContainer,
+			Label: info.Name,
+			State: info.State,
+			Image: info.Image,
+			Stack: info.Labels[composeProjectLabel],
+		}
+		topology.Nodes = append(topology.Nodes, containerNode)
+
+		if ctr.NetworkSettings != nil {
+			for _, endpoint := range ctr.NetworkSettings.Networks {
+				if endpoint == nil {
+					continue
+				}
+				networkNode, ok := networkNodes[endpoint.NetworkID]
+				if !ok {
+					continue
+				}
+				usedNetworks[endpoint.NetworkID] = true
+				topology.Edges = append(topology.Edges, TopologyEdge{
+					Source:      containerNode.ID,
+					Target:      networkNode.ID,
+					Type:        TopologyEdgeAttachment,
+					IPv4Address: endpoint.IPAddress,
+					IPv6Address: endpoint.GlobalIPv6Address,
+					MacAddress:  endpoint.MacAddress,
+					Aliases:     endpointAliases(endpoint),
+				})
+			}
+		}
+
+		published := make(map[string]bool)
+		for _, port := range info.Ports {
+			if port.PublicPort == 0 {
+				continue
+			}
+			node := portNode(port)
+			portNodes[node.ID] = node
+			// Skip the second address family of a wildcard mapping
+			edgeKey := node.ID + ">" + strconv.Itoa(int(port.PrivatePort))
+			if published[edgeKey] {
+				continue
+			}
+			published[edgeKey] = true
+			if isWildcardIP(port.IP) {
+				port.IP = ""
+			}
+			topology.Edges = append(topology.Edges, TopologyEdge{
+				Source: containerNode.ID,
+				Target: node.ID,
+				Type:   TopologyEdgePublish,
+				Port:   &port,
+			})
+		}
+	}
+
+	for id, node := range networkNodes {
+		if !onlyAttached || usedNetworks[id] {
+			topology.Nodes = append(topology.Nodes, node)
+		}
+	}
+	for _, node := range portNodes {
+		topology.Nodes = append(topology.Nodes, node)
+	}
+
+	sort.Slice(topology.Nodes, func(i, j int) bool {
+		a, b := topology.Nodes[i], topology.Nodes[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Label != b.Label {
+			return a.Label < b.Label
+		}
+		return a.ID < b.ID
+	})
+	sort.SliceStable(topology.Edges, func(i, j int) bool {
+		a, b := topology.Edges[i], topology.Edges[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Target < b.Target
+	})
+	return topology
+}
+
+// Topology handles GET /api/topology?stack=. With a stack, only the Compose
+// project's containers and the networks they use are included.
+func (h *NetworkHandler) Topology(c *gin.Context) {
+	stack := c.Query("stack")
+	if stack != "" && !resourceNamePattern.MatchString(stack) {
+		BadRequest(c, "Invalid stack name", stack)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	listOptions := container.ListOptions{All: true}
+	if stack != "" {
+		listOptions.Filters = filters.NewArgs(filters.Arg("label", composeProjectLabel+"="+stack))
+	}
+	containers, err := h.dockerClient.ContainerList(ctx, listOptions)
+	if err != nil {
+		h.logger.Error("Failed to list containers", zap.Error(err))
+		dockerError(c, "Failed to build topology", err)
+		return
+	}
+	networks, err := h.dockerClient.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		h.logger.Error("Failed to list networks", zap.Error(err))
+		dockerError(c, "Failed to build topology", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      buildTopology(h.host, readableContainers(c, containers), networks, stack != ""),
+		Timestamp: time.Now(),
+	})
+}
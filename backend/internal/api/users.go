@@ -0,0 +1,241 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// UserHandler handles user and API token management endpoints
+type UserHandler struct {
+	store interface {
+		Users() []auth.User
+		CreateUser(user auth.User) error
+		DeleteUser(name string) error
+		SetPassword(name, password string) error
+		CreateToken(userName, tokenName string) (string, error)
+		RevokeToken(userName, tokenName string) error
+	}
+	sessions interface {
+		RevokeUser(user string) error
+	}
+	logger *zap.Logger
+}
+
+// NewUserHandler creates a new user handler. Login sessions of a user are
+// ended when the user is deleted or their password changes.
+func NewUserHandler(store interface {
+	Users() []auth.User
+	CreateUser(user auth.User) error
+	DeleteUser(name string) error
+	SetPassword(name, password string) error
+	CreateToken(userName, tokenName string) (string, error)
+	RevokeToken(userName, tokenName string) error
+}, sessions interface {
+	RevokeUser(user string) error
+}, logger *zap.Logger) *UserHandler {
+	return &UserHandler{
+		store:    store,
+		sessions: sessions,
+		logger:   logger,
+	}
+}
+
+// CreateUserRequest is the body of POST /api/users
+type CreateUserRequest struct {
+	Name      string                     `json:"name" binding:"required"`
+	Role      auth.Role                  `json:"role" binding:"required"`
+	Selectors map[auth.Permission]string `json:"selectors,omitempty"`
+	Password  string                     `json:"password,omitempty"`
+}
+
+// SetPasswordRequest is the body of PUT /api/users/:name/password
+type SetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// CreateTokenRequest is the body of POST /api/users/:name/tokens
+type CreateTokenRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// CreatedToken is returned once when a token is issued
+type CreatedToken struct {
+	User  string `json:"user"`
+	Name  string `json:"name"`
+	Token string `json:"token"`
+}
+
+// WhoAmI handles GET /api/auth/whoami
+func (h *UserHandler) WhoAmI(c *gin.Context) {
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      middleware.GetPrincipal(c),
+		Timestamp: time.Now(),
+	})
+}
+
+// ListUsers handles GET /api/users
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	users := h.store.Users()
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      users,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total: len(users),
+		},
+	})
+}
+
+// CreateUser handles POST /api/users
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	user := auth.User{Name: req.Name, Role: req.Role, Selectors: req.Selectors}
+	if req.Password != "" {
+		hash, err := auth.HashPassword(req.Password)
+		if err != nil {
+			BadRequest(c, "Invalid password", err.Error())
+			return
+		}
+		user.PasswordHash = hash
+	}
+	if err := h.store.CreateUser(user); err != nil {
+		h.storeError(c, "Failed to create user", err)
+		return
+	}
+
+	h.logger.Info("User created",
+		zap.String("user", req.Name),
+		zap.String("role", string(req.Role)))
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success:   true,
+		Data:      user,
+		Timestamp: time.Now(),
+	})
+}
+
+// DeleteUser handles DELETE /api/users/:name
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.store.DeleteUser(name); err != nil {
+		h.storeError(c, "Failed to delete user", err)
+		return
+	}
+
+	h.revokeSessions(name)
+	h.logger.Info("User deleted", zap.String("user", name))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "User deleted successfully"},
+		Timestamp: time.Now(),
+	})
+}
+
+// SetPassword handles PUT /api/users/:name/password
+func (h *UserHandler) SetPassword(c *gin.Context) {
+	var req SetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	name := c.Param("name")
+	if err := h.store.SetPassword(name, req.Password); err != nil {
+		h.storeError(c, "Failed to set password", err)
+		return
+	}
+
+	h.revokeSessions(name)
+	h.logger.Info("User password changed", zap.String("user", name))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Password updated successfully"},
+		Timestamp: time.Now(),
+	})
+}
+
+// revokeSessions ends the login sessions of a user
+func (h *UserHandler) revokeSessions(name string) {
+	if h.sessions == nil {
+		return
+	}
+	if err := h.sessions.RevokeUser(name); err != nil {
+		h.logger.Warn("Failed to revoke sessions", zap.String("user", name), zap.Error(err))
+	}
+}
+
+// CreateToken handles POST /api/users/:name/tokens. The token secret is
+// only returned in this response.
+func (h *UserHandler) CreateToken(c *gin.Context) {
+	var req CreateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	name := c.Param("name")
+	secret, err := h.store.CreateToken(name, req.Name)
+	if err != nil {
+		h.storeError(c, "Failed to create token", err)
+		return
+	}
+
+	h.logger.Info("API token created",
+		zap.String("user", name),
+		zap.String("token", req.Name))
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success:   true,
+		Data:      CreatedToken{User: name, Name: req.Name, Token: secret},
+		Timestamp: time.Now(),
+	})
+}
+
+// RevokeToken handles DELETE /api/users/:name/tokens/:token
+func (h *UserHandler) RevokeToken(c *gin.Context) {
+	name := c.Param("name")
+	tokenName := c.Param("token")
+	if err := h.store.RevokeToken(name, tokenName); err != nil {
+		h.storeError(c, "Failed to revoke token", err)
+		return
+	}
+
+	h.logger.Info("API token revoked",
+		zap.String("user", name),
+		zap.String("token", tokenName))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Token revoked successfully"},
+		Timestamp: time.Now(),
+	})
+}
+
+// storeError maps store errors to responses
+func (h *UserHandler) storeError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
+		NotFound(c, message+": not found")
+	case errors.Is(err, auth.ErrExists):
+		ErrorResponse(c, http.StatusConflict, message+": already exists")
+	default:
+		h.logger.Warn(message, zap.Error(err))
+		BadRequest(c, message, err.Error())
+	}
+}
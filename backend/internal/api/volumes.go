@@ -0,0 +1,322 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// composeProjectLabel is set by Compose on the containers and volumes of a
+// project
+const composeProjectLabel = "com.docker.compose.project"
+
+// volumeSizeTimeout bounds reading volume sizes, which are left out when
+// disk usage takes longer so listings stay within the write timeout
+const volumeSizeTimeout = 10 * time.Second
+
+// VolumeHandler handles volume endpoints
+type VolumeHandler struct {
+	dockerClient interface {
+		VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error)
+		VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error)
+		VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error)
+		VolumeRemove(ctx context.Context, volumeID string, force bool) error
+		ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+		DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error)
+	}
+	logger *zap.Logger
+}
+
+// NewVolumeHandler creates a new volume handler
+func NewVolumeHandler(dockerClient interface {
+	VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error)
+	VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error)
+	VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error)
+	VolumeRemove(ctx context.Context, volumeID string, force bool) error
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error)
+}, logger *zap.Logger) *VolumeHandler {
+	return &VolumeHandler{
+		dockerClient: dockerClient,
+		logger:       logger,
+	}
+}
+
+// VolumeMountRef is a container mounting a volume
+type VolumeMountRef struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	Destination string `json:"destination"`
+	ReadOnly    bool   `json:"read_only"`
+}
+
+// VolumeInfo represents a volume in the API response. Size is omitted when
+// the driver does not report it.
+type VolumeInfo struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Mountpoint string            `json:"mountpoint"`
+	Scope      string            `json:"scope"`
+	Labels     map[string]string `json:"labels"`
+	Options    map[string]string `json:"options,omitempty"`
+	CreatedAt  string            `json:"created_at,omitempty"`
+	Size       *int64            `json:"size,omitempty"`
+	Containers []VolumeMountRef  `json:"containers"`
+	// Dangling volumes are not mounted by any container, running or not
+	Dangling bool `json:"dangling"`
+	// Orphaned volumes belong to a Compose project that has no containers
+	// left, typically after `docker compose down` without -v
+	Orphaned bool `json:"orphaned"`
+}
+
+// CreateVolumeRequest is the body of POST /api/volumes
+type CreateVolumeRequest struct {
+	Name       string            `json:"name,omitempty"`
+	Driver     string            `json:"driver,omitempty"`
+	DriverOpts map[string]string `json:"driver_opts,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// volumeUsage holds the container cross-reference and sizes used to
+// describe volumes
+type volumeUsage struct {
+	mounts   map[string][]VolumeMountRef
+	projects map[string]bool
+	sizes    map[string]int64
+}
+
+// loadVolumeUsage cross-references container mounts and reads volume sizes
+// from disk usage. Sizes are best effort since disk usage can be slow: they
+// are left out if it fails or takes longer than volumeSizeTimeout.
+func (h *VolumeHandler) loadVolumeUsage(ctx context.Context) (*volumeUsage, error) {
+	containers, err := h.dockerClient.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &volumeUsage{
+		mounts:   make(map[string][]VolumeMountRef),
+		projects: make(map[string]bool),
+		sizes:    make(map[string]int64),
+	}
+	for i := range containers {
+		ctr := &containers[i]
+		if project := ctr.Labels[composeProjectLabel]; project != "" {
+			usage.projects[project] = true
+		}
+		for _, mount := range ctr.Mounts {
+			if mount.Type != "volume" || mount.Name == "" {
+				continue
+			}
+			usage.mounts[mount.Name] = append(usage.mounts[mount.Name], VolumeMountRef{
+				ID:          ctr.ID,
+				Name:        containerName(ctr),
+				State:       string(ctr.State),
+				Destination: mount.Destination,
+				ReadOnly:    !mount.RW,
+			})
+		}
+	}
+
+	sizeCtx, cancel := context.WithTimeout(ctx, volumeSizeTimeout)
+	defer cancel()
+	du, err := h.dockerClient.DiskUsage(sizeCtx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		h.logger.Warn("Failed to get volume sizes", zap.Error(err))
+		return usage, nil
+	}
+	for _, vol := range du.Volumes {
+		if vol.UsageData != nil && vol.UsageData.Size >= 0 {
+			usage.sizes[vol.Name] = vol.UsageData.Size
+		}
+	}
+	return usage, nil
+}
+
+// describe converts a volume for the API response
+func (u *volumeUsage) describe(vol volume.Volume) VolumeInfo {
+	info := VolumeInfo{
+		Name:       vol.Name,
+		Driver:     vol.Driver,
+		Mountpoint: vol.Mountpoint,
+		Scope:      vol.Scope,
+		Labels:     vol.Labels,
+		Options:    vol.Options,
+		CreatedAt:  vol.CreatedAt,
+		Containers: u.mounts[vol.Name],
+	}
+	if info.Containers == nil {
+		info.Containers = []VolumeMountRef{}
+	}
+	if size, ok := u.sizes[vol.Name]; ok {
+		info.Size = &size
+	}
+	info.Dangling = len(info.Containers) == 0
+	if project := vol.Labels[composeProjectLabel]; project != "" {
+		info.Orphaned = info.Dangling && !u.projects[project]
+	}
+	return info
+}
+
+// ListVolumes handles GET /api/volumes?dangling=&orphaned=
+func (h *VolumeHandler) ListVolumes(c *gin.Context) {
+	onlyDangling, err := strconv.ParseBool(c.DefaultQuery("dangling", "false"))
+	if err != nil {
+		BadRequest(c, "Invalid 'dangling' parameter", err.Error())
+		return
+	}
+	onlyOrphaned, err := strconv.ParseBool(c.DefaultQuery("orphaned", "false"))
+	if err != nil {
+		BadRequest(c, "Invalid 'orphaned' parameter", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	list, err := h.dockerClient.VolumeList(ctx, volume.ListOptions{})
+	if err != nil {
+		h.logger.Error("Failed to list volumes", zap.Error(err))
+		dockerError(c, "Failed to list volumes", err)
+		return
+	}
+	usage, err := h.loadVolumeUsage(ctx)
+	if err != nil {
+		h.logger.Error("Failed to list volume containers", zap.Error(err))
+		dockerError(c, "Failed to list volumes", err)
+		return
+	}
+
+	volumes := make([]VolumeInfo, 0, len(list.Volumes))
+	for _, vol := range list.Volumes {
+		info := usage.describe(*vol)
+		if (onlyDangling && !info.Dangling) || (onlyOrphaned && !info.Orphaned) {
+			continue
+		}
+		volumes = append(volumes, info)
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      volumes,
+		Timestamp: time.Now(),
+		Meta: &Meta{
+			Total:    len(volumes),
+			Warnings: list.Warnings,
+		},
+	})
+}
+
+// GetVolume handles GET /api/volumes/:name
+func (h *VolumeHandler) GetVolume(c *gin.Context) {
+	name := c.Param("name")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	vol, err := h.dockerClient.VolumeInspect(ctx, name)
+	if err != nil {
+		dockerError(c, "Failed to inspect volume", err)
+		return
+	}
+	usage, err := h.loadVolumeUsage(ctx)
+	if err != nil {
+		h.logger.Error("Failed to list volume containers", zap.Error(err))
+		dockerError(c, "Failed to inspect volume", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      usage.describe(vol),
+		Timestamp: time.Now(),
+	})
+}
+
+// CreateVolume handles POST /api/volumes. Only admins may create local
+// volumes backed by a host path or device.
+func (h *VolumeHandler) CreateVolume(c *gin.Context) {
+	var req CreateVolumeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+	if req.Name != "" && !resourceNamePattern.MatchString(req.Name) {
+		BadRequest(c, "Invalid volume name", req.Name)
+		return
+	}
+	if req.Driver != "" && !resourceNamePattern.MatchString(req.Driver) {
+		BadRequest(c, "Invalid volume driver", req.Driver)
+		return
+	}
+	if mountsHostPath(req.Driver, req.DriverOpts) && !canAccessHost(c) {
+		Forbidden(c, "Only admins may create volumes backed by host paths")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	vol, err := h.dockerClient.VolumeCreate(ctx, volume.CreateOptions{
+		Name:       req.Name,
+		Driver:     req.Driver,
+		DriverOpts: req.DriverOpts,
+		Labels:     req.Labels,
+	})
+	if err != nil {
+		h.logger.Error("Failed to create volume", zap.String("name", req.Name), zap.Error(err))
+		dockerError(c, "Failed to create volume", err)
+		return
+	}
+	c.Set(middleware.AuditTargetKey, vol.Name)
+
+	h.logger.Info("Volume created",
+		zap.String("name", vol.Name),
+		zap.String("driver", vol.Driver))
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success:   true,
+		Data:      (&volumeUsage{}).describe(vol),
+		Timestamp: time.Now(),
+	})
+}
+
+// RemoveVolume handles DELETE /api/volumes/:name?force=. Docker refuses to
+// remove volumes that are in use.
+func (h *VolumeHandler) RemoveVolume(c *gin.Context) {
+	name := c.Param("name")
+	force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+	if err != nil {
+		BadRequest(c, "Invalid 'force' parameter", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := h.dockerClient.VolumeRemove(ctx, name, force); err != nil {
+		h.logger.Error("Failed to remove volume", zap.String("name", name), zap.Error(err))
+		dockerError(c, "Failed to remove volume", err)
+		return
+	}
+
+	h.logger.Info("Volume removed", zap.String("name", name), zap.Bool("force", force))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      gin.H{"message": "Volume removed successfully"},
+		Timestamp: time.Now(),
+	})
+}
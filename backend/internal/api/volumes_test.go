@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// mockVolumeClient serves fixed volumes and containers
+type mockVolumeClient struct {
+	volumes    []*volume.Volume
+	containers []container.Summary
+	created    *volume.CreateOptions
+	removeErr  error
+	usageErr   error
+	// usageDeadline is the deadline DiskUsage was called with
+	usageDeadline time.Time
+}
+
+func (m *mockVolumeClient) VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error) {
+	return volume.ListResponse{Volumes: m.volumes}, nil
+}
+
+func (m *mockVolumeClient) VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error) {
+	for _, vol := range m.volumes {
+		if vol.Name == volumeID {
+			return *vol, nil
+		}
+	}
+	return volume.Volume{}, cerrdefs.ErrNotFound
+}
+
+func (m *mockVolumeClient) VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error) {
+	m.created = &options
+	return volume.Volume{Name: options.Name, Driver: "local", Labels: options.Labels}, nil
+}
+
+func (m *mockVolumeClient) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	return m.removeErr
+}
+
+func (m *mockVolumeClient) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	return m.containers, nil
+}
+
+func (m *mockVolumeClient) DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error) {
+	m.usageDeadline, _ = ctx.Deadline()
+	if m.usageErr != nil {
+		return types.DiskUsage{}, m.usageErr
+	}
+	var usage types.DiskUsage
+	for _, vol := range m.volumes {
+		usage.Volumes = append(usage.Volumes, &volume.Volume{Name: vol.Name, UsageData: &volume.UsageData{Size: 1024}})
+	}
+	return usage, nil
+}
+
+func newVolumeRouter(client *mockVolumeClient) *gin.Engine {
+	return newVolumeRouterAs(client, auth.Anonymous)
+}
+
+func newVolumeRouterAs(client *mockVolumeClient, principal *auth.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewVolumeHandler(client, zap.NewNop())
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set(middleware.PrincipalKey, principal) })
+	router.GET("/volumes", handler.ListVolumes)
+	router.GET("/volumes/:name", handler.GetVolume)
+	router.POST("/volumes", handler.CreateVolume)
+	router.DELETE("/volumes/:name", handler.RemoveVolume)
+	return router
+}
+
+func TestVolumeHandler_ListVolumes(t *testing.T) {
+	client := &mockVolumeClient{
+		volumes: []*volume.Volume{
+			{Name: "web-data", Driver: "local"},
+			{Name: "shop_db", Driver: "local", Labels: map[string]string{composeProjectLabel: "shop"}},
+			{Name: "old_db", Driver: "local", Labels: map[string]string{composeProjectLabel: "old"}},
+			{Name: "scratch", Driver: "local"},
+		},
+		containers: []container.Summary{
+			{ID: "c1", Names: []string{"/web"}, State: container.StateRunning,
+				Mounts: []container.MountPoint{{Type: "volume", Name: "web-data", Destination: "/data", RW: false}}},
+			{ID: "c2", Names: []string{"/shop-api"}, State: container.StateExited,
+				Labels: map[string]string{composeProjectLabel: "shop"}},
+		},
+	}
+	router := newVolumeRouter(client)
+
+	list := func(query string) []VolumeInfo {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/volumes"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Data []VolumeInfo `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Data
+	}
+
+	volumes := list("")
+	if len(volumes) != 4 || volumes[3].Name != "web-data" {
+		t.Fatalf("Expected 4 sorted volumes, got %+v", volumes)
+	}
+	web := volumes[3]
+	if web.Dangling || len(web.Containers) != 1 || web.Containers[0].Name != "web" || !web.Containers[0].ReadOnly {
+		t.Errorf("Expected web-data to be mounted read-only by web, got %+v", web)
+	}
+	if web.Size == nil || *web.Size != 1024 {
+		t.Errorf("Expected size from disk usage, got %v", web.Size)
+	}
+
+	names := func(volumes []VolumeInfo) string {
+		var out []string
+		for _, vol := range volumes {
+			out = append(out, vol.Name)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := names(list("?dangling=true")); got != "old_db,scratch,shop_db" {
+		t.Errorf("Unexpected dangling volumes: %s", got)
+	}
+	// shop still has a container, so only the old project's volume is orphaned
+	if got := names(list("?orphaned=true")); got != "old_db" {
+		t.Errorf("Unexpected orphaned volumes: %s", got)
+	}
+}
+
+func TestVolumeHandler_SizesAreBestEffort(t *testing.T) {
+	client := &mockVolumeClient{
+		volumes:  []*volume.Volume{{Name: "web-data", Driver: "local"}},
+		usageErr: context.DeadlineExceeded,
+	}
+	router := newVolumeRouter(client)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/volumes/web-data", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 without sizes, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data VolumeInfo `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Data.Name != "web-data" || resp.Data.Size != nil {
+		t.Errorf("Expected the volume without a size, got %+v", resp.Data)
+	}
+	if client.usageDeadline.IsZero() || time.Until(client.usageDeadline) > volumeSizeTimeout {
+		t.Errorf("Expected disk usage to be bounded by %s, got deadline %v", volumeSizeTimeout, client.usageDeadline)
+	}
+}
+
+func TestVolumeHandler_CreateAndRemove(t *testing.T) {
+	client := &mockVolumeClient{}
+	router := newVolumeRouter(client)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/volumes", strings.NewReader(`{"name": "../etc"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid name to fail, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/volumes", strings.NewReader(`{"name": "cache", "labels": {"team": "web"}}`)))
+	if w.Code != http.StatusCreated || client.created == nil || client.created.Labels["team"] != "web" {
+		t.Errorf("Expected volume to be created, got %d: %s", w.Code, w.Body.String())
+	}
+
+	client.removeErr = cerrdefs.ErrConflict
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/volumes/cache", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected in-use volume removal to return 409, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/volumes/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown volume to return 404, got %d", w.Code)
+	}
+}
+
+func TestVolumeHandler_CreateHostPathVolume(t *testing.T) {
+	operator, _ := auth.NewPrincipal("ops", auth.RoleOperator, "t", nil)
+	bind := `{"name": "root", "driver_opts": {"type": "none", "o": "bind", "device": "/"}}`
+
+	client := &mockVolumeClient{}
+	w := httptest.NewRecorder()
+	newVolumeRouterAs(client, operator).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/volumes", strings.NewReader(bind)))
+	if w.Code != http.StatusForbidden || client.created != nil {
+		t.Errorf("Expected operator to be denied a host path volume, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	newVolumeRouterAs(client, operator).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/volumes",
+		strings.NewReader(`{"name": "scratch", "driver_opts": {"type": "tmpfs", "device": "tmpfs", "o": "size=100m"}}`)))
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected tmpfs volume to be created, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	newVolumeRouter(client).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/volumes", strings.NewReader(bind)))
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected admin to create a host path volume, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,320 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// currentFile is the file records are appended to
+	currentFile = "audit.log"
+
+	// rotatedPrefix and rotatedSuffix frame the timestamp of rotated files
+	rotatedPrefix = "audit-"
+	rotatedSuffix = ".log"
+
+	// rotatedTimeFormat sorts lexically in time order
+	rotatedTimeFormat = "20060102T150405.000000000"
+)
+
+// Outcome is the result of an audited action
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+	OutcomeDenied  Outcome = "denied"
+)
+
+// Record is a single audited action
+type Record struct {
+	Time          time.Time         `json:"time"`
+	Actor         string            `json:"actor"`
+	Role          string            `json:"role,omitempty"`
+	Token         string            `json:"token,omitempty"`
+	Session       string            `json:"session,omitempty"`
+	ClientIP      string            `json:"client_ip"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Action        string            `json:"action"`
+	Host          string            `json:"host,omitempty"`
+	Target        string            `json:"target,omitempty"`
+	Params        map[string]string `json:"params,omitempty"`
+	Body          any               `json:"body,omitempty"` // redacted JSON request body
+	Outcome       Outcome           `json:"outcome"`
+	Status        int               `json:"status"`
+	Error         string            `json:"error,omitempty"`
+	DurationMs    float64           `json:"duration_ms"`
+}
+
+// Filter selects records. Zero fields match everything. Action matches
+// exactly, or by prefix when it ends in "*" (e.g. "container.*").
+type Filter struct {
+	Actor  string
+	Action string
+	Target string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+// Matches reports whether a record passes the filter
+func (f Filter) Matches(r Record) bool {
+	if f.Actor != "" && r.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" {
+		if prefix, ok := strings.CutSuffix(f.Action, "*"); ok {
+			if !strings.HasPrefix(r.Action, prefix) {
+				return false
+			}
+		} else if r.Action != f.Action {
+			return false
+		}
+	}
+	if f.Target != "" && r.Target != f.Target && !strings.HasPrefix(r.Target, f.Target) {
+		return false
+	}
+	if !f.From.IsZero() && r.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !r.Time.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// Log is an append-only audit log of JSON lines. The current file is
+// rotated once it exceeds maxSize, and only the newest maxFiles rotated
+// files are kept. Rotated files are made read-only.
+type Log struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	now      func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Open opens or creates the audit log in dir
+func Open(dir string, maxSize int64, maxFiles int) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	l := &Log{dir: dir, maxSize: maxSize, maxFiles: maxFiles, now: time.Now}
+	if err := l.openCurrent(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// openCurrent opens the current file for appending. l.mu must be held (or
+// the log not yet shared).
+func (l *Log) openCurrent() error {
+	file, err := os.OpenFile(filepath.Join(l.dir, currentFile), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// Record appends a record. Each record is written with a single write so
+// concurrent readers never see interleaved lines.
+func (l *Log) Record(r Record) error {
+	if r.Time.IsZero() {
+		r.Time = l.now()
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return errors.New("audit log is closed")
+	}
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// rotate moves the current file aside and opens a new one. l.mu must be held.
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	l.file = nil
+
+	rotated := filepath.Join(l.dir, rotatedPrefix+l.now().UTC().Format(rotatedTimeFormat)+rotatedSuffix)
+	if err := os.Rename(filepath.Join(l.dir, currentFile), rotated); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	_ = os.Chmod(rotated, 0o400)
+
+	files, err := l.rotatedFiles()
+	if err == nil && len(files) > l.maxFiles {
+		for _, old := range files[:len(files)-l.maxFiles] {
+			_ = os.Remove(old)
+		}
+	}
+
+	return l.openCurrent()
+}
+
+// rotatedFiles returns the rotated files, oldest first
+func (l *Log) rotatedFiles() ([]string, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, rotatedPrefix) && strings.HasSuffix(name, rotatedSuffix) {
+			files = append(files, filepath.Join(l.dir, name))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// scan calls fn for every record matching the filter, oldest first. fn
+// receives the raw line alongside the decoded record; returning false stops
+// the scan.
+func (l *Log) scan(filter Filter, fn func(r Record, line []byte) bool) error {
+	l.mu.Lock()
+	files, err := l.rotatedFiles()
+	l.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to list audit files: %w", err)
+	}
+	files = append(files, filepath.Join(l.dir, currentFile))
+
+	for _, path := range files {
+		more, err := scanFile(path, filter, fn)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+func scanFile(path string, filter Filter, fn func(r Record, line []byte) bool) (bool, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Removed by a concurrent rotation
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// Skip a line still being written
+			continue
+		}
+		if filter.Matches(r) && !fn(r, scanner.Bytes()) {
+			return false, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read audit file: %w", err)
+	}
+	return true, nil
+}
+
+// Query returns the newest records matching the filter, newest first
+func (l *Log) Query(filter Filter) ([]Record, error) {
+	var records []Record
+	err := l.scan(filter, func(r Record, _ []byte) bool {
+		records = append(records, r)
+		// Only keep the newest Limit records while scanning
+		if filter.Limit > 0 && len(records) > 2*filter.Limit {
+			records = append(records[:0], records[len(records)-filter.Limit:]...)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[len(records)-filter.Limit:]
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// Export writes the records matching the filter to w as NDJSON, oldest
+// first. Limit caps the number of records written.
+func (l *Log) Export(w io.Writer, filter Filter) error {
+	written := 0
+	var writeErr error
+	err := l.scan(filter, func(_ Record, line []byte) bool {
+		if _, writeErr = w.Write(line); writeErr == nil {
+			_, writeErr = w.Write([]byte{'\n'})
+		}
+		if writeErr != nil {
+			return false
+		}
+		written++
+		return filter.Limit <= 0 || written < filter.Limit
+	})
+	if err != nil {
+		return err
+	}
+	return writeErr
+}
+
+// Close closes the current file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
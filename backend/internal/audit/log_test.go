@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLog_QueryFilters(t *testing.T) {
+	log, err := Open(t.TempDir(), 1<<20, 3)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer log.Close()
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: base, Actor: "alice", Action: "container.stop", Target: "abc123def456"},
+		{Time: base.Add(time.Minute), Actor: "bob", Action: "container.start", Target: "abc123def456"},
+		{Time: base.Add(2 * time.Minute), Actor: "alice", Action: "image.remove", Target: "sha256:ff"},
+	}
+	for _, r := range records {
+		if err := log.Record(r); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		filter  Filter
+		actions []string
+	}{
+		{"all newest first", Filter{}, []string{"image.remove", "container.start", "container.stop"}},
+		{"actor", Filter{Actor: "alice"}, []string{"image.remove", "container.stop"}},
+		{"action prefix", Filter{Action: "container.*"}, []string{"container.start", "container.stop"}},
+		{"exact action", Filter{Action: "container"}, nil},
+		{"target prefix", Filter{Target: "abc123"}, []string{"container.start", "container.stop"}},
+		{"time range", Filter{From: base.Add(time.Minute), To: base.Add(2 * time.Minute)}, []string{"container.start"}},
+		{"limit keeps newest", Filter{Limit: 1}, []string{"image.remove"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := log.Query(tc.filter)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(got) != len(tc.actions) {
+				t.Fatalf("Expected %d records, got %d", len(tc.actions), len(got))
+			}
+			for i, action := range tc.actions {
+				if got[i].Action != action {
+					t.Errorf("Record %d: expected %s, got %s", i, action, got[i].Action)
+				}
+			}
+		})
+	}
+}
+
+func TestLog_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	log, err := Open(dir, 512, 2)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tick := 0
+	log.now = func() time.Time {
+		tick++
+		return base.Add(time.Duration(tick) * time.Second)
+	}
+
+	for i := 0; i < 40; i++ {
+		if err := log.Record(Record{Actor: "alice", Action: "container.stop", Target: fmt.Sprintf("c%02d", i)}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	rotated, _ := log.rotatedFiles()
+	if len(rotated) != 2 {
+		t.Fatalf("Expected 2 rotated files to be kept, got %d", len(rotated))
+	}
+	if info, err := os.Stat(rotated[0]); err != nil || info.Mode().Perm()&0o200 != 0 {
+		t.Errorf("Expected rotated files to be read-only")
+	}
+
+	// Queries span rotated files and return the newest record first
+	records, err := log.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(records) == 0 || len(records) == 40 || records[0].Target != "c39" {
+		t.Errorf("Expected pruned history ending at c39, got %d records", len(records))
+	}
+
+	// Reopening appends to the current file
+	log.Close()
+	reopened, err := Open(dir, 512, 2)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer reopened.Close()
+	if err := reopened.Record(Record{Actor: "bob", Action: "image.remove"}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	latest, _ := reopened.Query(Filter{Limit: 1})
+	if len(latest) != 1 || latest[0].Actor != "bob" {
+		t.Errorf("Expected appended record, got %+v", latest)
+	}
+}
+
+func TestLog_Export(t *testing.T) {
+	log, err := Open(t.TempDir(), 1<<20, 3)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer log.Close()
+
+	for i := 0; i < 5; i++ {
+		action := "container.stop"
+		if i%2 == 1 {
+			action = "image.remove"
+		}
+		log.Record(Record{Actor: "alice", Action: action, Target: fmt.Sprintf("t%d", i)})
+	}
+
+	var buf bytes.Buffer
+	if err := log.Export(&buf, Filter{Action: "container.stop"}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	var targets []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("Export line is not JSON: %q", scanner.Text())
+		}
+		targets = append(targets, r.Target)
+	}
+	if fmt.Sprint(targets) != "[t0 t2 t4]" {
+		t.Errorf("Expected oldest-first matching records, got %v", targets)
+	}
+
+	if _, err := os.Stat(filepath.Join(log.dir, currentFile)); err != nil {
+		t.Errorf("Expected current audit file: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// TicketPrefix marks WebSocket tickets
+const TicketPrefix = "kvt_"
+
+// ticket is a single-use WebSocket credential
+type ticket struct {
+	principal *Principal
+	expiresAt time.Time
+}
+
+// Authenticator resolves bearer tokens to principals. JWT access tokens are
+// verified against the session manager and mapped to the user's current
+// role; anything else is treated as an API token. It also issues short
+// lived single-use tickets for browser WebSocket upgrades, which cannot
+// carry an Authorization header.
+type Authenticator struct {
+	store     *Store
+	sessions  *SessionManager
+	ticketTTL time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	tickets map[string]ticket // keyed by ticket hash
+}
+
+// NewAuthenticator creates an authenticator. sessions may be nil to accept
+// API tokens only.
+func NewAuthenticator(store *Store, sessions *SessionManager, ticketTTL time.Duration) *Authenticator {
+	return &Authenticator{
+		store:     store,
+		sessions:  sessions,
+		ticketTTL: ticketTTL,
+		now:       time.Now,
+		tickets:   make(map[string]ticket),
+	}
+}
+
+// Authenticate resolves a token to its principal
+func (a *Authenticator) Authenticate(token string) (*Principal, error) {
+	if !looksLikeJWT(token) {
+		return a.store.Authenticate(token)
+	}
+	if a.sessions == nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims, err := a.sessions.VerifyAccess(token)
+	if err != nil {
+		return nil, err
+	}
+	principal, err := a.store.Lookup(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	principal.SessionID = claims.SessionID
+	return principal, nil
+}
+
+// IssueTicket returns a single-use ticket that authenticates one WebSocket
+// upgrade as principal
+func (a *Authenticator) IssueTicket(principal *Principal) (string, time.Time, error) {
+	secret, err := randomID(24)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	for hash, t := range a.tickets {
+		if !now.Before(t.expiresAt) {
+			delete(a.tickets, hash)
+		}
+	}
+
+	expiresAt := now.Add(a.ticketTTL)
+	a.tickets[HashToken(secret)] = ticket{principal: principal, expiresAt: expiresAt}
+	return TicketPrefix + secret, expiresAt, nil
+}
+
+// RedeemTicket consumes a ticket and returns the principal it was issued to
+func (a *Authenticator) RedeemTicket(value string) (*Principal, error) {
+	secret, ok := strings.CutPrefix(value, TicketPrefix)
+	if !ok || secret == "" {
+		return nil, ErrInvalidToken
+	}
+	hash := HashToken(secret)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	t, ok := a.tickets[hash]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	delete(a.tickets, hash)
+	if !a.now().Before(t.expiresAt) {
+		return nil, ErrInvalidToken
+	}
+	return t.principal, nil
+}
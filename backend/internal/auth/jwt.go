@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// jwtIssuer is the iss claim of every token KubeVision issues
+	jwtIssuer = "kubevision"
+
+	// clockSkew is the leeway allowed when checking exp, nbf and iat
+	clockSkew = 30 * time.Second
+)
+
+var (
+	// ErrTokenExpired is returned for JWTs past their expiry
+	ErrTokenExpired = errors.New("token expired")
+
+	jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+)
+
+// Claims are the claims of a KubeVision access token
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	ID        string `json:"jti"`
+	Type      string `json:"typ"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Signer signs and verifies HS256 JWTs
+type Signer struct {
+	key []byte
+	now func() time.Time
+}
+
+// NewSigner creates a signer from a secret key
+func NewSigner(key []byte) (*Signer, error) {
+	if len(key) < 32 {
+		return nil, fmt.Errorf("signing key must be at least 32 bytes")
+	}
+	return &Signer{key: key, now: time.Now}, nil
+}
+
+// LoadOrCreateKey returns secret if set, otherwise the key stored at path,
+// generating and saving a random key on first use so issued tokens stay
+// valid across restarts
+func LoadOrCreateKey(secret, path string) ([]byte, error) {
+	if secret != "" {
+		return []byte(secret), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing key file: %w", err)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create signing key directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return key, nil
+}
+
+// Sign returns a compact JWT for the claims
+func (s *Signer) Sign(claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + s.signature(unsigned), nil
+}
+
+func (s *Signer) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a JWT's signature, algorithm, issuer, type and validity
+// window and returns its claims
+func (s *Signer) Verify(token, tokenType string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	if !hmac.Equal([]byte(parts[2]), []byte(s.signature(parts[0]+"."+parts[1]))) {
+		return nil, ErrInvalidToken
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Issuer != jwtIssuer || claims.Type != tokenType || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+
+	now := s.now()
+	if now.Add(-clockSkew).Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	if now.Add(clockSkew).Unix() < claims.NotBefore || now.Add(clockSkew).Unix() < claims.IssuedAt {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+// looksLikeJWT reports whether a bearer token is a JWT rather than an API token
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && !strings.HasPrefix(token, TokenPrefix)
+}
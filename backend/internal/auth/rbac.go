@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/kubevision/kubevision/internal/utils"
+)
+
+// Permission is a single action a principal may perform
+type Permission string
+
+const (
+	PermContainersRead    Permission = "containers:read"
+	PermContainersControl Permission = "containers:control"
+	PermImagesRead        Permission = "images:read"
+	PermImagesDelete      Permission = "images:delete"
+	PermImagesPull        Permission = "images:pull"
+	PermLogsRead          Permission = "logs:read"
+	PermExec              Permission = "exec"
+	PermAlertsRead        Permission = "alerts:read"
+	PermUsersManage       Permission = "users:manage"
+	PermAuditRead         Permission = "audit:read"
+	PermRegistriesManage  Permission = "registries:manage"
+	PermSystemPrune       Permission = "system:prune"
+	PermVolumesRead       Permission = "volumes:read"
+	PermVolumesCreate     Permission = "volumes:create"
+	PermVolumesDelete     Permission = "volumes:delete"
+	PermNetworksRead      Permission = "networks:read"
+	PermNetworksCreate    Permission = "networks:create"
+	PermNetworksDelete    Permission = "networks:delete"
+)
+
+// Role is a named set of permissions
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+// rolePermissions lists the permissions granted by each role
+var rolePermissions = map[Role][]Permission{
+	RoleViewer: {
+		PermContainersRead,
+		PermImagesRead,
+		PermLogsRead,
+		PermAlertsRead,
+		PermVolumesRead,
+		PermNetworksRead,
+	},
+	RoleOperator: {
+		PermContainersRead,
+		PermImagesRead,
+		PermLogsRead,
+		PermAlertsRead,
+		PermVolumesRead,
+		PermNetworksRead,
+		PermContainersControl,
+		PermExec,
+		PermImagesPull,
+		PermVolumesCreate,
+		PermNetworksCreate,
+	},
+	RoleAdmin: {
+		PermContainersRead,
+		PermImagesRead,
+		PermLogsRead,
+		PermAlertsRead,
+		PermVolumesRead,
+		PermNetworksRead,
+		PermContainersControl,
+		PermExec,
+		PermImagesPull,
+		PermVolumesCreate,
+		PermNetworksCreate,
+		PermImagesDelete,
+		PermVolumesDelete,
+		PermNetworksDelete,
+		PermUsersManage,
+		PermAuditRead,
+		PermRegistriesManage,
+		PermSystemPrune,
+	},
+}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role Role) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// ValidPermission reports whether perm is a known permission
+func ValidPermission(perm Permission) bool {
+	for _, p := range rolePermissions[RoleAdmin] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal is an authenticated user acting through a token
+type Principal struct {
+	User      string `json:"user"`
+	Role      Role   `json:"role"`
+	TokenName string `json:"token,omitempty"`
+	SessionID string `json:"session,omitempty"`
+
+	permissions map[Permission]bool
+	selectors   map[Permission]utils.LabelSelector
+}
+
+// NewPrincipal builds a principal for a user. selectors restrict
+// container-scoped permissions to containers whose labels match.
+func NewPrincipal(user string, role Role, tokenName string, selectors map[Permission]string) (*Principal, error) {
+	perms, ok := rolePermissions[role]
+	if !ok {
+		return nil, fmt.Errorf("unknown role %q", role)
+	}
+
+	p := &Principal{
+		User:        user,
+		Role:        role,
+		TokenName:   tokenName,
+		permissions: make(map[Permission]bool, len(perms)),
+		selectors:   make(map[Permission]utils.LabelSelector, len(selectors)),
+	}
+	for _, perm := range perms {
+		p.permissions[perm] = true
+	}
+	for perm, raw := range selectors {
+		if !ValidPermission(perm) {
+			return nil, fmt.Errorf("unknown permission %q", perm)
+		}
+		selector, err := utils.ParseLabelSelector(raw)
+		if err != nil {
+			return nil, fmt.Errorf("permission %s: %w", perm, err)
+		}
+		if !selector.Empty() {
+			p.selectors[perm] = selector
+		}
+	}
+	return p, nil
+}
+
+// Anonymous is the principal used when authentication is disabled
+var Anonymous, _ = NewPrincipal("anonymous", RoleAdmin, "", nil)
+
+// Can reports whether the principal holds a permission at all, possibly
+// restricted by a selector
+func (p *Principal) Can(perm Permission) bool {
+	return p.permissions[perm]
+}
+
+// Restricted returns the selector limiting a permission to some containers
+func (p *Principal) Restricted(perm Permission) (utils.LabelSelector, bool) {
+	selector, ok := p.selectors[perm]
+	return selector, ok
+}
+
+// CanOn reports whether the principal may perform perm on a container with
+// the given labels
+func (p *Principal) CanOn(perm Permission, labels map[string]string) bool {
+	if !p.Can(perm) {
+		return false
+	}
+	if selector, ok := p.selectors[perm]; ok {
+		return selector.Matches(labels)
+	}
+	return true
+}
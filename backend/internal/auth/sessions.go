@@ -0,0 +1,359 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RefreshTokenPrefix marks refresh tokens
+	RefreshTokenPrefix = "kvr_"
+
+	// accessTokenType is the typ claim of access tokens
+	accessTokenType = "access"
+
+	// maxUsedRefreshHashes bounds the rotated refresh tokens remembered per
+	// session for reuse detection
+	maxUsedRefreshHashes = 32
+)
+
+var (
+	// ErrSessionRevoked is returned for tokens of a logged out or expired session
+	ErrSessionRevoked = errors.New("session revoked")
+	// ErrRefreshReused is returned when an already rotated refresh token is
+	// presented again. The whole session is revoked.
+	ErrRefreshReused = errors.New("refresh token reused")
+)
+
+// Session is a login session. Its refresh token rotates on every use.
+type Session struct {
+	ID          string    `json:"id"`
+	User        string    `json:"user"`
+	RefreshHash string    `json:"refresh_hash"`
+	UsedHashes  []string  `json:"used_hashes,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// TokenPair is returned on login and refresh
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresIn        int       `json:"expires_in"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	User             string    `json:"user"`
+}
+
+// sessionsFile is the on-disk layout of the sessions file
+type sessionsFile struct {
+	Sessions map[string]*Session `json:"sessions"`
+}
+
+// SessionManager issues access and refresh tokens and keeps sessions in a
+// JSON file so that logouts survive restarts. An access token is only
+// valid while its session exists, so revoking a session also invalidates
+// the access tokens issued for it.
+type SessionManager struct {
+	path       string
+	signer     *Signer
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewSessionManager loads the sessions file at path, creating an empty
+// manager if it does not exist
+func NewSessionManager(path string, signer *Signer, accessTTL, refreshTTL time.Duration) (*SessionManager, error) {
+	m := &SessionManager{
+		path:       path,
+		signer:     signer,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		now:        time.Now,
+		sessions:   make(map[string]*Session),
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read sessions file: %w", err)
+	default:
+		var file sessionsFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse sessions file: %w", err)
+		}
+		if file.Sessions != nil {
+			m.sessions = file.Sessions
+		}
+	}
+
+	m.pruneLocked()
+	return m, nil
+}
+
+// randomID returns n random bytes hex encoded
+func randomID(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// save writes the sessions file atomically. m.mu must be held.
+func (m *SessionManager) save() error {
+	data, err := json.Marshal(sessionsFile{Sessions: m.sessions})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write sessions file: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to replace sessions file: %w", err)
+	}
+	return nil
+}
+
+// pruneLocked drops expired sessions and reports whether any were removed.
+// m.mu must be held (or the manager not yet shared).
+func (m *SessionManager) pruneLocked() bool {
+	now := m.now()
+	pruned := false
+	for id, session := range m.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(m.sessions, id)
+			pruned = true
+		}
+	}
+	return pruned
+}
+
+// issueLocked rotates the session's refresh token and signs a new access
+// token. m.mu must be held.
+func (m *SessionManager) issueLocked(session *Session) (*TokenPair, error) {
+	secret, err := randomID(32)
+	if err != nil {
+		return nil, err
+	}
+	jti, err := randomID(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	if session.RefreshHash != "" {
+		session.UsedHashes = append(session.UsedHashes, session.RefreshHash)
+		if len(session.UsedHashes) > maxUsedRefreshHashes {
+			session.UsedHashes = session.UsedHashes[len(session.UsedHashes)-maxUsedRefreshHashes:]
+		}
+	}
+	session.RefreshHash = HashToken(secret)
+	session.RefreshedAt = now
+	session.ExpiresAt = now.Add(m.refreshTTL)
+
+	access, err := m.signer.Sign(Claims{
+		Issuer:    jwtIssuer,
+		Subject:   session.User,
+		SessionID: session.ID,
+		ID:        jti,
+		Type:      accessTokenType,
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(m.accessTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(m.accessTTL.Seconds()),
+		RefreshToken:     RefreshTokenPrefix + session.ID + "." + secret,
+		RefreshExpiresAt: session.ExpiresAt,
+		User:             session.User,
+	}, nil
+}
+
+// Login starts a new session for a user
+func (m *SessionManager) Login(user string) (*TokenPair, error) {
+	id, err := randomID(16)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pruneLocked()
+	session := &Session{ID: id, User: user, CreatedAt: m.now()}
+	pair, err := m.issueLocked(session)
+	if err != nil {
+		return nil, err
+	}
+	m.sessions[id] = session
+	if err := m.save(); err != nil {
+		delete(m.sessions, id)
+		return nil, err
+	}
+	return pair, nil
+}
+
+// parseRefreshToken splits a refresh token into session ID and secret
+func parseRefreshToken(token string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(token, RefreshTokenPrefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(rest, ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// Refresh exchanges a refresh token for a new token pair. Presenting a
+// refresh token that was already rotated revokes the session, since either
+// the client or an attacker holds a stolen copy.
+func (m *SessionManager) Refresh(refreshToken string) (*TokenPair, error) {
+	id, secret, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok || !m.now().Before(session.ExpiresAt) {
+		return nil, ErrSessionRevoked
+	}
+
+	hash := HashToken(secret)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(session.RefreshHash)) != 1 {
+		for _, used := range session.UsedHashes {
+			if subtle.ConstantTimeCompare([]byte(hash), []byte(used)) == 1 {
+				delete(m.sessions, id)
+				if err := m.save(); err != nil {
+					return nil, err
+				}
+				return nil, ErrRefreshReused
+			}
+		}
+		return nil, ErrInvalidToken
+	}
+
+	previous := *session
+	previous.UsedHashes = append([]string(nil), session.UsedHashes...)
+	pair, err := m.issueLocked(session)
+	if err != nil {
+		*session = previous
+		return nil, err
+	}
+	if err := m.save(); err != nil {
+		*session = previous
+		return nil, err
+	}
+	return pair, nil
+}
+
+// VerifyAccess validates an access token and checks that its session is
+// still active
+func (m *SessionManager) VerifyAccess(token string) (*Claims, error) {
+	claims, err := m.signer.Verify(token, accessTokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[claims.SessionID]
+	if !ok || session.User != claims.Subject || !m.now().Before(session.ExpiresAt) {
+		return nil, ErrSessionRevoked
+	}
+	return claims, nil
+}
+
+// Revoke ends a session
+func (m *SessionManager) Revoke(sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[sessionID]; !ok {
+		return ErrNotFound
+	}
+	delete(m.sessions, sessionID)
+	return m.save()
+}
+
+// RevokeRefresh ends the session a refresh token belongs to. Rotated
+// refresh tokens of the session are accepted too, since presenting one to
+// Refresh would revoke the session anyway.
+func (m *SessionManager) RevokeRefresh(refreshToken string) error {
+	id, secret, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return ErrInvalidToken
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	hash := HashToken(secret)
+	valid := subtle.ConstantTimeCompare([]byte(hash), []byte(session.RefreshHash)) == 1
+	for _, used := range session.UsedHashes {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(used)) == 1 {
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrInvalidToken
+	}
+
+	delete(m.sessions, id)
+	return m.save()
+}
+
+// RevokeUser ends all sessions of a user
+func (m *SessionManager) RevokeUser(user string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revoked := false
+	for id, session := range m.sessions {
+		if session.User == user {
+			delete(m.sessions, id)
+			revoked = true
+		}
+	}
+	if !revoked {
+		return nil
+	}
+	return m.save()
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSessions(t *testing.T, path string) (*SessionManager, *Signer) {
+	t.Helper()
+	signer, err := NewSigner([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatalf("NewSigner failed: %v", err)
+	}
+	sessions, err := NewSessionManager(path, signer, 15*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("NewSessionManager failed: %v", err)
+	}
+	return sessions, signer
+}
+
+func TestSigner_Verify(t *testing.T) {
+	signer, _ := NewSigner([]byte(strings.Repeat("k", 32)))
+	other, _ := NewSigner([]byte(strings.Repeat("o", 32)))
+	now := time.Now()
+	claims := Claims{
+		Issuer:    jwtIssuer,
+		Subject:   "alice",
+		SessionID: "s1",
+		Type:      accessTokenType,
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+	}
+	token, err := signer.Sign(claims)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	if got, err := signer.Verify(token, accessTokenType); err != nil || got.Subject != "alice" {
+		t.Fatalf("Expected valid token, got %+v, %v", got, err)
+	}
+	if _, err := other.Verify(token, accessTokenType); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected signature mismatch, got %v", err)
+	}
+	if _, err := signer.Verify(token, "refresh"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected wrong type to fail, got %v", err)
+	}
+
+	// Tampering with the payload invalidates the signature
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + parts[1][:len(parts[1])-2] + "x." + parts[2]
+	if _, err := signer.Verify(forged, accessTokenType); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected tampered token to fail, got %v", err)
+	}
+
+	claims.ExpiresAt = now.Add(-time.Minute).Unix()
+	expired, _ := signer.Sign(claims)
+	if _, err := signer.Verify(expired, accessTokenType); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Expected expired token, got %v", err)
+	}
+}
+
+func TestSessionManager_RefreshRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	sessions, _ := newTestSessions(t, path)
+
+	first, err := sessions.Login("alice")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if _, err := sessions.VerifyAccess(first.AccessToken); err != nil {
+		t.Fatalf("Expected access token to verify: %v", err)
+	}
+
+	second, err := sessions.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("Expected refresh token to rotate")
+	}
+
+	// Reusing the rotated token revokes the whole session
+	if _, err := sessions.Refresh(first.RefreshToken); !errors.Is(err, ErrRefreshReused) {
+		t.Fatalf("Expected reuse detection, got %v", err)
+	}
+	if _, err := sessions.Refresh(second.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected session to be revoked, got %v", err)
+	}
+	if _, err := sessions.VerifyAccess(second.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected access token of revoked session to fail, got %v", err)
+	}
+}
+
+func TestSessionManager_LogoutSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	sessions, signer := newTestSessions(t, path)
+
+	kept, _ := sessions.Login("alice")
+	revoked, _ := sessions.Login("alice")
+	claims, _ := sessions.VerifyAccess(revoked.AccessToken)
+	if err := sessions.Revoke(claims.SessionID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+
+	reopened, err := NewSessionManager(path, signer, 15*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("NewSessionManager failed: %v", err)
+	}
+	if _, err := reopened.VerifyAccess(kept.AccessToken); err != nil {
+		t.Errorf("Expected kept session to survive restart: %v", err)
+	}
+	if _, err := reopened.VerifyAccess(revoked.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected logout to survive restart, got %v", err)
+	}
+	if _, err := reopened.Refresh(revoked.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected revoked refresh token to fail, got %v", err)
+	}
+}
+
+func TestSessionManager_RevokeRefreshChecksSecret(t *testing.T) {
+	sessions, _ := newTestSessions(t, filepath.Join(t.TempDir(), "sessions.json"))
+
+	pair, _ := sessions.Login("alice")
+	id, _, _ := parseRefreshToken(pair.RefreshToken)
+	if err := sessions.RevokeRefresh(RefreshTokenPrefix + id + ".guessed"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Expected a wrong secret to be rejected, got %v", err)
+	}
+	if _, err := sessions.VerifyAccess(pair.AccessToken); err != nil {
+		t.Fatalf("Expected session to survive a wrong secret: %v", err)
+	}
+
+	if err := sessions.RevokeRefresh(pair.RefreshToken); err != nil {
+		t.Fatalf("RevokeRefresh failed: %v", err)
+	}
+	if _, err := sessions.VerifyAccess(pair.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected session to be revoked, got %v", err)
+	}
+}
+
+func TestAuthenticator_JWTAndTickets(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenStore(filepath.Join(dir, "users.yaml"), "")
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if err := store.CreateUser(User{Name: "alice", Role: RoleViewer, PasswordHash: hash}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if _, err := store.VerifyPassword("alice", "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected wrong password to fail, got %v", err)
+	}
+	if _, err := store.VerifyPassword("bob", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected unknown user to fail, got %v", err)
+	}
+	if _, err := store.VerifyPassword("alice", "correct horse"); err != nil {
+		t.Fatalf("VerifyPassword failed: %v", err)
+	}
+
+	sessions, _ := newTestSessions(t, filepath.Join(dir, "sessions.json"))
+	authenticator := NewAuthenticator(store, sessions, 30*time.Second)
+
+	pair, _ := sessions.Login("alice")
+	principal, err := authenticator.Authenticate(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if principal.User != "alice" || principal.Role != RoleViewer || principal.SessionID == "" {
+		t.Errorf("Unexpected principal: %+v", principal)
+	}
+
+	ticket, _, err := authenticator.IssueTicket(principal)
+	if err != nil {
+		t.Fatalf("IssueTicket failed: %v", err)
+	}
+	if got, err := authenticator.RedeemTicket(ticket); err != nil || got.User != "alice" {
+		t.Fatalf("Expected ticket to redeem, got %+v, %v", got, err)
+	}
+	if _, err := authenticator.RedeemTicket(ticket); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ticket to be single-use, got %v", err)
+	}
+
+	// Deleted users lose access even with a valid token
+	if err := store.DeleteUser("alice"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if _, err := authenticator.Authenticate(pair.AccessToken); err == nil {
+		t.Error("Expected deleted user's token to fail")
+	}
+}
@@ -0,0 +1,378 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// TokenPrefix marks KubeVision API tokens
+const TokenPrefix = "kv_"
+
+var (
+	// ErrInvalidToken is returned for unknown or malformed tokens
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrNotFound is returned when a user or token does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrExists is returned when creating a user or token that already exists
+	ErrExists = errors.New("already exists")
+	// ErrInvalidCredentials is returned for a wrong user name or password
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// minPasswordLength is the shortest accepted password
+const minPasswordLength = 8
+
+// dummyPasswordHash is compared against for unknown users so that login
+// takes the same time whether or not the user exists
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("kubevision-dummy"), bcrypt.DefaultCost)
+
+// namePattern restricts user and token names
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_.@-]{1,64}$`)
+
+// User is a user entry of the users file
+type User struct {
+	Name         string                `yaml:"name" json:"name"`
+	Role         Role                  `yaml:"role" json:"role"`
+	Selectors    map[Permission]string `yaml:"selectors,omitempty" json:"selectors,omitempty"`
+	PasswordHash string                `yaml:"password_hash,omitempty" json:"-"`
+	Tokens       []Token               `yaml:"tokens,omitempty" json:"tokens"`
+}
+
+// Token is an API token. Only the SHA-256 hash of the secret is stored.
+type Token struct {
+	Name      string    `yaml:"name" json:"name"`
+	Hash      string    `yaml:"hash" json:"-"`
+	CreatedAt time.Time `yaml:"created_at" json:"created_at"`
+}
+
+// usersFile is the on-disk layout of the users file
+type usersFile struct {
+	Users []User `yaml:"users"`
+}
+
+// Store holds users and hashed tokens in a YAML file
+type Store struct {
+	path    string
+	mu      sync.RWMutex
+	users   []User
+	byToken map[string]*Principal // keyed by token hash
+	legacy  map[string]*Principal // AUTH_TOKEN, never persisted
+}
+
+// OpenStore loads the users file at path, creating an empty store if it
+// does not exist. A non-empty legacyToken is accepted as an admin token so
+// existing AUTH_TOKEN deployments keep working.
+func OpenStore(path, legacyToken string) (*Store, error) {
+	s := &Store{path: path, legacy: make(map[string]*Principal)}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read users file: %w", err)
+	default:
+		var file usersFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse users file: %w", err)
+		}
+		s.users = file.Users
+	}
+
+	if err := s.reindex(); err != nil {
+		return nil, err
+	}
+
+	if legacyToken != "" {
+		principal, _ := NewPrincipal("admin", RoleAdmin, "AUTH_TOKEN", nil)
+		s.legacy[HashToken(legacyToken)] = principal
+	}
+
+	return s, nil
+}
+
+// HashToken returns the stored form of a token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashPassword returns the bcrypt hash of a password
+func HashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// generateToken returns a new random token
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return TokenPrefix + hex.EncodeToString(buf), nil
+}
+
+// validateUser checks a user entry
+func validateUser(user User) error {
+	if !namePattern.MatchString(user.Name) {
+		return fmt.Errorf("invalid user name %q", user.Name)
+	}
+	if !ValidRole(user.Role) {
+		return fmt.Errorf("user %s: unknown role %q", user.Name, user.Role)
+	}
+	_, err := NewPrincipal(user.Name, user.Role, "", user.Selectors)
+	if err != nil {
+		return fmt.Errorf("user %s: %w", user.Name, err)
+	}
+	return nil
+}
+
+// reindex validates users and rebuilds the token index. s.mu must be held
+// for writing (or the store not yet shared).
+func (s *Store) reindex() error {
+	byToken := make(map[string]*Principal)
+	seen := make(map[string]bool)
+	for _, user := range s.users {
+		if err := validateUser(user); err != nil {
+			return err
+		}
+		if seen[user.Name] {
+			return fmt.Errorf("duplicate user %q", user.Name)
+		}
+		seen[user.Name] = true
+
+		for _, token := range user.Tokens {
+			principal, _ := NewPrincipal(user.Name, user.Role, token.Name, user.Selectors)
+			byToken[token.Hash] = principal
+		}
+	}
+	s.byToken = byToken
+	return nil
+}
+
+// save writes the users file atomically
+func (s *Store) save() error {
+	data, err := yaml.Marshal(usersFile{Users: s.users})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create users directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write users file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace users file: %w", err)
+	}
+	return nil
+}
+
+// Authenticate resolves a token to its principal
+func (s *Store) Authenticate(token string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash := HashToken(token)
+	if principal, ok := s.byToken[hash]; ok {
+		return principal, nil
+	}
+	if principal, ok := s.legacy[hash]; ok {
+		return principal, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+// VerifyPassword checks a user's password and returns their principal
+func (s *Store) VerifyPassword(name, password string) (*Principal, error) {
+	s.mu.RLock()
+	hash := dummyPasswordHash
+	var user *User
+	if i := findUser(s.users, name); i >= 0 && s.users[i].PasswordHash != "" {
+		user = &s.users[i]
+		hash = []byte(user.PasswordHash)
+	}
+	s.mu.RUnlock()
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || user == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return NewPrincipal(user.Name, user.Role, "", user.Selectors)
+}
+
+// Lookup returns a principal for a user's current role and selectors
+func (s *Store) Lookup(name string) (*Principal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := findUser(s.users, name)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	return NewPrincipal(s.users[i].Name, s.users[i].Role, "", s.users[i].Selectors)
+}
+
+// Users returns all users sorted by name
+func (s *Store) Users() []User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]User, len(s.users))
+	copy(users, s.users)
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users
+}
+
+// update applies fn to a copy of the users, then validates, saves and
+// swaps it in
+func (s *Store) update(fn func(users []User) ([]User, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]User, len(s.users))
+	for i, user := range s.users {
+		users[i] = user
+		users[i].Tokens = append([]Token(nil), user.Tokens...)
+	}
+
+	users, err := fn(users)
+	if err != nil {
+		return err
+	}
+
+	previous := s.users
+	s.users = users
+	if err := s.reindex(); err != nil {
+		s.users = previous
+		_ = s.reindex()
+		return err
+	}
+	if err := s.save(); err != nil {
+		s.users = previous
+		_ = s.reindex()
+		return err
+	}
+	return nil
+}
+
+func findUser(users []User, name string) int {
+	for i, user := range users {
+		if user.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// CreateUser adds a user without tokens. PasswordHash may be set with
+// HashPassword to allow the user to log in.
+func (s *Store) CreateUser(user User) error {
+	user.Tokens = nil
+	return s.update(func(users []User) ([]User, error) {
+		if findUser(users, user.Name) >= 0 {
+			return nil, ErrExists
+		}
+		return append(users, user), nil
+	})
+}
+
+// DeleteUser removes a user and revokes all of their tokens
+func (s *Store) DeleteUser(name string) error {
+	return s.update(func(users []User) ([]User, error) {
+		i := findUser(users, name)
+		if i < 0 {
+			return nil, ErrNotFound
+		}
+		return append(users[:i], users[i+1:]...), nil
+	})
+}
+
+// SetPassword sets or replaces a user's login password
+func (s *Store) SetPassword(name, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.update(func(users []User) ([]User, error) {
+		i := findUser(users, name)
+		if i < 0 {
+			return nil, ErrNotFound
+		}
+		users[i].PasswordHash = hash
+		return users, nil
+	})
+}
+
+// CreateToken issues a new token for a user and returns its secret. The
+// secret is not stored and cannot be retrieved again.
+func (s *Store) CreateToken(userName, tokenName string) (string, error) {
+	if !namePattern.MatchString(tokenName) {
+		return "", fmt.Errorf("invalid token name %q", tokenName)
+	}
+	secret, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.update(func(users []User) ([]User, error) {
+		i := findUser(users, userName)
+		if i < 0 {
+			return nil, ErrNotFound
+		}
+		for _, token := range users[i].Tokens {
+			if token.Name == tokenName {
+				return nil, ErrExists
+			}
+		}
+		users[i].Tokens = append(users[i].Tokens, Token{
+			Name:      tokenName,
+			Hash:      HashToken(secret),
+			CreatedAt: time.Now().UTC(),
+		})
+		return users, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// RevokeToken deletes a user's token
+func (s *Store) RevokeToken(userName, tokenName string) error {
+	return s.update(func(users []User) ([]User, error) {
+		i := findUser(users, userName)
+		if i < 0 {
+			return nil, ErrNotFound
+		}
+		for j, token := range users[i].Tokens {
+			if token.Name == tokenName {
+				users[i].Tokens = append(users[i].Tokens[:j], users[i].Tokens[j+1:]...)
+				return users, nil
+			}
+		}
+		return nil, ErrNotFound
+	})
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore_TokenLifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	store, err := OpenStore(path, "")
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+
+	if err := store.CreateUser(User{Name: "alice", Role: RoleOperator}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	secret, err := store.CreateToken("alice", "laptop")
+	if err != nil {
+		t.Fatalf("CreateToken failed: %v", err)
+	}
+	if !strings.HasPrefix(secret, TokenPrefix) {
+		t.Errorf("Expected token prefix, got %q", secret)
+	}
+
+	principal, err := store.Authenticate(secret)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if principal.User != "alice" || principal.TokenName != "laptop" || !principal.Can(PermExec) || principal.Can(PermImagesDelete) {
+		t.Errorf("Unexpected principal: %+v", principal)
+	}
+
+	// Only the hash is persisted, and a reopened store still accepts the token
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), secret) {
+		t.Error("Expected token secret not to be stored")
+	}
+	reopened, err := OpenStore(path, "")
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if _, err := reopened.Authenticate(secret); err != nil {
+		t.Errorf("Expected token to survive reopen: %v", err)
+	}
+
+	if err := store.RevokeToken("alice", "laptop"); err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+	if _, err := store.Authenticate(secret); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected revoked token to be rejected, got %v", err)
+	}
+}
+
+func TestStore_Validation(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "users.yaml"), "legacy-secret")
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+
+	if principal, err := store.Authenticate("legacy-secret"); err != nil || principal.Role != RoleAdmin {
+		t.Errorf("Expected legacy token to authenticate as admin, got %+v (%v)", principal, err)
+	}
+
+	invalid := []User{
+		{Name: "bad name", Role: RoleViewer},
+		{Name: "bob", Role: "superuser"},
+		{Name: "bob", Role: RoleViewer, Selectors: map[Permission]string{"containers:delete": "a=b"}},
+	}
+	for _, user := range invalid {
+		if err := store.CreateUser(user); err == nil {
+			t.Errorf("Expected error creating %+v", user)
+		}
+	}
+	if len(store.Users()) != 0 {
+		t.Errorf("Expected invalid users to be rejected, got %+v", store.Users())
+	}
+
+	if _, err := store.CreateToken("nobody", "x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPrincipal_Selectors(t *testing.T) {
+	principal, err := NewPrincipal("ops", RoleOperator, "", map[Permission]string{
+		PermContainersControl: "com.docker.compose.project=payments",
+	})
+	if err != nil {
+		t.Fatalf("NewPrincipal failed: %v", err)
+	}
+
+	payments := map[string]string{"com.docker.compose.project": "payments"}
+	billing := map[string]string{"com.docker.compose.project": "billing"}
+
+	if !principal.CanOn(PermContainersControl, payments) {
+		t.Error("Expected control of matching container")
+	}
+	if principal.CanOn(PermContainersControl, billing) {
+		t.Error("Expected control of non-matching container to be denied")
+	}
+	if !principal.CanOn(PermContainersRead, billing) {
+		t.Error("Expected unrestricted read permission")
+	}
+	if principal.CanOn(PermImagesDelete, payments) {
+		t.Error("Expected operator to lack images:delete")
+	}
+}
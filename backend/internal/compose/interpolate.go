@@ -0,0 +1,170 @@
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// interpolator substitutes ${VAR} style variables in scalar values and
+// records the variables that were not set
+type interpolator struct {
+	env     map[string]string
+	missing map[string]bool
+}
+
+// node interpolates every scalar under n in place. Mapping keys are left
+// alone, as Compose does.
+func (ip *interpolator) node(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range n.Content {
+			if err := ip.node(child); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := ip.node(n.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, "$") {
+			return nil
+		}
+		value, err := ip.interpolate(n.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		n.Value = value
+		// Let plain scalars resolve again, so "${PORT}" can become an int
+		if n.Style == 0 {
+			n.Tag = ""
+		}
+	}
+	return nil
+}
+
+// interpolate substitutes $VAR, ${VAR}, ${VAR:-default}, ${VAR-default},
+// ${VAR:?error} and ${VAR?error}. $$ is a literal dollar sign.
+func (ip *interpolator) interpolate(s string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			out.WriteByte(s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			out.WriteByte('$')
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			out.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable in %q", s)
+			}
+			value, err := ip.expression(s[i+2 : i+end])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(value)
+			i += end
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			out.WriteString(ip.lookup(s[i+1 : j]))
+			i = j - 1
+		default:
+			out.WriteByte('$')
+		}
+	}
+	return out.String(), nil
+}
+
+// expression evaluates the inside of ${...}
+func (ip *interpolator) expression(expr string) (string, error) {
+	name := expr
+	op, arg := "", ""
+	for i := 0; i < len(expr); i++ {
+		if !isNameChar(expr[i]) {
+			name, op = expr[:i], expr[i:]
+			break
+		}
+	}
+	if name == "" || !isNameStart(name[0]) {
+		return "", fmt.Errorf("invalid variable ${%s}", expr)
+	}
+	for _, prefix := range []string{":-", ":?", "-", "?"} {
+		if strings.HasPrefix(op, prefix) {
+			op, arg = prefix, op[len(prefix):]
+			break
+		}
+	}
+
+	value, set := ip.env[name]
+	switch op {
+	case "":
+		return ip.lookup(name), nil
+	case ":-":
+		if value == "" {
+			return arg, nil
+		}
+	case "-":
+		if !set {
+			return arg, nil
+		}
+	case ":?":
+		if value == "" {
+			return "", fmt.Errorf("required variable %s is missing a value: %s", name, arg)
+		}
+	case "?":
+		if !set {
+			return "", fmt.Errorf("required variable %s is missing a value: %s", name, arg)
+		}
+	default:
+		return "", fmt.Errorf("invalid variable ${%s}", expr)
+	}
+	return value, nil
+}
+
+// lookup returns a variable, recording it when it is not set
+func (ip *interpolator) lookup(name string) string {
+	value, ok := ip.env[name]
+	if !ok {
+		ip.missing[name] = true
+	}
+	return value
+}
+
+// warnings describes the variables that defaulted to an empty string
+func (ip *interpolator) warnings() []string {
+	names := make([]string, 0, len(ip.missing))
+	for name := range ip.missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	warnings := make([]string, 0, len(names))
+	for _, name := range names {
+		warnings = append(warnings, fmt.Sprintf("variable %s is not set, defaulting to an empty string", name))
+	}
+	return warnings
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
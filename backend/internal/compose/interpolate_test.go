@@ -0,0 +1,43 @@
+package compose
+
+import (
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"NAME": "web", "EMPTY": ""}
+	tests := map[string]string{
+		"plain":              "plain",
+		"$NAME-1":            "web-1",
+		"${NAME}_1":          "web_1",
+		"${MISSING:-def}":    "def",
+		"${EMPTY:-def}":      "def",
+		"${EMPTY-def}":       "",
+		"${MISSING-def}":     "def",
+		"${NAME:?required}":  "web",
+		"cost: $$5":          "cost: $5",
+		"trailing $":         "trailing $",
+		"$1 not a variable":  "$1 not a variable",
+		"${MISSING}/${NAME}": "/web",
+	}
+	for input, want := range tests {
+		ip := &interpolator{env: env, missing: make(map[string]bool)}
+		got, err := ip.interpolate(input)
+		if err != nil || got != want {
+			t.Errorf("interpolate(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{"${EMPTY:?must be set}", "${MISSING?must be set}", "${unterminated", "${1BAD}"} {
+		ip := &interpolator{env: env, missing: make(map[string]bool)}
+		if _, err := ip.interpolate(input); err == nil {
+			t.Errorf("Expected interpolate(%q) to fail", input)
+		}
+	}
+
+	ip := &interpolator{env: env, missing: make(map[string]bool)}
+	_, _ = ip.interpolate("$B ${A} ${C:-x}")
+	if warnings := ip.warnings(); len(warnings) != 2 || warnings[0] != "variable A is not set, defaulting to an empty string" {
+		t.Errorf("Unexpected warnings: %v", warnings)
+	}
+}
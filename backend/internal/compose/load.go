@@ -0,0 +1,943 @@
+package compose
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxReplicas bounds the containers of a single service
+const maxReplicas = 100
+
+var (
+	// projectNamePattern matches the project names Compose accepts
+	projectNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+	// keyPattern matches service, network and volume keys
+	keyPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+// Raw file structure. Unknown keys end up in Extra and are rejected unless
+// they are x- extensions.
+type (
+	rawProject struct {
+		Version  string                 `yaml:"version"`
+		Name     string                 `yaml:"name"`
+		Services map[string]*rawService `yaml:"services"`
+		Networks map[string]*rawNetwork `yaml:"networks"`
+		Volumes  map[string]*rawVolume  `yaml:"volumes"`
+		Extra    map[string]any         `yaml:",inline"`
+	}
+
+	rawService struct {
+		Image           string          `yaml:"image"`
+		ContainerName   string          `yaml:"container_name"`
+		Command         command         `yaml:"command"`
+		Entrypoint      command         `yaml:"entrypoint"`
+		Environment     mapping         `yaml:"environment"`
+		Ports           []yaml.Node     `yaml:"ports"`
+		Volumes         []yaml.Node     `yaml:"volumes"`
+		Networks        yaml.Node       `yaml:"networks"`
+		DependsOn       yaml.Node       `yaml:"depends_on"`
+		Restart         string          `yaml:"restart"`
+		Labels          mapping         `yaml:"labels"`
+		Healthcheck     *rawHealthcheck `yaml:"healthcheck"`
+		User            string          `yaml:"user"`
+		WorkingDir      string          `yaml:"working_dir"`
+		Hostname        string          `yaml:"hostname"`
+		CPUs            string          `yaml:"cpus"`
+		MemLimit        string          `yaml:"mem_limit"`
+		PidsLimit       *int64          `yaml:"pids_limit"`
+		Scale           *int            `yaml:"scale"`
+		StopGracePeriod string          `yaml:"stop_grace_period"`
+		Deploy          *rawDeploy      `yaml:"deploy"`
+		Extra           map[string]any  `yaml:",inline"`
+	}
+
+	rawDeploy struct {
+		Replicas  *int `yaml:"replicas"`
+		Resources struct {
+			Limits struct {
+				CPUs   string         `yaml:"cpus"`
+				Memory string         `yaml:"memory"`
+				Pids   *int64         `yaml:"pids"`
+				Extra  map[string]any `yaml:",inline"`
+			} `yaml:"limits"`
+			Extra map[string]any `yaml:",inline"`
+		} `yaml:"resources"`
+		Extra map[string]any `yaml:",inline"`
+	}
+
+	rawHealthcheck struct {
+		Test          yaml.Node      `yaml:"test"`
+		Interval      string         `yaml:"interval"`
+		Timeout       string         `yaml:"timeout"`
+		StartPeriod   string         `yaml:"start_period"`
+		StartInterval string         `yaml:"start_interval"`
+		Retries       *int           `yaml:"retries"`
+		Disable       bool           `yaml:"disable"`
+		Extra         map[string]any `yaml:",inline"`
+	}
+
+	rawPort struct {
+		Target    int            `yaml:"target"`
+		Published string         `yaml:"published"`
+		HostIP    string         `yaml:"host_ip"`
+		Protocol  string         `yaml:"protocol"`
+		Mode      string         `yaml:"mode"`
+		Extra     map[string]any `yaml:",inline"`
+	}
+
+	rawMount struct {
+		Type     string         `yaml:"type"`
+		Source   string         `yaml:"source"`
+		Target   string         `yaml:"target"`
+		ReadOnly bool           `yaml:"read_only"`
+		Extra    map[string]any `yaml:",inline"`
+	}
+
+	rawServiceNetwork struct {
+		Aliases     []string       `yaml:"aliases"`
+		IPv4Address string         `yaml:"ipv4_address"`
+		IPv6Address string         `yaml:"ipv6_address"`
+		Extra       map[string]any `yaml:",inline"`
+	}
+
+	rawDependency struct {
+		Condition string         `yaml:"condition"`
+		Restart   bool           `yaml:"restart"`
+		Required  *bool          `yaml:"required"`
+		Extra     map[string]any `yaml:",inline"`
+	}
+
+	rawNetwork struct {
+		Name       string         `yaml:"name"`
+		External   bool           `yaml:"external"`
+		Driver     string         `yaml:"driver"`
+		DriverOpts mapping        `yaml:"driver_opts"`
+		Internal   bool           `yaml:"internal"`
+		Attachable bool           `yaml:"attachable"`
+		EnableIPv6 bool           `yaml:"enable_ipv6"`
+		Labels     mapping        `yaml:"labels"`
+		IPAM       *rawIPAM       `yaml:"ipam"`
+		Extra      map[string]any `yaml:",inline"`
+	}
+
+	rawIPAM struct {
+		Driver string `yaml:"driver"`
+		Config []struct {
+			Subnet  string         `yaml:"subnet"`
+			Gateway string         `yaml:"gateway"`
+			IPRange string         `yaml:"ip_range"`
+			Extra   map[string]any `yaml:",inline"`
+		} `yaml:"config"`
+		Extra map[string]any `yaml:",inline"`
+	}
+
+	rawVolume struct {
+		Name       string         `yaml:"name"`
+		External   bool           `yaml:"external"`
+		Driver     string         `yaml:"driver"`
+		DriverOpts mapping        `yaml:"driver_opts"`
+		Labels     mapping        `yaml:"labels"`
+		Extra      map[string]any `yaml:",inline"`
+	}
+)
+
+// mapping is a map or a list of KEY=VALUE entries. Keys without a value
+// map to nil.
+type mapping map[string]*string
+
+func (m *mapping) UnmarshalYAML(n *yaml.Node) error {
+	out := make(mapping)
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i].Value, n.Content[i+1]
+			switch {
+			case value.ShortTag() == "!!null":
+				out[key] = nil
+			case value.Kind == yaml.ScalarNode:
+				v := value.Value
+				out[key] = &v
+			default:
+				return fmt.Errorf("line %d: value of %s must be a string", value.Line, key)
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: expected KEY=VALUE", item.Line)
+			}
+			key, value, ok := strings.Cut(item.Value, "=")
+			if ok {
+				out[key] = &value
+			} else {
+				out[key] = nil
+			}
+		}
+	default:
+		return fmt.Errorf("line %d: expected a map or a list", n.Line)
+	}
+	*m = out
+	return nil
+}
+
+// command is a list of arguments, or a string split like a shell would
+type command []string
+
+func (c *command) UnmarshalYAML(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		args, err := splitCommand(n.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		*c = args
+		return nil
+	case yaml.SequenceNode:
+		var args []string
+		if err := n.Decode(&args); err != nil {
+			return err
+		}
+		*c = args
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a string or a list", n.Line)
+}
+
+// splitCommand splits a command string into arguments, honouring single
+// and double quotes and backslash escapes
+func splitCommand(s string) ([]string, error) {
+	args := []string{}
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// checkExtra rejects unknown keys other than x- extensions
+func checkExtra(where string, extra map[string]any) error {
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		if !strings.HasPrefix(key, "x-") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	return fmt.Errorf("%s: unsupported key %q", where, keys[0])
+}
+
+// Load parses a Compose file, interpolating variables from env, and
+// validates it. A non-empty name overrides the file's top-level name. The
+// returned warnings name variables that were not set.
+func Load(data []byte, name string, env map[string]string) (*Project, []string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("compose file must be a map")
+	}
+
+	ip := &interpolator{env: env, missing: make(map[string]bool)}
+	if err := ip.node(&root); err != nil {
+		return nil, nil, err
+	}
+
+	var raw rawProject
+	if err := root.Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+	if err := checkExtra("top level", raw.Extra); err != nil {
+		return nil, nil, err
+	}
+
+	if name == "" {
+		name = raw.Name
+	}
+	if !projectNamePattern.MatchString(name) {
+		return nil, nil, fmt.Errorf("invalid project name %q: use lowercase letters, digits, dashes and underscores", name)
+	}
+	if len(raw.Services) == 0 {
+		return nil, nil, fmt.Errorf("compose file defines no services")
+	}
+
+	project := &Project{
+		Name:     name,
+		Services: make(map[string]*Service, len(raw.Services)),
+		Networks: make(map[string]*Network, len(raw.Networks)),
+		Volumes:  make(map[string]*Volume, len(raw.Volumes)),
+	}
+	for key, rn := range raw.Networks {
+		network, err := project.loadNetwork(key, rn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("network %s: %w", key, err)
+		}
+		project.Networks[key] = network
+	}
+	for key, rv := range raw.Volumes {
+		volume, err := project.loadVolume(key, rv)
+		if err != nil {
+			return nil, nil, fmt.Errorf("volume %s: %w", key, err)
+		}
+		project.Volumes[key] = volume
+	}
+	for key, rs := range raw.Services {
+		service, err := project.loadService(key, rs, env, func(name string) bool {
+			_, ok := raw.Services[name]
+			return ok
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("service %s: %w", key, err)
+		}
+		project.Services[key] = service
+	}
+	if err := project.validate(); err != nil {
+		return nil, nil, err
+	}
+	return project, ip.warnings(), nil
+}
+
+// resourceName returns the Docker name of a network or volume
+func (p *Project) resourceName(key, name string, external bool) string {
+	switch {
+	case name != "":
+		return name
+	case external:
+		return key
+	default:
+		return p.Name + "_" + key
+	}
+}
+
+func (p *Project) loadNetwork(key string, rn *rawNetwork) (*Network, error) {
+	if !keyPattern.MatchString(key) {
+		return nil, fmt.Errorf("invalid network key")
+	}
+	if rn == nil {
+		rn = &rawNetwork{}
+	}
+	if err := checkExtra("network", rn.Extra); err != nil {
+		return nil, err
+	}
+	network := &Network{
+		Name:       p.resourceName(key, rn.Name, rn.External),
+		External:   rn.External,
+		Driver:     rn.Driver,
+		DriverOpts: stringMap(rn.DriverOpts),
+		Internal:   rn.Internal,
+		Attachable: rn.Attachable,
+		EnableIPv6: rn.EnableIPv6,
+		Labels:     stringMap(rn.Labels),
+	}
+	if !keyPattern.MatchString(network.Name) {
+		return nil, fmt.Errorf("invalid network name %q", network.Name)
+	}
+	if rn.IPAM != nil {
+		if err := checkExtra("ipam", rn.IPAM.Extra); err != nil {
+			return nil, err
+		}
+		network.IPAM = &IPAM{Driver: rn.IPAM.Driver}
+		for _, cfg := range rn.IPAM.Config {
+			if err := checkExtra("ipam config", cfg.Extra); err != nil {
+				return nil, err
+			}
+			network.IPAM.Config = append(network.IPAM.Config, IPAMConfig{
+				Subnet:  cfg.Subnet,
+				Gateway: cfg.Gateway,
+				IPRange: cfg.IPRange,
+			})
+		}
+	}
+	return network, nil
+}
+
+func (p *Project) loadVolume(key string, rv *rawVolume) (*Volume, error) {
+	if !keyPattern.MatchString(key) {
+		return nil, fmt.Errorf("invalid volume key")
+	}
+	if rv == nil {
+		rv = &rawVolume{}
+	}
+	if err := checkExtra("volume", rv.Extra); err != nil {
+		return nil, err
+	}
+	volume := &Volume{
+		Name:       p.resourceName(key, rv.Name, rv.External),
+		External:   rv.External,
+		Driver:     rv.Driver,
+		DriverOpts: stringMap(rv.DriverOpts),
+		Labels:     stringMap(rv.Labels),
+	}
+	if !keyPattern.MatchString(volume.Name) {
+		return nil, fmt.Errorf("invalid volume name %q", volume.Name)
+	}
+	return volume, nil
+}
+
+// stringMap converts a mapping, treating keys without a value as empty
+func stringMap(m mapping) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for key, value := range m {
+		if value != nil {
+			out[key] = *value
+		} else {
+			out[key] = ""
+		}
+	}
+	return out
+}
+
+func (p *Project) loadService(name string, rs *rawService, env map[string]string, serviceExists func(string) bool) (*Service, error) {
+	if !keyPattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid service name")
+	}
+	if rs == nil {
+		return nil, fmt.Errorf("image is required")
+	}
+	if err := checkExtra("service", rs.Extra); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rs.Image) == "" {
+		return nil, fmt.Errorf("image is required; building images is not supported")
+	}
+
+	service := &Service{
+		Name:          name,
+		Image:         rs.Image,
+		ContainerName: rs.ContainerName,
+		Command:       rs.Command,
+		Entrypoint:    rs.Entrypoint,
+		Restart:       rs.Restart,
+		Labels:        stringMap(rs.Labels),
+		User:          rs.User,
+		WorkingDir:    rs.WorkingDir,
+		Hostname:      rs.Hostname,
+		MemLimit:      rs.MemLimit,
+		Replicas:      1,
+	}
+
+	// Keys without a value are taken from env, or left out like Compose
+	// does for unset shell variables
+	if len(rs.Environment) > 0 {
+		service.Environment = make(map[string]string, len(rs.Environment))
+		for key, value := range rs.Environment {
+			if value != nil {
+				service.Environment[key] = *value
+			} else if v, ok := env[key]; ok {
+				service.Environment[key] = v
+			}
+		}
+	}
+
+	switch rs.Restart {
+	case "", "no", "always", "unless-stopped", "on-failure":
+	default:
+		retries, ok := strings.CutPrefix(rs.Restart, "on-failure:")
+		if n, err := strconv.Atoi(retries); !ok || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid restart policy %q", rs.Restart)
+		}
+	}
+
+	if rs.CPUs != "" {
+		cpus, err := strconv.ParseFloat(rs.CPUs, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpus %q", rs.CPUs)
+		}
+		service.CPUs = cpus
+	}
+	if rs.PidsLimit != nil {
+		service.PidsLimit = *rs.PidsLimit
+	}
+	if rs.Scale != nil {
+		service.Replicas = *rs.Scale
+	}
+	if rs.Deploy != nil {
+		if err := service.loadDeploy(rs.Deploy); err != nil {
+			return nil, err
+		}
+	}
+	if service.Replicas < 0 || service.Replicas > maxReplicas {
+		return nil, fmt.Errorf("replicas must be between 0 and %d", maxReplicas)
+	}
+	if service.ContainerName != "" && service.Replicas > 1 {
+		return nil, fmt.Errorf("container_name cannot be used with more than one replica")
+	}
+
+	if rs.StopGracePeriod != "" {
+		d, err := time.ParseDuration(rs.StopGracePeriod)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid stop_grace_period %q", rs.StopGracePeriod)
+		}
+		service.StopGracePeriod = &d
+	}
+
+	if rs.Healthcheck != nil {
+		healthcheck, err := loadHealthcheck(rs.Healthcheck)
+		if err != nil {
+			return nil, fmt.Errorf("healthcheck: %w", err)
+		}
+		service.Healthcheck = healthcheck
+	}
+
+	for _, node := range rs.Ports {
+		ports, err := loadPort(&node)
+		if err != nil {
+			return nil, fmt.Errorf("ports: %w", err)
+		}
+		service.Ports = append(service.Ports, ports...)
+	}
+
+	for _, node := range rs.Volumes {
+		mount, err := loadMount(&node)
+		if err != nil {
+			return nil, fmt.Errorf("volumes: %w", err)
+		}
+		service.Volumes = append(service.Volumes, mount)
+	}
+
+	networks, err := loadServiceNetworks(&rs.Networks)
+	if err != nil {
+		return nil, fmt.Errorf("networks: %w", err)
+	}
+	service.Networks = networks
+
+	deps, err := loadDependsOn(&rs.DependsOn, serviceExists)
+	if err != nil {
+		return nil, fmt.Errorf("depends_on: %w", err)
+	}
+	service.DependsOn = deps
+	return service, nil
+}
+
+func (s *Service) loadDeploy(deploy *rawDeploy) error {
+	if err := checkExtra("deploy", deploy.Extra); err != nil {
+		return err
+	}
+	if err := checkExtra("deploy.resources", deploy.Resources.Extra); err != nil {
+		return err
+	}
+	limits := deploy.Resources.Limits
+	if err := checkExtra("deploy.resources.limits", limits.Extra); err != nil {
+		return err
+	}
+
+	if deploy.Replicas != nil {
+		s.Replicas = *deploy.Replicas
+	}
+	if limits.CPUs != "" {
+		cpus, err := strconv.ParseFloat(limits.CPUs, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cpus limit %q", limits.CPUs)
+		}
+		s.CPUs = cpus
+	}
+	if limits.Memory != "" {
+		s.MemLimit = limits.Memory
+	}
+	if limits.Pids != nil {
+		s.PidsLimit = *limits.Pids
+	}
+	return nil
+}
+
+func loadHealthcheck(rh *rawHealthcheck) (*Healthcheck, error) {
+	if err := checkExtra("healthcheck", rh.Extra); err != nil {
+		return nil, err
+	}
+	healthcheck := &Healthcheck{Disable: rh.Disable}
+
+	switch rh.Test.Kind {
+	case 0:
+	case yaml.ScalarNode:
+		healthcheck.Test = []string{"CMD-SHELL", rh.Test.Value}
+	case yaml.SequenceNode:
+		if err := rh.Test.Decode(&healthcheck.Test); err != nil {
+			return nil, err
+		}
+		if len(healthcheck.Test) == 0 {
+			return nil, fmt.Errorf("test must not be empty")
+		}
+		switch healthcheck.Test[0] {
+		case "NONE":
+			healthcheck.Test, healthcheck.Disable = nil, true
+		case "CMD", "CMD-SHELL":
+			if len(healthcheck.Test) < 2 {
+				return nil, fmt.Errorf("test needs a command")
+			}
+		default:
+			return nil, fmt.Errorf("test must start with CMD, CMD-SHELL or NONE")
+		}
+	default:
+		return nil, fmt.Errorf("test must be a string or a list")
+	}
+	if healthcheck.Disable {
+		return &Healthcheck{Disable: true}, nil
+	}
+
+	for _, d := range []struct {
+		value string
+		out   *time.Duration
+		name  string
+	}{
+		{rh.Interval, &healthcheck.Interval, "interval"},
+		{rh.Timeout, &healthcheck.Timeout, "timeout"},
+		{rh.StartPeriod, &healthcheck.StartPeriod, "start_period"},
+		{rh.StartInterval, &healthcheck.StartInterval, "start_interval"},
+	} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid %s %q", d.name, d.value)
+		}
+		*d.out = parsed
+	}
+	if rh.Retries != nil {
+		if *rh.Retries < 0 {
+			return nil, fmt.Errorf("retries must not be negative")
+		}
+		healthcheck.Retries = *rh.Retries
+	}
+	return healthcheck, nil
+}
+
+// loadPort parses a port in short ("[ip:][published:]target[/protocol]") or
+// long syntax. Port ranges expand to one port each.
+func loadPort(n *yaml.Node) ([]Port, error) {
+	var raw rawPort
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return parsePortSpec(n.Value)
+	case yaml.MappingNode:
+		if err := n.Decode(&raw); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("line %d: expected a string or a map", n.Line)
+	}
+	if err := checkExtra("port", raw.Extra); err != nil {
+		return nil, err
+	}
+	if raw.Mode != "" && raw.Mode != "ingress" && raw.Mode != "host" {
+		return nil, fmt.Errorf("invalid mode %q", raw.Mode)
+	}
+	return expandPorts(raw.HostIP, raw.Published, strconv.Itoa(raw.Target), raw.Protocol)
+}
+
+func parsePortSpec(spec string) ([]Port, error) {
+	rest, protocol := spec, ""
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		rest, protocol = spec[:i], spec[i+1:]
+	}
+
+	hostIP := ""
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]:")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid port %q", spec)
+		}
+		hostIP, rest = rest[1:end], rest[end+2:]
+	}
+
+	parts := strings.Split(rest, ":")
+	switch {
+	case len(parts) == 1:
+		return expandPorts(hostIP, "", parts[0], protocol)
+	case len(parts) == 2:
+		return expandPorts(hostIP, parts[0], parts[1], protocol)
+	case len(parts) == 3 && hostIP == "":
+		return expandPorts(parts[0], parts[1], parts[2], protocol)
+	}
+	return nil, fmt.Errorf("invalid port %q", spec)
+}
+
+// expandPorts validates a port mapping whose ports may be ranges of the
+// same length
+func expandPorts(hostIP, published, target, protocol string) ([]Port, error) {
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if protocol != "tcp" && protocol != "udp" && protocol != "sctp" {
+		return nil, fmt.Errorf("invalid protocol %q", protocol)
+	}
+	if hostIP != "" && net.ParseIP(hostIP) == nil {
+		return nil, fmt.Errorf("invalid host IP %q", hostIP)
+	}
+
+	targetStart, targetEnd, err := parsePortRange(target)
+	if err != nil {
+		return nil, err
+	}
+	publishedStart, publishedEnd := 0, 0
+	if published != "" {
+		if publishedStart, publishedEnd, err = parsePortRange(published); err != nil {
+			return nil, err
+		}
+		if publishedEnd-publishedStart != targetEnd-targetStart {
+			return nil, fmt.Errorf("published range %s does not match target range %s", published, target)
+		}
+	}
+
+	ports := make([]Port, 0, targetEnd-targetStart+1)
+	for i := 0; i <= targetEnd-targetStart; i++ {
+		port := Port{Target: targetStart + i, HostIP: hostIP, Protocol: protocol}
+		if publishedStart > 0 {
+			port.Published = publishedStart + i
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+func parsePortRange(s string) (int, int, error) {
+	startStr, endStr, isRange := strings.Cut(s, "-")
+	start, err := strconv.Atoi(startStr)
+	if err != nil || start < 1 || start > 65535 {
+		return 0, 0, fmt.Errorf("invalid port %q", s)
+	}
+	if !isRange {
+		return start, start, nil
+	}
+	end, err := strconv.Atoi(endStr)
+	if err != nil || end < start || end > 65535 {
+		return 0, 0, fmt.Errorf("invalid port range %q", s)
+	}
+	return start, end, nil
+}
+
+// loadMount parses a volume in short ("[source:]target[:mode]") or long
+// syntax. Relative bind mounts need a project directory and are rejected.
+func loadMount(n *yaml.Node) (Mount, error) {
+	var mount Mount
+	switch n.Kind {
+	case yaml.ScalarNode:
+		parts := strings.Split(n.Value, ":")
+		switch len(parts) {
+		case 1:
+			mount.Target = parts[0]
+		case 2, 3:
+			mount.Source, mount.Target = parts[0], parts[1]
+			if len(parts) == 3 {
+				for _, option := range strings.Split(parts[2], ",") {
+					switch option {
+					case "ro":
+						mount.ReadOnly = true
+					case "rw", "cached", "delegated", "consistent", "nocopy":
+					default:
+						return mount, fmt.Errorf("unsupported volume option %q", option)
+					}
+				}
+			}
+		default:
+			return mount, fmt.Errorf("invalid volume %q", n.Value)
+		}
+		mount.Type = MountVolume
+		if strings.HasPrefix(mount.Source, "/") || strings.HasPrefix(mount.Source, ".") || strings.HasPrefix(mount.Source, "~") {
+			mount.Type = MountBind
+		}
+	case yaml.MappingNode:
+		var raw rawMount
+		if err := n.Decode(&raw); err != nil {
+			return mount, err
+		}
+		if err := checkExtra("volume", raw.Extra); err != nil {
+			return mount, err
+		}
+		if raw.Type != MountVolume && raw.Type != MountBind {
+			return mount, fmt.Errorf("unsupported volume type %q", raw.Type)
+		}
+		mount = Mount{Type: raw.Type, Source: raw.Source, Target: raw.Target, ReadOnly: raw.ReadOnly}
+	default:
+		return mount, fmt.Errorf("line %d: expected a string or a map", n.Line)
+	}
+
+	if !path.IsAbs(mount.Target) {
+		return mount, fmt.Errorf("target %q must be an absolute path", mount.Target)
+	}
+	mount.Target = path.Clean(mount.Target)
+	if mount.Type == MountBind && !path.IsAbs(mount.Source) {
+		return mount, fmt.Errorf("bind source %q must be an absolute path; relative paths are not supported", mount.Source)
+	}
+	return mount, nil
+}
+
+// loadServiceNetworks parses a list of network keys or a map of keys to
+// attachment options
+func loadServiceNetworks(n *yaml.Node) (map[string]ServiceNetwork, error) {
+	networks := make(map[string]ServiceNetwork)
+	switch n.Kind {
+	case 0:
+		return nil, nil
+	case yaml.SequenceNode:
+		var keys []string
+		if err := n.Decode(&keys); err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			networks[key] = ServiceNetwork{}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i].Value, n.Content[i+1]
+			var raw rawServiceNetwork
+			if value.ShortTag() != "!!null" {
+				if err := value.Decode(&raw); err != nil {
+					return nil, err
+				}
+			}
+			if err := checkExtra("network "+key, raw.Extra); err != nil {
+				return nil, err
+			}
+			for _, address := range []string{raw.IPv4Address, raw.IPv6Address} {
+				if address != "" && net.ParseIP(address) == nil {
+					return nil, fmt.Errorf("invalid address %q", address)
+				}
+			}
+			networks[key] = ServiceNetwork{Aliases: raw.Aliases, IPv4Address: raw.IPv4Address, IPv6Address: raw.IPv6Address}
+		}
+	default:
+		return nil, fmt.Errorf("line %d: expected a list or a map", n.Line)
+	}
+	return networks, nil
+}
+
+// loadDependsOn parses a list of service names or a map of service names
+// to conditions. Optional dependencies, marked with required: false, are
+// dropped when the service does not exist.
+func loadDependsOn(n *yaml.Node, serviceExists func(string) bool) (map[string]Dependency, error) {
+	deps := make(map[string]Dependency)
+	switch n.Kind {
+	case 0:
+		return nil, nil
+	case yaml.SequenceNode:
+		var names []string
+		if err := n.Decode(&names); err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			deps[name] = Dependency{Condition: ConditionStarted}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			name, value := n.Content[i].Value, n.Content[i+1]
+			var raw rawDependency
+			if value.ShortTag() != "!!null" {
+				if err := value.Decode(&raw); err != nil {
+					return nil, err
+				}
+			}
+			if err := checkExtra(name, raw.Extra); err != nil {
+				return nil, err
+			}
+			dep := Dependency{Condition: raw.Condition, Restart: raw.Restart}
+			switch dep.Condition {
+			case "":
+				dep.Condition = ConditionStarted
+			case ConditionStarted, ConditionHealthy, ConditionCompleted:
+			default:
+				return nil, fmt.Errorf("invalid condition %q for %s", raw.Condition, name)
+			}
+			if raw.Required != nil && !*raw.Required && !serviceExists(name) {
+				continue
+			}
+			deps[name] = dep
+		}
+	default:
+		return nil, fmt.Errorf("line %d: expected a list or a map", n.Line)
+	}
+	return deps, nil
+}
+
+// validate checks references between services, networks and volumes and
+// attaches services without networks to the default network
+func (p *Project) validate() error {
+	needsDefault := false
+	for _, name := range p.ServiceNames() {
+		service := p.Services[name]
+
+		for dep := range service.DependsOn {
+			if dep == name {
+				return fmt.Errorf("service %s depends on itself", name)
+			}
+			if _, ok := p.Services[dep]; !ok {
+				return fmt.Errorf("service %s depends on undefined service %s", name, dep)
+			}
+		}
+
+		if len(service.Networks) == 0 {
+			service.Networks = map[string]ServiceNetwork{DefaultNetwork: {}}
+			needsDefault = true
+		}
+		for key := range service.Networks {
+			if _, ok := p.Networks[key]; !ok && key != DefaultNetwork {
+				return fmt.Errorf("service %s uses undefined network %s", name, key)
+			}
+			needsDefault = needsDefault || key == DefaultNetwork
+		}
+
+		for _, mount := range service.Volumes {
+			if mount.Type != MountVolume || mount.Source == "" {
+				continue
+			}
+			if _, ok := p.Volumes[mount.Source]; !ok {
+				return fmt.Errorf("service %s uses undefined volume %s", name, mount.Source)
+			}
+		}
+	}
+
+	if _, ok := p.Networks[DefaultNetwork]; needsDefault && !ok {
+		p.Networks[DefaultNetwork] = &Network{Name: p.resourceName(DefaultNetwork, "", false)}
+	}
+
+	_, err := p.Order()
+	return err
+}
@@ -0,0 +1,235 @@
+package compose
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCompose = `
+name: shop
+services:
+  web:
+    image: nginx:${NGINX_TAG:-1.27}
+    command: nginx -g 'daemon off;'
+    ports:
+      - "8080:80"
+      - "127.0.0.1::443/tcp"
+      - target: 9000
+        published: "9000"
+    networks: [front, back]
+    depends_on:
+      api:
+        condition: service_healthy
+      metrics:
+        condition: service_started
+        required: false
+  api:
+    image: shop/api
+    environment:
+      DB_URL: postgres://db/${DB_NAME}
+      TOKEN:
+      PRICE: $$5
+    volumes:
+      - data:/var/lib/api
+      - /etc/ssl/certs:/etc/ssl/certs:ro
+      - /tmp/cache
+    networks:
+      back:
+        aliases: [backend]
+    healthcheck:
+      test: curl -f http://localhost/health
+      interval: 10s
+      retries: 3
+    deploy:
+      replicas: 2
+      resources:
+        limits:
+          cpus: "0.5"
+          memory: 256m
+    x-owner: payments
+  worker:
+    image: shop/worker
+    depends_on: [api]
+networks:
+  front:
+  back:
+    ipam:
+      config:
+        - subnet: 10.10.0.0/24
+  shared:
+    external: true
+volumes:
+  data:
+  archive:
+    name: shop-archive
+x-common: ignored
+`
+
+func TestLoad(t *testing.T) {
+	project, warnings, err := Load([]byte(testCompose), "", map[string]string{"TOKEN": "secret"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if project.Name != "shop" || len(project.Services) != 3 {
+		t.Fatalf("Unexpected project: %+v", project)
+	}
+	if !reflect.DeepEqual(warnings, []string{"variable DB_NAME is not set, defaulting to an empty string"}) {
+		t.Errorf("Unexpected warnings: %v", warnings)
+	}
+
+	web := project.Services["web"]
+	if web.Image != "nginx:1.27" || !reflect.DeepEqual(web.Command, []string{"nginx", "-g", "daemon off;"}) {
+		t.Errorf("Unexpected web service: %+v", web)
+	}
+	wantPorts := []Port{
+		{Target: 80, Published: 8080, Protocol: "tcp"},
+		{Target: 443, HostIP: "127.0.0.1", Protocol: "tcp"},
+		{Target: 9000, Published: 9000, Protocol: "tcp"},
+	}
+	if !reflect.DeepEqual(web.Ports, wantPorts) {
+		t.Errorf("Unexpected ports: %+v", web.Ports)
+	}
+	if len(web.DependsOn) != 1 || web.DependsOn["api"].Condition != ConditionHealthy {
+		t.Errorf("Expected optional missing dependency to be dropped, got %+v", web.DependsOn)
+	}
+
+	api := project.Services["api"]
+	wantEnv := map[string]string{"DB_URL": "postgres://db/", "TOKEN": "secret", "PRICE": "$5"}
+	if !reflect.DeepEqual(api.Environment, wantEnv) {
+		t.Errorf("Unexpected environment: %v", api.Environment)
+	}
+	wantMounts := []Mount{
+		{Type: MountVolume, Source: "data", Target: "/var/lib/api"},
+		{Type: MountBind, Source: "/etc/ssl/certs", Target: "/etc/ssl/certs", ReadOnly: true},
+		{Type: MountVolume, Target: "/tmp/cache"},
+	}
+	if !reflect.DeepEqual(api.Volumes, wantMounts) {
+		t.Errorf("Unexpected volumes: %+v", api.Volumes)
+	}
+	if api.Replicas != 2 || api.CPUs != 0.5 || api.MemLimit != "256m" {
+		t.Errorf("Unexpected deploy settings: %+v", api)
+	}
+	if api.Healthcheck == nil || api.Healthcheck.Test[0] != "CMD-SHELL" || api.Healthcheck.Interval != 10*time.Second {
+		t.Errorf("Unexpected healthcheck: %+v", api.Healthcheck)
+	}
+	if !reflect.DeepEqual(api.Networks["back"].Aliases, []string{"backend"}) {
+		t.Errorf("Unexpected networks: %+v", api.Networks)
+	}
+
+	// Services without networks join the default network
+	if _, ok := project.Services["worker"].Networks[DefaultNetwork]; !ok {
+		t.Errorf("Expected worker on the default network, got %+v", project.Services["worker"].Networks)
+	}
+	names := map[string]string{}
+	for key, network := range project.Networks {
+		names[key] = network.Name
+	}
+	for key, volume := range project.Volumes {
+		names["volume:"+key] = volume.Name
+	}
+	wantNames := map[string]string{
+		"front": "shop_front", "back": "shop_back", "shared": "shared", "default": "shop_default",
+		"volume:data": "shop_data", "volume:archive": "shop-archive",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("Unexpected resource names: %v", names)
+	}
+
+	levels, err := project.Order()
+	if err != nil || !reflect.DeepEqual(levels, [][]string{{"api"}, {"web", "worker"}}) {
+		t.Errorf("Unexpected order %v, %v", levels, err)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		compose string
+		want    string
+	}{
+		{"build", "services:\n  web:\n    build: .\n", `unsupported key "build"`},
+		{"privileged", "services:\n  web:\n    image: a\n    privileged: true\n", `unsupported key "privileged"`},
+		{"top level", "services:\n  web:\n    image: a\nsecrets: {}\n", `unsupported key "secrets"`},
+		{"no image", "services:\n  web:\n    command: x\n", "image is required"},
+		{"no services", "name: x\n", "defines no services"},
+		{"relative bind", "services:\n  web:\n    image: a\n    volumes: ['./data:/data']\n", "relative paths are not supported"},
+		{"undefined volume", "services:\n  web:\n    image: a\n    volumes: ['data:/data']\n", "undefined volume data"},
+		{"undefined network", "services:\n  web:\n    image: a\n    networks: [front]\n", "undefined network front"},
+		{"undefined dependency", "services:\n  web:\n    image: a\n    depends_on: [db]\n", "undefined service db"},
+		{"cycle", "services:\n  a:\n    image: a\n    depends_on: [b]\n  b:\n    image: b\n    depends_on: [a]\n", "dependency cycle between services a, b"},
+		{"port range", "services:\n  web:\n    image: a\n    ports: ['8000-8001:80']\n", "does not match"},
+		{"replicas", "services:\n  web:\n    image: a\n    container_name: web\n    scale: 2\n", "container_name"},
+		{"required variable", "services:\n  web:\n    image: ${IMAGE:?set an image}\n", "set an image"},
+		{"restart", "services:\n  web:\n    image: a\n    restart: sometimes\n", "invalid restart policy"},
+		{"condition", "services:\n  web:\n    image: a\n    depends_on:\n      db:\n        condition: ready\n  db:\n    image: b\n", `invalid condition "ready"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Load([]byte(tt.compose), "test", nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if _, _, err := Load([]byte("services:\n  web:\n    image: a\n"), "Bad Name", nil); err == nil {
+		t.Error("Expected invalid project name to be rejected")
+	}
+}
+
+func TestLoad_InterpolatedValuesStayScalars(t *testing.T) {
+	// A variable value must not be able to inject YAML structure
+	env := map[string]string{"PORT": "8080", "IMAGE": "nginx\nprivileged: true"}
+	project, _, err := Load([]byte("services:\n  web:\n    image: ${IMAGE}\n    ports:\n      - target: ${PORT}\n"), "test", env)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	web := project.Services["web"]
+	if web.Image != "nginx\nprivileged: true" || web.Ports[0].Target != 8080 {
+		t.Errorf("Unexpected service: %+v", web)
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := map[string][]string{
+		`echo hello`:                {"echo", "hello"},
+		`sh -c "echo \"hi\" there"`: {"sh", "-c", `echo "hi" there`},
+		`printf '%s\n' a`:           {"printf", `%s\n`, "a"},
+		`  spaced   out  `:          {"spaced", "out"},
+		`empty ""`:                  {"empty", ""},
+	}
+	for input, want := range tests {
+		got, err := splitCommand(input)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("splitCommand(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := splitCommand(`echo "open`); err == nil {
+		t.Error("Expected unterminated quote to fail")
+	}
+}
+
+func TestProject_ConfigHash(t *testing.T) {
+	load := func(image string) *Project {
+		project, _, err := Load([]byte("services:\n  web:\n    image: "+image+"\n    scale: 2\n  db:\n    image: postgres\n"), "shop", nil)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		return project
+	}
+	a, b := load("nginx:1"), load("nginx:2")
+	if a.ConfigHash(a.Services["db"]) != b.ConfigHash(b.Services["db"]) {
+		t.Error("Unchanged services must keep their hash")
+	}
+	if a.ConfigHash(a.Services["web"]) == b.ConfigHash(b.Services["web"]) {
+		t.Error("Changed services must change their hash")
+	}
+
+	labels := a.ContainerLabels(a.Services["web"], 2)
+	if labels[ProjectLabel] != "shop" || labels[ServiceLabel] != "web" || labels[ContainerNumberLabel] != "2" ||
+		labels[ConfigHashLabel] == "" || a.ContainerName(a.Services["web"], 2) != "shop-web-2" {
+		t.Errorf("Unexpected container labels: %v", labels)
+	}
+}
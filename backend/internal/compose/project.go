@@ -0,0 +1,298 @@
+// Package compose loads Compose files into projects that can be deployed
+// through the Docker API. Only a subset of the Compose specification is
+// supported; keys outside of it are rejected rather than ignored.
+package compose
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Labels Compose sets on the objects of a project
+const (
+	ProjectLabel         = "com.docker.compose.project"
+	ServiceLabel         = "com.docker.compose.service"
+	NetworkLabel         = "com.docker.compose.network"
+	VolumeLabel          = "com.docker.compose.volume"
+	ContainerNumberLabel = "com.docker.compose.container-number"
+	OneoffLabel          = "com.docker.compose.oneoff"
+	DependsOnLabel       = "com.docker.compose.depends_on"
+	ConfigHashLabel      = "com.docker.compose.config-hash"
+)
+
+// Dependency conditions of depends_on
+const (
+	ConditionStarted   = "service_started"
+	ConditionHealthy   = "service_healthy"
+	ConditionCompleted = "service_completed_successfully"
+)
+
+// DefaultNetwork is the network services without networks are attached to
+const DefaultNetwork = "default"
+
+// Project is a loaded Compose file. Networks and volumes are keyed by their
+// name in the file.
+type Project struct {
+	Name     string
+	Services map[string]*Service
+	Networks map[string]*Network
+	Volumes  map[string]*Volume
+}
+
+// Network is a top-level network. Name is the Docker network name.
+type Network struct {
+	Name       string
+	External   bool
+	Driver     string
+	DriverOpts map[string]string
+	Internal   bool
+	Attachable bool
+	EnableIPv6 bool
+	Labels     map[string]string
+	IPAM       *IPAM
+}
+
+// IPAM is the address management of a network
+type IPAM struct {
+	Driver string
+	Config []IPAMConfig
+}
+
+// IPAMConfig is one subnet of a network
+type IPAMConfig struct {
+	Subnet  string
+	Gateway string
+	IPRange string
+}
+
+// Volume is a top-level volume. Name is the Docker volume name.
+type Volume struct {
+	Name       string
+	External   bool
+	Driver     string
+	DriverOpts map[string]string
+	Labels     map[string]string
+}
+
+// Service is a service of a project. It is serialized for the config hash,
+// so fields that do not require recreating containers are left out.
+type Service struct {
+	Name            string                    `json:"-"`
+	Image           string                    `json:"image"`
+	ContainerName   string                    `json:"container_name,omitempty"`
+	Command         []string                  `json:"command,omitempty"`
+	Entrypoint      []string                  `json:"entrypoint,omitempty"`
+	Environment     map[string]string         `json:"environment,omitempty"`
+	Ports           []Port                    `json:"ports,omitempty"`
+	Volumes         []Mount                   `json:"volumes,omitempty"`
+	Networks        map[string]ServiceNetwork `json:"networks,omitempty"`
+	DependsOn       map[string]Dependency     `json:"depends_on,omitempty"`
+	Restart         string                    `json:"restart,omitempty"`
+	Labels          map[string]string         `json:"labels,omitempty"`
+	Healthcheck     *Healthcheck              `json:"healthcheck,omitempty"`
+	User            string                    `json:"user,omitempty"`
+	WorkingDir      string                    `json:"working_dir,omitempty"`
+	Hostname        string                    `json:"hostname,omitempty"`
+	CPUs            float64                   `json:"cpus,omitempty"`
+	MemLimit        string                    `json:"mem_limit,omitempty"`
+	PidsLimit       int64                     `json:"pids_limit,omitempty"`
+	StopGracePeriod *time.Duration            `json:"stop_grace_period,omitempty"`
+	Replicas        int                       `json:"-"`
+}
+
+// Port publishes a container port. Published is zero for a random port.
+type Port struct {
+	Target    int    `json:"target"`
+	Published int    `json:"published,omitempty"`
+	HostIP    string `json:"host_ip,omitempty"`
+	Protocol  string `json:"protocol"`
+}
+
+// Mount types
+const (
+	MountVolume = "volume"
+	MountBind   = "bind"
+)
+
+// Mount mounts a volume or host path. Source is a top-level volume key for
+// volume mounts, or empty for an anonymous volume.
+type Mount struct {
+	Type     string `json:"type"`
+	Source   string `json:"source,omitempty"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+// ServiceNetwork is a service's attachment to a top-level network
+type ServiceNetwork struct {
+	Aliases     []string `json:"aliases,omitempty"`
+	IPv4Address string   `json:"ipv4_address,omitempty"`
+	IPv6Address string   `json:"ipv6_address,omitempty"`
+}
+
+// Dependency is an entry of depends_on
+type Dependency struct {
+	Condition string `json:"condition"`
+	Restart   bool   `json:"restart,omitempty"`
+}
+
+// Healthcheck overrides the image's health check. Test is empty when Disable
+// is set.
+type Healthcheck struct {
+	Test          []string      `json:"test,omitempty"`
+	Interval      time.Duration `json:"interval,omitempty"`
+	Timeout       time.Duration `json:"timeout,omitempty"`
+	StartPeriod   time.Duration `json:"start_period,omitempty"`
+	StartInterval time.Duration `json:"start_interval,omitempty"`
+	Retries       int           `json:"retries,omitempty"`
+	Disable       bool          `json:"disable,omitempty"`
+}
+
+// ServiceNames returns the names of the project's services, sorted
+func (p *Project) ServiceNames() []string {
+	names := make([]string, 0, len(p.Services))
+	for name := range p.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ContainerName returns the name of a service's container with the given
+// number, starting at 1
+func (p *Project) ContainerName(service *Service, number int) string {
+	if service.ContainerName != "" {
+		return service.ContainerName
+	}
+	return fmt.Sprintf("%s-%s-%d", p.Name, service.Name, number)
+}
+
+// ContainerLabels returns the labels of a service's container, including
+// the ones Compose uses to find and compare project containers
+func (p *Project) ContainerLabels(service *Service, number int) map[string]string {
+	labels := make(map[string]string, len(service.Labels)+6)
+	for key, value := range service.Labels {
+		labels[key] = value
+	}
+	labels[ProjectLabel] = p.Name
+	labels[ServiceLabel] = service.Name
+	labels[ContainerNumberLabel] = strconv.Itoa(number)
+	labels[OneoffLabel] = "False"
+	labels[ConfigHashLabel] = p.ConfigHash(service)
+
+	deps := make([]string, 0, len(service.DependsOn))
+	for name, dep := range service.DependsOn {
+		deps = append(deps, fmt.Sprintf("%s:%s:%t", name, dep.Condition, dep.Restart))
+	}
+	sort.Strings(deps)
+	labels[DependsOnLabel] = strings.Join(deps, ",")
+	return labels
+}
+
+// NetworkLabels returns the labels of a network the project creates
+func (p *Project) NetworkLabels(key string) map[string]string {
+	labels := map[string]string{ProjectLabel: p.Name, NetworkLabel: key}
+	for k, v := range p.Networks[key].Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// VolumeLabels returns the labels of a volume the project creates
+func (p *Project) VolumeLabels(key string) map[string]string {
+	labels := map[string]string{ProjectLabel: p.Name, VolumeLabel: key}
+	for k, v := range p.Volumes[key].Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// ConfigHash returns a hash of everything in a service's configuration that
+// requires recreating its containers when it changes, including the Docker
+// names of the networks and volumes it uses
+func (p *Project) ConfigHash(service *Service) string {
+	networks := make(map[string]string, len(service.Networks))
+	for key := range service.Networks {
+		networks[key] = p.Networks[key].Name
+	}
+	volumes := make(map[string]string)
+	for _, mount := range service.Volumes {
+		if mount.Type == MountVolume && mount.Source != "" {
+			volumes[mount.Source] = p.Volumes[mount.Source].Name
+		}
+	}
+
+	// Marshalling only fails for unsupported types, which Service has none of
+	data, _ := json.Marshal(struct {
+		Service  *Service          `json:"service"`
+		Networks map[string]string `json:"networks"`
+		Volumes  map[string]string `json:"volumes"`
+	}{service, networks, volumes})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Order sorts the project's services into levels where every service only
+// depends on services in earlier levels
+func (p *Project) Order() ([][]string, error) {
+	deps := make(map[string][]string, len(p.Services))
+	for name, service := range p.Services {
+		deps[name] = make([]string, 0, len(service.DependsOn))
+		for dep := range service.DependsOn {
+			deps[name] = append(deps[name], dep)
+		}
+	}
+	return Order(deps)
+}
+
+// Order sorts nodes into levels where every node only depends on nodes in
+// earlier levels. Dependencies on unknown nodes and on the node itself are
+// ignored. Names within a level are sorted.
+func Order(deps map[string][]string) ([][]string, error) {
+	pending := make(map[string]map[string]bool, len(deps))
+	for name := range deps {
+		pending[name] = make(map[string]bool)
+	}
+	for name, nodeDeps := range deps {
+		for _, dep := range nodeDeps {
+			if _, ok := pending[dep]; ok && dep != name {
+				pending[name][dep] = true
+			}
+		}
+	}
+
+	var levels [][]string
+	for len(pending) > 0 {
+		var level []string
+		for name, nodeDeps := range pending {
+			if len(nodeDeps) == 0 {
+				level = append(level, name)
+			}
+		}
+		if len(level) == 0 {
+			cycle := make([]string, 0, len(pending))
+			for name := range pending {
+				cycle = append(cycle, name)
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("dependency cycle between services %s", strings.Join(cycle, ", "))
+		}
+		sort.Strings(level)
+		for _, name := range level {
+			delete(pending, name)
+		}
+		for _, nodeDeps := range pending {
+			for _, name := range level {
+				delete(nodeDeps, name)
+			}
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
@@ -0,0 +1,288 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultHostName is the name of the implicit host used when no hosts file
+// is configured
+const DefaultHostName = "local"
+
+// hostNamePattern restricts host names to values that are safe in URL paths
+var hostNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+// HostConfig configures a single Docker endpoint
+type HostConfig struct {
+	Name    string     `yaml:"name"`
+	URL     string     `yaml:"url"`
+	Default bool       `yaml:"default"`
+	TLS     *TLSConfig `yaml:"tls,omitempty"`
+}
+
+// TLSConfig holds client certificate paths for tcp+TLS endpoints
+type TLSConfig struct {
+	CA   string `yaml:"ca"`
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+// HostHealth is the last observed health of a host
+type HostHealth struct {
+	Name          string    `json:"name"`
+	URL           string    `json:"url"`
+	Default       bool      `json:"default"`
+	Healthy       bool      `json:"healthy"`
+	Error         string    `json:"error,omitempty"`
+	APIVersion    string    `json:"api_version,omitempty"`
+	LastCheckedAt time.Time `json:"last_checked_at"`
+}
+
+// Host is a named Docker endpoint with its own client and health state
+type Host struct {
+	name     string
+	url      string
+	client   *client.Client
+	statsHub *StatsHub
+	mu       sync.RWMutex
+	health   HostHealth
+}
+
+// Name returns the host name
+func (h *Host) Name() string {
+	return h.name
+}
+
+// Client returns the host's Docker client
+func (h *Host) Client() *client.Client {
+	return h.client
+}
+
+// StatsHub returns the hub sharing stats streams for the host's containers
+func (h *Host) StatsHub() *StatsHub {
+	return h.statsHub
+}
+
+// Health returns the host's last observed health
+func (h *Host) Health() HostHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.health
+}
+
+// Healthy reports whether the last health check succeeded
+func (h *Host) Healthy() bool {
+	return h.Health().Healthy
+}
+
+// HealthCheck pings the daemon and records the result
+func (h *Host) HealthCheck(ctx context.Context) error {
+	ping, err := h.client.Ping(ctx)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.health.LastCheckedAt = time.Now()
+	if err != nil {
+		h.health.Healthy = false
+		h.health.Error = err.Error()
+		return err
+	}
+	h.health.Healthy = true
+	h.health.Error = ""
+	h.health.APIVersion = ping.APIVersion
+	return nil
+}
+
+// HostRegistry holds the configured Docker hosts
+type HostRegistry struct {
+	hosts       []*Host
+	byName      map[string]*Host
+	defaultHost *Host
+	logger      *zap.Logger
+}
+
+// hostsFile is the on-disk layout of the hosts file
+type hostsFile struct {
+	Hosts []HostConfig `yaml:"hosts"`
+}
+
+// LoadHostConfigs reads host definitions from a YAML file
+func LoadHostConfigs(path string) ([]HostConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hosts file: %w", err)
+	}
+
+	var file hostsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse hosts file: %w", err)
+	}
+	return file.Hosts, nil
+}
+
+// NewHostRegistry creates clients for every configured host. Without any
+// configured hosts, the singleton client from GetClient is registered as
+// "local". Hosts that cannot be reached at startup are registered unhealthy.
+func NewHostRegistry(configs []HostConfig, logger *zap.Logger) (*HostRegistry, error) {
+	registry := &HostRegistry{
+		byName: make(map[string]*Host),
+		logger: logger,
+	}
+
+	if len(configs) == 0 {
+		dc, err := GetClient(logger)
+		if err != nil {
+			return nil, err
+		}
+		host := &Host{name: DefaultHostName, url: dc.client.DaemonHost(), client: dc.client}
+		host.health = HostHealth{Name: host.name, URL: host.url, Default: true, Healthy: true, LastCheckedAt: time.Now()}
+		registry.add(host, true)
+		return registry, nil
+	}
+
+	for i, cfg := range configs {
+		if !hostNamePattern.MatchString(cfg.Name) {
+			return nil, fmt.Errorf("host %d: invalid name %q", i, cfg.Name)
+		}
+		if _, exists := registry.byName[cfg.Name]; exists {
+			return nil, fmt.Errorf("host %d: duplicate name %q", i, cfg.Name)
+		}
+
+		cli, err := newHostClient(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", cfg.Name, err)
+		}
+
+		host := &Host{name: cfg.Name, url: cfg.URL, client: cli}
+		host.health = HostHealth{Name: cfg.Name, URL: cfg.URL, Default: cfg.Default}
+		registry.add(host, cfg.Default)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := host.HealthCheck(ctx); err != nil {
+			logger.Warn("Docker host is unreachable",
+				zap.String("host", cfg.Name),
+				zap.Error(err))
+		} else {
+			logger.Info("Docker host initialized", zap.String("host", cfg.Name))
+		}
+		cancel()
+	}
+
+	if registry.defaultHost == nil {
+		registry.defaultHost = registry.hosts[0]
+		registry.defaultHost.health.Default = true
+	}
+
+	return registry, nil
+}
+
+func (r *HostRegistry) add(host *Host, isDefault bool) {
+	host.statsHub = NewStatsHub(host.client, r.logger)
+	r.hosts = append(r.hosts, host)
+	r.byName[host.name] = host
+	if isDefault && r.defaultHost == nil {
+		r.defaultHost = host
+	}
+}
+
+// newHostClient creates a Docker client for unix://, tcp:// (optionally
+// with TLS) and ssh:// endpoints
+func newHostClient(cfg HostConfig) (*client.Client, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	opts := []client.Opt{
+		client.WithAPIVersionNegotiation(),
+	}
+
+	switch u.Scheme {
+	case "unix", "npipe":
+		opts = append(opts, client.WithHost(cfg.URL))
+	case "tcp":
+		opts = append(opts, client.WithHost(cfg.URL))
+		if cfg.TLS != nil {
+			opts = append(opts, client.WithTLSClientConfig(cfg.TLS.CA, cfg.TLS.Cert, cfg.TLS.Key))
+		}
+	case "ssh":
+		opts = append(opts,
+			// The host is a placeholder; connections go through the SSH dialer
+			client.WithHost("http://docker.example.com"),
+			client.WithDialContext(sshDialer(u)),
+		)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	return cli, nil
+}
+
+// Hosts returns all hosts in configuration order
+func (r *HostRegistry) Hosts() []*Host {
+	return r.hosts
+}
+
+// Get returns the host with the given name
+func (r *HostRegistry) Get(name string) (*Host, bool) {
+	host, ok := r.byName[name]
+	return host, ok
+}
+
+// Default returns the default host
+func (r *HostRegistry) Default() *Host {
+	return r.defaultHost
+}
+
+// RunHealthChecks pings every host at the given interval until ctx is cancelled
+func (r *HostRegistry) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, host := range r.hosts {
+				wasHealthy := host.Healthy()
+				checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				err := host.HealthCheck(checkCtx)
+				cancel()
+
+				switch {
+				case err != nil && wasHealthy:
+					r.logger.Warn("Docker host became unhealthy",
+						zap.String("host", host.name),
+						zap.Error(err))
+				case err == nil && !wasHealthy:
+					r.logger.Info("Docker host recovered", zap.String("host", host.name))
+				}
+			}
+		}
+	}
+}
+
+// Close closes every host client
+func (r *HostRegistry) Close() error {
+	for _, host := range r.hosts {
+		host.statsHub.Close()
+		if err := host.client.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
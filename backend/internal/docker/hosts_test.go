@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// fakeDaemon serves just enough of the Docker API for health checks
+func fakeDaemon(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/_ping") {
+			w.Header().Set("API-Version", "1.47")
+			_, _ = w.Write([]byte("OK"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return "tcp://" + strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestLoadHostConfigs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.yaml")
+	content := "hosts:\n  - name: prod\n    url: tcp://10.0.0.5:2376\n    default: true\n    tls:\n      ca: /certs/ca.pem\n      cert: /certs/cert.pem\n      key: /certs/key.pem\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	configs, err := LoadHostConfigs(path)
+	if err != nil {
+		t.Fatalf("LoadHostConfigs failed: %v", err)
+	}
+	if len(configs) != 1 || configs[0].Name != "prod" || !configs[0].Default || configs[0].TLS == nil {
+		t.Errorf("Unexpected configs: %+v", configs)
+	}
+}
+
+func TestNewHostRegistry_Validation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		configs []HostConfig
+	}{
+		{name: "invalid name", configs: []HostConfig{{Name: "a/b", URL: "tcp://127.0.0.1:2375"}}},
+		{name: "duplicate name", configs: []HostConfig{{Name: "a", URL: "tcp://127.0.0.1:2375"}, {Name: "a", URL: "tcp://127.0.0.1:2376"}}},
+		{name: "unsupported scheme", configs: []HostConfig{{Name: "a", URL: "ftp://127.0.0.1"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewHostRegistry(tc.configs, zap.NewNop()); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestNewHostRegistry_HealthAndDefault(t *testing.T) {
+	registry, err := NewHostRegistry([]HostConfig{
+		{Name: "up", URL: fakeDaemon(t)},
+		{Name: "down", URL: "tcp://127.0.0.1:1", Default: true},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewHostRegistry failed: %v", err)
+	}
+	defer registry.Close()
+
+	if registry.Default().Name() != "down" {
+		t.Errorf("Expected explicit default host, got %s", registry.Default().Name())
+	}
+
+	up, ok := registry.Get("up")
+	if !ok {
+		t.Fatal("Expected host up to be registered")
+	}
+	if health := up.Health(); !health.Healthy || health.APIVersion != "1.47" {
+		t.Errorf("Expected healthy host with API version, got %+v", health)
+	}
+
+	down, _ := registry.Get("down")
+	if health := down.Health(); health.Healthy || health.Error == "" {
+		t.Errorf("Expected unreachable host to be unhealthy, got %+v", health)
+	}
+}
@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
@@ -22,8 +23,10 @@ type ContainerStats struct {
 	PIDs          uint64    `json:"pids"`
 }
 
-// StatsCalculator handles container statistics calculation
+// StatsCalculator handles container statistics calculation. It is safe for
+// concurrent use.
 type StatsCalculator struct {
+	mu            sync.Mutex
 	previousStats map[string]*container.StatsResponse
 	logger        *zap.Logger
 }
@@ -38,6 +41,9 @@ func NewStatsCalculator(logger *zap.Logger) *StatsCalculator {
 
 // CalculateStats processes raw Docker stats and calculates percentages
 func (sc *StatsCalculator) CalculateStats(containerID string, stats *container.StatsResponse) (*ContainerStats, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
 	prevStats, hasPrevious := sc.previousStats[containerID]
 
 	// Calculate CPU percentage
@@ -153,12 +159,16 @@ func (sc *StatsCalculator) calculateCPUPercent(prevStats, currStats *container.S
 
 // ResetStats clears previous stats for a container (useful after restart)
 func (sc *StatsCalculator) ResetStats(containerID string) {
+	sc.mu.Lock()
 	delete(sc.previousStats, containerID)
+	sc.mu.Unlock()
 	sc.logger.Debug("Reset stats for container", zap.String("container_id", containerID))
 }
 
 // ClearAllStats clears all stored previous stats
 func (sc *StatsCalculator) ClearAllStats() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
 	sc.previousStats = make(map[string]*container.StatsResponse)
 }
 
@@ -0,0 +1,72 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// PullProgress is one normalized progress message of an image pull
+type PullProgress struct {
+	Layer   string
+	Status  string
+	Current int64
+	Total   int64
+}
+
+// NormalizeImageRef parses an image reference, adding the default registry
+// and the latest tag when they are omitted
+func NormalizeImageRef(ref string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", ref, err)
+	}
+	return reference.TagNameOnly(named).String(), nil
+}
+
+// PullImage pulls an image and reports Docker's progress messages until
+// the pull completes. Errors reported inside the stream are returned.
+func PullImage(ctx context.Context, dockerClient interface {
+	ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error)
+}, ref string, options image.PullOptions, progress func(PullProgress)) error {
+	stream, err := dockerClient.ImagePull(ctx, ref, options)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	decoder := json.NewDecoder(stream)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to read pull progress: %w", err)
+		}
+
+		if msg.Error != nil {
+			return errors.New(msg.Error.Message)
+		}
+		// Older daemons only set the deprecated field
+		if msg.ErrorMessage != "" {
+			return errors.New(msg.ErrorMessage)
+		}
+
+		update := PullProgress{Layer: msg.ID, Status: msg.Status}
+		if msg.Progress != nil {
+			update.Current = msg.Progress.Current
+			update.Total = msg.Progress.Total
+		}
+		progress(update)
+	}
+}
@@ -0,0 +1,67 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/image"
+)
+
+// streamPuller returns a fixed pull progress stream
+type streamPuller string
+
+func (s streamPuller) ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(string(s))), nil
+}
+
+func TestNormalizeImageRef(t *testing.T) {
+	testCases := map[string]string{
+		"nginx":                       "docker.io/library/nginx:latest",
+		"postgres:16":                 "docker.io/library/postgres:16",
+		"registry.local:5000/app/api": "registry.local:5000/app/api:latest",
+	}
+	for input, expected := range testCases {
+		got, err := NormalizeImageRef(input)
+		if err != nil || got != expected {
+			t.Errorf("NormalizeImageRef(%q) = %q, %v; expected %q", input, got, err, expected)
+		}
+	}
+	if _, err := NormalizeImageRef("nginx:bad tag"); err == nil {
+		t.Error("Expected invalid reference to fail")
+	}
+}
+
+func TestPullImage_Progress(t *testing.T) {
+	stream := streamPuller(`{"status":"Pulling from library/nginx","id":"latest"}
+{"status":"Downloading","progressDetail":{"current":512,"total":2048},"id":"a1b2"}
+{"status":"Pull complete","progressDetail":{},"id":"a1b2"}
+{"status":"Status: Downloaded newer image for nginx:latest"}
+`)
+
+	var updates []PullProgress
+	err := PullImage(context.Background(), stream, "nginx:latest", image.PullOptions{}, func(p PullProgress) {
+		updates = append(updates, p)
+	})
+	if err != nil {
+		t.Fatalf("PullImage failed: %v", err)
+	}
+	if len(updates) != 4 {
+		t.Fatalf("Expected 4 updates, got %d", len(updates))
+	}
+	if updates[1].Layer != "a1b2" || updates[1].Current != 512 || updates[1].Total != 2048 {
+		t.Errorf("Unexpected progress update: %+v", updates[1])
+	}
+}
+
+func TestPullImage_StreamError(t *testing.T) {
+	stream := streamPuller(`{"status":"Pulling from library/missing","id":"latest"}
+{"errorDetail":{"message":"manifest unknown"},"error":"manifest unknown"}
+`)
+
+	err := PullImage(context.Background(), stream, "missing:latest", image.PullOptions{}, func(PullProgress) {})
+	if err == nil || err.Error() != "manifest unknown" {
+		t.Errorf("Expected stream error, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// sshDialer returns a dial function that tunnels the Docker API over SSH by
+// running "docker system dial-stdio" on the remote host, the same mechanism
+// the docker CLI uses for ssh:// hosts. Authentication relies on the local
+// ssh client configuration (agent, keys, known_hosts).
+func sshDialer(u *url.URL) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	args := []string{"-o", "ConnectTimeout=30"}
+	if u.User != nil && u.User.Username() != "" {
+		args = append(args, "-l", u.User.Username())
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	args = append(args, "--", u.Hostname(), "docker", "system", "dial-stdio")
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		// Not bound to ctx: the connection outlives the dial
+		cmd := exec.Command("ssh", args...)
+
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start ssh: %w", err)
+		}
+
+		return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout, remote: u.Host}, nil
+	}
+}
+
+// commandConn is a net.Conn over a subprocess's stdin and stdout
+type commandConn struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	stdout    io.ReadCloser
+	remote    string
+	closeOnce sync.Once
+}
+
+func (c *commandConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *commandConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+func (c *commandConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.stdin.Close()
+		if c.cmd.Process != nil {
+			_ = c.cmd.Process.Kill()
+		}
+		_ = c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr  { return dummyAddr("ssh-local") }
+func (c *commandConn) RemoteAddr() net.Addr { return dummyAddr(c.remote) }
+
+// Deadlines are not supported on pipes; the HTTP client enforces timeouts
+func (c *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// dummyAddr is a net.Addr for connections without a socket address
+type dummyAddr string
+
+func (a dummyAddr) Network() string { return "ssh" }
+func (a dummyAddr) String() string  { return string(a) }
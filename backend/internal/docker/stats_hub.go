@@ -0,0 +1,293 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultStatsLinger is how long an upstream stream is kept open after
+	// its last subscriber leaves, so quick reconnects reuse it
+	DefaultStatsLinger = 5 * time.Second
+
+	// DefaultStatsFrameInterval throttles frames published per container
+	DefaultStatsFrameInterval = 1 * time.Second
+
+	// subscriberBuffer is the number of frames queued per subscriber before
+	// the oldest is dropped
+	subscriberBuffer = 4
+)
+
+// StatsHub keeps one upstream Docker stats stream per container and fans
+// calculated stats out to any number of subscribers. Slow subscribers drop
+// their oldest queued frames instead of blocking the stream.
+type StatsHub struct {
+	dockerClient interface {
+		ContainerStats(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error)
+	}
+	calculator *StatsCalculator
+	interval   time.Duration
+	linger     time.Duration
+	mu         sync.Mutex
+	streams    map[string]*statsStream
+	closed     bool
+	logger     *zap.Logger
+}
+
+// statsStream is a single upstream stream and its subscribers
+type statsStream struct {
+	containerID string
+	cancel      context.CancelFunc
+	subscribers map[*StatsSubscription]struct{}
+	latest      *ContainerStats
+	lingerTimer *time.Timer
+	stopped     bool
+}
+
+// StatsSubscription receives calculated stats for one container
+type StatsSubscription struct {
+	containerID string
+	hub         *StatsHub
+	stream      *statsStream
+	ch          chan *ContainerStats
+	mu          sync.Mutex
+	closed      bool
+}
+
+// NewStatsHub creates a new stats hub
+func NewStatsHub(dockerClient interface {
+	ContainerStats(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error)
+}, logger *zap.Logger) *StatsHub {
+	return &StatsHub{
+		dockerClient: dockerClient,
+		calculator:   NewStatsCalculator(logger),
+		interval:     DefaultStatsFrameInterval,
+		linger:       DefaultStatsLinger,
+		streams:      make(map[string]*statsStream),
+		logger:       logger,
+	}
+}
+
+// Subscribe starts receiving stats for a container, opening the upstream
+// stream if needed. The subscription's channel is closed when the upstream
+// stream ends (e.g. the container stops) or the hub is closed.
+func (h *StatsHub) Subscribe(containerID string) *StatsSubscription {
+	sub := &StatsSubscription{
+		containerID: containerID,
+		hub:         h,
+		ch:          make(chan *ContainerStats, subscriberBuffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		sub.close()
+		return sub
+	}
+
+	stream, ok := h.streams[containerID]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream = &statsStream{
+			containerID: containerID,
+			cancel:      cancel,
+			subscribers: make(map[*StatsSubscription]struct{}),
+		}
+		h.streams[containerID] = stream
+		go h.run(ctx, stream)
+	}
+	if stream.lingerTimer != nil {
+		stream.lingerTimer.Stop()
+		stream.lingerTimer = nil
+	}
+
+	sub.stream = stream
+	stream.subscribers[sub] = struct{}{}
+
+	// New subscribers get the last frame straight away
+	if stream.latest != nil {
+		sub.send(stream.latest)
+	}
+
+	return sub
+}
+
+// Stats returns the channel delivering stats frames
+func (s *StatsSubscription) Stats() <-chan *ContainerStats {
+	return s.ch
+}
+
+// ContainerID returns the subscribed container ID
+func (s *StatsSubscription) ContainerID() string {
+	return s.containerID
+}
+
+// Close unsubscribes. The upstream stream is torn down after the linger
+// period once no subscribers remain.
+func (s *StatsSubscription) Close() {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stream := s.stream
+	if stream != nil && h.streams[s.containerID] == stream {
+		delete(stream.subscribers, s)
+		if len(stream.subscribers) == 0 && stream.lingerTimer == nil {
+			stream.lingerTimer = time.AfterFunc(h.linger, func() {
+				h.stopIfIdle(stream)
+			})
+		}
+	}
+	s.close()
+}
+
+// send queues a frame, dropping the oldest queued frame if the buffer is full
+func (s *StatsSubscription) send(stats *ContainerStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	for {
+		select {
+		case s.ch <- stats:
+			return
+		default:
+		}
+		select {
+		case <-s.ch:
+		default:
+		}
+	}
+}
+
+func (s *StatsSubscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// stopIfIdle tears down a stream that still has no subscribers
+func (h *StatsHub) stopIfIdle(stream *statsStream) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.streams[stream.containerID] != stream || len(stream.subscribers) > 0 {
+		return
+	}
+	h.removeLocked(stream)
+}
+
+// removeLocked stops a stream and closes its subscribers. h.mu must be held.
+func (h *StatsHub) removeLocked(stream *statsStream) {
+	if stream.stopped {
+		return
+	}
+	stream.stopped = true
+
+	if h.streams[stream.containerID] == stream {
+		delete(h.streams, stream.containerID)
+	}
+	if stream.lingerTimer != nil {
+		stream.lingerTimer.Stop()
+	}
+	stream.cancel()
+	for sub := range stream.subscribers {
+		sub.close()
+	}
+	stream.subscribers = nil
+	h.calculator.ResetStats(stream.containerID)
+}
+
+// run reads the upstream stream and publishes calculated frames until the
+// stream ends or is cancelled
+func (h *StatsHub) run(ctx context.Context, stream *statsStream) {
+	defer func() {
+		h.mu.Lock()
+		h.removeLocked(stream)
+		h.mu.Unlock()
+	}()
+
+	stats, err := h.dockerClient.ContainerStats(ctx, stream.containerID, true)
+	if err != nil {
+		if ctx.Err() == nil {
+			h.logger.Error("Failed to get container stats stream",
+				zap.String("container_id", stream.containerID),
+				zap.Error(err))
+		}
+		return
+	}
+	defer stats.Body.Close()
+
+	decoder := json.NewDecoder(stats.Body)
+	var lastSent time.Time
+
+	for {
+		// Decode into a fresh value; the calculator keeps the previous frame
+		statsJSON := new(container.StatsResponse)
+		if err := decoder.Decode(statsJSON); err != nil {
+			switch {
+			case ctx.Err() != nil:
+			case errors.Is(err, io.EOF):
+				h.logger.Info("Stats stream ended",
+					zap.String("container_id", stream.containerID))
+			default:
+				h.logger.Error("Failed to decode stats",
+					zap.String("container_id", stream.containerID),
+					zap.Error(err))
+			}
+			return
+		}
+
+		now := time.Now()
+		if now.Sub(lastSent) < h.interval {
+			continue
+		}
+
+		calculatedStats, err := h.calculator.CalculateStats(stream.containerID, statsJSON)
+		if err != nil {
+			h.logger.Error("Failed to calculate stats",
+				zap.String("container_id", stream.containerID),
+				zap.Error(err))
+			continue
+		}
+		lastSent = now
+
+		h.mu.Lock()
+		stream.latest = calculatedStats
+		for sub := range stream.subscribers {
+			sub.send(calculatedStats)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// StreamCount returns the number of open upstream streams
+func (h *StatsHub) StreamCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.streams)
+}
+
+// Close stops every stream and closes all subscriptions
+func (h *StatsHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, stream := range h.streams {
+		h.removeLocked(stream)
+	}
+}
@@ -0,0 +1,226 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"go.uber.org/zap"
+)
+
+// fakeStatsClient hands out streams whose frames are written by the test
+type fakeStatsClient struct {
+	mu      sync.Mutex
+	opened  int
+	writers []*io.PipeWriter
+	closed  chan struct{}
+}
+
+func newFakeStatsClient() *fakeStatsClient {
+	return &fakeStatsClient{closed: make(chan struct{}, 10)}
+}
+
+func (f *fakeStatsClient) ContainerStats(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error) {
+	reader, writer := io.Pipe()
+
+	f.mu.Lock()
+	f.opened++
+	f.writers = append(f.writers, writer)
+	f.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		writer.CloseWithError(ctx.Err())
+		f.closed <- struct{}{}
+	}()
+	return container.StatsResponseReader{Body: reader}, nil
+}
+
+// write sends a frame on the most recently opened stream, waiting for the
+// hub to open one
+func (f *fakeStatsClient) write(t *testing.T, pids uint64) {
+	t.Helper()
+	var writer *io.PipeWriter
+	for deadline := time.Now().Add(2 * time.Second); writer == nil; {
+		f.mu.Lock()
+		if len(f.writers) > 0 {
+			writer = f.writers[len(f.writers)-1]
+		}
+		f.mu.Unlock()
+		if writer == nil {
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for stats stream")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	frame := container.StatsResponse{PidsStats: container.PidsStats{Current: pids}}
+	if err := json.NewEncoder(writer).Encode(frame); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+}
+
+func (f *fakeStatsClient) openedCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.opened
+}
+
+func receive(t *testing.T, sub *StatsSubscription) *ContainerStats {
+	t.Helper()
+	select {
+	case stats, ok := <-sub.Stats():
+		if !ok {
+			t.Fatal("Subscription closed unexpectedly")
+		}
+		return stats
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for stats")
+		return nil
+	}
+}
+
+func newTestHub(client *fakeStatsClient) *StatsHub {
+	hub := NewStatsHub(client, zap.NewNop())
+	hub.interval = 0
+	hub.linger = 50 * time.Millisecond
+	return hub
+}
+
+func TestStatsHub_SharesUpstreamStream(t *testing.T) {
+	client := newFakeStatsClient()
+	hub := newTestHub(client)
+	defer hub.Close()
+
+	first := hub.Subscribe("abc123")
+	second := hub.Subscribe("abc123")
+
+	client.write(t, 7)
+	if got := receive(t, first); got.PIDs != 7 || got.ContainerID != "abc123" {
+		t.Errorf("Unexpected stats for first subscriber: %+v", got)
+	}
+	if got := receive(t, second); got.PIDs != 7 {
+		t.Errorf("Unexpected stats for second subscriber: %+v", got)
+	}
+
+	// A late subscriber gets the last frame straight away
+	third := hub.Subscribe("abc123")
+	if got := receive(t, third); got.PIDs != 7 {
+		t.Errorf("Expected latest frame for late subscriber, got %+v", got)
+	}
+
+	if opened := client.openedCount(); opened != 1 {
+		t.Errorf("Expected a single upstream stream, got %d", opened)
+	}
+}
+
+func TestStatsHub_TearsDownAfterLastSubscriber(t *testing.T) {
+	client := newFakeStatsClient()
+	hub := newTestHub(client)
+	defer hub.Close()
+
+	first := hub.Subscribe("abc123")
+	second := hub.Subscribe("abc123")
+	client.write(t, 1)
+	receive(t, first)
+
+	first.Close()
+	second.Close()
+	for range second.Stats() {
+		// Drain frames queued before Close
+	}
+
+	select {
+	case <-client.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected upstream stream to be closed after linger")
+	}
+	if count := hub.StreamCount(); count != 0 {
+		t.Errorf("Expected no open streams, got %d", count)
+	}
+
+	// Subscribing again opens a fresh stream
+	third := hub.Subscribe("abc123")
+	defer third.Close()
+	for deadline := time.Now().Add(2 * time.Second); client.openedCount() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for a new upstream stream")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	client.write(t, 2)
+	if got := receive(t, third); got.PIDs != 2 {
+		t.Errorf("Expected frame from new stream, got %+v", got)
+	}
+	if opened := client.openedCount(); opened != 2 {
+		t.Errorf("Expected a new upstream stream, got %d total", opened)
+	}
+}
+
+func TestStatsHub_ResubscribeWithinLingerReusesStream(t *testing.T) {
+	client := newFakeStatsClient()
+	hub := newTestHub(client)
+	hub.linger = time.Hour
+	defer hub.Close()
+
+	hub.Subscribe("abc123").Close()
+	sub := hub.Subscribe("abc123")
+	defer sub.Close()
+	client.write(t, 1)
+	receive(t, sub)
+
+	if opened := client.openedCount(); opened != 1 {
+		t.Errorf("Expected stream to be reused within linger, got %d streams", opened)
+	}
+}
+
+func TestStatsHub_SlowSubscriberDropsOldest(t *testing.T) {
+	client := newFakeStatsClient()
+	hub := newTestHub(client)
+	defer hub.Close()
+
+	slow := hub.Subscribe("abc123")
+	fast := hub.Subscribe("abc123")
+
+	const frames = subscriberBuffer + 3
+	for i := 1; i <= frames; i++ {
+		client.write(t, uint64(i))
+		receive(t, fast)
+	}
+
+	var last uint64
+	for i := 0; i < subscriberBuffer; i++ {
+		last = receive(t, slow).PIDs
+	}
+	if last != frames {
+		t.Errorf("Expected slow subscriber to end on the newest frame %d, got %d", frames, last)
+	}
+}
+
+func TestStatsHub_StreamEndClosesSubscribers(t *testing.T) {
+	client := newFakeStatsClient()
+	hub := newTestHub(client)
+	defer hub.Close()
+
+	sub := hub.Subscribe("abc123")
+	client.write(t, 1)
+	receive(t, sub)
+
+	client.mu.Lock()
+	client.writers[0].Close()
+	client.mu.Unlock()
+
+	select {
+	case _, ok := <-sub.Stats():
+		if ok {
+			t.Error("Expected subscription to close when the stream ends")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for subscription to close")
+	}
+}
@@ -0,0 +1,209 @@
+package forward
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kubevision/kubevision/internal/utils"
+)
+
+// Sink types
+const (
+	TypeSyslog = "syslog"
+	TypeLoki   = "loki"
+	TypeHTTP   = "http"
+)
+
+// Where a sink starts reading containers it has no position for
+const (
+	StartBeginning = "beginning"
+	StartEnd       = "end"
+)
+
+// Config is the log forwarding file
+type Config struct {
+	// Selector picks the containers whose logs are forwarded; sinks can
+	// override it. The empty selector matches every container.
+	Selector string `yaml:"selector"`
+
+	// DiscoveryInterval is how often containers are listed to start tailing
+	// new ones
+	DiscoveryInterval time.Duration `yaml:"discovery_interval"`
+
+	Sinks []SinkConfig `yaml:"sinks"`
+}
+
+// SinkConfig configures a single sink
+type SinkConfig struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
+	Selector string `yaml:"selector"`
+
+	// Stream is stdout, stderr or all
+	Stream string `yaml:"stream"`
+
+	// StartFrom is beginning or end: whether containers without a position
+	// are forwarded from the start of their log or from when forwarding
+	// started
+	StartFrom string `yaml:"start_from"`
+
+	BatchSize     int           `yaml:"batch_size"`
+	BatchInterval time.Duration `yaml:"batch_interval"`
+	// BufferSize is the number of lines queued before tailing blocks
+	BufferSize int           `yaml:"buffer_size"`
+	Timeout    time.Duration `yaml:"timeout"`
+	Retry      RetryConfig   `yaml:"retry"`
+
+	// Syslog
+	Address  string `yaml:"address"`
+	Protocol string `yaml:"protocol"`
+	Facility string `yaml:"facility"`
+	Hostname string `yaml:"hostname"`
+
+	// Loki and HTTP
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+
+	// Loki
+	TenantID string            `yaml:"tenant_id"`
+	Labels   map[string]string `yaml:"labels"`
+
+	selector utils.LabelSelector
+}
+
+// RetryConfig configures delivery retries with exponential backoff. Batches
+// are retried until they are delivered or forwarding stops.
+type RetryConfig struct {
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// LoadConfig reads the log forwarding file. Environment variables (${VAR})
+// are expanded so credentials can stay out of it.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read log forwarding config: %w", err)
+	}
+	return ParseConfig([]byte(os.ExpandEnv(string(data))))
+}
+
+// ParseConfig parses and validates the log forwarding config
+func ParseConfig(data []byte) (Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("failed to parse log forwarding config: %w", err)
+	}
+
+	if _, err := utils.ParseLabelSelector(cfg.Selector); err != nil {
+		return Config{}, fmt.Errorf("invalid selector: %w", err)
+	}
+	if cfg.DiscoveryInterval == 0 {
+		cfg.DiscoveryInterval = 10 * time.Second
+	}
+
+	seen := make(map[string]bool, len(cfg.Sinks))
+	for i := range cfg.Sinks {
+		s := &cfg.Sinks[i]
+		if s.Selector == "" {
+			s.Selector = cfg.Selector
+		}
+		if err := s.validate(); err != nil {
+			return Config{}, fmt.Errorf("sink %d (%s): %w", i, s.Name, err)
+		}
+		if seen[s.Name] {
+			return Config{}, fmt.Errorf("sink %d: duplicate sink name %q", i, s.Name)
+		}
+		seen[s.Name] = true
+	}
+
+	return cfg, nil
+}
+
+func (s *SinkConfig) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	switch s.Type {
+	case TypeSyslog:
+		if s.Address == "" {
+			return fmt.Errorf("address is required")
+		}
+		if s.Protocol == "" {
+			s.Protocol = "tcp"
+		}
+		if s.Protocol != "tcp" && s.Protocol != "udp" {
+			return fmt.Errorf("unknown protocol %q", s.Protocol)
+		}
+		if s.Facility == "" {
+			s.Facility = "user"
+		}
+		if _, ok := syslogFacilities[s.Facility]; !ok {
+			return fmt.Errorf("unknown facility %q", s.Facility)
+		}
+	case TypeLoki, TypeHTTP:
+		if s.URL == "" {
+			return fmt.Errorf("url is required")
+		}
+		if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid url %q", s.URL)
+		}
+		for name := range s.Labels {
+			if !lokiLabelPattern.MatchString(name) {
+				return fmt.Errorf("invalid label name %q", name)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown sink type %q", s.Type)
+	}
+
+	selector, err := utils.ParseLabelSelector(s.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	s.selector = selector
+
+	switch s.Stream {
+	case "":
+		s.Stream = "all"
+	case "all", "stdout", "stderr":
+	default:
+		return fmt.Errorf("unknown stream %q", s.Stream)
+	}
+	switch s.StartFrom {
+	case "":
+		s.StartFrom = StartBeginning
+	case StartBeginning, StartEnd:
+	default:
+		return fmt.Errorf("unknown start_from %q", s.StartFrom)
+	}
+
+	if s.BatchSize == 0 {
+		s.BatchSize = 500
+	}
+	if s.BatchInterval == 0 {
+		s.BatchInterval = time.Second
+	}
+	if s.BufferSize == 0 {
+		s.BufferSize = 10 * s.BatchSize
+	}
+	if s.BatchSize < 0 || s.BufferSize < 0 || s.BatchInterval < 0 {
+		return fmt.Errorf("batch_size, batch_interval and buffer_size must be positive")
+	}
+	if s.Timeout == 0 {
+		s.Timeout = 10 * time.Second
+	}
+	if s.Retry.InitialBackoff == 0 {
+		s.Retry.InitialBackoff = time.Second
+	}
+	if s.Retry.MaxBackoff == 0 {
+		s.Retry.MaxBackoff = time.Minute
+	}
+
+	return nil
+}
@@ -0,0 +1,474 @@
+package forward
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"go.uber.org/zap"
+)
+
+var logBase = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func testRecord(line string) Record {
+	return Record{
+		Host:        "local",
+		ContainerID: "aaaaaaaaaaaa1111",
+		Container:   "shop-api-1",
+		Stack:       "shop",
+		Service:     "api",
+		Stream:      "stdout",
+		Timestamp:   logBase.Add(123456 * time.Microsecond),
+		Line:        line,
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+selector: forward=true
+sinks:
+  - name: loki
+    type: loki
+    url: http://loki:3100/loki/api/v1/push
+    labels: {env: prod}
+  - name: syslog
+    type: syslog
+    address: syslog:514
+    selector: com.docker.compose.project=shop
+    stream: stderr
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if cfg.DiscoveryInterval != 10*time.Second || len(cfg.Sinks) != 2 {
+		t.Fatalf("Unexpected config: %+v", cfg)
+	}
+	loki, syslog := cfg.Sinks[0], cfg.Sinks[1]
+	if loki.Selector != "forward=true" || loki.BatchSize != 500 || loki.BufferSize != 5000 ||
+		loki.Stream != "all" || loki.StartFrom != StartBeginning || loki.Retry.MaxBackoff != time.Minute {
+		t.Errorf("Expected defaults and the global selector, got %+v", loki)
+	}
+	if syslog.Selector != "com.docker.compose.project=shop" || syslog.Protocol != "tcp" || syslog.Facility != "user" {
+		t.Errorf("Expected selector override and syslog defaults, got %+v", syslog)
+	}
+
+	for name, data := range map[string]string{
+		"no name":        "sinks: [{type: http, url: http://x}]",
+		"unknown type":   "sinks: [{name: a, type: kafka}]",
+		"no url":         "sinks: [{name: a, type: http}]",
+		"bad url":        "sinks: [{name: a, type: http, url: 'ftp://x'}]",
+		"no address":     "sinks: [{name: a, type: syslog}]",
+		"bad protocol":   "sinks: [{name: a, type: syslog, address: 'x:514', protocol: sctp}]",
+		"bad facility":   "sinks: [{name: a, type: syslog, address: 'x:514', facility: local9}]",
+		"bad label":      "sinks: [{name: a, type: loki, url: 'http://x', labels: {'my-label': x}}]",
+		"bad stream":     "sinks: [{name: a, type: http, url: 'http://x', stream: both}]",
+		"bad start_from": "sinks: [{name: a, type: http, url: 'http://x', start_from: middle}]",
+		"bad selector":   "sinks: [{name: a, type: http, url: 'http://x', selector: '=x'}]",
+		"duplicate":      "sinks: [{name: a, type: http, url: 'http://x'}, {name: a, type: http, url: 'http://y'}]",
+	} {
+		if _, err := ParseConfig([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSyslogSink_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer listener.Close()
+
+	messages := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			// Octet counting: "LEN SP MSG"
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			message := make([]byte, n)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				return
+			}
+			messages <- string(message)
+		}
+	}()
+
+	cfg := SinkConfig{Name: "syslog", Type: TypeSyslog, Address: listener.Addr().String(), Protocol: "tcp", Facility: "local0", Hostname: "docker-1", Timeout: time.Second}
+	sink, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer sink.(io.Closer).Close()
+
+	stderr := testRecord("multi\nline")
+	stderr.Stream = "stderr"
+	stderr.Stack = `we"ird]`
+	if err := sink.Send(context.Background(), []Record{testRecord("hello world"), stderr}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	want := []string{
+		`<134>1 2025-03-01T12:00:00.123456Z docker-1 shop-api-1 aaaaaaaaaaaa stdout [kubevision@32473 host="local" stack="shop" service="api"] hello world`,
+		`<131>1 2025-03-01T12:00:00.123456Z docker-1 shop-api-1 aaaaaaaaaaaa stderr [kubevision@32473 host="local" stack="we\"ird\]" service="api"] multi` + "\nline",
+	}
+	for _, expected := range want {
+		select {
+		case got := <-messages:
+			if got != expected {
+				t.Errorf("Unexpected message:\n got %q\nwant %q", got, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for syslog message")
+		}
+	}
+}
+
+func TestSyslogSink_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer conn.Close()
+
+	sink, err := New(SinkConfig{Name: "syslog", Type: TypeSyslog, Address: conn.LocalAddr().String(), Protocol: "udp", Facility: "user", Hostname: "my host", Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer sink.(io.Closer).Close()
+
+	record := testRecord("no metadata")
+	record.Stack, record.Service, record.Container = "", "", ""
+	if err := sink.Send(context.Background(), []Record{record}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	want := `<14>1 2025-03-01T12:00:00.123456Z my_host - aaaaaaaaaaaa stdout [kubevision@32473 host="local"] no metadata`
+	if string(buf[:n]) != want {
+		t.Errorf("Unexpected datagram:\n got %q\nwant %q", buf[:n], want)
+	}
+}
+
+func TestLokiSink(t *testing.T) {
+	var push lokiPush
+	var tenant string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant = r.Header.Get("X-Scope-OrgID")
+		if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+			t.Errorf("Invalid push body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink, _ := New(SinkConfig{Name: "loki", Type: TypeLoki, URL: server.URL, TenantID: "team-a", Labels: map[string]string{"env": "prod"}, Timeout: time.Second})
+	second := testRecord("second")
+	second.Timestamp = second.Timestamp.Add(time.Nanosecond)
+	stderr := testRecord("oops")
+	stderr.Stream = "stderr"
+	if err := sink.Send(context.Background(), []Record{testRecord("first"), stderr, second}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if tenant != "team-a" || len(push.Streams) != 2 {
+		t.Fatalf("Expected two streams for team-a, got %q %+v", tenant, push)
+	}
+	stdout := push.Streams[0]
+	labels := stdout.Stream
+	if labels["env"] != "prod" || labels["host"] != "local" || labels["container"] != "shop-api-1" ||
+		labels["stack"] != "shop" || labels["service"] != "api" || labels["stream"] != "stdout" {
+		t.Errorf("Unexpected labels: %v", labels)
+	}
+	ns := strconv.FormatInt(testRecord("").Timestamp.UnixNano(), 10)
+	if len(stdout.Values) != 2 || stdout.Values[0] != [2]string{ns, "first"} || stdout.Values[1][1] != "second" {
+		t.Errorf("Unexpected values: %v", stdout.Values)
+	}
+	if push.Streams[1].Stream["stream"] != "stderr" || push.Streams[1].Values[0][1] != "oops" {
+		t.Errorf("Unexpected stderr stream: %+v", push.Streams[1])
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	status := http.StatusOK
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-ndjson" || r.Header.Get("Authorization") != "Bearer t0ken" {
+			t.Errorf("Unexpected headers: %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		lines = strings.Split(strings.TrimSpace(string(body)), "\n")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink, _ := New(SinkConfig{Name: "http", Type: TypeHTTP, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer t0ken"}, Timeout: time.Second})
+	if err := sink.Send(context.Background(), []Record{testRecord("<a>"), testRecord("b")}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	var record Record
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &record) != nil || record.Line != "<a>" || record.Stack != "shop" {
+		t.Errorf("Unexpected NDJSON body: %v", lines)
+	}
+
+	status = http.StatusBadRequest
+	if err := sink.Send(context.Background(), []Record{testRecord("c")}); err == nil || !isPermanent(err) {
+		t.Errorf("Expected permanent error for 400, got %v", err)
+	}
+	status = http.StatusTooManyRequests
+	if err := sink.Send(context.Background(), []Record{testRecord("c")}); err == nil || isPermanent(err) {
+		t.Errorf("Expected retryable error for 429, got %v", err)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "positions.json")
+	positions, err := OpenPositions(path)
+	if err != nil {
+		t.Fatalf("OpenPositions failed: %v", err)
+	}
+
+	at := func(s int) time.Time { return logBase.Add(time.Duration(s) * time.Second) }
+	records := []Record{
+		{Host: "local", ContainerID: "a", Timestamp: at(1)},
+		{Host: "local", ContainerID: "a", Timestamp: at(2)},
+		{Host: "local", ContainerID: "a", Timestamp: at(2)},
+		{Host: "local", ContainerID: "b", Timestamp: at(1)},
+		{Host: "local", ContainerID: "b"}, // no timestamp
+		{Host: "remote", ContainerID: "c", Timestamp: at(3)},
+	}
+	if err := positions.Advance("loki", records); err != nil {
+		t.Fatalf("Advance failed: %v", err)
+	}
+
+	reopened, err := OpenPositions(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if position, _ := reopened.Get("loki", "local/a"); !position.Time.Equal(at(2)) || position.Seen != 2 {
+		t.Errorf("Expected two lines seen at 12:00:02, got %+v", position)
+	}
+	if position, _ := reopened.Get("loki", "local/b"); !position.Time.Equal(at(1)) || position.Seen != 1 {
+		t.Errorf("Unexpected position for b: %+v", position)
+	}
+	if _, ok := reopened.Get("http", "local/a"); ok {
+		t.Error("Expected positions to be per sink")
+	}
+
+	// Only the listed host's removed containers are pruned
+	if err := reopened.Prune("loki", "local", map[string]bool{"local/b": true}); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if _, ok := reopened.Get("loki", "local/a"); ok {
+		t.Error("Expected removed container to be pruned")
+	}
+	if _, ok := reopened.Get("loki", "remote/c"); !ok {
+		t.Error("Expected other hosts to be kept")
+	}
+}
+
+// logEntry is a line of a fake container log
+type logEntry struct {
+	stream string
+	at     time.Time
+	text   string
+}
+
+// fakeDockerClient serves fixed container logs, honouring since
+type fakeDockerClient struct {
+	mu         sync.Mutex
+	containers []container.Summary
+	logs       map[string][]logEntry
+}
+
+func (f *fakeDockerClient) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]container.Summary(nil), f.containers...), nil
+}
+
+func (f *fakeDockerClient) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	return container.InspectResponse{Config: &container.Config{}}, nil
+}
+
+func (f *fakeDockerClient) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries, ok := f.logs[containerID]
+	if !ok {
+		return nil, cerrdefs.ErrNotFound
+	}
+
+	var since time.Time
+	if options.Since != "" {
+		seconds, nanos, _ := strings.Cut(options.Since, ".")
+		s, _ := strconv.ParseInt(seconds, 10, 64)
+		n, _ := strconv.ParseInt(nanos, 10, 64)
+		since = time.Unix(s, n)
+	}
+
+	var buf bytes.Buffer
+	stdout, stderr := stdcopy.NewStdWriter(&buf, stdcopy.Stdout), stdcopy.NewStdWriter(&buf, stdcopy.Stderr)
+	for _, entry := range entries {
+		if entry.at.Before(since) {
+			continue
+		}
+		line := entry.at.Format(time.RFC3339Nano) + " " + entry.text + "\n"
+		if entry.stream == "stderr" {
+			_, _ = stderr.Write([]byte(line))
+		} else {
+			_, _ = stdout.Write([]byte(line))
+		}
+	}
+	return io.NopCloser(&buf), nil
+}
+
+func (f *fakeDockerClient) log(containerID string, entries ...logEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logs[containerID] = append(f.logs[containerID], entries...)
+}
+
+// collector is an HTTP sink endpoint recording delivered lines, failing the
+// first failures requests
+type collector struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	lines    []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	if c.requests <= c.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var record Record
+		_ = json.Unmarshal(scanner.Bytes(), &record)
+		c.lines = append(c.lines, record.Container+":"+record.Stream+":"+record.Line)
+	}
+}
+
+func (c *collector) delivered() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.lines...)
+}
+
+// forwardUntil runs a forwarder until the collector has n lines
+func forwardUntil(t *testing.T, client *fakeDockerClient, cfg SinkConfig, positionsPath string, sink *collector, n int) {
+	t.Helper()
+	positions, err := OpenPositions(positionsPath)
+	if err != nil {
+		t.Fatalf("OpenPositions failed: %v", err)
+	}
+	forwarder, err := NewForwarder(Config{DiscoveryInterval: 10 * time.Millisecond, Sinks: []SinkConfig{cfg}}, positions, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewForwarder failed: %v", err)
+	}
+	forwarder.AddHost("local", client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		forwarder.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.delivered()) < n && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Let discovery re-read the logs a few times to catch duplicates
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+}
+
+func TestForwarder_ResumesWithoutDuplicates(t *testing.T) {
+	at := func(s int) time.Time { return logBase.Add(time.Duration(s) * time.Second) }
+	client := &fakeDockerClient{
+		containers: []container.Summary{
+			{ID: "aaaaaaaaaaaa1111", Names: []string{"/api"}, State: container.StateRunning, Labels: map[string]string{"forward": "true"}},
+			{ID: "bbbbbbbbbbbb2222", Names: []string{"/worker"}, State: container.StateExited, Labels: map[string]string{"forward": "true"}},
+			{ID: "cccccccccccc3333", Names: []string{"/db"}, State: container.StateRunning},
+		},
+		logs: map[string][]logEntry{
+			"aaaaaaaaaaaa1111": {
+				{"stdout", at(1), "one"},
+				{"stderr", at(2), "two"},
+				{"stdout", at(2), "three"},
+			},
+			"bbbbbbbbbbbb2222": {{"stdout", at(1), "done"}},
+			"cccccccccccc3333": {{"stdout", at(1), "not selected"}},
+		},
+	}
+	sink := &collector{failures: 2}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	cfg := SinkConfig{Name: "http", Type: TypeHTTP, URL: server.URL, Selector: "forward=true", BatchSize: 2, BatchInterval: 5 * time.Millisecond}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	cfg.Retry = RetryConfig{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+	path := filepath.Join(t.TempDir(), "positions.json")
+	forwardUntil(t, client, cfg, path, sink, 4)
+
+	got := sink.delivered()
+	if len(got) != 4 || sink.requests <= sink.failures {
+		t.Fatalf("Expected 4 lines after retries, got %v", got)
+	}
+	var api []string
+	for _, line := range got {
+		if strings.HasPrefix(line, "api:") {
+			api = append(api, line)
+		}
+	}
+	if strings.Join(api, ",") != "api:stdout:one,api:stderr:two,api:stdout:three" {
+		t.Errorf("Expected api lines in order, got %v", api)
+	}
+
+	// After a restart only new lines are forwarded, including one logged at
+	// the same timestamp as the last delivered line
+	client.log("aaaaaaaaaaaa1111", logEntry{"stdout", at(2), "four"}, logEntry{"stdout", at(3), "five"})
+	forwardUntil(t, client, cfg, path, sink, 6)
+
+	got = sink.delivered()
+	if len(got) != 6 || got[4] != "api:stdout:four" || got[5] != "api:stdout:five" {
+		t.Errorf("Expected exactly the two new lines after restart, got %v", got)
+	}
+}
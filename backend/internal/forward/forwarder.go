@@ -0,0 +1,388 @@
+package forward
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/logparse"
+)
+
+// Compose labels copied onto records
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+)
+
+// dockerClient is the part of the Docker API the forwarder uses
+type dockerClient interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+}
+
+// Forwarder tails the logs of matching containers on every host and ships
+// them to sinks. Each sink has its own pipeline: a tailer per container
+// feeding a bounded queue, so a slow sink blocks its tailers rather than
+// buffering without limit, and a batcher that delivers the queue and
+// advances the sink's persisted positions once a batch is delivered.
+type Forwarder struct {
+	hosts     map[string]dockerClient
+	pipelines []*pipeline
+	positions *Positions
+	interval  time.Duration
+	tailers   sync.WaitGroup
+	logger    *zap.Logger
+}
+
+// NewForwarder creates a forwarder from the log forwarding config
+func NewForwarder(cfg Config, positions *Positions, logger *zap.Logger) (*Forwarder, error) {
+	f := &Forwarder{
+		hosts:     make(map[string]dockerClient),
+		positions: positions,
+		interval:  cfg.DiscoveryInterval,
+		logger:    logger,
+	}
+
+	for _, sinkCfg := range cfg.Sinks {
+		sink, err := New(sinkCfg)
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %w", sinkCfg.Name, err)
+		}
+		f.AddSink(sink, sinkCfg)
+	}
+
+	return f, nil
+}
+
+// AddSink registers a sink configured by cfg. It must be called before Run.
+func (f *Forwarder) AddSink(sink Sink, cfg SinkConfig) {
+	var since time.Time
+	if cfg.StartFrom == StartEnd {
+		since = time.Now()
+	}
+	f.pipelines = append(f.pipelines, &pipeline{
+		cfg:       cfg,
+		sink:      sink,
+		queue:     make(chan Record, cfg.BufferSize),
+		positions: f.positions,
+		since:     since,
+		cursors:   make(map[string]Position),
+		tailing:   make(map[string]bool),
+		drained:   make(map[string]bool),
+		logger:    f.logger.With(zap.String("sink", sink.Name())),
+	})
+}
+
+// AddHost registers a Docker host to forward logs from. It must be called
+// before Run.
+func (f *Forwarder) AddHost(name string, client dockerClient) {
+	f.hosts[name] = client
+}
+
+// Run discovers containers immediately and then every discovery interval
+// until ctx is cancelled, then flushes what the sinks have queued
+func (f *Forwarder) Run(ctx context.Context) {
+	var batchers sync.WaitGroup
+	for _, p := range f.pipelines {
+		batchers.Add(1)
+		go func(p *pipeline) {
+			defer batchers.Done()
+			p.run(ctx)
+		}(p)
+	}
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		f.discover(ctx)
+		select {
+		case <-ctx.Done():
+			f.tailers.Wait()
+			batchers.Wait()
+			for _, p := range f.pipelines {
+				if closer, ok := p.sink.(io.Closer); ok {
+					closer.Close()
+				}
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// discover lists every host's containers and starts tailers for those that
+// are not tailed yet
+func (f *Forwarder) discover(ctx context.Context) {
+	for name, client := range f.hosts {
+		if ctx.Err() != nil {
+			return
+		}
+		containers, err := client.ContainerList(ctx, container.ListOptions{All: true})
+		if err != nil {
+			f.logger.Warn("Failed to list containers for log forwarding", zap.String("host", name), zap.Error(err))
+			continue
+		}
+		for _, p := range f.pipelines {
+			p.sync(ctx, &f.tailers, name, client, containers)
+		}
+	}
+}
+
+// pipeline forwards logs to one sink
+type pipeline struct {
+	cfg       SinkConfig
+	sink      Sink
+	queue     chan Record
+	positions *Positions
+	// since is where containers without a position start
+	since  time.Time
+	logger *zap.Logger
+
+	mu sync.Mutex
+	// cursors is the position of the last queued line per container, ahead
+	// of the persisted position while lines are queued or being delivered
+	cursors map[string]Position
+	tailing map[string]bool
+	// drained marks containers whose log was read to the end since they
+	// last ran, so stopped containers are not read again
+	drained map[string]bool
+}
+
+// sync starts tailing a host's matching containers: running ones are
+// followed, stopped ones are read to the end once to pick up lines logged
+// while they were not tailed
+func (p *pipeline) sync(ctx context.Context, tailers *sync.WaitGroup, host string, client dockerClient, containers []container.Summary) {
+	present := make(map[string]bool, len(containers))
+	for i := range containers {
+		ctr := &containers[i]
+		key := positionKey(host, ctr.ID)
+		present[key] = true
+		if !p.cfg.selector.Matches(ctr.Labels) {
+			continue
+		}
+
+		follow := ctr.State == container.StateRunning
+		p.mu.Lock()
+		if p.tailing[key] || (!follow && p.drained[key]) {
+			p.mu.Unlock()
+			continue
+		}
+		p.tailing[key] = true
+		p.drained[key] = false
+		start := p.cursor(key)
+		p.mu.Unlock()
+
+		record := Record{
+			Host:        host,
+			ContainerID: ctr.ID,
+			Container:   containerName(ctr),
+			Stack:       ctr.Labels[composeProjectLabel],
+			Service:     ctr.Labels[composeServiceLabel],
+		}
+		tailers.Add(1)
+		go func() {
+			defer tailers.Done()
+			p.tail(ctx, client, record, start, follow)
+		}()
+	}
+
+	// Forget removed containers
+	p.mu.Lock()
+	for key := range p.drained {
+		if strings.HasPrefix(key, host+"/") && !present[key] && !p.tailing[key] {
+			delete(p.cursors, key)
+			delete(p.drained, key)
+		}
+	}
+	p.mu.Unlock()
+	if err := p.positions.Prune(p.cfg.Name, host, present); err != nil {
+		p.logger.Error("Failed to save log forwarding positions", zap.Error(err))
+	}
+}
+
+// cursor returns where tailing a container resumes. p.mu must be held.
+func (p *pipeline) cursor(key string) Position {
+	if position, ok := p.cursors[key]; ok {
+		return position
+	}
+	if position, ok := p.positions.Get(p.cfg.Name, key); ok {
+		return position
+	}
+	return Position{Time: p.since}
+}
+
+// tail queues a container's log lines after start
+func (p *pipeline) tail(ctx context.Context, client dockerClient, record Record, start Position, follow bool) {
+	key := positionKey(record.Host, record.ContainerID)
+	err := p.read(ctx, client, record, start, follow)
+
+	p.mu.Lock()
+	delete(p.tailing, key)
+	p.drained[key] = err == nil
+	p.mu.Unlock()
+
+	if err != nil && ctx.Err() == nil {
+		p.logger.Warn("Failed to read container logs for forwarding",
+			zap.String("host", record.Host),
+			zap.String("container_id", record.ContainerID),
+			zap.Error(err))
+	}
+}
+
+// read queues the lines of a container's log, skipping those at or before
+// start that were already queued or delivered
+func (p *pipeline) read(ctx context.Context, client dockerClient, record Record, start Position, follow bool) error {
+	key := positionKey(record.Host, record.ContainerID)
+
+	inspect, err := client.ContainerInspect(ctx, record.ContainerID)
+	if err != nil {
+		return err
+	}
+	options := container.LogsOptions{
+		ShowStdout: p.cfg.Stream != "stderr",
+		ShowStderr: p.cfg.Stream != "stdout",
+		Timestamps: true,
+		Follow:     follow,
+	}
+	if !start.Time.IsZero() {
+		// Since is inclusive, so lines at start.Time are read again and the
+		// ones already seen skipped
+		options.Since = fmt.Sprintf("%d.%09d", start.Time.Unix(), start.Time.Nanosecond())
+	}
+	reader, err := client.ContainerLogs(ctx, record.ContainerID, options)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	tty := inspect.Config != nil && inspect.Config.Tty
+	skip := start.Seen
+	return logparse.ReadLines(reader, tty, func(stream string, line []byte) error {
+		timestamp, text, ok := logparse.SplitTimestamp(line)
+		if !ok {
+			text = line
+		} else if timestamp.Before(start.Time) {
+			return nil
+		} else if timestamp.Equal(start.Time) && skip > 0 {
+			skip--
+			return nil
+		}
+
+		record := record
+		record.Stream = stream
+		record.Timestamp = timestamp
+		record.Line = string(text)
+		select {
+		case p.queue <- record:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		p.mu.Lock()
+		if position, ok := p.cursors[key]; ok {
+			p.cursors[key] = position.advance(timestamp)
+		} else {
+			p.cursors[key] = start.advance(timestamp)
+		}
+		p.mu.Unlock()
+		return nil
+	})
+}
+
+// run batches queued records until ctx is cancelled, sending a batch when it
+// is full or the batch interval passes, then gives what is left a last
+// chance with a bounded deadline
+func (p *pipeline) run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.BatchInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, p.cfg.BatchSize)
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case record := <-p.queue:
+			batch = append(batch, record)
+			if len(batch) >= p.cfg.BatchSize && p.deliver(ctx, batch) == nil {
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 && p.deliver(ctx, batch) == nil {
+				batch = batch[:0]
+			}
+		}
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for {
+		select {
+		case record := <-p.queue:
+			batch = append(batch, record)
+			if len(batch) < p.cfg.BatchSize {
+				continue
+			}
+		default:
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := p.deliver(flushCtx, batch); err != nil {
+			// Undelivered lines are read again after a restart
+			p.logger.Warn("Dropped queued log lines on shutdown", zap.Int("lines", len(batch)+len(p.queue)))
+			return
+		}
+		batch = batch[:0]
+	}
+}
+
+// deliver sends a batch until it is delivered, fails permanently or ctx is
+// cancelled, doubling the backoff between attempts, and then advances the
+// persisted positions. A permanently failed batch is dropped. The batch is
+// kept, and ctx's error returned, only if ctx ends first.
+func (p *pipeline) deliver(ctx context.Context, batch []Record) error {
+	backoff := p.cfg.Retry.InitialBackoff
+	for {
+		err := p.sink.Send(ctx, batch)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if isPermanent(err) {
+			p.logger.Error("Dropped log batch rejected by sink", zap.Int("lines", len(batch)), zap.Error(err))
+			break
+		}
+		p.logger.Warn("Failed to forward logs, retrying",
+			zap.Int("lines", len(batch)),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, p.cfg.Retry.MaxBackoff)
+	}
+
+	if err := p.positions.Advance(p.cfg.Name, batch); err != nil {
+		p.logger.Error("Failed to save log forwarding positions", zap.Error(err))
+	}
+	return nil
+}
+
+// containerName returns a container's name without the leading slash
+func containerName(ctr *container.Summary) string {
+	if len(ctr.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(ctr.Names[0], "/")
+}
@@ -0,0 +1,40 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// httpSink POSTs batches as newline-delimited JSON records
+type httpSink struct {
+	cfg    SinkConfig
+	client *http.Client
+}
+
+func newHTTPSink(cfg SinkConfig) *httpSink {
+	return &httpSink{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Name returns the sink name
+func (s *httpSink) Name() string {
+	return s.cfg.Name
+}
+
+// Send posts the records, one JSON object per line
+func (s *httpSink) Send(ctx context.Context, records []Record) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	encoder.SetEscapeHTML(false)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return &permanentError{err: fmt.Errorf("failed to encode record: %w", err)}
+		}
+	}
+	return post(ctx, s.client, s.cfg.URL, "application/x-ndjson", body.Bytes(), s.cfg.Headers)
+}
@@ -0,0 +1,103 @@
+package forward
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// lokiLabelPattern matches valid Loki label names
+var lokiLabelPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// lokiPush is the body of Loki's push API
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+// lokiStream holds the lines of one label set as [unix nanoseconds, line]
+// pairs
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiSink pushes batches to a Loki-compatible push API, one stream per
+// container and output stream
+type lokiSink struct {
+	cfg     SinkConfig
+	headers map[string]string
+	client  *http.Client
+}
+
+func newLokiSink(cfg SinkConfig) *lokiSink {
+	headers := make(map[string]string, len(cfg.Headers)+1)
+	for key, value := range cfg.Headers {
+		headers[key] = value
+	}
+	if cfg.TenantID != "" {
+		headers["X-Scope-OrgID"] = cfg.TenantID
+	}
+	return &lokiSink{
+		cfg:     cfg,
+		headers: headers,
+		client:  &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Name returns the sink name
+func (s *lokiSink) Name() string {
+	return s.cfg.Name
+}
+
+// Send pushes the records grouped by label set
+func (s *lokiSink) Send(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(s.push(records))
+	if err != nil {
+		return &permanentError{err: fmt.Errorf("failed to encode push request: %w", err)}
+	}
+	return post(ctx, s.client, s.cfg.URL, "application/json", body, s.headers)
+}
+
+// push groups records into streams, keeping their order within each stream
+func (s *lokiSink) push(records []Record) lokiPush {
+	var push lokiPush
+	streams := make(map[string]int)
+	for _, record := range records {
+		key := record.Host + "/" + record.ContainerID + "/" + record.Stream
+		i, ok := streams[key]
+		if !ok {
+			i = len(push.Streams)
+			streams[key] = i
+			push.Streams = append(push.Streams, lokiStream{Stream: s.labels(record)})
+		}
+		push.Streams[i].Values = append(push.Streams[i].Values, [2]string{
+			strconv.FormatInt(record.Timestamp.UnixNano(), 10),
+			record.Line,
+		})
+	}
+	return push
+}
+
+// labels is the label set of a record: the configured static labels plus
+// its host, container, stack, service and stream
+func (s *lokiSink) labels(record Record) map[string]string {
+	labels := make(map[string]string, len(s.cfg.Labels)+5)
+	for key, value := range s.cfg.Labels {
+		labels[key] = value
+	}
+	for key, value := range map[string]string{
+		"host":      record.Host,
+		"container": record.Container,
+		"stack":     record.Stack,
+		"service":   record.Service,
+		"stream":    record.Stream,
+	} {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+	return labels
+}
@@ -0,0 +1,151 @@
+package forward
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Position is how far a container's log has been delivered to a sink.
+// Docker timestamps are not unique, so besides the timestamp of the last
+// delivered line it counts the delivered lines sharing that timestamp;
+// reading resumes at Time and skips Seen lines.
+type Position struct {
+	Time time.Time `json:"time"`
+	Seen int       `json:"seen"`
+}
+
+// advance returns the position after a line logged at t. Lines without a
+// timestamp or older than the position leave it unchanged.
+func (p Position) advance(t time.Time) Position {
+	switch {
+	case t.Equal(p.Time):
+		p.Seen++
+	case t.After(p.Time):
+		p = Position{Time: t, Seen: 1}
+	}
+	return p
+}
+
+// positionKey identifies a container across hosts
+func positionKey(host, containerID string) string {
+	return host + "/" + containerID
+}
+
+// positionsFile is the on-disk layout of the positions file
+type positionsFile struct {
+	Sinks map[string]map[string]Position `json:"sinks"`
+}
+
+// Positions persists per-sink, per-container positions in a JSON file
+type Positions struct {
+	path  string
+	mu    sync.Mutex
+	sinks map[string]map[string]Position
+}
+
+// OpenPositions loads the positions file, starting empty if it does not
+// exist yet
+func OpenPositions(path string) (*Positions, error) {
+	p := &Positions{
+		path:  path,
+		sinks: make(map[string]map[string]Position),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read positions file: %w", err)
+	}
+
+	var file positionsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse positions file: %w", err)
+	}
+	for sink, positions := range file.Sinks {
+		if positions != nil {
+			p.sinks[sink] = positions
+		}
+	}
+	return p, nil
+}
+
+// Get returns a sink's position for a container
+func (p *Positions) Get(sink, key string) (Position, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	position, ok := p.sinks[sink][key]
+	return position, ok
+}
+
+// Advance moves a sink's positions past delivered records and saves them
+func (p *Positions) Advance(sink string, records []Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	positions := p.sinks[sink]
+	if positions == nil {
+		positions = make(map[string]Position)
+		p.sinks[sink] = positions
+	}
+	changed := false
+	for _, record := range records {
+		if record.Timestamp.IsZero() {
+			continue
+		}
+		key := positionKey(record.Host, record.ContainerID)
+		positions[key] = positions[key].advance(record.Timestamp)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return p.save()
+}
+
+// Prune drops a sink's positions for containers of host that are not in
+// keep, i.e. containers that were removed
+func (p *Positions) Prune(sink, host string, keep map[string]bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pruned := false
+	for key := range p.sinks[sink] {
+		if strings.HasPrefix(key, host+"/") && !keep[key] {
+			delete(p.sinks[sink], key)
+			pruned = true
+		}
+	}
+	if !pruned {
+		return nil
+	}
+	return p.save()
+}
+
+// save writes the positions atomically. p.mu must be held.
+func (p *Positions) save() error {
+	data, err := json.Marshal(positionsFile{Sinks: p.sinks})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create positions directory: %w", err)
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write positions file: %w", err)
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		return fmt.Errorf("failed to replace positions file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Record is a log line forwarded to sinks
+type Record struct {
+	Host        string    `json:"host"`
+	ContainerID string    `json:"container_id"`
+	Container   string    `json:"container"`
+	Stack       string    `json:"stack,omitempty"`
+	Service     string    `json:"service,omitempty"`
+	Stream      string    `json:"stream"`
+	Timestamp   time.Time `json:"timestamp"`
+	Line        string    `json:"line"`
+}
+
+// Sink ships batches of log records to an external system. Send either
+// delivers the whole batch or returns an error; a failed batch is retried
+// as a whole, so sinks may see lines twice but never lose them.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, records []Record) error
+}
+
+// permanentError marks a delivery failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// isPermanent reports whether err must not be retried
+func isPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// New creates a sink from its configuration
+func New(cfg SinkConfig) (Sink, error) {
+	switch cfg.Type {
+	case TypeSyslog:
+		return newSyslogSink(cfg)
+	case TypeLoki:
+		return newLokiSink(cfg), nil
+	case TypeHTTP:
+		return newHTTPSink(cfg), nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", cfg.Type)
+}
+
+// post sends body, treating 4xx responses other than 429 as permanent
+func post(ctx context.Context, client *http.Client, url, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err: fmt.Errorf("failed to create request: %w", err)}
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "KubeVision")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{err: err}
+	}
+	return err
+}
@@ -0,0 +1,197 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// syslogSDID is the structured data element carrying container metadata
+	// (32473 is the enterprise number reserved for documentation, RFC 5612)
+	syslogSDID = "kubevision@32473"
+
+	// syslogTimeFormat is RFC 5424's timestamp with its maximum precision
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+	// maxUDPMessageSize is the largest syslog message sent in a datagram;
+	// longer messages are truncated
+	maxUDPMessageSize = 65507
+)
+
+// Syslog severities of container output
+const (
+	syslogSeverityError = 3
+	syslogSeverityInfo  = 6
+)
+
+// syslogFacilities maps facility names to their codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSink sends RFC 5424 messages over TCP, with octet-counting framing
+// (RFC 6587), or UDP, one message per datagram. The connection is opened on
+// first use and reopened after a write error.
+type syslogSink struct {
+	cfg      SinkConfig
+	facility int
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newSyslogSink(cfg SinkConfig) (*syslogSink, error) {
+	facility, ok := syslogFacilities[cfg.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown facility %q", cfg.Facility)
+	}
+	hostname := cfg.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	return &syslogSink{
+		cfg:      cfg,
+		facility: facility,
+		hostname: syslogHeaderField(hostname, 255),
+	}, nil
+}
+
+// Name returns the sink name
+func (s *syslogSink) Name() string {
+	return s.cfg.Name
+}
+
+// Send writes the records as syslog messages
+func (s *syslogSink) Send(ctx context.Context, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		dialer := net.Dialer{Timeout: s.cfg.Timeout}
+		conn, err := dialer.DialContext(ctx, s.cfg.Protocol, s.cfg.Address)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		s.conn = conn
+	}
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.cfg.Timeout)); err != nil {
+		return s.fail(err)
+	}
+
+	if s.cfg.Protocol == "udp" {
+		for _, record := range records {
+			message := s.format(record)
+			if len(message) > maxUDPMessageSize {
+				message = message[:maxUDPMessageSize]
+			}
+			if _, err := s.conn.Write(message); err != nil {
+				return s.fail(err)
+			}
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, record := range records {
+		message := s.format(record)
+		buf.WriteString(strconv.Itoa(len(message)))
+		buf.WriteByte(' ')
+		buf.Write(message)
+	}
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		return s.fail(err)
+	}
+	return nil
+}
+
+// Close closes the connection
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// fail drops the connection after a write error so the retry reconnects.
+// s.mu must be held.
+func (s *syslogSink) fail(err error) error {
+	s.conn.Close()
+	s.conn = nil
+	return fmt.Errorf("failed to write: %w", err)
+}
+
+// format renders a record as an RFC 5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+func (s *syslogSink) format(record Record) []byte {
+	severity := syslogSeverityInfo
+	if record.Stream == "stderr" {
+		severity = syslogSeverityError
+	}
+	timestamp := "-"
+	if !record.Timestamp.IsZero() {
+		timestamp = record.Timestamp.UTC().Format(syslogTimeFormat)
+	}
+	containerID := record.ContainerID
+	if len(containerID) > 12 {
+		containerID = containerID[:12]
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s %s [%s",
+		s.facility*8+severity,
+		timestamp,
+		s.hostname,
+		syslogHeaderField(record.Container, 48),
+		syslogHeaderField(containerID, 128),
+		syslogHeaderField(record.Stream, 32),
+		syslogSDID,
+	)
+	for _, param := range [][2]string{
+		{"host", record.Host},
+		{"stack", record.Stack},
+		{"service", record.Service},
+	} {
+		if param[1] != "" {
+			fmt.Fprintf(&buf, " %s=\"%s\"", param[0], syslogParamEscaper.Replace(param[1]))
+		}
+	}
+	buf.WriteString("] ")
+	buf.WriteString(record.Line)
+	return buf.Bytes()
+}
+
+// syslogParamEscaper escapes structured data parameter values
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogHeaderField makes value a valid header field: printable ASCII
+// without spaces, at most limit characters and "-" when empty
+func syslogHeaderField(value string, limit int) string {
+	if value == "" {
+		return "-"
+	}
+	field := []byte(value)
+	if len(field) > limit {
+		field = field[:limit]
+	}
+	for i, c := range field {
+		if c < 33 || c > 126 {
+			field[i] = '_'
+		}
+	}
+	return string(field)
+}
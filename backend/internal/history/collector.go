@@ -0,0 +1,153 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+)
+
+// Observer receives every sample taken by a Collector
+type Observer func(ctr container.Summary, stats *docker.ContainerStats)
+
+// Collector periodically samples every running container, writes the
+// calculated stats to a Store (if any) and passes them to observers
+type Collector struct {
+	dockerClient interface {
+		ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+		ContainerStatsOneShot(ctx context.Context, containerID string) (container.StatsResponseReader, error)
+	}
+	store      *Store
+	calculator *docker.StatsCalculator
+	interval   time.Duration
+	observers  []Observer
+	logger     *zap.Logger
+}
+
+// NewCollector creates a new collector sampling at the given interval.
+// store may be nil when samples are only needed by observers.
+func NewCollector(dockerClient interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerStatsOneShot(ctx context.Context, containerID string) (container.StatsResponseReader, error)
+}, store *Store, interval time.Duration, logger *zap.Logger) *Collector {
+	return &Collector{
+		dockerClient: dockerClient,
+		store:        store,
+		// The collector owns its calculator so CPU deltas span one interval
+		calculator: docker.NewStatsCalculator(logger),
+		interval:   interval,
+		logger:     logger,
+	}
+}
+
+// AddObserver registers an observer. It must be called before Run.
+func (c *Collector) AddObserver(observer Observer) {
+	c.observers = append(c.observers, observer)
+}
+
+// Run samples containers until ctx is cancelled, pruning expired data hourly
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	pruneTicker := time.NewTicker(time.Hour)
+	defer pruneTicker.Stop()
+
+	c.prune()
+
+	seen := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			if c.store != nil {
+				if err := c.store.Close(); err != nil {
+					c.logger.Warn("Failed to flush metrics history", zap.Error(err))
+				}
+			}
+			return
+		case <-pruneTicker.C:
+			c.prune()
+		case <-ticker.C:
+			seen = c.sample(ctx, seen)
+		}
+	}
+}
+
+// sample takes one sample of every running container and returns the set of
+// container IDs seen, resetting calculator state for containers that went away
+func (c *Collector) sample(ctx context.Context, previous map[string]bool) map[string]bool {
+	listCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	containers, err := c.dockerClient.ContainerList(listCtx, container.ListOptions{})
+	cancel()
+	if err != nil {
+		c.logger.Warn("Failed to list containers for metrics history", zap.Error(err))
+		return previous
+	}
+
+	seen := make(map[string]bool, len(containers))
+	for _, ctr := range containers {
+		seen[ctr.ID] = true
+		if err := c.sampleContainer(ctx, ctr); err != nil {
+			c.logger.Debug("Failed to sample container stats",
+				zap.String("container_id", ctr.ID),
+				zap.Error(err))
+		}
+	}
+
+	for containerID := range previous {
+		if !seen[containerID] {
+			c.calculator.ResetStats(containerID)
+		}
+	}
+
+	if c.store != nil {
+		if err := c.store.Flush(time.Now()); err != nil {
+			c.logger.Warn("Failed to flush metrics history", zap.Error(err))
+		}
+	}
+
+	return seen
+}
+
+func (c *Collector) prune() {
+	if c.store == nil {
+		return
+	}
+	if err := c.store.Prune(time.Now()); err != nil {
+		c.logger.Warn("Failed to prune metrics history", zap.Error(err))
+	}
+}
+
+func (c *Collector) sampleContainer(ctx context.Context, ctr container.Summary) error {
+	statsCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	stats, err := c.dockerClient.ContainerStatsOneShot(statsCtx, ctr.ID)
+	if err != nil {
+		return err
+	}
+	defer stats.Body.Close()
+
+	var statsJSON container.StatsResponse
+	if err := json.NewDecoder(stats.Body).Decode(&statsJSON); err != nil {
+		return err
+	}
+
+	calculatedStats, err := c.calculator.CalculateStats(ctr.ID, &statsJSON)
+	if err != nil {
+		return err
+	}
+
+	for _, observer := range c.observers {
+		observer(ctr, calculatedStats)
+	}
+
+	if c.store == nil {
+		return nil
+	}
+	return c.store.Append(calculatedStats)
+}
@@ -0,0 +1,75 @@
+package history
+
+import (
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/kubevision/kubevision/internal/docker"
+)
+
+// Latest keeps the most recent sample of every container, for endpoints
+// that show current usage without subscribing to stats streams. A nil
+// Latest has no samples.
+type Latest struct {
+	mu         sync.RWMutex
+	samples    map[string]latestSample
+	staleAfter time.Duration
+	lastSweep  time.Time
+	now        func() time.Time
+}
+
+// latestSample is a stats sample and when it was observed
+type latestSample struct {
+	stats docker.ContainerStats
+	at    time.Time
+}
+
+// NewLatest creates an empty sample cache. Samples older than staleAfter
+// are ignored and eventually dropped.
+func NewLatest(staleAfter time.Duration) *Latest {
+	return &Latest{
+		samples:    make(map[string]latestSample),
+		staleAfter: staleAfter,
+		now:        time.Now,
+	}
+}
+
+// Observer returns a collector observer recording samples for a host
+func (l *Latest) Observer(host string) Observer {
+	return func(ctr container.Summary, stats *docker.ContainerStats) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		now := l.now()
+		l.samples[host+"/"+ctr.ID] = latestSample{stats: *stats, at: now}
+
+		// Drop containers that stopped reporting, at most once per period
+		if now.Sub(l.lastSweep) < l.staleAfter {
+			return
+		}
+		l.lastSweep = now
+		for key, sample := range l.samples {
+			if now.Sub(sample.at) > l.staleAfter {
+				delete(l.samples, key)
+			}
+		}
+	}
+}
+
+// Get returns the latest non-stale sample of a container on a host
+func (l *Latest) Get(host, containerID string) (docker.ContainerStats, bool) {
+	if l == nil {
+		return docker.ContainerStats{}, false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	sample, ok := l.samples[host+"/"+containerID]
+	if !ok || l.now().Sub(sample.at) > l.staleAfter {
+		return docker.ContainerStats{}, false
+	}
+	return sample.stats, true
+}
@@ -0,0 +1,43 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/kubevision/kubevision/internal/docker"
+)
+
+func TestLatest_KeepsFreshSamplesPerHost(t *testing.T) {
+	now := time.Now()
+	latest := NewLatest(time.Minute)
+	latest.now = func() time.Time { return now }
+
+	observe := latest.Observer("local")
+	observe(container.Summary{ID: testContainerID}, &docker.ContainerStats{CPUPercent: 10})
+	observe(container.Summary{ID: testContainerID}, &docker.ContainerStats{CPUPercent: 20})
+
+	stats, ok := latest.Get("local", testContainerID)
+	if !ok || stats.CPUPercent != 20 {
+		t.Errorf("Expected latest sample, got %+v, %v", stats, ok)
+	}
+	if _, ok := latest.Get("remote", testContainerID); ok {
+		t.Error("Samples must be scoped to their host")
+	}
+
+	// Stale samples are ignored and swept on the next observation
+	now = now.Add(2 * time.Minute)
+	if _, ok := latest.Get("local", testContainerID); ok {
+		t.Error("Expected stale sample to be ignored")
+	}
+	observe(container.Summary{ID: "other"}, &docker.ContainerStats{})
+	if len(latest.samples) != 1 {
+		t.Errorf("Expected stale sample to be dropped, have %d samples", len(latest.samples))
+	}
+
+	var disabled *Latest
+	if _, ok := disabled.Get("local", testContainerID); ok {
+		t.Error("A nil Latest must have no samples")
+	}
+}
@@ -0,0 +1,405 @@
+package history
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/utils"
+)
+
+const (
+	// recordSize is the encoded size of a single sample on disk
+	// (timestamp + 9 fields, 8 bytes each)
+	recordSize = 80
+
+	// segmentLayout names one segment file per UTC day
+	segmentLayout = "2006-01-02"
+
+	// MaxQueryPoints caps the number of points returned by a single query
+	MaxQueryPoints = 2000
+)
+
+// Retention configures how long each resolution tier is kept on disk
+type Retention struct {
+	Raw    time.Duration
+	Minute time.Duration
+	Hour   time.Duration
+}
+
+// DefaultRetention keeps raw samples for a day, 1m rollups for a week and
+// 1h rollups for 90 days
+var DefaultRetention = Retention{
+	Raw:    24 * time.Hour,
+	Minute: 7 * 24 * time.Hour,
+	Hour:   90 * 24 * time.Hour,
+}
+
+// tier is one resolution level of the store
+type tier struct {
+	name      string
+	step      time.Duration // 0 for raw samples
+	retention time.Duration
+}
+
+// Store is an embedded on-disk time-series store for container stats.
+// Samples are written to the raw tier and rolled up into 1m and 1h tiers
+// as buckets complete. Each tier keeps one append-only segment file per
+// container per UTC day, so retention is enforced by deleting whole files.
+//
+// Rollups average gauges (CPU and memory) and keep the last value of
+// cumulative counters (network, block I/O) and PIDs.
+type Store struct {
+	dir     string
+	tiers   []tier
+	mu      sync.Mutex
+	rollups map[string][]*bucket // container ID -> open bucket per rollup tier
+	logger  *zap.Logger
+}
+
+// bucket accumulates samples for one rollup interval
+type bucket struct {
+	start     time.Time
+	count     int
+	cpuSum    float64
+	memSum    float64
+	memPctSum float64
+	last      docker.ContainerStats
+}
+
+// Open opens (creating if needed) a store rooted at dir
+func Open(dir string, retention Retention, logger *zap.Logger) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+
+	return &Store{
+		dir: dir,
+		tiers: []tier{
+			{name: "raw", retention: retention.Raw},
+			{name: "1m", step: time.Minute, retention: retention.Minute},
+			{name: "1h", step: time.Hour, retention: retention.Hour},
+		},
+		rollups: make(map[string][]*bucket),
+		logger:  logger,
+	}, nil
+}
+
+// Append writes a sample to the raw tier and feeds the rollup tiers
+func (s *Store) Append(stats *docker.ContainerStats) error {
+	if !utils.ValidateContainerID(stats.ContainerID) {
+		return fmt.Errorf("invalid container ID %q", stats.ContainerID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.writeRecord(s.tiers[0], *stats); err != nil {
+		return err
+	}
+
+	buckets, ok := s.rollups[stats.ContainerID]
+	if !ok {
+		buckets = make([]*bucket, len(s.tiers)-1)
+		s.rollups[stats.ContainerID] = buckets
+	}
+
+	for i, t := range s.tiers[1:] {
+		start := stats.Timestamp.Truncate(t.step)
+		b := buckets[i]
+		if b != nil && !b.start.Equal(start) {
+			if err := s.writeRecord(t, b.aggregate()); err != nil {
+				return err
+			}
+			b = nil
+		}
+		if b == nil {
+			b = &bucket{start: start}
+			buckets[i] = b
+		}
+		b.add(stats)
+	}
+
+	return nil
+}
+
+// Flush writes rollup buckets that ended before now, e.g. for containers
+// that stopped producing samples
+func (s *Store) Flush(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushLocked(func(t tier, b *bucket) bool {
+		return !b.start.Add(t.step).After(now)
+	})
+}
+
+// Close flushes every open rollup bucket
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushLocked(func(tier, *bucket) bool { return true })
+}
+
+func (s *Store) flushLocked(shouldFlush func(t tier, b *bucket) bool) error {
+	var errs []error
+	for containerID, buckets := range s.rollups {
+		open := false
+		for i, b := range buckets {
+			if b == nil {
+				continue
+			}
+			t := s.tiers[i+1]
+			if !shouldFlush(t, b) {
+				open = true
+				continue
+			}
+			if err := s.writeRecord(t, b.aggregate()); err != nil {
+				errs = append(errs, err)
+			}
+			buckets[i] = nil
+		}
+		if !open {
+			delete(s.rollups, containerID)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Prune deletes segment files that fall entirely outside their tier's retention
+func (s *Store) Prune(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	containers, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read metrics directory: %w", err)
+	}
+
+	for _, containerDir := range containers {
+		if !containerDir.IsDir() {
+			continue
+		}
+		for _, t := range s.tiers {
+			tierDir := filepath.Join(s.dir, containerDir.Name(), t.name)
+			segments, err := os.ReadDir(tierDir)
+			if err != nil {
+				continue
+			}
+			cutoff := now.Add(-t.retention)
+			for _, segment := range segments {
+				day, err := time.Parse(segmentLayout, strings.TrimSuffix(segment.Name(), ".dat"))
+				if err != nil {
+					continue
+				}
+				if day.Add(24 * time.Hour).Before(cutoff) {
+					if err := os.Remove(filepath.Join(tierDir, segment.Name())); err != nil {
+						s.logger.Warn("Failed to remove expired metrics segment",
+							zap.String("path", filepath.Join(tierDir, segment.Name())),
+							zap.Error(err))
+					}
+				}
+			}
+		}
+
+		// Drop directories of containers with no remaining data
+		removeIfEmpty(filepath.Join(s.dir, containerDir.Name()), s.tiers)
+	}
+
+	return nil
+}
+
+// Query returns samples for a container between from and to, bucketed by step.
+// The finest tier that still retains from is used; a zero step returns the
+// tier's native resolution, capped at MaxQueryPoints.
+func (s *Store) Query(containerID string, from, to time.Time, step time.Duration) ([]docker.ContainerStats, error) {
+	if !utils.ValidateContainerID(containerID) {
+		return nil, fmt.Errorf("invalid container ID %q", containerID)
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("invalid time range: to must be after from")
+	}
+
+	t := s.selectTier(time.Now(), from)
+
+	s.mu.Lock()
+	points, err := s.readRange(t, containerID, from, to)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if minStep := to.Sub(from) / MaxQueryPoints; step < minStep {
+		step = minStep
+	}
+	if step <= t.step {
+		return points, nil
+	}
+	return downsample(points, step), nil
+}
+
+// selectTier picks the finest tier that still retains from, falling back
+// to the coarsest tier
+func (s *Store) selectTier(now, from time.Time) tier {
+	for _, t := range s.tiers {
+		if now.Sub(from) <= t.retention {
+			return t
+		}
+	}
+	return s.tiers[len(s.tiers)-1]
+}
+
+func (s *Store) readRange(t tier, containerID string, from, to time.Time) ([]docker.ContainerStats, error) {
+	var points []docker.ContainerStats
+	for day := from.UTC().Truncate(24 * time.Hour); !day.After(to); day = day.Add(24 * time.Hour) {
+		data, err := os.ReadFile(s.segmentPath(t, containerID, day))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read metrics segment: %w", err)
+		}
+
+		// Ignore a trailing partial record left by an interrupted write
+		for offset := 0; offset+recordSize <= len(data); offset += recordSize {
+			stats := decodeRecord(containerID, data[offset:offset+recordSize])
+			if stats.Timestamp.Before(from) || stats.Timestamp.After(to) {
+				continue
+			}
+			points = append(points, stats)
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Timestamp.Before(points[j].Timestamp)
+	})
+	return points, nil
+}
+
+func (s *Store) writeRecord(t tier, stats docker.ContainerStats) error {
+	path := s.segmentPath(t, stats.ContainerID, stats.Timestamp)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create metrics segment directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open metrics segment: %w", err)
+	}
+	defer f.Close()
+
+	// Drop a partial record left by an interrupted write so appends stay aligned
+	if info, err := f.Stat(); err == nil && info.Size()%recordSize != 0 {
+		if err := f.Truncate(info.Size() - info.Size()%recordSize); err != nil {
+			return fmt.Errorf("failed to repair metrics segment: %w", err)
+		}
+	}
+
+	if _, err := f.Write(encodeRecord(stats)); err != nil {
+		return fmt.Errorf("failed to write metrics sample: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) segmentPath(t tier, containerID string, ts time.Time) string {
+	return filepath.Join(s.dir, containerID, t.name, ts.UTC().Format(segmentLayout)+".dat")
+}
+
+func (b *bucket) add(stats *docker.ContainerStats) {
+	b.count++
+	b.cpuSum += stats.CPUPercent
+	b.memSum += float64(stats.MemoryUsage)
+	b.memPctSum += stats.MemoryPercent
+	b.last = *stats
+}
+
+func (b *bucket) aggregate() docker.ContainerStats {
+	result := b.last
+	result.Timestamp = b.start
+	if b.count > 0 {
+		result.CPUPercent = b.cpuSum / float64(b.count)
+		result.MemoryUsage = uint64(b.memSum / float64(b.count))
+		result.MemoryPercent = b.memPctSum / float64(b.count)
+	}
+	return result
+}
+
+// downsample re-buckets sorted points into step-sized buckets
+func downsample(points []docker.ContainerStats, step time.Duration) []docker.ContainerStats {
+	result := make([]docker.ContainerStats, 0, len(points))
+	var current *bucket
+	for i := range points {
+		start := points[i].Timestamp.Truncate(step)
+		if current != nil && !current.start.Equal(start) {
+			result = append(result, current.aggregate())
+			current = nil
+		}
+		if current == nil {
+			current = &bucket{start: start}
+		}
+		current.add(&points[i])
+	}
+	if current != nil {
+		result = append(result, current.aggregate())
+	}
+	return result
+}
+
+func encodeRecord(stats docker.ContainerStats) []byte {
+	buf := make([]byte, recordSize)
+	binary.BigEndian.PutUint64(buf[0:], uint64(stats.Timestamp.UnixNano()))
+	binary.BigEndian.PutUint64(buf[8:], math.Float64bits(stats.CPUPercent))
+	binary.BigEndian.PutUint64(buf[16:], stats.MemoryUsage)
+	binary.BigEndian.PutUint64(buf[24:], stats.MemoryLimit)
+	binary.BigEndian.PutUint64(buf[32:], math.Float64bits(stats.MemoryPercent))
+	binary.BigEndian.PutUint64(buf[40:], stats.NetworkRx)
+	binary.BigEndian.PutUint64(buf[48:], stats.NetworkTx)
+	binary.BigEndian.PutUint64(buf[56:], stats.BlockRead)
+	binary.BigEndian.PutUint64(buf[64:], stats.BlockWrite)
+	binary.BigEndian.PutUint64(buf[72:], stats.PIDs)
+	return buf
+}
+
+func decodeRecord(containerID string, buf []byte) docker.ContainerStats {
+	return docker.ContainerStats{
+		ContainerID:   containerID,
+		Timestamp:     time.Unix(0, int64(binary.BigEndian.Uint64(buf[0:]))),
+		CPUPercent:    math.Float64frombits(binary.BigEndian.Uint64(buf[8:])),
+		MemoryUsage:   binary.BigEndian.Uint64(buf[16:]),
+		MemoryLimit:   binary.BigEndian.Uint64(buf[24:]),
+		MemoryPercent: math.Float64frombits(binary.BigEndian.Uint64(buf[32:])),
+		NetworkRx:     binary.BigEndian.Uint64(buf[40:]),
+		NetworkTx:     binary.BigEndian.Uint64(buf[48:]),
+		BlockRead:     binary.BigEndian.Uint64(buf[56:]),
+		BlockWrite:    binary.BigEndian.Uint64(buf[64:]),
+		PIDs:          binary.BigEndian.Uint64(buf[72:]),
+	}
+}
+
+// removeIfEmpty removes a container directory once all its tiers are empty
+func removeIfEmpty(containerDir string, tiers []tier) {
+	for _, t := range tiers {
+		tierDir := filepath.Join(containerDir, t.name)
+		f, err := os.Open(tierDir)
+		if err != nil {
+			continue
+		}
+		_, err = f.Readdirnames(1)
+		f.Close()
+		if err != io.EOF {
+			return
+		}
+		_ = os.Remove(tierDir)
+	}
+	_ = os.Remove(containerDir)
+}
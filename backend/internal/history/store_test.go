@@ -0,0 +1,173 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+)
+
+const testContainerID = "0123456789abcdef0123"
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(t.TempDir(), DefaultRetention, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return store
+}
+
+func TestStore_AppendAndQuery(t *testing.T) {
+	store := openTestStore(t)
+
+	start := time.Now().Add(-10 * time.Minute).Truncate(time.Minute)
+	for i := 0; i < 8; i++ {
+		err := store.Append(&docker.ContainerStats{
+			ContainerID: testContainerID,
+			Timestamp:   start.Add(time.Duration(i) * 15 * time.Second),
+			CPUPercent:  float64(i * 10),
+			MemoryUsage: uint64(1000 * (i + 1)),
+			NetworkRx:   uint64(100 * i),
+		})
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	points, err := store.Query(testContainerID, start, start.Add(5*time.Minute), 0)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(points) != 8 {
+		t.Fatalf("Expected 8 raw points, got %d", len(points))
+	}
+	if points[3].CPUPercent != 30 || points[3].MemoryUsage != 4000 {
+		t.Errorf("Unexpected decoded point: %+v", points[3])
+	}
+	if points[0].ContainerID != testContainerID {
+		t.Errorf("Expected container ID on decoded point, got %q", points[0].ContainerID)
+	}
+
+	// Step-based downsampling averages gauges and keeps the last counter value
+	points, err = store.Query(testContainerID, start, start.Add(5*time.Minute), time.Minute)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("Expected 2 one-minute points, got %d", len(points))
+	}
+	if points[0].CPUPercent != 15 {
+		t.Errorf("Expected averaged CPU 15, got %f", points[0].CPUPercent)
+	}
+	if points[0].NetworkRx != 300 {
+		t.Errorf("Expected last NetworkRx 300, got %d", points[0].NetworkRx)
+	}
+	if !points[1].Timestamp.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected bucket timestamp %v, got %v", start.Add(time.Minute), points[1].Timestamp)
+	}
+}
+
+func TestStore_Rollups(t *testing.T) {
+	store := openTestStore(t)
+
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Hour)
+	for i := 0; i < 4; i++ {
+		err := store.Append(&docker.ContainerStats{
+			ContainerID: testContainerID,
+			Timestamp:   start.Add(time.Duration(i) * 30 * time.Second),
+			CPUPercent:  float64(i),
+		})
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	if err := store.Flush(time.Now()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	minute, err := store.readRange(store.tiers[1], testContainerID, start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("readRange failed: %v", err)
+	}
+	if len(minute) != 2 || minute[0].CPUPercent != 0.5 || minute[1].CPUPercent != 2.5 {
+		t.Errorf("Unexpected 1m rollups: %+v", minute)
+	}
+
+	hour, err := store.readRange(store.tiers[2], testContainerID, start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("readRange failed: %v", err)
+	}
+	if len(hour) != 1 || hour[0].CPUPercent != 1.5 {
+		t.Errorf("Unexpected 1h rollups: %+v", hour)
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	store := openTestStore(t)
+
+	old := time.Now().Add(-3 * 24 * time.Hour)
+	recent := time.Now()
+	for _, ts := range []time.Time{old, recent} {
+		if err := store.Append(&docker.ContainerStats{ContainerID: testContainerID, Timestamp: ts}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	if err := store.Prune(time.Now()); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+
+	if _, err := os.Stat(store.segmentPath(store.tiers[0], testContainerID, old)); !os.IsNotExist(err) {
+		t.Error("Expected expired raw segment to be removed")
+	}
+	if _, err := os.Stat(store.segmentPath(store.tiers[0], testContainerID, recent)); err != nil {
+		t.Errorf("Expected recent raw segment to be kept: %v", err)
+	}
+}
+
+func TestStore_RepairsPartialRecord(t *testing.T) {
+	store := openTestStore(t)
+
+	ts := time.Now()
+	if err := store.Append(&docker.ContainerStats{ContainerID: testContainerID, Timestamp: ts, PIDs: 1}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	// Simulate a torn write
+	path := store.segmentPath(store.tiers[0], testContainerID, ts)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("Failed to open segment: %v", err)
+	}
+	_, _ = f.Write([]byte{1, 2, 3})
+	f.Close()
+
+	if err := store.Append(&docker.ContainerStats{ContainerID: testContainerID, Timestamp: ts.Add(time.Second), PIDs: 2}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	points, err := store.Query(testContainerID, ts.Add(-time.Minute), ts.Add(time.Minute), 0)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(points) != 2 || points[1].PIDs != 2 {
+		t.Errorf("Expected 2 intact points after repair, got %+v", points)
+	}
+}
+
+func TestStore_RejectsInvalidContainerID(t *testing.T) {
+	store := openTestStore(t)
+
+	err := store.Append(&docker.ContainerStats{ContainerID: "../../etc", Timestamp: time.Now()})
+	if err == nil {
+		t.Fatal("Expected invalid container ID to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(store.dir, "..", "etc")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be written outside the store directory")
+	}
+}
@@ -0,0 +1,306 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// State is the lifecycle state of a job
+type State string
+
+const (
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// Event types
+const (
+	EventProgress = "progress"
+	EventStatus   = "status"
+	EventDone     = "done"
+)
+
+const (
+	// subscriberBuffer is the event buffer of each subscriber. Slow
+	// subscribers miss progress events but always receive the done event.
+	subscriberBuffer = 64
+
+	// maxSnapshotEvents bounds the events replayed to late subscribers
+	maxSnapshotEvents = 500
+)
+
+var (
+	// ErrNotFound is returned for unknown job IDs
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when cancelling a job that already finished
+	ErrFinished = errors.New("job already finished")
+)
+
+// Event is a normalized progress event of a job
+type Event struct {
+	Type    string    `json:"type"`
+	Layer   string    `json:"layer,omitempty"`
+	Status  string    `json:"status,omitempty"`
+	Current int64     `json:"current,omitempty"`
+	Total   int64     `json:"total,omitempty"`
+	State   State     `json:"state,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Info describes a job
+type Info struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Target     string     `json:"target"`
+	Host       string     `json:"host,omitempty"`
+	User       string     `json:"user,omitempty"`
+	State      State      `json:"state"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Job is a background operation whose progress can be streamed
+type Job struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu          sync.Mutex
+	info        Info
+	snapshot    []Event
+	layers      map[string]int // layer ID -> index in snapshot
+	subscribers map[chan Event]struct{}
+}
+
+// Info returns the current job description
+func (j *Job) Info() Info {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.info
+}
+
+// Done is closed when the job has finished
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Emit records an event and forwards it to subscribers. Per-layer events
+// replace the previous event of the same layer in the replay snapshot.
+func (j *Job) Emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.info.State != StateRunning {
+		return
+	}
+	j.record(event)
+	for ch := range j.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// record adds an event to the snapshot. j.mu must be held.
+func (j *Job) record(event Event) {
+	if event.Layer != "" {
+		if i, ok := j.layers[event.Layer]; ok {
+			j.snapshot[i] = event
+			return
+		}
+	}
+	if len(j.snapshot) >= maxSnapshotEvents {
+		return
+	}
+	if event.Layer != "" {
+		j.layers[event.Layer] = len(j.snapshot)
+	}
+	j.snapshot = append(j.snapshot, event)
+}
+
+// Subscribe returns the events so far and a channel of later events. The
+// channel is closed after the done event; for finished jobs the snapshot
+// already ends with it and the channel is closed.
+func (j *Job) Subscribe() ([]Event, <-chan Event, func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	snapshot := append([]Event(nil), j.snapshot...)
+	ch := make(chan Event, subscriberBuffer)
+	if j.info.State != StateRunning {
+		close(ch)
+		return snapshot, ch, func() {}
+	}
+
+	j.subscribers[ch] = struct{}{}
+	return snapshot, ch, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if _, ok := j.subscribers[ch]; ok {
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// finish records the final state and closes every subscription
+func (j *Job) finish(err error, cancelled bool) {
+	now := time.Now()
+	event := Event{Type: EventDone, Time: now}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	switch {
+	case cancelled:
+		j.info.State = StateCancelled
+	case err != nil:
+		j.info.State = StateFailed
+		j.info.Error = err.Error()
+	default:
+		j.info.State = StateSucceeded
+	}
+	j.info.FinishedAt = &now
+	event.State = j.info.State
+	event.Error = j.info.Error
+	j.snapshot = append(j.snapshot, event)
+
+	for ch := range j.subscribers {
+		// Make room so the done event is never dropped
+		select {
+		case ch <- event:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- event
+		}
+		close(ch)
+		delete(j.subscribers, ch)
+	}
+	close(j.done)
+}
+
+// Manager runs jobs and keeps finished ones for a retention period
+type Manager struct {
+	ctx       context.Context
+	retention time.Duration
+	logger    *zap.Logger
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewManager creates a job manager. Cancelling ctx cancels every job.
+func NewManager(ctx context.Context, retention time.Duration, logger *zap.Logger) *Manager {
+	return &Manager{
+		ctx:       ctx,
+		retention: retention,
+		logger:    logger,
+		jobs:      make(map[string]*Job),
+	}
+}
+
+// Start runs fn in the background as a new job described by info
+func (m *Manager) Start(info Info, fn func(ctx context.Context, job *Job) error) *Job {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	info.ID = hex.EncodeToString(buf)
+	info.State = StateRunning
+	info.CreatedAt = time.Now()
+	info.FinishedAt = nil
+	job := &Job{
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		info:        info,
+		layers:      make(map[string]int),
+		subscribers: make(map[chan Event]struct{}),
+	}
+
+	m.mu.Lock()
+	m.pruneLocked()
+	m.jobs[info.ID] = job
+	m.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		err := fn(ctx, job)
+		cancelled := ctx.Err() != nil
+		job.finish(err, cancelled)
+
+		finished := job.Info()
+		m.logger.Info("Job finished",
+			zap.String("job_id", finished.ID),
+			zap.String("kind", finished.Kind),
+			zap.String("target", finished.Target),
+			zap.String("state", string(finished.State)),
+			zap.String("error", finished.Error))
+	}()
+
+	return job
+}
+
+// pruneLocked drops jobs finished longer than the retention ago. m.mu must
+// be held.
+func (m *Manager) pruneLocked() {
+	cutoff := time.Now().Add(-m.retention)
+	for id, job := range m.jobs {
+		info := job.Info()
+		if info.FinishedAt != nil && info.FinishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// Get returns a job by ID
+func (m *Manager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	return job, ok
+}
+
+// List returns all retained jobs, newest first
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	m.pruneLocked()
+	infos := make([]Info, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		infos = append(infos, job.Info())
+	}
+	m.mu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CreatedAt.After(infos[j].CreatedAt) })
+	return infos
+}
+
+// Cancel stops a running job
+func (m *Manager) Cancel(id string) error {
+	job, ok := m.Get(id)
+	if !ok {
+		return ErrNotFound
+	}
+	if job.Info().State != StateRunning {
+		return ErrFinished
+	}
+	job.cancel()
+	return nil
+}
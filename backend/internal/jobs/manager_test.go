@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// waitDone waits for a job to finish
+func waitDone(t *testing.T, job *Job) {
+	t.Helper()
+	select {
+	case <-job.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for job")
+	}
+}
+
+func TestManager_EventsAndReplay(t *testing.T) {
+	manager := NewManager(context.Background(), time.Hour, zap.NewNop())
+
+	release := make(chan struct{})
+	job := manager.Start(Info{Kind: "image.pull", Target: "nginx:latest"}, func(ctx context.Context, job *Job) error {
+		job.Emit(Event{Type: EventStatus, Status: "Pulling from library/nginx"})
+		job.Emit(Event{Type: EventProgress, Layer: "a1", Status: "Downloading", Current: 10, Total: 100})
+		<-release
+		job.Emit(Event{Type: EventProgress, Layer: "a1", Status: "Downloading", Current: 100, Total: 100})
+		return nil
+	})
+
+	// Wait for the first two events before subscribing
+	deadline := time.Now().Add(2 * time.Second)
+	var snapshot []Event
+	var events <-chan Event
+	for {
+		var unsubscribe func()
+		snapshot, events, unsubscribe = job.Subscribe()
+		if len(snapshot) == 2 || time.Now().After(deadline) {
+			defer unsubscribe()
+			break
+		}
+		unsubscribe()
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(snapshot) != 2 || snapshot[1].Current != 10 {
+		t.Fatalf("Unexpected snapshot: %+v", snapshot)
+	}
+
+	close(release)
+	var received []Event
+	for event := range events {
+		received = append(received, event)
+	}
+	if len(received) != 2 || received[0].Current != 100 || received[1].Type != EventDone || received[1].State != StateSucceeded {
+		t.Fatalf("Unexpected live events: %+v", received)
+	}
+
+	// Late subscribers get the latest event per layer and the done event
+	snapshot, events, _ = job.Subscribe()
+	if _, open := <-events; open {
+		t.Error("Expected closed channel for finished job")
+	}
+	if len(snapshot) != 3 || snapshot[1].Current != 100 || snapshot[2].Type != EventDone {
+		t.Errorf("Unexpected replay: %+v", snapshot)
+	}
+}
+
+func TestManager_CancelAndFailure(t *testing.T) {
+	manager := NewManager(context.Background(), time.Hour, zap.NewNop())
+
+	job := manager.Start(Info{Kind: "image.pull"}, func(ctx context.Context, job *Job) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := manager.Cancel(job.Info().ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	waitDone(t, job)
+	if job.Info().State != StateCancelled {
+		t.Errorf("Expected cancelled state, got %s", job.Info().State)
+	}
+	if err := manager.Cancel(job.Info().ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("Expected ErrFinished, got %v", err)
+	}
+	if err := manager.Cancel("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	failed := manager.Start(Info{Kind: "image.pull"}, func(ctx context.Context, job *Job) error {
+		return errors.New("manifest unknown")
+	})
+	waitDone(t, failed)
+	if info := failed.Info(); info.State != StateFailed || info.Error != "manifest unknown" || info.FinishedAt == nil {
+		t.Errorf("Unexpected failed job: %+v", info)
+	}
+
+	if len(manager.List()) != 2 {
+		t.Errorf("Expected 2 jobs, got %d", len(manager.List()))
+	}
+}
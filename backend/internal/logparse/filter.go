@@ -0,0 +1,209 @@
+package logparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FieldPrefix marks a filter on a record field, as in "field.user_id=42"
+const FieldPrefix = "field."
+
+// operators in the order they are tried, longest first
+var operators = []string{">=", "<=", "!=", "!~", "=", ">", "<", "~"}
+
+// Filter is a condition on parsed entries: "level>=warn", "message~timeout"
+// or "field.user_id=42". Fields of nested JSON objects are addressed with
+// dots, as in "field.http.status>=500".
+type Filter struct {
+	// Target is "level", "message" or the field path after "field."
+	Target   string
+	Field    bool
+	Operator string // "=", "!=", ">", ">=", "<", "<=", "~" or "!~"
+	Value    string
+
+	pattern *regexp.Regexp
+}
+
+// Filters matches entries that satisfy every filter
+type Filters []Filter
+
+// ParseFilter parses a single filter expression
+func ParseFilter(s string) (Filter, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexAny(s, "=!<>~")
+	if i <= 0 {
+		return Filter{}, fmt.Errorf("invalid filter %q: expected <target><operator><value>", s)
+	}
+
+	var filter Filter
+	for _, op := range operators {
+		if strings.HasPrefix(s[i:], op) {
+			filter.Operator = op
+			break
+		}
+	}
+	if filter.Operator == "" {
+		return Filter{}, fmt.Errorf("invalid filter %q: unknown operator", s)
+	}
+	target := strings.TrimSpace(s[:i])
+	filter.Value = strings.TrimSpace(s[i+len(filter.Operator):])
+
+	switch {
+	case strings.HasPrefix(target, FieldPrefix) && len(target) > len(FieldPrefix):
+		filter.Target, filter.Field = target[len(FieldPrefix):], true
+	case target == "level":
+		filter.Target = target
+		level, ok := levelAliases[strings.ToLower(filter.Value)]
+		if !ok {
+			return Filter{}, fmt.Errorf("invalid filter %q: unknown level %q", s, filter.Value)
+		}
+		if filter.Operator == "~" || filter.Operator == "!~" {
+			return Filter{}, fmt.Errorf("invalid filter %q: levels are compared with =, !=, <, <=, > or >=", s)
+		}
+		filter.Value = level
+	case target == "message" || target == "msg":
+		filter.Target = "message"
+	default:
+		return Filter{}, fmt.Errorf("invalid filter %q: target must be level, message or field.<name>", s)
+	}
+
+	if filter.Operator == "~" || filter.Operator == "!~" {
+		pattern, err := regexp.Compile(filter.Value)
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid filter %q: %w", s, err)
+		}
+		filter.pattern = pattern
+	}
+	return filter, nil
+}
+
+// ParseFilters parses a list of filter expressions
+func ParseFilters(exprs []string) (Filters, error) {
+	filters := make(Filters, 0, len(exprs))
+	for _, expr := range exprs {
+		filter, err := ParseFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// Matches reports whether the entry satisfies every filter
+func (f Filters) Matches(entry Entry) bool {
+	for _, filter := range f {
+		if !filter.Matches(entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether the entry satisfies the filter. Entries without a
+// level only match "!=" level filters, and entries missing a field only match
+// "!=" and "!~" filters on it.
+func (f Filter) Matches(entry Entry) bool {
+	switch {
+	case f.Target == "level" && !f.Field:
+		rank, want := levelRank[entry.Level], levelRank[f.Value]
+		if rank == 0 {
+			return f.Operator == "!="
+		}
+		return compare(f.Operator, float64(rank), float64(want))
+	case f.Target == "message" && !f.Field:
+		return f.matchString(entry.Message)
+	}
+
+	value, ok := lookup(entry.Fields, f.Target)
+	if !ok {
+		return f.Operator == "!=" || f.Operator == "!~"
+	}
+	return f.matchString(fieldString(value))
+}
+
+// matchString applies the filter to a value, comparing numerically when both
+// sides are numbers
+func (f Filter) matchString(value string) bool {
+	switch f.Operator {
+	case "=":
+		return value == f.Value || numbersEqual(value, f.Value)
+	case "!=":
+		return value != f.Value && !numbersEqual(value, f.Value)
+	case "~":
+		return f.pattern.MatchString(value)
+	case "!~":
+		return !f.pattern.MatchString(value)
+	}
+
+	a, errA := strconv.ParseFloat(value, 64)
+	b, errB := strconv.ParseFloat(f.Value, 64)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return compare(f.Operator, a, b)
+}
+
+// numbersEqual reports whether both strings are the same number, so that
+// "42" matches 42.0
+func numbersEqual(a, b string) bool {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	return errA == nil && errB == nil && x == y
+}
+
+// compare applies an ordering or equality operator
+func compare(op string, a, b float64) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+// lookup finds a field by path, descending into nested objects at dots
+func lookup(fields map[string]any, path string) (any, bool) {
+	if value, ok := fields[path]; ok {
+		return value, true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if nested, ok := fields[path[:i]].(map[string]any); ok {
+			if value, found := lookup(nested, path[i+1:]); found {
+				return value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// fieldString renders a field value for comparison
+func fieldString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return "null"
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,62 @@
+package logparse
+
+import "testing"
+
+func TestFilters_Matches(t *testing.T) {
+	warn := Parse(`{"level":"warn","msg":"slow request","user_id":42,"http":{"status":503,"path":"/api"}}`, FormatJSON)
+	info := Parse(`level=info msg="request done" user_id=7`, FormatLogfmt)
+	text := Parse(`plain text`, FormatAuto)
+
+	tests := []struct {
+		filters []string
+		want    [3]bool // warn, info, text
+	}{
+		{[]string{"level>=warn"}, [3]bool{true, false, false}},
+		{[]string{"level<warning"}, [3]bool{false, true, false}},
+		{[]string{"level=info"}, [3]bool{false, true, false}},
+		{[]string{"level!=info"}, [3]bool{true, false, true}},
+		{[]string{"field.user_id=42"}, [3]bool{true, false, false}},
+		{[]string{"field.user_id=42.0"}, [3]bool{true, false, false}},
+		{[]string{"field.user_id>10"}, [3]bool{true, false, false}},
+		{[]string{"field.user_id!=42"}, [3]bool{false, true, true}},
+		{[]string{"field.http.status>=500"}, [3]bool{true, false, false}},
+		{[]string{"field.http.path~^/api"}, [3]bool{true, false, false}},
+		{[]string{"message~request"}, [3]bool{true, true, false}},
+		{[]string{"msg!~slow"}, [3]bool{false, true, true}},
+		{[]string{"level>=info", "field.user_id<10"}, [3]bool{false, true, false}},
+		{nil, [3]bool{true, true, true}},
+	}
+	for _, tt := range tests {
+		filters, err := ParseFilters(tt.filters)
+		if err != nil {
+			t.Fatalf("ParseFilters(%v) failed: %v", tt.filters, err)
+		}
+		for i, entry := range []Entry{warn, info, text} {
+			if got := filters.Matches(entry); got != tt.want[i] {
+				t.Errorf("%v on %q: got %v, want %v", tt.filters, entry.Message, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestParseFilter_Errors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"level",
+		"=warn",
+		"level>=loud",
+		"level~warn",
+		"status=500",
+		"field.=1",
+		"message~(",
+	} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("Expected error for %q", expr)
+		}
+	}
+
+	filter, err := ParseFilter(" field.user_id = 42 ")
+	if err != nil || filter.Target != "user_id" || !filter.Field || filter.Operator != "=" || filter.Value != "42" {
+		t.Errorf("Unexpected filter %+v, %v", filter, err)
+	}
+}
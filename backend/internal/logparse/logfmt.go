@@ -0,0 +1,64 @@
+package logparse
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseLogfmt parses a line of space-separated key=value pairs. Values may be
+// double-quoted with Go escapes. Lines with a word that is not a pair are not
+// logfmt, so plain text with an "a=b" in it is left alone.
+func parseLogfmt(line string) (map[string]any, bool) {
+	fields := make(map[string]any)
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		eq := strings.IndexAny(rest, "= \t\"")
+		if eq <= 0 || rest[eq] != '=' {
+			return nil, false
+		}
+		key := rest[:eq]
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := closingQuote(rest)
+			if end < 0 {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(rest[:end+1])
+			if err != nil {
+				return nil, false
+			}
+			value, rest = unquoted, rest[end+1:]
+			if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+				return nil, false
+			}
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+			if strings.ContainsRune(value, '"') {
+				return nil, false
+			}
+		}
+
+		fields[key] = value
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	return fields, len(fields) > 0
+}
+
+// closingQuote returns the index of the quote closing the string s starts with
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,265 @@
+// Package logparse parses container log lines written as JSON or logfmt into
+// a timestamp, level, message and fields, and filters the parsed entries.
+package logparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format selects how log lines are parsed
+type Format string
+
+const (
+	// FormatAuto parses JSON objects and logfmt, and anything else as text
+	FormatAuto Format = "auto"
+	// FormatJSON parses lines as JSON objects
+	FormatJSON Format = "json"
+	// FormatLogfmt parses lines as logfmt key=value pairs
+	FormatLogfmt Format = "logfmt"
+	// FormatRaw keeps lines as plain text messages
+	FormatRaw Format = "raw"
+)
+
+// ParseFormat validates a format name
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(strings.ToLower(s)); format {
+	case FormatAuto, FormatJSON, FormatLogfmt, FormatRaw:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown log format %q: must be auto, json, logfmt or raw", s)
+}
+
+// Normalized levels, in increasing severity
+const (
+	LevelTrace = "trace"
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// levelRank orders normalized levels; unknown levels rank 0
+var levelRank = map[string]int{
+	LevelTrace: 1,
+	LevelDebug: 2,
+	LevelInfo:  3,
+	LevelWarn:  4,
+	LevelError: 5,
+	LevelFatal: 6,
+}
+
+// levelAliases maps the level names used by common loggers to normalized levels
+var levelAliases = map[string]string{
+	"trace":       LevelTrace,
+	"debug":       LevelDebug,
+	"dbg":         LevelDebug,
+	"info":        LevelInfo,
+	"information": LevelInfo,
+	"notice":      LevelInfo,
+	"warn":        LevelWarn,
+	"warning":     LevelWarn,
+	"error":       LevelError,
+	"err":         LevelError,
+	"fatal":       LevelFatal,
+	"panic":       LevelFatal,
+	"critical":    LevelFatal,
+	"crit":        LevelFatal,
+	"alert":       LevelFatal,
+	"emerg":       LevelFatal,
+}
+
+// Well-known keys, in order of preference
+var (
+	levelKeys   = []string{"level", "lvl", "severity", "loglevel", "log.level"}
+	messageKeys = []string{"msg", "message"}
+	timeKeys    = []string{"time", "ts", "timestamp", "@timestamp", "t"}
+)
+
+// Entry is a parsed log line. Fields holds every key that is not the
+// timestamp, level or message; JSON values keep their types and logfmt
+// values are strings.
+type Entry struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Level     string         `json:"level,omitempty"`
+	Message   string         `json:"message"`
+	Fields    map[string]any `json:"fields,omitempty"`
+	// Format is the format the line was parsed as, raw when it did not match
+	Format Format `json:"format"`
+}
+
+// Parse parses a log line. Lines that are not valid in the requested format,
+// like a stack trace between JSON lines, are kept as raw text, with a level
+// guessed from a leading word such as "ERROR" or "[warn]".
+func Parse(line string, format Format) Entry {
+	line = strings.TrimRight(line, "\r\n")
+
+	switch format {
+	case FormatRaw:
+		return Entry{Message: line, Format: FormatRaw}
+	case FormatJSON:
+		if fields, ok := parseJSON(line); ok {
+			return newEntry(fields, FormatJSON)
+		}
+	case FormatLogfmt:
+		if fields, ok := parseLogfmt(line); ok {
+			return newEntry(fields, FormatLogfmt)
+		}
+	default:
+		if fields, ok := parseJSON(line); ok {
+			return newEntry(fields, FormatJSON)
+		}
+		if fields, ok := parseLogfmt(line); ok {
+			return newEntry(fields, FormatLogfmt)
+		}
+	}
+	return Entry{Level: guessLevel(line), Message: line, Format: FormatRaw}
+}
+
+// parseJSON decodes a line holding a single JSON object
+func parseJSON(line string) (map[string]any, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, false
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, false
+	}
+	return fields, true
+}
+
+// newEntry moves the well-known keys of a record out of its fields
+func newEntry(fields map[string]any, format Format) Entry {
+	entry := Entry{Format: format}
+
+	if key, value, ok := takeKey(fields, levelKeys); ok {
+		if level := normalizeLevel(value); level != "" {
+			entry.Level = level
+		} else {
+			fields[key] = value
+		}
+	}
+	if key, value, ok := takeKey(fields, messageKeys); ok {
+		if s, isString := value.(string); isString {
+			entry.Message = s
+		} else {
+			fields[key] = value
+		}
+	}
+	if key, value, ok := takeKey(fields, timeKeys); ok {
+		if t, parsed := parseTime(value); parsed {
+			entry.Timestamp = t
+		} else {
+			fields[key] = value
+		}
+	}
+
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
+	return entry
+}
+
+// takeKey removes and returns the first of keys present in fields
+func takeKey(fields map[string]any, keys []string) (string, any, bool) {
+	for _, key := range keys {
+		if value, ok := fields[key]; ok {
+			delete(fields, key)
+			return key, value, true
+		}
+	}
+	return "", nil, false
+}
+
+// normalizeLevel maps a level name, or a numeric level as written by pino and
+// bunyan (10 trace to 60 fatal), to a normalized level
+func normalizeLevel(value any) string {
+	switch v := value.(type) {
+	case string:
+		if level, ok := levelAliases[strings.ToLower(strings.TrimSpace(v))]; ok {
+			return level
+		}
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return ""
+		}
+		switch {
+		case n <= 10:
+			return LevelTrace
+		case n <= 20:
+			return LevelDebug
+		case n <= 30:
+			return LevelInfo
+		case n <= 40:
+			return LevelWarn
+		case n <= 50:
+			return LevelError
+		default:
+			return LevelFatal
+		}
+	}
+	return ""
+}
+
+// timeLayouts are the textual timestamp layouts recognized in records
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// parseTime parses a textual timestamp, or a Unix timestamp in seconds,
+// milliseconds, microseconds or nanoseconds
+func parseTime(value any) (time.Time, bool) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	case json.Number:
+		s = v.String()
+	default:
+		return time.Time{}, false
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}, false
+	}
+	switch {
+	case n < 1e11:
+		sec := int64(n)
+		return time.Unix(sec, int64((n-float64(sec))*1e9)).UTC(), true
+	case n < 1e14:
+		return time.UnixMicro(int64(n * 1e3)).UTC(), true
+	case n < 1e17:
+		return time.UnixMicro(int64(n)).UTC(), true
+	default:
+		return time.Unix(0, int64(n)).UTC(), true
+	}
+}
+
+// guessLevel reads a level from the first word of a text line
+func guessLevel(line string) string {
+	word, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	word = strings.Trim(word, "[]<>():|")
+	return levelAliases[strings.ToLower(word)]
+}
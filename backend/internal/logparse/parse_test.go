@@ -0,0 +1,147 @@
+package logparse
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	ts := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		line    string
+		format  Format
+		want    Entry
+		wantKey string
+	}{
+		{
+			name:    "json",
+			line:    `{"level":"WARNING","msg":"slow query","time":"2025-03-01T12:00:00Z","user_id":42}`,
+			format:  FormatAuto,
+			want:    Entry{Timestamp: ts, Level: LevelWarn, Message: "slow query", Format: FormatJSON},
+			wantKey: "user_id",
+		},
+		{
+			name:    "pino",
+			line:    `{"level":50,"time":1740830400000,"msg":"failed","pid":7}`,
+			format:  FormatJSON,
+			want:    Entry{Timestamp: ts, Level: LevelError, Message: "failed", Format: FormatJSON},
+			wantKey: "pid",
+		},
+		{
+			name:   "zap",
+			line:   `{"level":"info","ts":1740830400,"msg":"started"}`,
+			format: FormatAuto,
+			want:   Entry{Timestamp: ts, Level: LevelInfo, Message: "started", Format: FormatJSON},
+		},
+		{
+			name:    "logfmt",
+			line:    `time=2025-03-01T12:00:00Z level=error msg="connection \"db\" lost" retries=3`,
+			format:  FormatAuto,
+			want:    Entry{Timestamp: ts, Level: LevelError, Message: `connection "db" lost`, Format: FormatLogfmt},
+			wantKey: "retries",
+		},
+		{
+			name:   "text",
+			line:   "[ERROR] disk full\n",
+			format: FormatAuto,
+			want:   Entry{Level: LevelError, Message: "[ERROR] disk full", Format: FormatRaw},
+		},
+		{
+			name:   "text with pair",
+			line:   "user logged in with id=42",
+			format: FormatAuto,
+			want:   Entry{Message: "user logged in with id=42", Format: FormatRaw},
+		},
+		{
+			name:   "json expected",
+			line:   "panic: runtime error",
+			format: FormatJSON,
+			want:   Entry{Level: LevelFatal, Message: "panic: runtime error", Format: FormatRaw},
+		},
+		{
+			name:   "logfmt not parsed as json",
+			line:   `{"level":"info"}`,
+			format: FormatLogfmt,
+			want:   Entry{Message: `{"level":"info"}`, Format: FormatRaw},
+		},
+		{
+			name:   "raw",
+			line:   `level=error msg=x`,
+			format: FormatRaw,
+			want:   Entry{Message: "level=error msg=x", Format: FormatRaw},
+		},
+		{
+			name:   "trailing data",
+			line:   `{"msg":"a"} {"msg":"b"}`,
+			format: FormatJSON,
+			want:   Entry{Message: `{"msg":"a"} {"msg":"b"}`, Format: FormatRaw},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.line, tt.format)
+			if !got.Timestamp.Equal(tt.want.Timestamp) || got.Level != tt.want.Level ||
+				got.Message != tt.want.Message || got.Format != tt.want.Format {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+			if tt.wantKey != "" {
+				if _, ok := got.Fields[tt.wantKey]; !ok || len(got.Fields) != 1 {
+					t.Errorf("Expected only field %q, got %v", tt.wantKey, got.Fields)
+				}
+			} else if len(got.Fields) != 0 {
+				t.Errorf("Expected no fields, got %v", got.Fields)
+			}
+		})
+	}
+}
+
+func TestParse_KeepsUnrecognizedValues(t *testing.T) {
+	entry := Parse(`{"level":"verbose","msg":{"nested":true},"time":"yesterday","n":42}`, FormatJSON)
+	if entry.Level != "" || entry.Message != "" || !entry.Timestamp.IsZero() {
+		t.Errorf("Unexpected well-known values: %+v", entry)
+	}
+	if len(entry.Fields) != 4 {
+		t.Errorf("Expected unrecognized values to stay fields, got %v", entry.Fields)
+	}
+
+	// Numbers keep their JSON representation
+	data, _ := json.Marshal(entry.Fields["n"])
+	if string(data) != "42" {
+		t.Errorf("Expected 42, got %s", data)
+	}
+}
+
+func TestParseLogfmt(t *testing.T) {
+	for line, ok := range map[string]bool{
+		`a=1 b="two words" c=`:    true,
+		`url=http://x/?q=1`:       true,
+		`a=1 stray`:               false,
+		`a="unterminated`:         false,
+		`a="x"b=1`:                false,
+		`=1`:                      false,
+		`GET /health 200`:         false,
+		`a=1	b=2`:                 true,
+		`msg="tab\tand \\ slash"`: true,
+	} {
+		if _, got := parseLogfmt(line); got != ok {
+			t.Errorf("parseLogfmt(%q) ok = %v, want %v", line, got, ok)
+		}
+	}
+
+	fields, _ := parseLogfmt(`msg="tab\tand \\ slash" empty=`)
+	if fields["msg"] != "tab\tand \\ slash" || fields["empty"] != "" {
+		t.Errorf("Unexpected fields: %q", fields)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if format, err := ParseFormat("JSON"); err != nil || format != FormatJSON {
+		t.Errorf("Expected json, got %q, %v", format, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
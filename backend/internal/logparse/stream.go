@@ -0,0 +1,105 @@
+package logparse
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// MaxLineLength is where lines without a newline are cut
+const MaxLineLength = 64 * 1024
+
+// Streams of Docker log output
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// ReadLines calls onLine for every line of a Docker log stream,
+// demultiplexing stdout and stderr unless the container has a TTY. Lines
+// longer than MaxLineLength are cut; the line slice is only valid during the
+// call.
+func ReadLines(r io.Reader, tty bool, onLine func(stream string, line []byte) error) error {
+	stdout := NewLineSplitter(StreamStdout, onLine)
+	if tty {
+		if _, err := io.Copy(stdout, r); err != nil {
+			return err
+		}
+		return stdout.Flush()
+	}
+
+	stderr := NewLineSplitter(StreamStderr, onLine)
+	if _, err := stdcopy.StdCopy(stdout, stderr, r); err != nil {
+		return err
+	}
+	if err := stdout.Flush(); err != nil {
+		return err
+	}
+	return stderr.Flush()
+}
+
+// SplitTimestamp splits the RFC3339 timestamp Docker prefixes log lines with
+// from the text
+func SplitTimestamp(line []byte) (time.Time, []byte, bool) {
+	line = bytes.TrimRight(line, "\r\n")
+	prefix, text, ok := bytes.Cut(line, []byte(" "))
+	if !ok {
+		prefix, text = line, nil
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, string(prefix))
+	if err != nil {
+		return time.Time{}, nil, false
+	}
+	return timestamp, text, true
+}
+
+// LineSplitter splits one demultiplexed stream into lines as it is written,
+// buffering a line split across writes. Lines longer than MaxLineLength are
+// cut; the line slice is only valid during the onLine call.
+type LineSplitter struct {
+	stream string
+	buf    []byte
+	onLine func(stream string, line []byte) error
+}
+
+// NewLineSplitter returns a LineSplitter calling onLine for every line of
+// a stream
+func NewLineSplitter(stream string, onLine func(stream string, line []byte) error) *LineSplitter {
+	return &LineSplitter{stream: stream, onLine: onLine}
+}
+
+func (w *LineSplitter) Write(p []byte) (int, error) {
+	// Only the new data can hold a newline
+	searched := len(w.buf)
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf[searched:], '\n')
+		if i >= 0 {
+			i += searched
+		}
+		searched = 0
+		if i < 0 && len(w.buf) < MaxLineLength {
+			return len(p), nil
+		}
+		next := i + 1
+		if i < 0 || i > MaxLineLength {
+			i, next = MaxLineLength, MaxLineLength
+		}
+		if err := w.onLine(w.stream, w.buf[:i]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[next:]
+	}
+}
+
+// Flush emits a trailing line without a newline
+func (w *LineSplitter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := w.buf
+	w.buf = nil
+	return w.onLine(w.stream, line)
+}
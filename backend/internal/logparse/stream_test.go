@@ -0,0 +1,58 @@
+package logparse
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// readAll collects the lines of a log stream as "stream|line"
+func readAll(t *testing.T, output []byte, tty bool) []string {
+	t.Helper()
+	var lines []string
+	err := ReadLines(bytes.NewReader(output), tty, func(stream string, line []byte) error {
+		lines = append(lines, stream+"|"+string(line))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadLines failed: %v", err)
+	}
+	return lines
+}
+
+func TestReadLines(t *testing.T) {
+	var output bytes.Buffer
+	stdout, stderr := stdcopy.NewStdWriter(&output, stdcopy.Stdout), stdcopy.NewStdWriter(&output, stdcopy.Stderr)
+	_, _ = stdout.Write([]byte("one\ntw"))
+	_, _ = stderr.Write([]byte("oops\n"))
+	_, _ = stdout.Write([]byte("o\nno newline"))
+
+	got := strings.Join(readAll(t, output.Bytes(), false), ",")
+	if got != "stdout|one,stderr|oops,stdout|two,stdout|no newline" {
+		t.Errorf("Unexpected lines: %s", got)
+	}
+
+	// TTY output is raw and all stdout; long lines are cut
+	long := strings.Repeat("x", MaxLineLength+10)
+	lines := readAll(t, []byte("\x01\x00\x00\x00 raw\r\n"+long+"\n"), true)
+	if len(lines) != 3 || lines[0] != "stdout|\x01\x00\x00\x00 raw\r" ||
+		len(lines[1]) != len("stdout|")+MaxLineLength || lines[2] != "stdout|xxxxxxxxxx" {
+		t.Errorf("Unexpected TTY lines: %d", len(lines))
+	}
+}
+
+func TestSplitTimestamp(t *testing.T) {
+	timestamp, text, ok := SplitTimestamp([]byte("2025-03-01T12:00:00.5Z hello world\r\n"))
+	if !ok || string(text) != "hello world" || !timestamp.Equal(time.Date(2025, 3, 1, 12, 0, 0, 5e8, time.UTC)) {
+		t.Errorf("Unexpected split: %v %q %v", timestamp, text, ok)
+	}
+	if _, text, ok := SplitTimestamp([]byte("2025-03-01T12:00:00Z")); !ok || len(text) != 0 {
+		t.Errorf("Expected empty line, got %q %v", text, ok)
+	}
+	if _, _, ok := SplitTimestamp([]byte("no timestamp")); ok {
+		t.Error("Expected no timestamp")
+	}
+}
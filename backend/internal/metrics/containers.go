@@ -0,0 +1,375 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+)
+
+// composeProjectLabel is the label Docker Compose sets on project containers
+const composeProjectLabel = "com.docker.compose.project"
+
+const (
+	// restartInspectConcurrency bounds the container inspections a scrape
+	// runs at once to read restart counts
+	restartInspectConcurrency = 8
+
+	// eventReconnectDelay is how long WatchEvents waits before reconnecting
+	eventReconnectDelay = 5 * time.Second
+)
+
+// containerStates are the states exported by kubevision_container_state
+var containerStates = []string{"created", "running", "paused", "restarting", "removing", "exited", "dead"}
+
+// containerSample is the latest collected stats sample of a container
+type containerSample struct {
+	host  string
+	ctr   container.Summary
+	stats docker.ContainerStats
+	at    time.Time
+}
+
+// exporterHost is a Docker host queried for container state at scrape time
+type exporterHost struct {
+	name         string
+	dockerClient interface {
+		ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+		ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	}
+}
+
+// restartCache holds a host's restart counts by container ID. Counts only
+// change when a container starts, so they are kept while the host's events
+// are watched and dropped on start and destroy events.
+type restartCache struct {
+	counts map[string]int
+	// generation changes whenever counts are dropped, so inspections that
+	// raced with an event are not cached
+	generation uint64
+}
+
+// ContainerExporter exports per-container metrics. Resource usage comes from
+// the stats collector via Observer; state is read from each host at scrape
+// time and restart counts are cached while WatchEvents runs for the host.
+type ContainerExporter struct {
+	mu         sync.RWMutex
+	hosts      []exporterHost
+	samples    map[string]containerSample
+	staleAfter time.Duration
+	now        func() time.Time
+	logger     *zap.Logger
+
+	restartsMu sync.Mutex
+	restarts   map[string]*restartCache
+}
+
+// NewContainerExporter creates a new exporter. Samples older than staleAfter
+// are no longer exported.
+func NewContainerExporter(staleAfter time.Duration, logger *zap.Logger) *ContainerExporter {
+	return &ContainerExporter{
+		samples:    make(map[string]containerSample),
+		restarts:   make(map[string]*restartCache),
+		staleAfter: staleAfter,
+		now:        time.Now,
+		logger:     logger,
+	}
+}
+
+// AddHost registers a host whose container states are exported. It must be
+// called before the exporter is served.
+func (e *ContainerExporter) AddHost(name string, dockerClient interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+}) {
+	e.hosts = append(e.hosts, exporterHost{name: name, dockerClient: dockerClient})
+}
+
+// Observer returns a stats collector observer recording samples for a host
+func (e *ContainerExporter) Observer(host string) func(ctr container.Summary, stats *docker.ContainerStats) {
+	return func(ctr container.Summary, stats *docker.ContainerStats) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.samples[host+"/"+ctr.ID] = containerSample{host: host, ctr: ctr, stats: *stats, at: e.now()}
+	}
+}
+
+// freshSamples returns non-stale samples in a stable order, dropping stale ones
+func (e *ContainerExporter) freshSamples() []containerSample {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cutoff := e.now().Add(-e.staleAfter)
+	samples := make([]containerSample, 0, len(e.samples))
+	for key, sample := range e.samples {
+		if sample.at.Before(cutoff) {
+			delete(e.samples, key)
+			continue
+		}
+		samples = append(samples, sample)
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].host != samples[j].host {
+			return samples[i].host < samples[j].host
+		}
+		return containerName(samples[i].ctr) < containerName(samples[j].ctr)
+	})
+	return samples
+}
+
+// containerStatus is the scrape-time state of a container
+type containerStatus struct {
+	host         string
+	ctr          container.Summary
+	restartCount int
+}
+
+// statuses lists every container on every host with its restart count
+func (e *ContainerExporter) statuses(ctx context.Context) []containerStatus {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var statuses []containerStatus
+	for _, host := range e.hosts {
+		containers, err := host.dockerClient.ContainerList(ctx, container.ListOptions{All: true})
+		if err != nil {
+			e.logger.Warn("Failed to list containers for metrics",
+				zap.String("host", host.name),
+				zap.Error(err))
+			continue
+		}
+
+		sort.Slice(containers, func(i, j int) bool {
+			return containerName(containers[i]) < containerName(containers[j])
+		})
+		counts := e.restartCounts(ctx, host, containers)
+		for _, ctr := range containers {
+			statuses = append(statuses, containerStatus{host: host.name, ctr: ctr, restartCount: counts[ctr.ID]})
+		}
+	}
+	return statuses
+}
+
+// restartCounts returns the restart counts of a host's containers, inspecting
+// those not cached a bounded number at a time. Containers that could not be
+// inspected are missing.
+func (e *ContainerExporter) restartCounts(ctx context.Context, host exporterHost, containers []container.Summary) map[string]int {
+	counts := make(map[string]int, len(containers))
+	var missing []string
+
+	e.restartsMu.Lock()
+	cache := e.restarts[host.name]
+	var generation uint64
+	if cache != nil {
+		generation = cache.generation
+	}
+	for _, ctr := range containers {
+		if count, ok := cache.lookup(ctr.ID); ok {
+			counts[ctr.ID] = count
+		} else {
+			missing = append(missing, ctr.ID)
+		}
+	}
+	e.restartsMu.Unlock()
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		inspected = make(map[string]int, len(missing))
+		slots     = make(chan struct{}, restartInspectConcurrency)
+	)
+	for _, id := range missing {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			inspect, err := host.dockerClient.ContainerInspect(ctx, id)
+			if err != nil || inspect.ContainerJSONBase == nil {
+				return
+			}
+			mu.Lock()
+			inspected[id] = inspect.RestartCount
+			mu.Unlock()
+		}(id)
+	}
+	wg.Wait()
+
+	for id, count := range inspected {
+		counts[id] = count
+	}
+
+	e.restartsMu.Lock()
+	defer e.restartsMu.Unlock()
+	if cache != nil && e.restarts[host.name] == cache {
+		// Forget removed containers
+		for id := range cache.counts {
+			if _, ok := counts[id]; !ok {
+				delete(cache.counts, id)
+			}
+		}
+		if cache.generation == generation {
+			for id, count := range inspected {
+				cache.counts[id] = count
+			}
+		}
+	}
+	return counts
+}
+
+// lookup returns a cached restart count. A nil cache holds nothing.
+func (c *restartCache) lookup(containerID string) (int, bool) {
+	if c == nil {
+		return 0, false
+	}
+	count, ok := c.counts[containerID]
+	return count, ok
+}
+
+// WatchEvents caches a host's restart counts while its container events are
+// streamed, dropping a container's count when it starts or is destroyed. The
+// cache is discarded whenever the stream drops, as events may be missed
+// until it reconnects. It returns when ctx is cancelled.
+func (e *ContainerExporter) WatchEvents(ctx context.Context, host string, dockerClient interface {
+	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+}) {
+	eventFilters := filters.NewArgs()
+	eventFilters.Add("type", string(events.ContainerEventType))
+	eventFilters.Add("event", string(events.ActionStart))
+	eventFilters.Add("event", string(events.ActionDestroy))
+
+	defer e.dropRestarts(host)
+	for {
+		eventChan, errChan := dockerClient.Events(ctx, events.ListOptions{
+			Filters: eventFilters,
+		})
+		e.restartsMu.Lock()
+		e.restarts[host] = &restartCache{counts: make(map[string]int)}
+		e.restartsMu.Unlock()
+
+	stream:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errChan:
+				if err != nil {
+					e.logger.Warn("Metrics events stream error, reconnecting",
+						zap.String("host", host),
+						zap.Error(err))
+				}
+				break stream
+			case event := <-eventChan:
+				e.restartsMu.Lock()
+				if cache := e.restarts[host]; cache != nil {
+					delete(cache.counts, event.Actor.ID)
+					cache.generation++
+				}
+				e.restartsMu.Unlock()
+			}
+		}
+
+		e.dropRestarts(host)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventReconnectDelay):
+		}
+	}
+}
+
+// dropRestarts stops caching a host's restart counts
+func (e *ContainerExporter) dropRestarts(host string) {
+	e.restartsMu.Lock()
+	defer e.restartsMu.Unlock()
+	delete(e.restarts, host)
+}
+
+func (e *ContainerExporter) write(ctx context.Context, w *expositionWriter) {
+	samples := e.freshSamples()
+
+	gauges := []struct {
+		name  string
+		help  string
+		kind  string
+		value func(stats docker.ContainerStats) float64
+	}{
+		{"kubevision_container_cpu_usage_percent", "Container CPU usage as a percentage of one host", "gauge",
+			func(s docker.ContainerStats) float64 { return s.CPUPercent }},
+		{"kubevision_container_memory_usage_bytes", "Container memory usage in bytes", "gauge",
+			func(s docker.ContainerStats) float64 { return float64(s.MemoryUsage) }},
+		{"kubevision_container_memory_limit_bytes", "Container memory limit in bytes", "gauge",
+			func(s docker.ContainerStats) float64 { return float64(s.MemoryLimit) }},
+		{"kubevision_container_memory_usage_percent", "Container memory usage as a percentage of its limit", "gauge",
+			func(s docker.ContainerStats) float64 { return s.MemoryPercent }},
+		{"kubevision_container_network_receive_bytes_total", "Bytes received over all container networks", "counter",
+			func(s docker.ContainerStats) float64 { return float64(s.NetworkRx) }},
+		{"kubevision_container_network_transmit_bytes_total", "Bytes transmitted over all container networks", "counter",
+			func(s docker.ContainerStats) float64 { return float64(s.NetworkTx) }},
+		{"kubevision_container_block_read_bytes_total", "Bytes read from block devices", "counter",
+			func(s docker.ContainerStats) float64 { return float64(s.BlockRead) }},
+		{"kubevision_container_block_write_bytes_total", "Bytes written to block devices", "counter",
+			func(s docker.ContainerStats) float64 { return float64(s.BlockWrite) }},
+		{"kubevision_container_pids", "Number of processes in the container", "gauge",
+			func(s docker.ContainerStats) float64 { return float64(s.PIDs) }},
+	}
+
+	for _, gauge := range gauges {
+		w.family(gauge.name, gauge.help, gauge.kind)
+		for _, sample := range samples {
+			w.sample(gauge.name, containerLabels(sample.host, sample.ctr), gauge.value(sample.stats))
+		}
+	}
+
+	statuses := e.statuses(ctx)
+
+	w.family("kubevision_container_restart_count", "Number of times Docker restarted the container", "gauge")
+	for _, status := range statuses {
+		w.sample("kubevision_container_restart_count", containerLabels(status.host, status.ctr), float64(status.restartCount))
+	}
+
+	w.family("kubevision_container_state", "Container state, 1 for the current state", "gauge")
+	for _, status := range statuses {
+		labels := containerLabels(status.host, status.ctr)
+		for _, state := range containerStates {
+			value := 0.0
+			if status.ctr.State == state {
+				value = 1
+			}
+			w.sample("kubevision_container_state", append(labels, label{"state", state}), value)
+		}
+	}
+}
+
+func containerLabels(host string, ctr container.Summary) []label {
+	id := ctr.ID
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return []label{
+		{"host", host},
+		{"id", id},
+		{"name", containerName(ctr)},
+		{"image", ctr.Image},
+		{"compose_project", ctr.Labels[composeProjectLabel]},
+	}
+}
+
+func containerName(ctr container.Summary) string {
+	if len(ctr.Names) == 0 {
+		return ""
+	}
+	name := ctr.Names[0]
+	if len(name) > 0 && name[0] == '/' {
+		return name[1:]
+	}
+	return name
+}
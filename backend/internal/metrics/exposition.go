@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// label is a single Prometheus label pair
+type label struct {
+	name  string
+	value string
+}
+
+// expositionWriter writes the Prometheus text exposition format
+type expositionWriter struct {
+	w *bufio.Writer
+}
+
+func newExpositionWriter(w io.Writer) *expositionWriter {
+	return &expositionWriter{w: bufio.NewWriter(w)}
+}
+
+// family writes the HELP and TYPE lines of a metric family
+func (e *expositionWriter) family(name, help, metricType string) {
+	e.w.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+	e.w.WriteString("# TYPE " + name + " " + metricType + "\n")
+}
+
+// sample writes a single sample line
+func (e *expositionWriter) sample(name string, labels []label, value float64) {
+	e.w.WriteString(name)
+	if len(labels) > 0 {
+		e.w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				e.w.WriteByte(',')
+			}
+			e.w.WriteString(l.name + `="` + escapeLabelValue(l.value) + `"`)
+		}
+		e.w.WriteByte('}')
+	}
+	e.w.WriteByte(' ')
+	e.w.WriteString(formatFloat(value))
+	e.w.WriteByte('\n')
+}
+
+// flush writes any buffered output
+func (e *expositionWriter) flush() error {
+	return e.w.Flush()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortRequestKeys(keys []requestKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
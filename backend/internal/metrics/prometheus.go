@@ -1,99 +1,158 @@
 package metrics
 
 import (
-	"fmt"
+	"bytes"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// DefaultBuckets are the upper bounds in seconds of the request duration histogram
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// requestKey identifies an HTTP request series
+type requestKey struct {
+	method string
+	route  string
+	status string
+}
+
+// histogram is a cumulative Prometheus histogram
+type histogram struct {
+	counts []uint64 // one per bucket, non-cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range DefaultBuckets {
+		if value <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
 var (
 	// HTTP metrics
-	httpRequestsTotal   = make(map[string]int64)
-	httpRequestsLatency = make(map[string][]time.Duration)
-	httpRequestsMutex   sync.RWMutex
-
-	// WebSocket metrics
-	websocketConnectionsActive int64
-	websocketConnectionsTotal   int64
-	websocketConnectionsMutex   sync.RWMutex
+	httpRequestsTotal    = make(map[requestKey]uint64)
+	httpRequestsDuration = make(map[requestKey]*histogram)
+	httpRequestsMutex    sync.RWMutex
+
+	// WebSocket metrics, keyed by stream type
+	websocketConnectionsActive = make(map[string]int64)
+	websocketConnectionsTotal  = make(map[string]uint64)
+	websocketConnectionsMutex  sync.RWMutex
 )
 
-// RecordHTTPRequest records an HTTP request
-func RecordHTTPRequest(method, path string, statusCode int, duration time.Duration) {
+// RecordHTTPRequest records an HTTP request. route should be the matched
+// route pattern rather than the raw path to keep label cardinality bounded.
+func RecordHTTPRequest(method, route string, statusCode int, duration time.Duration) {
 	httpRequestsMutex.Lock()
 	defer httpRequestsMutex.Unlock()
 
-	key := fmt.Sprintf("%s %s %d", method, path, statusCode)
+	key := requestKey{method: method, route: route, status: strconv.Itoa(statusCode)}
 	httpRequestsTotal[key]++
 
-	if httpRequestsLatency[key] == nil {
-		httpRequestsLatency[key] = make([]time.Duration, 0, 100)
-	}
-	httpRequestsLatency[key] = append(httpRequestsLatency[key], duration)
-	if len(httpRequestsLatency[key]) > 100 {
-		httpRequestsLatency[key] = httpRequestsLatency[key][1:]
+	h, ok := httpRequestsDuration[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(DefaultBuckets))}
+		httpRequestsDuration[key] = h
 	}
+	h.observe(duration.Seconds())
 }
 
 // IncrementWebSocketConnections increments active WebSocket connections
-func IncrementWebSocketConnections() {
+// for a stream type (stats, logs, events, ...)
+func IncrementWebSocketConnections(stream string) {
 	websocketConnectionsMutex.Lock()
 	defer websocketConnectionsMutex.Unlock()
-	websocketConnectionsActive++
-	websocketConnectionsTotal++
+	websocketConnectionsActive[stream]++
+	websocketConnectionsTotal[stream]++
 }
 
 // DecrementWebSocketConnections decrements active WebSocket connections
-func DecrementWebSocketConnections() {
+// for a stream type
+func DecrementWebSocketConnections(stream string) {
 	websocketConnectionsMutex.Lock()
 	defer websocketConnectionsMutex.Unlock()
-	if websocketConnectionsActive > 0 {
-		websocketConnectionsActive--
+	if websocketConnectionsActive[stream] > 0 {
+		websocketConnectionsActive[stream]--
+	}
+}
+
+// Handler returns a handler serving metrics in the Prometheus text format.
+// containers may be nil to omit per-container metrics.
+func Handler(containers *ContainerExporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var buf bytes.Buffer
+		w := newExpositionWriter(&buf)
+
+		writeHTTPMetrics(w)
+		writeWebSocketMetrics(w)
+		if containers != nil {
+			containers.write(c.Request.Context(), w)
+		}
+		_ = w.flush()
+
+		c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
 	}
 }
 
-// MetricsHandler returns Prometheus-compatible metrics
-func MetricsHandler(c *gin.Context) {
+func writeHTTPMetrics(w *expositionWriter) {
 	httpRequestsMutex.RLock()
-	websocketConnectionsMutex.RLock()
 	defer httpRequestsMutex.RUnlock()
-	defer websocketConnectionsMutex.RUnlock()
 
-	var output string
+	keys := make([]requestKey, 0, len(httpRequestsTotal))
+	for key := range httpRequestsTotal {
+		keys = append(keys, key)
+	}
+	sortRequestKeys(keys)
 
-	// HTTP request metrics
-	output += "# HELP http_requests_total Total number of HTTP requests\n"
-	output += "# TYPE http_requests_total counter\n"
-	for key, count := range httpRequestsTotal {
-		output += fmt.Sprintf("http_requests_total{key=\"%s\"} %d\n", key, count)
+	w.family("http_requests_total", "Total number of HTTP requests", "counter")
+	for _, key := range keys {
+		w.sample("http_requests_total", requestLabels(key), float64(httpRequestsTotal[key]))
 	}
 
-	// HTTP latency metrics
-	output += "# HELP http_request_duration_seconds HTTP request duration in seconds\n"
-	output += "# TYPE http_request_duration_seconds histogram\n"
-	for key, latencies := range httpRequestsLatency {
-		if len(latencies) > 0 {
-			var sum time.Duration
-			for _, lat := range latencies {
-				sum += lat
-			}
-			avg := sum / time.Duration(len(latencies))
-			output += fmt.Sprintf("http_request_duration_seconds{key=\"%s\"} %f\n", key, avg.Seconds())
+	w.family("http_request_duration_seconds", "HTTP request duration in seconds", "histogram")
+	for _, key := range keys {
+		h := httpRequestsDuration[key]
+		labels := requestLabels(key)
+
+		var cumulative uint64
+		for i, bound := range DefaultBuckets {
+			cumulative += h.counts[i]
+			w.sample("http_request_duration_seconds_bucket",
+				append(labels, label{"le", formatFloat(bound)}), float64(cumulative))
 		}
+		w.sample("http_request_duration_seconds_bucket", append(labels, label{"le", "+Inf"}), float64(h.count))
+		w.sample("http_request_duration_seconds_sum", labels, h.sum)
+		w.sample("http_request_duration_seconds_count", labels, float64(h.count))
 	}
+}
 
-	// WebSocket metrics
-	output += "# HELP websocket_connections_active Current number of active WebSocket connections\n"
-	output += "# TYPE websocket_connections_active gauge\n"
-	output += fmt.Sprintf("websocket_connections_active %d\n", websocketConnectionsActive)
+func writeWebSocketMetrics(w *expositionWriter) {
+	websocketConnectionsMutex.RLock()
+	defer websocketConnectionsMutex.RUnlock()
+
+	streams := sortedKeys(websocketConnectionsTotal)
 
-	output += "# HELP websocket_connections_total Total number of WebSocket connections\n"
-	output += "# TYPE websocket_connections_total counter\n"
-	output += fmt.Sprintf("websocket_connections_total %d\n", websocketConnectionsTotal)
+	w.family("websocket_connections_active", "Current number of active WebSocket connections", "gauge")
+	for _, stream := range streams {
+		w.sample("websocket_connections_active", []label{{"stream", stream}}, float64(websocketConnectionsActive[stream]))
+	}
 
-	c.Data(http.StatusOK, "text/plain; version=0.0.4", []byte(output))
+	w.family("websocket_connections_total", "Total number of WebSocket connections", "counter")
+	for _, stream := range streams {
+		w.sample("websocket_connections_total", []label{{"stream", stream}}, float64(websocketConnectionsTotal[stream]))
+	}
 }
 
+func requestLabels(key requestKey) []label {
+	return []label{{"method", key.method}, {"route", key.route}, {"status", key.status}}
+}
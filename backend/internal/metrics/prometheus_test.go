@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+)
+
+// fakeDockerClient serves a fixed container list and the events sent on
+// its channel
+type fakeDockerClient struct {
+	containers []container.Summary
+	events     chan events.Message
+	inspects   atomic.Int32
+}
+
+func (f *fakeDockerClient) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	return f.containers, nil
+}
+
+func (f *fakeDockerClient) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	f.inspects.Add(1)
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: containerID, RestartCount: 3},
+	}, nil
+}
+
+func (f *fakeDockerClient) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	return f.events, nil
+}
+
+func scrape(t *testing.T, exporter *ContainerExporter) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/metrics", Handler(exporter))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	return w.Body.String()
+}
+
+func TestHandler_HTTPHistogram(t *testing.T) {
+	RecordHTTPRequest("GET", "/api/test/:id", 200, 30*time.Millisecond)
+	RecordHTTPRequest("GET", "/api/test/:id", 200, 2*time.Second)
+	IncrementWebSocketConnections("test")
+
+	body := scrape(t, nil)
+
+	expected := []string{
+		"# TYPE http_request_duration_seconds histogram",
+		`http_requests_total{method="GET",route="/api/test/:id",status="200"} 2`,
+		`http_request_duration_seconds_bucket{method="GET",route="/api/test/:id",status="200",le="0.025"} 0`,
+		`http_request_duration_seconds_bucket{method="GET",route="/api/test/:id",status="200",le="0.05"} 1`,
+		`http_request_duration_seconds_bucket{method="GET",route="/api/test/:id",status="200",le="2.5"} 2`,
+		`http_request_duration_seconds_bucket{method="GET",route="/api/test/:id",status="200",le="+Inf"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="/api/test/:id",status="200"} 2`,
+		`websocket_connections_active{stream="test"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected line %q in output:\n%s", line, body)
+		}
+	}
+}
+
+func TestContainerExporter(t *testing.T) {
+	ctr := container.Summary{
+		ID:     "0123456789abcdef0123",
+		Names:  []string{"/payments-api"},
+		Image:  "payments:1.2",
+		State:  "running",
+		Labels: map[string]string{composeProjectLabel: "payments"},
+	}
+	stale := container.Summary{ID: "fedcba9876543210", Names: []string{"/old"}, State: "exited"}
+
+	exporter := NewContainerExporter(time.Minute, zap.NewNop())
+	exporter.AddHost("local", &fakeDockerClient{containers: []container.Summary{ctr, stale}})
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	exporter.now = func() time.Time { return now }
+	exporter.Observer("local")(stale, &docker.ContainerStats{CPUPercent: 1})
+	now = now.Add(2 * time.Minute)
+	exporter.Observer("local")(ctr, &docker.ContainerStats{CPUPercent: 12.5, MemoryUsage: 1024, PIDs: 7})
+
+	body := scrape(t, exporter)
+
+	labels := `host="local",id="0123456789ab",name="payments-api",image="payments:1.2",compose_project="payments"`
+	expected := []string{
+		"kubevision_container_cpu_usage_percent{" + labels + "} 12.5",
+		"kubevision_container_memory_usage_bytes{" + labels + "} 1024",
+		"kubevision_container_pids{" + labels + "} 7",
+		"kubevision_container_restart_count{" + labels + "} 3",
+		"kubevision_container_state{" + labels + `,state="running"} 1`,
+		"kubevision_container_state{" + labels + `,state="exited"} 0`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected line %q in output:\n%s", line, body)
+		}
+	}
+
+	if strings.Contains(body, `kubevision_container_cpu_usage_percent{host="local",id="fedcba987654"`) {
+		t.Error("Expected stale sample to be dropped")
+	}
+}
+
+func TestContainerExporter_CachesRestartCounts(t *testing.T) {
+	client := &fakeDockerClient{
+		containers: []container.Summary{{ID: "a", Names: []string{"/a"}}, {ID: "b", Names: []string{"/b"}}},
+		events:     make(chan events.Message),
+	}
+	exporter := NewContainerExporter(time.Minute, zap.NewNop())
+	exporter.AddHost("local", client)
+
+	// Without an events stream every scrape inspects
+	scrape(t, exporter)
+	scrape(t, exporter)
+	if got := client.inspects.Load(); got != 4 {
+		t.Fatalf("Expected 4 inspections without events, got %d", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exporter.WatchEvents(ctx, "local", client)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	for deadline := time.Now().Add(time.Second); ; {
+		exporter.restartsMu.Lock()
+		watching := exporter.restarts["local"] != nil
+		exporter.restartsMu.Unlock()
+		if watching {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the events stream to be watched")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	client.inspects.Store(0)
+	scrape(t, exporter)
+	body := scrape(t, exporter)
+	if got := client.inspects.Load(); got != 2 {
+		t.Errorf("Expected restart counts to be cached after 2 inspections, got %d", got)
+	}
+	if !strings.Contains(body, `kubevision_container_restart_count{host="local",id="a",name="a",image="",compose_project=""} 3`) {
+		t.Errorf("Expected cached restart count in output:\n%s", body)
+	}
+
+	// A start event refreshes only the started container
+	client.events <- events.Message{Type: events.ContainerEventType, Action: events.ActionStart, Actor: events.Actor{ID: "a"}}
+	client.events <- events.Message{} // wait for the first event to be handled
+	client.inspects.Store(0)
+	scrape(t, exporter)
+	if got := client.inspects.Load(); got != 1 {
+		t.Errorf("Expected 1 inspection after a start event, got %d", got)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if got := escapeLabelValue("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("Unexpected escaped value %q", got)
+	}
+}
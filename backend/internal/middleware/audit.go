@@ -0,0 +1,303 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/audit"
+)
+
+// AuditTargetKey is the context key a handler may set to name the target of
+// an action that has no :id or :name parameter, e.g. a created container
+const AuditTargetKey = "audit_target"
+
+const (
+	// maxCapturedErrorBody bounds how much of an error response is buffered
+	// to extract its message
+	maxCapturedErrorBody = 4096
+
+	// maxAuditedBody bounds the request bodies summarized in the audit log;
+	// larger ones are recorded by size only
+	maxAuditedBody = 64 * 1024
+
+	// maxAuditedString is the longest string value kept in a body summary
+	maxAuditedString = 256
+
+	// redacted replaces sensitive values in the audit log
+	redacted = "[REDACTED]"
+)
+
+// redactedQueryParams are never written to the audit log
+var redactedQueryParams = map[string]bool{
+	"token":  true,
+	"ticket": true,
+}
+
+// redactedBodyFields are request body fields whose values are never written
+// to the audit log. Environment variables and compose files often hold
+// secrets too.
+var redactedBodyFields = map[string]bool{
+	"env":         true,
+	"environment": true,
+	"compose":     true,
+	"auth":        true,
+}
+
+// sensitiveFieldParts redact every body field whose name contains them
+var sensitiveFieldParts = []string{"password", "secret", "token", "credential"}
+
+// auditWriter captures the start of error response bodies
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) capture(data []byte) {
+	if w.Status() >= http.StatusBadRequest && w.body.Len() < maxCapturedErrorBody {
+		remaining := maxCapturedErrorBody - w.body.Len()
+		if len(data) > remaining {
+			data = data[:remaining]
+		}
+		w.body.Write(data)
+	}
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController, so
+// handlers behind the audit middleware can still extend write deadlines
+func (w *auditWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// AuditMiddleware records the request as action in the audit log once the
+// handler returns. It should run before permission checks so that denied
+// attempts are recorded too. The target is the :id or :name path parameter;
+// other path parameters and the query string are recorded as parameters,
+// and a JSON body as a summary with secrets redacted.
+func AuditMiddleware(recorder interface {
+	Record(record audit.Record) error
+}, action, host string, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		body := peekBody(c)
+
+		c.Next()
+
+		record := newRecord(c, writer, action, host, start)
+		for _, param := range c.Params {
+			if record.Target == "" && (param.Key == "id" || param.Key == "name") {
+				record.Target = param.Value
+				continue
+			}
+			record.Params[param.Key] = param.Value
+		}
+		for key, values := range c.Request.URL.Query() {
+			if !redactedQueryParams[key] && len(values) > 0 {
+				record.Params[key] = values[0]
+			}
+		}
+		if len(record.Params) == 0 {
+			record.Params = nil
+		}
+		if record.Target == "" {
+			record.Target = c.GetString(AuditTargetKey)
+		}
+		record.Body = summarizeBody(body, c.Request.ContentLength)
+
+		if err := recorder.Record(record); err != nil {
+			logger.Error("Failed to write audit record",
+				zap.String("action", action),
+				zap.String("target", record.Target),
+				zap.Error(err))
+		}
+	}
+}
+
+// AuditUnauthenticated records requests rejected by AuthMiddleware, which
+// never reach the per-route audit middleware, as "auth.unauthenticated" with
+// the request path as target. It must run before AuthMiddleware.
+func AuditUnauthenticated(recorder interface {
+	Record(record audit.Record) error
+}, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		if c.Writer.Status() != http.StatusUnauthorized || GetPrincipal(c) != nil {
+			return
+		}
+		record := newRecord(c, writer, "auth.unauthenticated", "", start)
+		record.Target = c.Request.URL.Path
+		record.Params = map[string]string{"method": c.Request.Method}
+
+		if err := recorder.Record(record); err != nil {
+			logger.Error("Failed to write audit record",
+				zap.String("action", record.Action),
+				zap.String("target", record.Target),
+				zap.Error(err))
+		}
+	}
+}
+
+// newRecord builds the audit record of a handled request with its actor,
+// outcome and error, and empty parameters
+func newRecord(c *gin.Context, writer *auditWriter, action, host string, start time.Time) audit.Record {
+	record := audit.Record{
+		Time:          start.UTC(),
+		ClientIP:      c.ClientIP(),
+		CorrelationID: c.GetString(CorrelationIDKey),
+		Action:        action,
+		Host:          host,
+		Params:        make(map[string]string),
+		Status:        c.Writer.Status(),
+		DurationMs:    float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if principal := GetPrincipal(c); principal != nil {
+		record.Actor = principal.User
+		record.Role = string(principal.Role)
+		record.Token = principal.TokenName
+		record.Session = principal.SessionID
+	}
+
+	switch status := record.Status; {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		record.Outcome = audit.OutcomeDenied
+	case status >= http.StatusBadRequest:
+		record.Outcome = audit.OutcomeFailure
+	default:
+		record.Outcome = audit.OutcomeSuccess
+	}
+	if record.Outcome != audit.OutcomeSuccess {
+		record.Error = errorMessage(writer.body.Bytes())
+	}
+	return record
+}
+
+// peekBody reads up to maxAuditedBody+1 bytes of a JSON request body and
+// puts them back for the handler
+func peekBody(c *gin.Context) []byte {
+	if c.Request.Body == nil || c.Request.ContentLength == 0 {
+		return nil
+	}
+	if contentType := c.ContentType(); contentType != "" && contentType != gin.MIMEJSON {
+		return nil
+	}
+
+	body := c.Request.Body
+	peeked, err := io.ReadAll(io.LimitReader(body, maxAuditedBody+1))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), body), body}
+	if err != nil {
+		return nil
+	}
+	return peeked
+}
+
+// summarizeBody returns a JSON body with secrets redacted and long strings
+// replaced by their size. Bodies that are too large or not JSON are
+// summarized by size.
+func summarizeBody(body []byte, contentLength int64) any {
+	if len(body) == 0 {
+		return nil
+	}
+	if len(body) > maxAuditedBody {
+		if contentLength > 0 {
+			return fmt.Sprintf("[%d bytes]", contentLength)
+		}
+		return fmt.Sprintf("[more than %d bytes]", maxAuditedBody)
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Sprintf("[%d bytes]", len(body))
+	}
+	return redactValue(value)
+}
+
+// redactValue redacts sensitive fields of a decoded JSON value
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if sensitiveField(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(field)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	case string:
+		if len(v) > maxAuditedString {
+			return fmt.Sprintf("[%d bytes]", len(v))
+		}
+	}
+	return value
+}
+
+// sensitiveField reports whether a body field's value must not be logged
+func sensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	if redactedBodyFields[name] {
+		return true
+	}
+	for _, part := range sensitiveFieldParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorMessage extracts the error of a JSON error response, which is
+// either a plain string or a structured error with message and details
+func errorMessage(body []byte) string {
+	var response struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil || len(response.Error) == 0 {
+		return ""
+	}
+
+	var message string
+	if err := json.Unmarshal(response.Error, &message); err == nil {
+		return message
+	}
+	var structured struct {
+		Message string `json:"message"`
+		Details string `json:"details"`
+	}
+	if err := json.Unmarshal(response.Error, &structured); err != nil {
+		return ""
+	}
+	if structured.Details != "" {
+		return structured.Message + ": " + structured.Details
+	}
+	return structured.Message
+}
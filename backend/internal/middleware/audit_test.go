@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/audit"
+	"github.com/kubevision/kubevision/internal/auth"
+)
+
+// memoryRecorder keeps audit records in memory
+type memoryRecorder struct {
+	records []audit.Record
+}
+
+func (m *memoryRecorder) Record(record audit.Record) error {
+	m.records = append(m.records, record)
+	return nil
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	viewer, _ := auth.NewPrincipal("viewer", auth.RoleViewer, "laptop", nil)
+	admin, _ := auth.NewPrincipal("root", auth.RoleAdmin, "ci", nil)
+	recorder := &memoryRecorder{}
+
+	router := gin.New()
+	router.Use(CorrelationIDMiddleware())
+	router.Use(AuthMiddleware(true, staticAuthenticator{"viewer-token": viewer, "admin-token": admin}))
+	router.DELETE("/images/:id",
+		AuditMiddleware(recorder, "image.remove", "local", zap.NewNop()),
+		RequirePermission(auth.PermImagesDelete),
+		func(c *gin.Context) {
+			if c.Query("force") != "true" {
+				c.JSON(http.StatusConflict, gin.H{"success": false, "error": gin.H{"message": "Image in use", "details": "container abc"}})
+				return
+			}
+			c.Status(http.StatusOK)
+		})
+
+	testCases := []struct {
+		name    string
+		token   string
+		query   string
+		outcome audit.Outcome
+		err     string
+	}{
+		{"denied", "viewer-token", "?force=true", audit.OutcomeDenied, "Permission denied: images:delete"},
+		{"failure", "admin-token", "", audit.OutcomeFailure, "Image in use: container abc"},
+		{"success", "admin-token", "?force=true&ticket=secret", audit.OutcomeSuccess, ""},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/images/sha256:ff"+tc.query, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			req.Header.Set(CorrelationIDHeader, "corr-"+tc.name)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(recorder.records) != i+1 {
+				t.Fatalf("Expected %d audit records, got %d", i+1, len(recorder.records))
+			}
+			record := recorder.records[i]
+			if record.Outcome != tc.outcome || record.Error != tc.err {
+				t.Errorf("Expected %s %q, got %s %q", tc.outcome, tc.err, record.Outcome, record.Error)
+			}
+			if record.Action != "image.remove" || record.Host != "local" || record.Target != "sha256:ff" {
+				t.Errorf("Unexpected action/target: %+v", record)
+			}
+			if record.CorrelationID != "corr-"+tc.name || record.Actor == "" || record.Token == "" {
+				t.Errorf("Expected actor, token and correlation ID, got %+v", record)
+			}
+			if _, ok := record.Params["ticket"]; ok {
+				t.Error("Expected ticket to be redacted")
+			}
+		})
+	}
+}
+
+func TestAuditMiddleware_RedactsBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := &memoryRecorder{}
+
+	var received string
+	router := gin.New()
+	router.POST("/registries/:name",
+		AuditMiddleware(recorder, "registry.put", "", zap.NewNop()),
+		func(c *gin.Context) {
+			body, _ := io.ReadAll(c.Request.Body)
+			received = string(body)
+			c.Status(http.StatusOK)
+		})
+
+	body := `{"username":"ci","password":"hunter2","IdentityToken":"abc","spec":{"env":["A=1"],"image":"` + strings.Repeat("x", 300) + `"}}`
+	req := httptest.NewRequest(http.MethodPost, "/registries/ghcr", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != body {
+		t.Fatalf("Expected the handler to read the full body, got %q", received)
+	}
+	summary, ok := recorder.records[0].Body.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected a body summary, got %#v", recorder.records[0].Body)
+	}
+	spec, _ := summary["spec"].(map[string]any)
+	if summary["username"] != "ci" || summary["password"] != redacted || summary["IdentityToken"] != redacted {
+		t.Errorf("Unexpected body summary: %#v", summary)
+	}
+	if spec["env"] != redacted || spec["image"] != "[300 bytes]" {
+		t.Errorf("Unexpected nested summary: %#v", spec)
+	}
+}
+
+func TestAuditUnauthenticated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	viewer, _ := auth.NewPrincipal("viewer", auth.RoleViewer, "laptop", nil)
+	recorder := &memoryRecorder{}
+
+	router := gin.New()
+	group := router.Group("/api",
+		AuditUnauthenticated(recorder, zap.NewNop()),
+		AuthMiddleware(true, staticAuthenticator{"viewer-token": viewer}))
+	group.POST("/containers/:id/stop", RequirePermission(auth.PermContainersControl))
+
+	for _, token := range []string{"", "wrong", "viewer-token"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/containers/abc/stop", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(recorder.records) != 2 {
+		t.Fatalf("Expected the 2 unauthenticated requests to be recorded, got %+v", recorder.records)
+	}
+	record := recorder.records[1]
+	if record.Action != "auth.unauthenticated" || record.Target != "/api/containers/abc/stop" ||
+		record.Outcome != audit.OutcomeDenied || record.Error != "Invalid token" || record.Params["method"] != http.MethodPost {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+}
@@ -1,47 +1,159 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/docker/docker/api/types/container"
 	"github.com/gin-gonic/gin"
+
+	"github.com/kubevision/kubevision/internal/auth"
+)
+
+const (
+	// PrincipalKey is the context key holding the authenticated principal
+	PrincipalKey = "principal"
+
+	// BearerSubprotocolPrefix prefixes a token passed as a WebSocket
+	// subprotocol, e.g. Sec-WebSocket-Protocol: kubevision, bearer.<token>
+	BearerSubprotocolPrefix = "bearer."
 )
 
-// AuthMiddleware validates authentication tokens
-func AuthMiddleware(authEnabled bool, authToken string) gin.HandlerFunc {
+// AuthMiddleware validates authentication tokens and stores the resulting
+// principal in the context. When auth is disabled every request acts as
+// the anonymous admin.
+func AuthMiddleware(authEnabled bool, authenticator interface {
+	Authenticate(token string) (*auth.Principal, error)
+	RedeemTicket(ticket string) (*auth.Principal, error)
+}) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Skip auth if disabled
 		if !authEnabled {
+			c.Set(PrincipalKey, auth.Anonymous)
 			c.Next()
 			return
 		}
 
-		// Get token from Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		var principal *auth.Principal
+		var err error
+		if ticket := websocketTicket(c); ticket != "" {
+			principal, err = authenticator.RedeemTicket(ticket)
+		} else {
+			token := requestToken(c)
+			if token == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"error":   "Authorization header required",
+				})
+				c.Abort()
+				return
+			}
+
+			// Validate token signature, expiry and session
+			principal, err = authenticator.Authenticate(token)
+		}
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
-				"error":   "Authorization header required",
+				"error":   "Invalid token",
 			})
 			c.Abort()
 			return
 		}
 
-		// Extract token (format: "Bearer <token>" or just "<token>")
-		token := strings.TrimSpace(authHeader)
-		token = strings.TrimPrefix(token, "Bearer ")
+		c.Set(PrincipalKey, principal)
+		c.Next()
+	}
+}
 
-		// Validate token
-		if token != authToken {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"error":   "Invalid token",
-			})
-			c.Abort()
+// isWebSocketUpgrade reports whether the request is a WebSocket handshake
+func isWebSocketUpgrade(c *gin.Context) bool {
+	return strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+}
+
+// websocketTicket returns the one-time ticket of a WebSocket upgrade
+func websocketTicket(c *gin.Context) string {
+	if !isWebSocketUpgrade(c) {
+		return ""
+	}
+	return c.Query("ticket")
+}
+
+// requestToken extracts the token from the Authorization header ("Bearer
+// <token>" or just "<token>"). Browsers cannot set headers on WebSocket
+// upgrades, so those may offer it as a "bearer.<token>" subprotocol instead.
+func requestToken(c *gin.Context) string {
+	if authHeader := strings.TrimSpace(c.GetHeader("Authorization")); authHeader != "" {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	if isWebSocketUpgrade(c) {
+		for _, header := range c.Request.Header.Values("Sec-WebSocket-Protocol") {
+			for _, protocol := range strings.Split(header, ",") {
+				if token, ok := strings.CutPrefix(strings.TrimSpace(protocol), BearerSubprotocolPrefix); ok {
+					return token
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// GetPrincipal returns the authenticated principal of a request
+func GetPrincipal(c *gin.Context) *auth.Principal {
+	if value, ok := c.Get(PrincipalKey); ok {
+		if principal, ok := value.(*auth.Principal); ok {
+			return principal
+		}
+	}
+	return nil
+}
+
+// RequirePermission rejects requests whose principal lacks perm
+func RequirePermission(perm auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := GetPrincipal(c)
+		if principal == nil || !principal.Can(perm) {
+			forbidden(c, perm)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireContainerPermission rejects requests whose principal may not
+// perform perm on the container in the :id path parameter. Containers are
+// only inspected when the permission is restricted by a label selector.
+func RequireContainerPermission(perm auth.Permission, dockerClient interface {
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := GetPrincipal(c)
+		if principal == nil || !principal.Can(perm) {
+			forbidden(c, perm)
 			return
 		}
 
+		if _, restricted := principal.Restricted(perm); restricted {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+			defer cancel()
+
+			inspect, err := dockerClient.ContainerInspect(ctx, c.Param("id"))
+			if err != nil || inspect.Config == nil || !principal.CanOn(perm, inspect.Config.Labels) {
+				forbidden(c, perm)
+				return
+			}
+		}
+
 		c.Next()
 	}
 }
 
+func forbidden(c *gin.Context, perm auth.Permission) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"error":   "Permission denied: " + string(perm),
+	})
+	c.Abort()
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kubevision/kubevision/internal/auth"
+)
+
+// staticAuthenticator maps tokens to principals
+type staticAuthenticator map[string]*auth.Principal
+
+func (a staticAuthenticator) Authenticate(token string) (*auth.Principal, error) {
+	if principal, ok := a[token]; ok {
+		return principal, nil
+	}
+	return nil, auth.ErrInvalidToken
+}
+
+// RedeemTicket accepts "ticket-<token>" as a ticket for token
+func (a staticAuthenticator) RedeemTicket(ticket string) (*auth.Principal, error) {
+	token, ok := strings.CutPrefix(ticket, "ticket-")
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+	return a.Authenticate(token)
+}
+
+// labelInspector returns containers with fixed labels
+type labelInspector map[string]map[string]string
+
+func (l labelInspector) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	return container.InspectResponse{Config: &container.Config{Labels: l[containerID]}}, nil
+}
+
+func TestAuthMiddleware_Permissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	viewer, _ := auth.NewPrincipal("viewer", auth.RoleViewer, "t", nil)
+	operator, _ := auth.NewPrincipal("ops", auth.RoleOperator, "t", map[auth.Permission]string{
+		auth.PermContainersControl: "com.docker.compose.project=payments",
+	})
+	authenticator := staticAuthenticator{"viewer-token": viewer, "operator-token": operator}
+	inspector := labelInspector{
+		"payments123456": {"com.docker.compose.project": "payments"},
+		"billing1234567": {"com.docker.compose.project": "billing"},
+	}
+
+	router := gin.New()
+	router.Use(AuthMiddleware(true, authenticator))
+	router.GET("/containers", RequirePermission(auth.PermContainersRead), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/containers/:id/stop", RequireContainerPermission(auth.PermContainersControl, inspector), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"missing token", http.MethodGet, "/containers", "", http.StatusUnauthorized},
+		{"invalid token", http.MethodGet, "/containers", "nope", http.StatusUnauthorized},
+		{"viewer reads", http.MethodGet, "/containers", "viewer-token", http.StatusOK},
+		{"viewer cannot control", http.MethodPost, "/containers/payments123456/stop", "viewer-token", http.StatusForbidden},
+		{"operator controls matching", http.MethodPost, "/containers/payments123456/stop", "operator-token", http.StatusOK},
+		{"operator cannot control other project", http.MethodPost, "/containers/billing1234567/stop", "operator-token", http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(AuthMiddleware(false, staticAuthenticator{}))
+	router.DELETE("/images/:id", RequirePermission(auth.PermImagesDelete), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/images/abc", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected anonymous admin access when auth is disabled, got %d", w.Code)
+	}
+}
+
+func TestAuthMiddleware_WebSocketCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	viewer, _ := auth.NewPrincipal("viewer", auth.RoleViewer, "t", nil)
+	router := gin.New()
+	router.Use(AuthMiddleware(true, staticAuthenticator{"viewer-token": viewer}))
+	router.GET("/ws/events", RequirePermission(auth.PermContainersRead), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	testCases := []struct {
+		name     string
+		path     string
+		protocol string
+		upgrade  bool
+		status   int
+	}{
+		{"subprotocol token", "/ws/events", "kubevision, bearer.viewer-token", true, http.StatusOK},
+		{"invalid subprotocol token", "/ws/events", "kubevision, bearer.nope", true, http.StatusUnauthorized},
+		{"ticket", "/ws/events?ticket=ticket-viewer-token", "", true, http.StatusOK},
+		{"invalid ticket", "/ws/events?ticket=ticket-nope", "", true, http.StatusUnauthorized},
+		{"ticket without upgrade", "/ws/events?ticket=ticket-viewer-token", "", false, http.StatusUnauthorized},
+		{"token query parameter", "/ws/events?token=viewer-token", "", true, http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.upgrade {
+				req.Header.Set("Upgrade", "websocket")
+			}
+			if tc.protocol != "" {
+				req.Header.Set("Sec-WebSocket-Protocol", tc.protocol)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, w.Code)
+			}
+		})
+	}
+}
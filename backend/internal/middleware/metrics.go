@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kubevision/kubevision/internal/metrics"
+)
+
+// MetricsMiddleware records request counts and durations by method, route
+// pattern and status. WebSocket upgrades are tracked by the stream handlers.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		// Unmatched paths share one label value to bound cardinality
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.RecordHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
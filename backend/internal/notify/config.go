@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kubevision/kubevision/internal/alerting"
+)
+
+// Notifier types
+const (
+	TypeWebhook = "webhook"
+	TypeSlack   = "slack"
+	TypeSMTP    = "smtp"
+)
+
+// Config is the notifications section of the alert rules file
+type Config struct {
+	// GroupWait is how long transitions for the same rule are collected
+	// before a single grouped notification is sent
+	GroupWait time.Duration `yaml:"group_wait"`
+
+	// RepeatInterval suppresses re-sending an alert in the same state
+	RepeatInterval time.Duration `yaml:"repeat_interval"`
+
+	Notifiers []NotifierConfig `yaml:"notifiers"`
+}
+
+// NotifierConfig configures a single notifier
+type NotifierConfig struct {
+	Name        string      `yaml:"name"`
+	Type        string      `yaml:"type"`
+	MinSeverity string      `yaml:"min_severity"`
+	Template    string      `yaml:"template"`
+	Retry       RetryConfig `yaml:"retry"`
+
+	// Webhook and Slack
+	URL     string        `yaml:"url"`
+	Secret  string        `yaml:"secret"`
+	Timeout time.Duration `yaml:"timeout"`
+
+	// SMTP
+	Host            string   `yaml:"host"`
+	Port            int      `yaml:"port"`
+	Username        string   `yaml:"username"`
+	Password        string   `yaml:"password"`
+	From            string   `yaml:"from"`
+	To              []string `yaml:"to"`
+	SubjectTemplate string   `yaml:"subject_template"`
+}
+
+// RetryConfig configures delivery retries with exponential backoff
+type RetryConfig struct {
+	Attempts       int           `yaml:"attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// configFile is the on-disk layout of the rules file as seen by this package
+type configFile struct {
+	Notifications Config `yaml:"notifications"`
+}
+
+// LoadConfig reads the notifications section from the alert rules file.
+// Environment variables (${VAR}) are expanded so secrets can stay out of it.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read notifications config: %w", err)
+	}
+	return ParseConfig([]byte(os.ExpandEnv(string(data))))
+}
+
+// ParseConfig parses and validates the notifications section
+func ParseConfig(data []byte) (Config, error) {
+	var file configFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return Config{}, fmt.Errorf("failed to parse notifications config: %w", err)
+	}
+
+	cfg := file.Notifications
+	if cfg.GroupWait == 0 {
+		cfg.GroupWait = 30 * time.Second
+	}
+	if cfg.RepeatInterval == 0 {
+		cfg.RepeatInterval = 4 * time.Hour
+	}
+
+	seen := make(map[string]bool, len(cfg.Notifiers))
+	for i := range cfg.Notifiers {
+		n := &cfg.Notifiers[i]
+		if err := n.validate(); err != nil {
+			return Config{}, fmt.Errorf("notifier %d (%s): %w", i, n.Name, err)
+		}
+		if seen[n.Name] {
+			return Config{}, fmt.Errorf("notifier %d: duplicate notifier name %q", i, n.Name)
+		}
+		seen[n.Name] = true
+	}
+
+	return cfg, nil
+}
+
+func (n *NotifierConfig) validate() error {
+	if n.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	switch n.Type {
+	case TypeWebhook, TypeSlack:
+		if n.URL == "" {
+			return fmt.Errorf("url is required")
+		}
+	case TypeSMTP:
+		if n.Host == "" || n.From == "" || len(n.To) == 0 {
+			return fmt.Errorf("host, from and to are required")
+		}
+		if n.Port == 0 {
+			n.Port = 587
+		}
+	default:
+		return fmt.Errorf("unknown notifier type %q", n.Type)
+	}
+
+	if n.MinSeverity == "" {
+		n.MinSeverity = alerting.SeverityInfo
+	}
+	if _, ok := severityRank[n.MinSeverity]; !ok {
+		return fmt.Errorf("unknown min_severity %q", n.MinSeverity)
+	}
+
+	if n.Timeout == 0 {
+		n.Timeout = 10 * time.Second
+	}
+	if n.Retry.Attempts == 0 {
+		n.Retry.Attempts = 5
+	}
+	if n.Retry.InitialBackoff == 0 {
+		n.Retry.InitialBackoff = time.Second
+	}
+	if n.Retry.MaxBackoff == 0 {
+		n.Retry.MaxBackoff = time.Minute
+	}
+
+	return nil
+}
+
+// severityRank orders severities for min_severity filtering
+var severityRank = map[string]int{
+	alerting.SeverityInfo:     0,
+	alerting.SeverityWarning:  1,
+	alerting.SeverityCritical: 2,
+}
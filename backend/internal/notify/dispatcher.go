@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/alerting"
+)
+
+// route pairs a notifier with its severity filter
+type route struct {
+	notifier    Notifier
+	minSeverity int
+}
+
+// group collects transitions for one rule until its wait expires
+type group struct {
+	alerts   map[string]alerting.Alert // latest transition per alert ID
+	deadline time.Time
+}
+
+// sentRecord remembers the last delivered state of an alert
+type sentRecord struct {
+	state alerting.State
+	at    time.Time
+}
+
+// Dispatcher groups alert transitions by rule, drops duplicates and fans the
+// resulting notifications out to notifiers
+type Dispatcher struct {
+	routes         []route
+	groupWait      time.Duration
+	repeatInterval time.Duration
+	groups         map[string]*group
+	sent           map[string]sentRecord
+	inflight       sync.WaitGroup
+	now            func() time.Time
+	logger         *zap.Logger
+}
+
+// NewDispatcher creates a dispatcher from the notifications config
+func NewDispatcher(cfg Config, logger *zap.Logger) (*Dispatcher, error) {
+	d := &Dispatcher{
+		groupWait:      cfg.GroupWait,
+		repeatInterval: cfg.RepeatInterval,
+		groups:         make(map[string]*group),
+		sent:           make(map[string]sentRecord),
+		now:            time.Now,
+		logger:         logger,
+	}
+
+	for _, notifierCfg := range cfg.Notifiers {
+		notifier, err := New(notifierCfg)
+		if err != nil {
+			return nil, err
+		}
+		d.AddNotifier(notifier, notifierCfg.MinSeverity)
+	}
+
+	return d, nil
+}
+
+// AddNotifier registers a notifier receiving alerts at or above minSeverity
+func (d *Dispatcher) AddNotifier(notifier Notifier, minSeverity string) {
+	d.routes = append(d.routes, route{
+		notifier:    notifier,
+		minSeverity: severityRank[minSeverity],
+	})
+}
+
+// Run consumes transitions until ctx is cancelled or the channel closes,
+// then flushes pending groups and waits for in-flight deliveries
+func (d *Dispatcher) Run(ctx context.Context, transitions <-chan alerting.Alert) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	defer func() {
+		// Give pending groups a last chance with a bounded deadline
+		flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		d.flush(flushCtx, true)
+		d.inflight.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert, ok := <-transitions:
+			if !ok {
+				return
+			}
+			d.add(alert)
+		case <-ticker.C:
+			d.flush(ctx, false)
+		}
+	}
+}
+
+// add queues a transition into its rule's group
+func (d *Dispatcher) add(alert alerting.Alert) {
+	// Pending alerts are not actionable yet
+	if alert.State == alerting.StatePending {
+		return
+	}
+
+	g, ok := d.groups[alert.Rule]
+	if !ok {
+		g = &group{
+			alerts:   make(map[string]alerting.Alert),
+			deadline: d.now().Add(d.groupWait),
+		}
+		d.groups[alert.Rule] = g
+	}
+	g.alerts[alert.ID] = alert
+}
+
+// flush delivers groups whose wait has expired (or all groups when force is set)
+func (d *Dispatcher) flush(ctx context.Context, force bool) {
+	now := d.now()
+
+	for rule, g := range d.groups {
+		if !force && now.Before(g.deadline) {
+			continue
+		}
+		delete(d.groups, rule)
+
+		alerts := make([]alerting.Alert, 0, len(g.alerts))
+		for id, alert := range g.alerts {
+			if last, ok := d.sent[id]; ok && last.state == alert.State && now.Sub(last.at) < d.repeatInterval {
+				continue
+			}
+			// A resolution is only interesting if the firing was delivered
+			if _, ok := d.sent[id]; !ok && alert.State == alerting.StateResolved {
+				continue
+			}
+			alerts = append(alerts, alert)
+			if alert.State == alerting.StateResolved {
+				delete(d.sent, id)
+			} else {
+				d.sent[id] = sentRecord{state: alert.State, at: now}
+			}
+		}
+
+		if len(alerts) > 0 {
+			d.deliver(ctx, newNotification(rule, alerts))
+		}
+	}
+}
+
+// deliver sends a notification to every matching notifier concurrently
+func (d *Dispatcher) deliver(ctx context.Context, notification Notification) {
+	for _, r := range d.routes {
+		alerts := make([]alerting.Alert, 0, len(notification.Alerts))
+		for _, alert := range notification.Alerts {
+			if severityRank[alert.Severity] >= r.minSeverity {
+				alerts = append(alerts, alert)
+			}
+		}
+		if len(alerts) == 0 {
+			continue
+		}
+		filtered := newNotification(notification.Group, alerts)
+
+		d.inflight.Add(1)
+		go func(notifier Notifier, notification Notification) {
+			defer d.inflight.Done()
+			if err := notifier.Notify(ctx, notification); err != nil {
+				d.logger.Error("Failed to deliver alert notification",
+					zap.String("notifier", notifier.Name()),
+					zap.String("group", notification.Group),
+					zap.Error(err))
+				return
+			}
+			d.logger.Info("Delivered alert notification",
+				zap.String("notifier", notifier.Name()),
+				zap.String("group", notification.Group),
+				zap.String("status", notification.Status),
+				zap.Int("alerts", len(notification.Alerts)))
+		}(r.notifier, filtered)
+	}
+}
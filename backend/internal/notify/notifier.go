@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kubevision/kubevision/internal/alerting"
+)
+
+// DefaultTemplate renders a grouped notification as plain text
+const DefaultTemplate = `[{{ .Status | upper }}] {{ .Group }} ({{ len .Alerts }} alert{{ if gt (len .Alerts) 1 }}s{{ end }})
+{{ range .Alerts }}- [{{ .Severity }}] {{ .ContainerName }} ({{ .Image }}): {{ .Message }}
+{{- range $key, $value := .Labels }}
+    {{ $key }}={{ $value }}
+{{- end }}
+{{ end }}`
+
+// DefaultSubjectTemplate renders the email subject line
+const DefaultSubjectTemplate = `[KubeVision] {{ .Status | upper }}: {{ .Group }}`
+
+// Notification is a group of alert transitions delivered together
+type Notification struct {
+	Group  string           `json:"group"`
+	Status string           `json:"status"`
+	Alerts []alerting.Alert `json:"alerts"`
+	SentAt time.Time        `json:"sent_at"`
+}
+
+// Notifier delivers notifications to an external system
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// permanentError marks a delivery failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// New creates a notifier from its configuration
+func New(cfg NotifierConfig) (Notifier, error) {
+	tmpl, err := parseTemplate(cfg.Name, cfg.Template, DefaultTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case TypeWebhook:
+		return newWebhookNotifier(cfg, tmpl), nil
+	case TypeSlack:
+		return newSlackNotifier(cfg, tmpl), nil
+	case TypeSMTP:
+		subject, err := parseTemplate(cfg.Name+"-subject", cfg.SubjectTemplate, DefaultSubjectTemplate)
+		if err != nil {
+			return nil, err
+		}
+		return newSMTPNotifier(cfg, tmpl, subject), nil
+	}
+	return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+}
+
+// newNotification builds a notification, reporting "firing" when any alert
+// in the group is firing
+func newNotification(group string, alerts []alerting.Alert) Notification {
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].ContainerName < alerts[j].ContainerName
+	})
+
+	status := string(alerting.StateResolved)
+	for _, alert := range alerts {
+		if alert.State == alerting.StateFiring {
+			status = string(alerting.StateFiring)
+			break
+		}
+	}
+
+	return Notification{
+		Group:  group,
+		Status: status,
+		Alerts: alerts,
+		SentAt: time.Now(),
+	}
+}
+
+func parseTemplate(name, text, fallback string) (*template.Template, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template for notifier %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+func render(tmpl *template.Template, notification Notification) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, notification); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// withRetry calls send until it succeeds, fails permanently or attempts run
+// out, doubling the backoff between attempts
+func withRetry(ctx context.Context, retry RetryConfig, send func(ctx context.Context) error) error {
+	backoff := retry.InitialBackoff
+	var err error
+	for attempt := 1; attempt <= retry.Attempts; attempt++ {
+		if err = send(ctx); err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt == retry.Attempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > retry.MaxBackoff {
+			backoff = retry.MaxBackoff
+		}
+	}
+	return err
+}
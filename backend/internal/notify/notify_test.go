@@ -0,0 +1,316 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/alerting"
+)
+
+var testRetry = RetryConfig{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+func firingAlert(id, severity string) alerting.Alert {
+	return alerting.Alert{
+		ID:            "high-cpu/" + id,
+		Rule:          "high-cpu",
+		Severity:      severity,
+		State:         alerting.StateFiring,
+		ContainerID:   id,
+		ContainerName: "payments-" + id,
+		Image:         "payments:1.2",
+		Labels:        map[string]string{"com.docker.compose.project": "payments"},
+		Message:       "cpu_percent on payments-" + id + " is 95.00 (> 80.00)",
+	}
+}
+
+func TestWebhookNotifier_SignsAndRetries(t *testing.T) {
+	var attempts int32
+	var payload webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(SignatureHeader), "sha256="+Sign("s3cret", r.Header.Get(TimestampHeader), body); got != want {
+			t.Errorf("Expected signature %s, got %s", want, got)
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier, err := New(NotifierConfig{Name: "hook", Type: TypeWebhook, URL: server.URL, Secret: "s3cret", Retry: testRetry})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	notification := newNotification("high-cpu", []alerting.Alert{firingAlert("a", alerting.SeverityWarning)})
+	if err := notifier.Notify(context.Background(), notification); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+	if payload.Status != "firing" || len(payload.Alerts) != 1 {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+	if !strings.Contains(payload.Text, "payments-a (payments:1.2)") {
+		t.Errorf("Expected rendered text with container and image, got %q", payload.Text)
+	}
+}
+
+func TestWebhookNotifier_PermanentFailure(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	notifier, _ := New(NotifierConfig{Name: "hook", Type: TypeWebhook, URL: server.URL, Retry: testRetry})
+	err := notifier.Notify(context.Background(), newNotification("g", []alerting.Alert{firingAlert("a", alerting.SeverityInfo)}))
+	if err == nil {
+		t.Fatal("Expected error for 400 response")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected no retries for a 4xx response, got %d attempts", attempts)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var payload slackPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	notifier, err := New(NotifierConfig{
+		Name:     "slack",
+		Type:     TypeSlack,
+		URL:      server.URL,
+		Template: `{{ range .Alerts }}{{ .ContainerName }} {{ index .Labels "com.docker.compose.project" }}{{ end }}`,
+		Retry:    testRetry,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if err := notifier.Notify(context.Background(), newNotification("g", []alerting.Alert{firingAlert("a", alerting.SeverityInfo)})); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if payload.Text != "payments-a payments" {
+		t.Errorf("Expected custom template output, got %q", payload.Text)
+	}
+}
+
+// fakeSMTPServer accepts a single SMTP session and records the message data
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "DATA"):
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				messages <- data.String()
+				reply("250 queued")
+			case strings.HasPrefix(command, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), messages
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, messages := fakeSMTPServer(t)
+	host, portStr, _ := net.SplitHostPort(addr)
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("Invalid port: %v", err)
+	}
+
+	cfg := NotifierConfig{
+		Name:  "email",
+		Type:  TypeSMTP,
+		Host:  host,
+		Port:  port,
+		From:  "kubevision@example.com",
+		To:    []string{"ops@example.com"},
+		Retry: testRetry,
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+
+	notifier, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := notifier.Notify(context.Background(), newNotification("high-cpu", []alerting.Alert{firingAlert("a", alerting.SeverityCritical)})); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	select {
+	case msg := <-messages:
+		if !strings.Contains(msg, "Subject: [KubeVision] FIRING: high-cpu") {
+			t.Errorf("Expected subject header, got %q", msg)
+		}
+		if !strings.Contains(msg, "com.docker.compose.project=payments") {
+			t.Errorf("Expected labels in body, got %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for SMTP message")
+	}
+}
+
+// recordingNotifier records notifications it receives
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+}
+
+func (r *recordingNotifier) Name() string { return "recorder" }
+
+func (r *recordingNotifier) Notify(ctx context.Context, notification Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifications = append(r.notifications, notification)
+	return nil
+}
+
+func TestDispatcher_GroupsAndDeduplicates(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	dispatcher, err := NewDispatcher(Config{GroupWait: 30 * time.Second, RepeatInterval: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewDispatcher failed: %v", err)
+	}
+	dispatcher.now = func() time.Time { return clock }
+
+	all := &recordingNotifier{}
+	criticalOnly := &recordingNotifier{}
+	dispatcher.AddNotifier(all, alerting.SeverityInfo)
+	dispatcher.AddNotifier(criticalOnly, alerting.SeverityCritical)
+
+	dispatcher.add(firingAlert("a", alerting.SeverityWarning))
+	dispatcher.add(firingAlert("b", alerting.SeverityCritical))
+
+	// Nothing is sent until the group wait elapses
+	dispatcher.flush(context.Background(), false)
+	dispatcher.inflight.Wait()
+	if len(all.notifications) != 0 {
+		t.Fatalf("Expected no notifications before group wait, got %d", len(all.notifications))
+	}
+
+	clock = clock.Add(31 * time.Second)
+	dispatcher.flush(context.Background(), false)
+	dispatcher.inflight.Wait()
+	if len(all.notifications) != 1 || len(all.notifications[0].Alerts) != 2 {
+		t.Fatalf("Expected one grouped notification with 2 alerts, got %+v", all.notifications)
+	}
+	if len(criticalOnly.notifications) != 1 || len(criticalOnly.notifications[0].Alerts) != 1 {
+		t.Fatalf("Expected critical-only notifier to receive 1 alert, got %+v", criticalOnly.notifications)
+	}
+
+	// The same firing alert within the repeat interval is suppressed
+	dispatcher.add(firingAlert("a", alerting.SeverityWarning))
+	clock = clock.Add(31 * time.Second)
+	dispatcher.flush(context.Background(), false)
+	dispatcher.inflight.Wait()
+	if len(all.notifications) != 1 {
+		t.Fatalf("Expected duplicate to be suppressed, got %d notifications", len(all.notifications))
+	}
+
+	// Resolution is delivered
+	resolved := firingAlert("a", alerting.SeverityWarning)
+	resolved.State = alerting.StateResolved
+	dispatcher.add(resolved)
+	clock = clock.Add(31 * time.Second)
+	dispatcher.flush(context.Background(), false)
+	dispatcher.inflight.Wait()
+	if len(all.notifications) != 2 || all.notifications[1].Status != "resolved" {
+		t.Fatalf("Expected resolved notification, got %+v", all.notifications)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+rules: []
+notifications:
+  group_wait: 10s
+  notifiers:
+    - name: ops
+      type: webhook
+      url: http://example.com/hook
+      min_severity: critical
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if cfg.GroupWait != 10*time.Second || cfg.RepeatInterval != 4*time.Hour {
+		t.Errorf("Unexpected intervals: %+v", cfg)
+	}
+	if cfg.Notifiers[0].Retry.Attempts != 5 {
+		t.Errorf("Expected default retry attempts, got %d", cfg.Notifiers[0].Retry.Attempts)
+	}
+
+	if _, err := ParseConfig([]byte("notifications:\n  notifiers:\n    - name: x\n      type: pager\n")); err == nil {
+		t.Error("Expected error for unknown notifier type")
+	}
+}
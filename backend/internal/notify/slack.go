@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+)
+
+// slackPayload is the body accepted by Slack-compatible incoming webhooks
+// (Slack, Mattermost, Rocket.Chat, ...)
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// slackNotifier posts rendered notifications to an incoming webhook
+type slackNotifier struct {
+	cfg    NotifierConfig
+	tmpl   *template.Template
+	client *http.Client
+}
+
+func newSlackNotifier(cfg NotifierConfig, tmpl *template.Template) *slackNotifier {
+	return &slackNotifier{
+		cfg:    cfg,
+		tmpl:   tmpl,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Name returns the notifier name
+func (n *slackNotifier) Name() string {
+	return n.cfg.Name
+}
+
+// Notify sends the notification
+func (n *slackNotifier) Notify(ctx context.Context, notification Notification) error {
+	text, err := render(n.tmpl, notification)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(slackPayload{Text: text})
+	if err != nil {
+		return fmt.Errorf("failed to encode slack payload: %w", err)
+	}
+
+	return withRetry(ctx, n.cfg.Retry, func(ctx context.Context) error {
+		return postJSON(ctx, n.client, n.cfg.URL, body, nil)
+	})
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// smtpNotifier emails notifications, upgrading to TLS when the server
+// supports STARTTLS
+type smtpNotifier struct {
+	cfg     NotifierConfig
+	tmpl    *template.Template
+	subject *template.Template
+}
+
+func newSMTPNotifier(cfg NotifierConfig, tmpl, subject *template.Template) *smtpNotifier {
+	return &smtpNotifier{
+		cfg:     cfg,
+		tmpl:    tmpl,
+		subject: subject,
+	}
+}
+
+// Name returns the notifier name
+func (n *smtpNotifier) Name() string {
+	return n.cfg.Name
+}
+
+// Notify sends the notification
+func (n *smtpNotifier) Notify(ctx context.Context, notification Notification) error {
+	body, err := render(n.tmpl, notification)
+	if err != nil {
+		return err
+	}
+	subject, err := render(n.subject, notification)
+	if err != nil {
+		return err
+	}
+
+	message := n.buildMessage(strings.TrimSpace(subject), body, notification.SentAt)
+
+	return withRetry(ctx, n.cfg.Retry, func(ctx context.Context) error {
+		return n.send(ctx, message)
+	})
+}
+
+func (n *smtpNotifier) buildMessage(subject, body string, date time.Time) []byte {
+	// Headers must not contain line breaks from templated content
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return msg.Bytes()
+}
+
+func (n *smtpNotifier) send(ctx context.Context, message []byte) error {
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+
+	dialer := net.Dialer{Timeout: n.cfg.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(n.cfg.Timeout))
+
+	client, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.cfg.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	if n.cfg.Username != "" {
+		auth := smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return &permanentError{err: fmt.Errorf("SMTP authentication failed: %w", err)}
+		}
+	}
+
+	if err := client.Mail(n.cfg.From); err != nil {
+		return fmt.Errorf("MAIL FROM failed: %w", err)
+	}
+	for _, rcpt := range n.cfg.To {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("RCPT TO %s failed: %w", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA failed: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish message: %w", err)
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"text/template"
+)
+
+const (
+	// SignatureHeader carries the hex HMAC-SHA256 of the timestamp, a dot
+	// and the request body
+	SignatureHeader = "X-KubeVision-Signature"
+
+	// TimestampHeader carries the Unix time the notification was sent
+	TimestampHeader = "X-KubeVision-Timestamp"
+)
+
+// webhookPayload is the JSON body POSTed by the webhook notifier
+type webhookPayload struct {
+	Notification
+	Text string `json:"text"`
+}
+
+// webhookNotifier POSTs notifications as JSON, signed with HMAC-SHA256
+type webhookNotifier struct {
+	cfg    NotifierConfig
+	tmpl   *template.Template
+	client *http.Client
+}
+
+func newWebhookNotifier(cfg NotifierConfig, tmpl *template.Template) *webhookNotifier {
+	return &webhookNotifier{
+		cfg:    cfg,
+		tmpl:   tmpl,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Name returns the notifier name
+func (n *webhookNotifier) Name() string {
+	return n.cfg.Name
+}
+
+// Notify sends the notification
+func (n *webhookNotifier) Notify(ctx context.Context, notification Notification) error {
+	text, err := render(n.tmpl, notification)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(webhookPayload{Notification: notification, Text: text})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(notification.SentAt.Unix(), 10)
+	headers := map[string]string{
+		TimestampHeader: timestamp,
+	}
+	if n.cfg.Secret != "" {
+		headers[SignatureHeader] = "sha256=" + Sign(n.cfg.Secret, timestamp, body)
+	}
+
+	return withRetry(ctx, n.cfg.Retry, func(ctx context.Context) error {
+		return postJSON(ctx, n.client, n.cfg.URL, body, headers)
+	})
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of timestamp + "." + body using
+// secret. Covering the timestamp lets receivers reject replayed deliveries.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// postJSON POSTs body, treating 4xx responses other than 429 as permanent
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err: fmt.Errorf("failed to create request: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "KubeVision")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{err: err}
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrUnauthorized is returned when a registry rejects the credentials
+var ErrUnauthorized = errors.New("registry rejected the credentials")
+
+// Challenge is a parsed WWW-Authenticate header
+type Challenge struct {
+	Scheme string
+	Params map[string]string
+}
+
+// ParseChallenge parses a WWW-Authenticate header such as
+// `Bearer realm="https://auth.example.com/token",service="registry"`
+func ParseChallenge(header string) Challenge {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	challenge := Challenge{Scheme: strings.ToLower(scheme), Params: make(map[string]string)}
+
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				challenge.Params[key] = value[1:]
+				break
+			}
+			challenge.Params[key] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(value, ",")
+			challenge.Params[key] = strings.TrimSpace(value)
+		}
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return challenge
+}
+
+// Client talks to registries over the distribution API, answering basic
+// and bearer token auth challenges with stored credentials
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient creates a registry client
+func NewClient(timeout time.Duration) *Client {
+	return &Client{httpClient: &http.Client{Timeout: timeout}}
+}
+
+// BaseURL returns the API base URL of a registry. Docker Hub's API lives on
+// a different host than its name.
+func BaseURL(cred Credential) string {
+	scheme := "https"
+	if cred.Insecure {
+		scheme = "http"
+	}
+	host := cred.Registry
+	if host == DockerHub {
+		host = "registry-1.docker.io"
+	}
+	return scheme + "://" + host
+}
+
+// Do sends a request to the registry, authenticating when challenged. scope
+// is the token scope to request, e.g. "repository:library/nginx:pull".
+func (c *Client) Do(ctx context.Context, cred Credential, method, path, scope string, header http.Header) (*http.Response, error) {
+	send := func(authorization string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, BaseURL(cred)+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		return c.httpClient.Do(req)
+	}
+
+	resp, err := send("")
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := ParseChallenge(resp.Header.Get("WWW-Authenticate"))
+	resp.Body.Close()
+
+	var authorization string
+	switch challenge.Scheme {
+	case "basic":
+		if cred.Username == "" {
+			return nil, ErrUnauthorized
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(cred.Username, cred.Password)
+		authorization = req.Header.Get("Authorization")
+	case "bearer":
+		token, err := c.token(ctx, cred, challenge, scope)
+		if err != nil {
+			return nil, err
+		}
+		authorization = "Bearer " + token
+	default:
+		return nil, fmt.Errorf("unsupported auth challenge %q", challenge.Scheme)
+	}
+
+	resp, err = send(authorization)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, ErrUnauthorized
+	}
+	return resp, nil
+}
+
+// token fetches a bearer token from the realm of a challenge
+func (c *Client) token(ctx context.Context, cred Credential, challenge Challenge, scope string) (string, error) {
+	realm, err := url.Parse(challenge.Params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid token realm %q", challenge.Params["realm"])
+	}
+	query := realm.Query()
+	if service := challenge.Params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	if scope == "" {
+		scope = challenge.Params["scope"]
+	}
+	if scope != "" {
+		query.Set("scope", scope)
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if cred.Username != "" {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch registry token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", ErrUnauthorized
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", errors.New("token response contains no token")
+}
+
+// CheckLogin validates credentials against the registry's /v2/ endpoint
+func (c *Client) CheckLogin(ctx context.Context, cred Credential) error {
+	resp, err := c.Do(ctx, cred, http.MethodGet, "/v2/", "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRegistry fakes a registry that only serves /v2/ with a bearer
+// token issued to user "ci" with password "pw", or with basic auth
+func newTestRegistry(t *testing.T, scheme string) (*httptest.Server, Credential) {
+	t.Helper()
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "ci" || pass != "pw" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("service") != "test-registry" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": "good-token"})
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		switch scheme {
+		case "bearer":
+			if r.Header.Get("Authorization") == "Bearer good-token" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test-registry"`)
+		case "basic":
+			if user, pass, ok := r.BasicAuth(); ok && user == "ci" && pass == "pw" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, Credential{Registry: strings.TrimPrefix(server.URL, "http://"), Insecure: true}
+}
+
+func TestParseChallenge(t *testing.T) {
+	challenge := ParseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull,push"`)
+	if challenge.Scheme != "bearer" {
+		t.Errorf("Expected bearer scheme, got %q", challenge.Scheme)
+	}
+	if challenge.Params["realm"] != "https://auth.docker.io/token" ||
+		challenge.Params["service"] != "registry.docker.io" ||
+		challenge.Params["scope"] != "repository:library/nginx:pull,push" {
+		t.Errorf("Unexpected params: %v", challenge.Params)
+	}
+
+	if basic := ParseChallenge(`Basic realm=registry`); basic.Scheme != "basic" || basic.Params["realm"] != "registry" {
+		t.Errorf("Unexpected basic challenge: %+v", basic)
+	}
+}
+
+func TestClient_CheckLogin(t *testing.T) {
+	client := NewClient(5 * time.Second)
+
+	for _, scheme := range []string{"bearer", "basic"} {
+		t.Run(scheme, func(t *testing.T) {
+			_, cred := newTestRegistry(t, scheme)
+
+			cred.Username, cred.Password = "ci", "pw"
+			if err := client.CheckLogin(context.Background(), cred); err != nil {
+				t.Errorf("Expected valid credentials, got %v", err)
+			}
+
+			cred.Password = "wrong"
+			if err := client.CheckLogin(context.Background(), cred); !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("Expected ErrUnauthorized, got %v", err)
+			}
+
+			cred.Username, cred.Password = "", ""
+			if err := client.CheckLogin(context.Background(), cred); !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("Expected anonymous login to fail, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package registry
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/reference"
+	registrytypes "github.com/docker/docker/api/types/registry"
+)
+
+// DockerHub is the key under which Docker Hub credentials are stored
+const DockerHub = "docker.io"
+
+var (
+	// ErrNotFound is returned for registries without stored credentials
+	ErrNotFound = errors.New("registry not found")
+	// ErrNoKey is returned when storing credentials without an encryption key
+	ErrNoKey = errors.New("REGISTRY_CREDENTIALS_KEY is not set")
+
+	// hostPattern matches registry hosts with an optional port
+	hostPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(:[0-9]{1,5})?$`)
+)
+
+// Credential is a login for one registry
+type Credential struct {
+	Registry  string    `json:"registry"`
+	Username  string    `json:"username"`
+	Password  string    `json:"-"`
+	Insecure  bool      `json:"insecure,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// storedCredential is the on-disk form of a credential. The password is
+// sealed with AES-GCM, bound to the registry host.
+type storedCredential struct {
+	Registry  string    `json:"registry"`
+	Username  string    `json:"username"`
+	Secret    string    `json:"secret"`
+	Insecure  bool      `json:"insecure,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// credentialsFile is the on-disk layout of the credentials file
+type credentialsFile struct {
+	Registries []storedCredential `json:"registries"`
+}
+
+// NormalizeHost returns the key under which a registry's credentials are
+// stored. Docker Hub's aliases all map to DockerHub.
+func NormalizeHost(host string) (string, error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	host = strings.TrimSuffix(host, "/")
+	host = strings.TrimSuffix(host, "/v1")
+	host = strings.TrimSuffix(host, "/v2")
+
+	switch host {
+	case "docker.io", "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return DockerHub, nil
+	}
+	if !hostPattern.MatchString(host) {
+		return "", fmt.Errorf("invalid registry host %q", host)
+	}
+	return host, nil
+}
+
+// HostForImage returns the registry host of an image reference
+func HostForImage(imageRef string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(imageRef)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", imageRef, err)
+	}
+	return NormalizeHost(reference.Domain(named))
+}
+
+// CredentialStore keeps registry credentials in a JSON file with the
+// passwords encrypted by a key derived from config. Without a key the store
+// is empty and read-only, so image operations fall back to anonymous access.
+type CredentialStore struct {
+	path string
+	aead cipher.AEAD
+
+	mu          sync.RWMutex
+	credentials map[string]Credential
+}
+
+// OpenCredentialStore loads the credentials file at path. key may be empty
+// to disable stored credentials.
+func OpenCredentialStore(path, key string) (*CredentialStore, error) {
+	s := &CredentialStore{path: path, credentials: make(map[string]Credential)}
+	if key == "" {
+		return s, nil
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	if s.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return s, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read registry credentials: %w", err)
+	}
+
+	var file credentialsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse registry credentials: %w", err)
+	}
+	for _, stored := range file.Registries {
+		password, err := s.open(stored.Registry, stored.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt credentials for %s (wrong REGISTRY_CREDENTIALS_KEY?): %w", stored.Registry, err)
+		}
+		s.credentials[stored.Registry] = Credential{
+			Registry:  stored.Registry,
+			Username:  stored.Username,
+			Password:  password,
+			Insecure:  stored.Insecure,
+			UpdatedAt: stored.UpdatedAt,
+		}
+	}
+	return s, nil
+}
+
+// Enabled reports whether credentials can be stored
+func (s *CredentialStore) Enabled() bool {
+	return s.aead != nil
+}
+
+// seal encrypts a password for a registry
+func (s *CredentialStore) seal(registry, password string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(password), []byte(registry))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a sealed password
+func (s *CredentialStore) open(registry, secret string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < s.aead.NonceSize() {
+		return "", errors.New("secret too short")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(registry))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// save writes the credentials file atomically. s.mu must be held.
+func (s *CredentialStore) save(credentials map[string]Credential) error {
+	var file credentialsFile
+	for _, cred := range credentials {
+		secret, err := s.seal(cred.Registry, cred.Password)
+		if err != nil {
+			return err
+		}
+		file.Registries = append(file.Registries, storedCredential{
+			Registry:  cred.Registry,
+			Username:  cred.Username,
+			Secret:    secret,
+			Insecure:  cred.Insecure,
+			UpdatedAt: cred.UpdatedAt,
+		})
+	}
+	sort.Slice(file.Registries, func(i, j int) bool { return file.Registries[i].Registry < file.Registries[j].Registry })
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write registry credentials: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace registry credentials: %w", err)
+	}
+	return nil
+}
+
+// List returns the stored credentials without passwords, sorted by registry
+func (s *CredentialStore) List() []Credential {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]Credential, 0, len(s.credentials))
+	for _, cred := range s.credentials {
+		cred.Password = ""
+		list = append(list, cred)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Registry < list[j].Registry })
+	return list
+}
+
+// Get returns the credential of a registry
+func (s *CredentialStore) Get(registry string) (Credential, error) {
+	host, err := NormalizeHost(registry)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cred, ok := s.credentials[host]
+	if !ok {
+		return Credential{}, ErrNotFound
+	}
+	return cred, nil
+}
+
+// Put creates or replaces the credential of a registry and reports whether
+// it was created
+func (s *CredentialStore) Put(cred Credential) (bool, error) {
+	if !s.Enabled() {
+		return false, ErrNoKey
+	}
+	host, err := NormalizeHost(cred.Registry)
+	if err != nil {
+		return false, err
+	}
+	if cred.Username == "" || cred.Password == "" {
+		return false, errors.New("username and password are required")
+	}
+	cred.Registry = host
+	cred.UpdatedAt = time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := make(map[string]Credential, len(s.credentials)+1)
+	for k, v := range s.credentials {
+		updated[k] = v
+	}
+	_, existed := updated[host]
+	updated[host] = cred
+	if err := s.save(updated); err != nil {
+		return false, err
+	}
+	s.credentials = updated
+	return !existed, nil
+}
+
+// Delete removes the credential of a registry
+func (s *CredentialStore) Delete(registry string) error {
+	host, err := NormalizeHost(registry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.credentials[host]; !ok {
+		return ErrNotFound
+	}
+	updated := make(map[string]Credential, len(s.credentials))
+	for k, v := range s.credentials {
+		if k != host {
+			updated[k] = v
+		}
+	}
+	if err := s.save(updated); err != nil {
+		return err
+	}
+	s.credentials = updated
+	return nil
+}
+
+// ForImage returns the stored credential for an image's registry
+func (s *CredentialStore) ForImage(imageRef string) (Credential, bool) {
+	host, err := HostForImage(imageRef)
+	if err != nil {
+		return Credential{}, false
+	}
+	cred, err := s.Get(host)
+	return cred, err == nil
+}
+
+// RegistryAuth returns the encoded X-Registry-Auth value Docker expects for
+// pulling imageRef, or "" when no credential is stored for its registry
+func (s *CredentialStore) RegistryAuth(imageRef string) (string, error) {
+	cred, ok := s.ForImage(imageRef)
+	if !ok {
+		return "", nil
+	}
+	serverAddress := cred.Registry
+	if serverAddress == DockerHub {
+		serverAddress = "https://index.docker.io/v1/"
+	}
+	return registrytypes.EncodeAuthConfig(registrytypes.AuthConfig{
+		Username:      cred.Username,
+		Password:      cred.Password,
+		ServerAddress: serverAddress,
+	})
+}
@@ -0,0 +1,151 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	registrytypes "github.com/docker/docker/api/types/registry"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"registry.example.com", "registry.example.com", false},
+		{"https://Registry.Example.com:5000/", "registry.example.com:5000", false},
+		{"index.docker.io", DockerHub, false},
+		{"https://index.docker.io/v1/", DockerHub, false},
+		{"registry.example.com/path", "", true},
+		{"", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			host, err := NormalizeHost(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("NormalizeHost(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			}
+			if host != tc.expected {
+				t.Errorf("NormalizeHost(%q) = %q, want %q", tc.input, host, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCredentialStore_EncryptedAtRest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registries.json")
+	store, err := OpenCredentialStore(path, "test-key")
+	if err != nil {
+		t.Fatalf("OpenCredentialStore failed: %v", err)
+	}
+
+	created, err := store.Put(Credential{Registry: "https://registry.example.com", Username: "ci", Password: "s3cret-password"})
+	if err != nil || !created {
+		t.Fatalf("Put failed: created=%v err=%v", created, err)
+	}
+	if created, _ := store.Put(Credential{Registry: "registry.example.com", Username: "ci", Password: "rotated-password"}); created {
+		t.Errorf("Expected second put to update")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if strings.Contains(string(data), "password") {
+		t.Errorf("Credentials file contains a plaintext password: %s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	reopened, err := OpenCredentialStore(path, "test-key")
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	cred, err := reopened.Get("registry.example.com")
+	if err != nil || cred.Password != "rotated-password" {
+		t.Errorf("Expected decrypted password after reopen, got %+v, %v", cred, err)
+	}
+	if listed := reopened.List(); len(listed) != 1 || listed[0].Password != "" {
+		t.Errorf("Expected one listed credential without password, got %+v", listed)
+	}
+
+	if _, err := OpenCredentialStore(path, "wrong-key"); err == nil {
+		t.Errorf("Expected wrong key to fail")
+	}
+
+	// Ciphertexts are bound to their registry host
+	var file credentialsFile
+	_ = json.Unmarshal(data, &file)
+	file.Registries[0].Registry = "evil.example.com"
+	tampered, _ := json.Marshal(file)
+	_ = os.WriteFile(path, tampered, 0o600)
+	if _, err := OpenCredentialStore(path, "test-key"); err == nil {
+		t.Errorf("Expected moved ciphertext to fail")
+	}
+}
+
+func TestCredentialStore_WithoutKey(t *testing.T) {
+	store, err := OpenCredentialStore(filepath.Join(t.TempDir(), "registries.json"), "")
+	if err != nil {
+		t.Fatalf("OpenCredentialStore failed: %v", err)
+	}
+	if store.Enabled() {
+		t.Errorf("Expected store without key to be disabled")
+	}
+	if _, err := store.Put(Credential{Registry: "registry.example.com", Username: "ci", Password: "pw"}); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Expected ErrNoKey, got %v", err)
+	}
+	if auth, err := store.RegistryAuth("registry.example.com/app:1"); auth != "" || err != nil {
+		t.Errorf("Expected anonymous auth, got %q, %v", auth, err)
+	}
+}
+
+func TestCredentialStore_RegistryAuth(t *testing.T) {
+	store, _ := OpenCredentialStore(filepath.Join(t.TempDir(), "registries.json"), "test-key")
+	_, _ = store.Put(Credential{Registry: "registry.example.com:5000", Username: "ci", Password: "pw"})
+	_, _ = store.Put(Credential{Registry: "docker.io", Username: "hub", Password: "pw"})
+
+	testCases := []struct {
+		image    string
+		username string
+		server   string
+	}{
+		{"registry.example.com:5000/team/app:1.2", "ci", "registry.example.com:5000"},
+		{"nginx:latest", "hub", "https://index.docker.io/v1/"},
+		{"library/postgres", "hub", "https://index.docker.io/v1/"},
+		{"ghcr.io/owner/tool", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			encoded, err := store.RegistryAuth(tc.image)
+			if err != nil {
+				t.Fatalf("RegistryAuth failed: %v", err)
+			}
+			if tc.username == "" {
+				if encoded != "" {
+					t.Errorf("Expected no auth for %s", tc.image)
+				}
+				return
+			}
+
+			data, err := base64.URLEncoding.DecodeString(encoded)
+			if err != nil {
+				t.Fatalf("Invalid encoding: %v", err)
+			}
+			var config registrytypes.AuthConfig
+			_ = json.Unmarshal(data, &config)
+			if config.Username != tc.username || config.ServerAddress != tc.server {
+				t.Errorf("Unexpected auth config %+v", config)
+			}
+		})
+	}
+}
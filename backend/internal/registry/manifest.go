@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// manifestMediaTypes are accepted when resolving a tag, so multi-platform
+// images resolve to the index digest Docker records in RepoDigests
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+var (
+	// ErrManifestNotFound is returned for unknown repositories or tags
+	ErrManifestNotFound = errors.New("manifest not found")
+	// ErrRateLimited is returned when the registry throttles requests
+	ErrRateLimited = errors.New("registry rate limit exceeded")
+)
+
+// ManifestDigest resolves a tag of a repository (e.g. "library/postgres",
+// "16") to its manifest digest. It uses a HEAD request, which registries
+// such as Docker Hub do not count against pull limits, and falls back to
+// hashing the manifest when the digest header is missing.
+func (c *Client) ManifestDigest(ctx context.Context, cred Credential, repository, tag string) (string, error) {
+	path := "/v2/" + repository + "/manifests/" + tag
+	scope := "repository:" + repository + ":pull"
+	header := http.Header{"Accept": {strings.Join(manifestMediaTypes, ", ")}}
+
+	resp, err := c.Do(ctx, cred, http.MethodHead, path, scope, header)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if err := manifestStatus(resp); err != nil {
+		return "", err
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	resp, err = c.Do(ctx, cred, http.MethodGet, path, scope, header)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := manifestStatus(resp); err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.LimitReader(resp.Body, 4<<20)); err != nil {
+		return "", fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// manifestStatus maps the status of a manifest request to an error
+func manifestStatus(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrManifestNotFound
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusForbidden:
+		return ErrUnauthorized
+	default:
+		return fmt.Errorf("registry returned %s", resp.Status)
+	}
+}
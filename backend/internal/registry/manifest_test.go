@@ -0,0 +1,53 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_ManifestDigest(t *testing.T) {
+	const manifest = `{"schemaVersion":2}`
+	sum := sha256.Sum256([]byte(manifest))
+	hashed := "sha256:" + hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json") {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/team/api/manifests/1":
+			w.Header().Set("Docker-Content-Digest", "sha256:abc")
+		case "/v2/team/api/manifests/nodigest":
+			_, _ = w.Write([]byte(manifest))
+		case "/v2/team/api/manifests/throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(5 * time.Second)
+	cred := Credential{Registry: strings.TrimPrefix(server.URL, "http://"), Insecure: true}
+
+	if digest, err := client.ManifestDigest(context.Background(), cred, "team/api", "1"); err != nil || digest != "sha256:abc" {
+		t.Errorf("Expected digest header, got %q, %v", digest, err)
+	}
+	if digest, err := client.ManifestDigest(context.Background(), cred, "team/api", "nodigest"); err != nil || digest != hashed {
+		t.Errorf("Expected hashed manifest %s, got %q, %v", hashed, digest, err)
+	}
+	if _, err := client.ManifestDigest(context.Background(), cred, "team/api", "missing"); !errors.Is(err, ErrManifestNotFound) {
+		t.Errorf("Expected ErrManifestNotFound, got %v", err)
+	}
+	if _, err := client.ManifestDigest(context.Background(), cred, "team/api", "throttled"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/kubevision/kubevision/internal/registry"
+)
+
+// Status is the update state of a tagged image
+type Status string
+
+const (
+	StatusUpToDate Status = "up_to_date"
+	StatusOutdated Status = "outdated"
+	// StatusUnknown is used for images without a registry digest, such as
+	// locally built ones
+	StatusUnknown Status = "unknown"
+	StatusError   Status = "error"
+)
+
+// ContainerRef identifies a container using an image
+type ContainerRef struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// ImageUpdate is the result of checking one tag of a local image
+type ImageUpdate struct {
+	Image        string         `json:"image"`
+	ImageID      string         `json:"image_id"`
+	Registry     string         `json:"registry"`
+	LocalDigest  string         `json:"local_digest,omitempty"`
+	RemoteDigest string         `json:"remote_digest,omitempty"`
+	Status       Status         `json:"status"`
+	Error        string         `json:"error,omitempty"`
+	Containers   []ContainerRef `json:"containers"`
+	CheckedAt    time.Time      `json:"checked_at"`
+}
+
+// Report holds the latest results for one host
+type Report struct {
+	Host      string        `json:"host"`
+	CheckedAt *time.Time    `json:"checked_at,omitempty"`
+	Outdated  int           `json:"outdated"`
+	Images    []ImageUpdate `json:"images"`
+}
+
+// dockerClient is the part of the Docker API the checker needs
+type dockerClient interface {
+	ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error)
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+}
+
+// Checker periodically compares the digests of local tagged images with
+// the digests their registries currently serve for the same tags
+type Checker struct {
+	resolver interface {
+		ManifestDigest(ctx context.Context, cred registry.Credential, repository, tag string) (string, error)
+	}
+	credentials interface {
+		ForImage(imageRef string) (registry.Credential, bool)
+	}
+	interval  time.Duration
+	rateLimit rate.Limit
+	logger    *zap.Logger
+
+	hosts    map[string]dockerClient
+	limiters map[string]*rate.Limiter
+
+	mu      sync.RWMutex
+	reports map[string]Report
+}
+
+// NewChecker creates a checker running every interval. Each registry is
+// sent at most requestsPerMinute manifest requests.
+func NewChecker(resolver interface {
+	ManifestDigest(ctx context.Context, cred registry.Credential, repository, tag string) (string, error)
+}, credentials interface {
+	ForImage(imageRef string) (registry.Credential, bool)
+}, interval time.Duration, requestsPerMinute int, logger *zap.Logger) *Checker {
+	limit := rate.Inf
+	if requestsPerMinute > 0 {
+		limit = rate.Every(time.Minute / time.Duration(requestsPerMinute))
+	}
+	return &Checker{
+		resolver:    resolver,
+		credentials: credentials,
+		interval:    interval,
+		rateLimit:   limit,
+		logger:      logger,
+		hosts:       make(map[string]dockerClient),
+		limiters:    make(map[string]*rate.Limiter),
+		reports:     make(map[string]Report),
+	}
+}
+
+// AddHost registers a Docker host to check. It must be called before Run.
+func (c *Checker) AddHost(name string, client dockerClient) {
+	c.hosts[name] = client
+}
+
+// Run checks every host immediately and then every interval until ctx is
+// cancelled
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check runs one pass over every host. A tag present on several hosts is
+// resolved once per pass.
+func (c *Checker) Check(ctx context.Context) {
+	start := time.Now()
+	resolved := make(map[string]resolution)
+	throttled := make(map[string]bool)
+
+	names := make([]string, 0, len(c.hosts))
+	for name := range c.hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		images, err := c.checkHost(ctx, c.hosts[name], resolved, throttled)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			c.logger.Warn("Image update check failed", zap.String("host", name), zap.Error(err))
+			continue
+		}
+
+		now := time.Now()
+		report := Report{Host: name, CheckedAt: &now, Images: images}
+		for _, update := range images {
+			if update.Status == StatusOutdated {
+				report.Outdated++
+			}
+		}
+		c.mu.Lock()
+		c.reports[name] = report
+		c.mu.Unlock()
+	}
+
+	c.logger.Info("Image update check finished",
+		zap.Int("tags", len(resolved)),
+		zap.Duration("duration", time.Since(start)))
+}
+
+// resolution is a resolved remote digest or the error resolving it
+type resolution struct {
+	digest string
+	err    error
+}
+
+// checkHost checks every tagged image of a host
+func (c *Checker) checkHost(ctx context.Context, client dockerClient, resolved map[string]resolution, throttled map[string]bool) ([]ImageUpdate, error) {
+	listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	images, err := client.ImageList(listCtx, image.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	containers, err := client.ContainerList(listCtx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	usedBy := make(map[string][]ContainerRef)
+	for _, ctr := range containers {
+		ref := ContainerRef{ID: ctr.ID, State: string(ctr.State)}
+		if len(ctr.Names) > 0 {
+			ref.Name = strings.TrimPrefix(ctr.Names[0], "/")
+		}
+		usedBy[ctr.ImageID] = append(usedBy[ctr.ImageID], ref)
+	}
+
+	updates := make([]ImageUpdate, 0, len(images))
+	for _, img := range images {
+		for _, tag := range img.RepoTags {
+			named, err := reference.ParseNormalizedNamed(tag)
+			if err != nil {
+				continue // "<none>:<none>"
+			}
+			tagged, ok := named.(reference.NamedTagged)
+			if !ok {
+				continue
+			}
+			host, err := registry.NormalizeHost(reference.Domain(named))
+			if err != nil {
+				continue
+			}
+
+			update := ImageUpdate{
+				Image:       tagged.String(),
+				ImageID:     img.ID,
+				Registry:    host,
+				LocalDigest: localDigest(named, img.RepoDigests),
+				Containers:  usedBy[img.ID],
+				CheckedAt:   time.Now(),
+			}
+			if update.Containers == nil {
+				update.Containers = []ContainerRef{}
+			}
+			if update.LocalDigest == "" {
+				update.Status = StatusUnknown
+				updates = append(updates, update)
+				continue
+			}
+
+			result, ok := resolved[update.Image]
+			if !ok {
+				if throttled[host] {
+					result.err = registry.ErrRateLimited
+				} else {
+					result = c.resolve(ctx, host, tagged)
+					if ctx.Err() != nil {
+						return nil, ctx.Err()
+					}
+					if errors.Is(result.err, registry.ErrRateLimited) {
+						throttled[host] = true
+					}
+				}
+				resolved[update.Image] = result
+			}
+
+			switch {
+			case result.err != nil:
+				update.Status = StatusError
+				update.Error = result.err.Error()
+			case result.digest == update.LocalDigest:
+				update.Status = StatusUpToDate
+			default:
+				update.Status = StatusOutdated
+			}
+			update.RemoteDigest = result.digest
+			updates = append(updates, update)
+		}
+	}
+
+	sort.Slice(updates, func(i, j int) bool { return updates[i].Image < updates[j].Image })
+	return updates, nil
+}
+
+// resolve fetches the remote digest of a tag, waiting for the registry's
+// rate limit
+func (c *Checker) resolve(ctx context.Context, host string, tagged reference.NamedTagged) resolution {
+	limiter, ok := c.limiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(c.rateLimit, 1)
+		c.limiters[host] = limiter
+	}
+	if err := limiter.Wait(ctx); err != nil {
+		return resolution{err: err}
+	}
+
+	cred, ok := c.credentials.ForImage(tagged.String())
+	if !ok {
+		cred = registry.Credential{Registry: host}
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	digest, err := c.resolver.ManifestDigest(reqCtx, cred, reference.Path(tagged), tagged.Tag())
+	if err != nil {
+		c.logger.Debug("Failed to resolve remote digest",
+			zap.String("image", tagged.String()),
+			zap.Error(err))
+	}
+	return resolution{digest: digest, err: err}
+}
+
+// localDigest returns the digest Docker recorded when the image was pulled
+// from named's repository
+func localDigest(named reference.Named, repoDigests []string) string {
+	for _, entry := range repoDigests {
+		canonical, err := reference.ParseNormalizedNamed(entry)
+		if err != nil {
+			continue
+		}
+		digested, ok := canonical.(reference.Canonical)
+		if ok && canonical.Name() == named.Name() {
+			return digested.Digest().String()
+		}
+	}
+	return ""
+}
+
+// Report returns the latest results for a host. Before the first check it
+// holds no images.
+func (c *Checker) Report(host string) Report {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	report, ok := c.reports[host]
+	if !ok {
+		return Report{Host: host, Images: []ImageUpdate{}}
+	}
+	return report
+}
@@ -0,0 +1,155 @@
+package updates
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/registry"
+)
+
+const (
+	oldDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	newDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// fakeDocker serves a fixed image and container list
+type fakeDocker struct {
+	images     []image.Summary
+	containers []container.Summary
+}
+
+func (f fakeDocker) ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error) {
+	return f.images, nil
+}
+
+func (f fakeDocker) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	return f.containers, nil
+}
+
+// fakeResolver returns digests by "repository:tag" and records requests
+type fakeResolver struct {
+	digests  map[string]string
+	requests []string
+	creds    []registry.Credential
+}
+
+func (f *fakeResolver) ManifestDigest(ctx context.Context, cred registry.Credential, repository, tag string) (string, error) {
+	key := repository + ":" + tag
+	f.requests = append(f.requests, key)
+	f.creds = append(f.creds, cred)
+	digest, ok := f.digests[key]
+	if !ok {
+		return "", registry.ErrManifestNotFound
+	}
+	return digest, nil
+}
+
+// fakeCredentials has a login for registry.example.com only
+type fakeCredentials struct{}
+
+func (fakeCredentials) ForImage(imageRef string) (registry.Credential, bool) {
+	cred := registry.Credential{Registry: "registry.example.com", Username: "ci", Password: "pw"}
+	host, _ := registry.HostForImage(imageRef)
+	return cred, host == cred.Registry
+}
+
+func TestChecker_Check(t *testing.T) {
+	resolver := &fakeResolver{digests: map[string]string{
+		"library/postgres:16": newDigest,
+		"library/nginx:1.27":  oldDigest,
+		"team/api:2":          oldDigest,
+	}}
+	checker := NewChecker(resolver, fakeCredentials{}, time.Hour, 0, zap.NewNop())
+
+	docker := fakeDocker{
+		images: []image.Summary{
+			{ID: "sha256:pg", RepoTags: []string{"postgres:16"}, RepoDigests: []string{"postgres@" + oldDigest}},
+			{ID: "sha256:nginx", RepoTags: []string{"nginx:1.27"}, RepoDigests: []string{"nginx@" + oldDigest}},
+			{ID: "sha256:api", RepoTags: []string{"registry.example.com/team/api:2"}, RepoDigests: []string{"registry.example.com/team/api@" + oldDigest}},
+			{ID: "sha256:local", RepoTags: []string{"myapp:dev"}},
+			{ID: "sha256:dangling", RepoTags: []string{"<none>:<none>"}},
+		},
+		containers: []container.Summary{
+			{ID: "c1", Names: []string{"/db"}, ImageID: "sha256:pg", State: "running"},
+			{ID: "c2", Names: []string{"/web"}, ImageID: "sha256:nginx", State: "exited"},
+		},
+	}
+	checker.AddHost("local", docker)
+	checker.AddHost("remote", docker)
+	checker.Check(context.Background())
+
+	report := checker.Report("local")
+	if report.CheckedAt == nil || len(report.Images) != 4 {
+		t.Fatalf("Expected 4 checked tags, got %+v", report)
+	}
+	if report.Outdated != 1 {
+		t.Errorf("Expected one outdated image, got %d", report.Outdated)
+	}
+
+	byImage := make(map[string]ImageUpdate)
+	for _, update := range report.Images {
+		byImage[update.Image] = update
+	}
+
+	postgres := byImage["docker.io/library/postgres:16"]
+	if postgres.Status != StatusOutdated || postgres.RemoteDigest != newDigest || postgres.LocalDigest != oldDigest {
+		t.Errorf("Expected postgres to be outdated, got %+v", postgres)
+	}
+	if len(postgres.Containers) != 1 || postgres.Containers[0].Name != "db" {
+		t.Errorf("Expected postgres to be used by db, got %+v", postgres.Containers)
+	}
+	if nginx := byImage["docker.io/library/nginx:1.27"]; nginx.Status != StatusUpToDate {
+		t.Errorf("Expected nginx to be up to date, got %+v", nginx)
+	}
+	if api := byImage["registry.example.com/team/api:2"]; api.Status != StatusUpToDate || api.Registry != "registry.example.com" {
+		t.Errorf("Expected private image to be up to date, got %+v", api)
+	}
+	if local := byImage["docker.io/library/myapp:dev"]; local.Status != StatusUnknown {
+		t.Errorf("Expected image without digest to be unknown, got %+v", local)
+	}
+
+	// Tags shared by both hosts are resolved once per pass
+	if len(resolver.requests) != 3 {
+		t.Errorf("Expected 3 registry requests, got %v", resolver.requests)
+	}
+	for i, key := range resolver.requests {
+		private := key == "team/api:2"
+		if (resolver.creds[i].Username != "") != private {
+			t.Errorf("Unexpected credentials for %s: %+v", key, resolver.creds[i])
+		}
+	}
+	if remote := checker.Report("remote"); remote.Outdated != 1 {
+		t.Errorf("Expected remote host report, got %+v", remote)
+	}
+	if empty := checker.Report("unknown"); empty.CheckedAt != nil || len(empty.Images) != 0 {
+		t.Errorf("Expected empty report for unchecked host, got %+v", empty)
+	}
+}
+
+func TestChecker_RateLimit(t *testing.T) {
+	resolver := &fakeResolver{digests: map[string]string{}}
+	// 600 requests per minute spaces requests 100ms apart
+	checker := NewChecker(resolver, fakeCredentials{}, time.Hour, 600, zap.NewNop())
+	checker.AddHost("local", fakeDocker{images: []image.Summary{
+		{ID: "a", RepoTags: []string{"a:1"}, RepoDigests: []string{"a@" + oldDigest}},
+		{ID: "b", RepoTags: []string{"b:1"}, RepoDigests: []string{"b@" + oldDigest}},
+		{ID: "c", RepoTags: []string{"c:1"}, RepoDigests: []string{"c@" + oldDigest}},
+	}})
+
+	start := time.Now()
+	checker.Check(context.Background())
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected requests to one registry to be spaced out, took %v", elapsed)
+	}
+
+	for _, update := range checker.Report("local").Images {
+		if update.Status != StatusError || update.Error == "" {
+			t.Errorf("Expected unresolved tags to report an error, got %+v", update)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LabelRequirement is a single term of a label selector
+type LabelRequirement struct {
+	Key      string
+	Operator string // "=", "!=", "exists" or "!exists"
+	Value    string
+}
+
+// LabelSelector matches a set of labels against all of its requirements.
+// The empty selector matches everything.
+type LabelSelector []LabelRequirement
+
+// ParseLabelSelector parses a comma-separated selector such as
+// "com.docker.compose.project=payments,tier!=db,monitored,!ignored"
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	var selector LabelSelector
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var req LabelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			parts := strings.SplitN(term, "!=", 2)
+			req = LabelRequirement{Key: strings.TrimSpace(parts[0]), Operator: "!=", Value: strings.TrimSpace(parts[1])}
+		case strings.Contains(term, "="):
+			parts := strings.SplitN(term, "=", 2)
+			req = LabelRequirement{Key: strings.TrimSpace(parts[0]), Operator: "=", Value: strings.TrimSpace(parts[1])}
+		case strings.HasPrefix(term, "!"):
+			req = LabelRequirement{Key: strings.TrimSpace(term[1:]), Operator: "!exists"}
+		default:
+			req = LabelRequirement{Key: term, Operator: "exists"}
+		}
+
+		if req.Key == "" {
+			return nil, fmt.Errorf("invalid selector term %q", term)
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// SelectorFromMap builds an equality selector from a label map
+func SelectorFromMap(labels map[string]string) LabelSelector {
+	selector := make(LabelSelector, 0, len(labels))
+	for key, value := range labels {
+		selector = append(selector, LabelRequirement{Key: key, Operator: "=", Value: value})
+	}
+	return selector
+}
+
+// Matches reports whether labels satisfy every requirement of the selector
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		value, ok := labels[req.Key]
+		switch req.Operator {
+		case "=":
+			if !ok || value != req.Value {
+				return false
+			}
+		case "!=":
+			if ok && value == req.Value {
+				return false
+			}
+		case "exists":
+			if !ok {
+				return false
+			}
+		case "!exists":
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Empty reports whether the selector has no requirements
+func (s LabelSelector) Empty() bool {
+	return len(s) == 0
+}
+
+// String renders the selector in the syntax accepted by ParseLabelSelector
+func (s LabelSelector) String() string {
+	terms := make([]string, 0, len(s))
+	for _, req := range s {
+		switch req.Operator {
+		case "exists":
+			terms = append(terms, req.Key)
+		case "!exists":
+			terms = append(terms, "!"+req.Key)
+		default:
+			terms = append(terms, req.Key+req.Operator+req.Value)
+		}
+	}
+	return strings.Join(terms, ",")
+}
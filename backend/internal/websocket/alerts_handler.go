@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/alerting"
+	"github.com/kubevision/kubevision/internal/metrics"
+)
+
+// AlertMessage is a frame sent on the alerts WebSocket. The first frame is a
+// snapshot of all active alerts; subsequent frames carry single transitions.
+type AlertMessage struct {
+	Type   string           `json:"type"`
+	Alerts []alerting.Alert `json:"alerts,omitempty"`
+	Alert  *alerting.Alert  `json:"alert,omitempty"`
+}
+
+// AlertsHandler handles WebSocket connections for alert state transitions
+func AlertsHandler(engine interface {
+	Alerts(state alerting.State) []alerting.Alert
+	Subscribe() (<-chan alerting.Alert, func())
+}, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Upgrade connection to WebSocket
+		upgrader := GetUpgrader()
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			logger.Error("Failed to upgrade connection", zap.Error(err))
+			return
+		}
+		defer conn.Close()
+
+		metrics.IncrementWebSocketConnections("alerts")
+		defer metrics.DecrementWebSocketConnections("alerts")
+
+		// Set connection parameters
+		_ = conn.SetReadDeadline(time.Now().Add(PongWait))
+		conn.SetPongHandler(func(string) error {
+			_ = conn.SetReadDeadline(time.Now().Add(PongWait))
+			return nil
+		})
+
+		// Create context for this connection
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+
+		// Subscribe before taking the snapshot so no transition is missed
+		transitions, unsubscribe := engine.Subscribe()
+		defer unsubscribe()
+
+		_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
+		if err := conn.WriteJSON(AlertMessage{Type: "snapshot", Alerts: engine.Alerts("")}); err != nil {
+			logger.Error("Failed to write alerts snapshot", zap.Error(err))
+			return
+		}
+
+		// Read loop keeps pong handling alive and detects client disconnects
+		go func() {
+			defer cancel()
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		pingTicker := time.NewTicker(PingPeriod)
+		defer pingTicker.Stop()
+
+		// Main loop: send transitions and pings from a single writer
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-pingTicker.C:
+				_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			case alert, ok := <-transitions:
+				if !ok {
+					return
+				}
+				_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
+				if err := conn.WriteJSON(AlertMessage{Type: "transition", Alert: &alert}); err != nil {
+					logger.Error("Failed to write alert", zap.Error(err))
+					return
+				}
+			}
+		}
+	}
+}
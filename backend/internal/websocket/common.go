@@ -4,8 +4,12 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 	"github.com/gorilla/websocket"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/middleware"
 )
 
 const (
@@ -20,6 +24,10 @@ const (
 
 	// MaxMessageSize is the maximum message size allowed from peer
 	MaxMessageSize = 512
+
+	// Subprotocol is the subprotocol negotiated with clients that pass their
+	// token as a "bearer.<token>" subprotocol
+	Subprotocol = "kubevision"
 )
 
 // GetUpgrader returns a WebSocket upgrader with origin validation
@@ -32,6 +40,7 @@ func GetUpgrader() websocket.Upgrader {
 	return websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
+		Subprotocols:    []string{Subprotocol},
 		CheckOrigin: func(r *http.Request) bool {
 			origin := r.Header.Get("Origin")
 			// If no origin header (same-origin request), allow it
@@ -57,3 +66,17 @@ func GetUpgrader() websocket.Upgrader {
 	}
 }
 
+// readableFilter returns a check of container labels against the principal's
+// containers:read selector, or nil if the principal may read every container
+func readableFilter(c *gin.Context) func(labels map[string]string) bool {
+	principal := middleware.GetPrincipal(c)
+	if principal == nil {
+		return nil
+	}
+	if _, restricted := principal.Restricted(auth.PermContainersRead); !restricted {
+		return nil
+	}
+	return func(labels map[string]string) bool {
+		return principal.CanOn(auth.PermContainersRead, labels)
+	}
+}
@@ -0,0 +1,126 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kubevision/kubevision/internal/logparse"
+)
+
+const (
+	// logHeaderSize is the size of the header Docker prefixes each frame of
+	// non-TTY output with: [STREAM_TYPE(1)][RESERVED(3)][SIZE(4)]
+	logHeaderSize = 8
+
+	// maxLogFrameSize bounds a frame's payload; Docker splits lines at 16 KiB
+	maxLogFrameSize = 1 << 20
+)
+
+// Docker's stream type bytes
+const (
+	streamTypeStdin  = 0
+	streamTypeStdout = 1
+	streamTypeStderr = 2
+	streamTypeSystem = 3
+)
+
+// errInvalidLogHeader is returned for output that is not multiplexed
+var errInvalidLogHeader = errors.New("invalid log frame header")
+
+// logLine is a line of container output
+type logLine struct {
+	Stream    string
+	Timestamp time.Time
+	Text      []byte
+}
+
+// logDemuxer splits Docker log output into lines tagged with their stream.
+// Without a TTY the output is multiplexed into frames that, like lines, can
+// be split across reads; frames are buffered here until complete and lines
+// by a logparse.LineSplitter per stream. With a TTY the output is raw and
+// all of it is stdout.
+type logDemuxer struct {
+	tty bool
+	// timestamps strips the RFC3339 timestamp Docker prefixes lines with
+	timestamps bool
+
+	frame     []byte
+	splitters [streamTypeStderr + 1]*logparse.LineSplitter
+	lines     []logLine
+}
+
+// write adds output and returns every line it completes
+func (d *logDemuxer) write(data []byte) ([]logLine, error) {
+	d.lines = nil
+	if d.tty {
+		_, _ = d.splitter(streamTypeStdout).Write(data)
+		return d.lines, nil
+	}
+
+	d.frame = append(d.frame, data...)
+	consumed := 0
+	for len(d.frame)-consumed >= logHeaderSize {
+		header := d.frame[consumed : consumed+logHeaderSize]
+		stream := header[0]
+		size := int(binary.BigEndian.Uint32(header[4:]))
+		if stream > streamTypeSystem || header[1] != 0 || header[2] != 0 || header[3] != 0 || size > maxLogFrameSize {
+			return d.lines, errInvalidLogHeader
+		}
+		end := consumed + logHeaderSize + size
+		if len(d.frame) < end {
+			break
+		}
+
+		payload := d.frame[consumed+logHeaderSize : end]
+		switch stream {
+		case streamTypeSystem:
+			return d.lines, fmt.Errorf("log stream error: %s", bytes.TrimSpace(payload))
+		case streamTypeStdin:
+			// Output of an attached stdin is reported as stdout
+			stream = streamTypeStdout
+		}
+		_, _ = d.splitter(stream).Write(payload)
+		consumed = end
+	}
+	d.frame = append(d.frame[:0], d.frame[consumed:]...)
+	return d.lines, nil
+}
+
+// flush returns the output left without a final newline
+func (d *logDemuxer) flush() []logLine {
+	d.lines = nil
+	for _, splitter := range d.splitters {
+		if splitter != nil {
+			_ = splitter.Flush()
+		}
+	}
+	return d.lines
+}
+
+// splitter returns the line splitter of a stream
+func (d *logDemuxer) splitter(stream byte) *logparse.LineSplitter {
+	if d.splitters[stream] == nil {
+		name := logparse.StreamStdout
+		if stream == streamTypeStderr {
+			name = logparse.StreamStderr
+		}
+		d.splitters[stream] = logparse.NewLineSplitter(name, d.collect)
+	}
+	return d.splitters[stream]
+}
+
+// collect copies a line, splitting off the Docker timestamp
+func (d *logDemuxer) collect(stream string, text []byte) error {
+	line := logLine{Stream: stream}
+	if d.timestamps {
+		if timestamp, rest, ok := logparse.SplitTimestamp(text); ok {
+			line.Timestamp, text = timestamp, rest
+		}
+	}
+	line.Text = bytes.Clone(text)
+	d.lines = append(d.lines, line)
+	return nil
+}
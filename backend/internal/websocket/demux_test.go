@@ -0,0 +1,187 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/logparse"
+)
+
+// frame builds a multiplexed frame
+func frame(stream byte, payload string) []byte {
+	header := make([]byte, logHeaderSize, logHeaderSize+len(payload))
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+// demuxAll feeds output to a demuxer in chunks of the given sizes
+func demuxAll(t *testing.T, d *logDemuxer, output []byte, sizes []int) (lines, partial []logLine) {
+	t.Helper()
+	for i := 0; len(output) > 0; i++ {
+		n := len(output)
+		if len(sizes) > 0 {
+			n = min(sizes[i%len(sizes)], len(output))
+		}
+		got, err := d.write(output[:n])
+		if err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		lines = append(lines, got...)
+		output = output[n:]
+	}
+	return lines, d.flush()
+}
+
+func TestLogDemuxer_Frames(t *testing.T) {
+	var output []byte
+	output = append(output, frame(1, "2025-03-01T12:00:00.123456789Z hello\n2025-03-01T12:00:01Z wor")...)
+	output = append(output, frame(2, "2025-03-01T12:00:02Z oops\n")...)
+	output = append(output, frame(1, "ld\n")...)
+	output = append(output, frame(0, "2025-03-01T12:00:03Z\n")...)
+	output = append(output, frame(2, "2025-03-01T12:00:04Z no newline")...)
+
+	// Every chunking, including one byte at a time, gives the same lines
+	for _, size := range []int{1, 3, 8, 9, 1024} {
+		lines, partial := demuxAll(t, &logDemuxer{timestamps: true}, output, []int{size})
+		var got []string
+		for _, line := range append(lines, partial...) {
+			got = append(got, line.Stream+"|"+line.Timestamp.Format(time.RFC3339Nano)+"|"+string(line.Text))
+		}
+		want := "stdout|2025-03-01T12:00:00.123456789Z|hello," +
+			"stderr|2025-03-01T12:00:02Z|oops," +
+			"stdout|2025-03-01T12:00:01Z|world," +
+			"stdout|2025-03-01T12:00:03Z|," +
+			"stderr|2025-03-01T12:00:04Z|no newline"
+		if strings.Join(got, ",") != want {
+			t.Errorf("chunk size %d: got %v", size, got)
+		}
+	}
+}
+
+func TestLogDemuxer_TTY(t *testing.T) {
+	output := []byte("2025-03-01T12:00:00Z \x01\x00\x00\x00 looks like a header\r\n2025-03-01T12:00:01Z second\n")
+	lines, _ := demuxAll(t, &logDemuxer{tty: true, timestamps: true}, output, []int{5})
+	if len(lines) != 2 || lines[0].Stream != logparse.StreamStdout || string(lines[0].Text) != "\x01\x00\x00\x00 looks like a header" {
+		t.Errorf("Expected raw TTY lines, got %q", lines)
+	}
+}
+
+func TestLogDemuxer_Errors(t *testing.T) {
+	if _, err := (&logDemuxer{}).write([]byte("plain text, not a frame")); err != errInvalidLogHeader {
+		t.Errorf("Expected invalid header error, got %v", err)
+	}
+
+	output := append(frame(1, "before\n"), frame(3, "container not running")...)
+	lines, err := (&logDemuxer{}).write(output)
+	if err == nil || !strings.Contains(err.Error(), "container not running") || len(lines) != 1 {
+		t.Errorf("Expected lines before the system error and the error, got %q, %v", lines, err)
+	}
+}
+
+func TestLogDemuxer_SplitsLongLines(t *testing.T) {
+	d := &logDemuxer{tty: true}
+	lines, _ := d.write([]byte(strings.Repeat("x", logparse.MaxLineLength+10) + "\n"))
+	if len(lines) != 2 || len(lines[0].Text) != logparse.MaxLineLength || len(lines[1].Text) != 10 {
+		t.Errorf("Expected the line to be split at the limit, got %d lines", len(lines))
+	}
+}
+
+// FuzzLogDemuxer checks that demultiplexing does not depend on where reads
+// split the output: each stream's text is recovered exactly.
+func FuzzLogDemuxer(f *testing.F) {
+	f.Add([]byte("a\nb\n"), []byte("err\n"), int64(1), false)
+	f.Add([]byte("partial"), []byte("x\n\ny"), int64(2), false)
+	f.Add([]byte("tty\r\noutput"), []byte{}, int64(3), true)
+	f.Add([]byte{1, 0, 0, 0, 0, 0, 0, 5, '\n'}, []byte{0, 0, 0, 0, '\n'}, int64(4), false)
+
+	f.Fuzz(func(t *testing.T, stdout, stderr []byte, seed int64, tty bool) {
+		if len(stdout)+len(stderr) > logparse.MaxLineLength {
+			t.Skip()
+		}
+		rng := rand.New(rand.NewSource(seed))
+
+		// Interleave the streams in frames of random size
+		var output []byte
+		rest := [2][]byte{stdout, stderr}
+		for len(rest[0])+len(rest[1]) > 0 {
+			stream := rng.Intn(2)
+			if len(rest[stream]) == 0 {
+				stream = 1 - stream
+			}
+			n := min(1+rng.Intn(16), len(rest[stream]))
+			if tty {
+				output = append(output, rest[stream][:n]...)
+			} else {
+				output = append(output, frame(byte(stream+1), string(rest[stream][:n]))...)
+			}
+			rest[stream] = rest[stream][n:]
+		}
+
+		var sizes []int
+		for i := 0; i < 8; i++ {
+			sizes = append(sizes, 1+rng.Intn(32))
+		}
+		lines, partial := demuxAll(t, &logDemuxer{tty: tty}, output, sizes)
+
+		var got [2]bytes.Buffer
+		for _, line := range lines {
+			got[streamIndex(line.Stream)].Write(append(line.Text, '\n'))
+		}
+		for _, line := range partial {
+			got[streamIndex(line.Stream)].Write(line.Text)
+		}
+
+		want := [2][]byte{stdout, stderr}
+		if tty {
+			// TTY output is all stdout, in the order it was written
+			want = [2][]byte{output, nil}
+		}
+		for i := range want {
+			if !bytes.Equal(got[i].Bytes(), want[i]) {
+				t.Fatalf("stream %d: got %q, want %q", i, got[i].Bytes(), want[i])
+			}
+		}
+	})
+}
+
+func streamIndex(stream string) int {
+	if stream == logparse.StreamStderr {
+		return 1
+	}
+	return 0
+}
+
+func TestLogsHandler_TTY(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	fake := &fakeLogsClient{output: []byte("2025-03-01T12:00:00Z $ \x1b[32mready\x1b[0m\n"), tty: true}
+	router.GET("/ws/logs/:id", LogsHandler(fake, zap.NewNop()))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws/logs/abcdef123456", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var msg LogMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if msg.Stream != logparse.StreamStdout || msg.Message != "$ \x1b[32mready\x1b[0m" || msg.Format != "raw" ||
+		!msg.Timestamp.Equal(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/metrics"
 )
 
 // DockerEvent represents a Docker event
@@ -20,7 +22,9 @@ type DockerEvent struct {
 	TimeNano int64                `json:"timeNano"`
 }
 
-// EventsHandler handles WebSocket connections for Docker events
+// EventsHandler handles WebSocket connections for Docker events. Principals
+// whose containers:read permission is restricted by a label selector only
+// receive events of containers it matches.
 func EventsHandler(dockerClient interface {
 	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
 }, logger *zap.Logger) gin.HandlerFunc {
@@ -34,6 +38,9 @@ func EventsHandler(dockerClient interface {
 		}
 		defer conn.Close()
 
+		metrics.IncrementWebSocketConnections("events")
+		defer metrics.DecrementWebSocketConnections("events")
+
 		// Set connection parameters
 		_ = conn.SetReadDeadline(time.Now().Add(PongWait))
 		conn.SetPongHandler(func(string) error {
@@ -45,6 +52,8 @@ func EventsHandler(dockerClient interface {
 		ctx, cancel := context.WithCancel(c.Request.Context())
 		defer cancel()
 
+		readable := readableFilter(c)
+
 		// Get event filters from query params
 		eventTypes := c.QueryArray("type")
 		eventActions := c.QueryArray("action")
@@ -98,6 +107,10 @@ func EventsHandler(dockerClient interface {
 					return
 				}
 			case event := <-eventChan:
+				if readable != nil && (event.Type != events.ContainerEventType || !readable(event.Actor.Attributes)) {
+					continue
+				}
+
 				dockerEvent := DockerEvent{
 					Type:     string(event.Type),
 					Action:   string(event.Action),
@@ -0,0 +1,289 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/metrics"
+	"github.com/kubevision/kubevision/internal/utils"
+)
+
+const (
+	// ExecMaxMessageSize is the maximum stdin message size allowed from peer
+	// (larger than MaxMessageSize so pasted text fits in a single frame)
+	ExecMaxMessageSize = 64 * 1024
+
+	// defaultExecCommand is used when neither the request nor EXEC_COMMAND set one
+	defaultExecCommand = "/bin/sh"
+)
+
+// ExecMessage is a control message exchanged over the exec WebSocket.
+// Binary frames carry raw terminal data in both directions; text frames
+// carry JSON-encoded ExecMessages.
+type ExecMessage struct {
+	Type     string `json:"type"`
+	Data     string `json:"data,omitempty"`
+	Cols     uint   `json:"cols,omitempty"`
+	Rows     uint   `json:"rows,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ExecHandler handles WebSocket connections for interactive container exec sessions
+func ExecHandler(dockerClient interface {
+	ContainerExecCreate(ctx context.Context, containerID string, options container.ExecOptions) (container.ExecCreateResponse, error)
+	ContainerExecAttach(ctx context.Context, execID string, config container.ExecAttachOptions) (types.HijackedResponse, error)
+	ContainerExecResize(ctx context.Context, execID string, options container.ResizeOptions) error
+	ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error)
+}, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID := c.Param("id")
+		if containerID == "" {
+			c.JSON(400, gin.H{"error": "Container ID is required"})
+			return
+		}
+
+		// Validate container ID
+		if !utils.ValidateContainerID(containerID) {
+			c.JSON(400, gin.H{"error": "Invalid container ID format"})
+			return
+		}
+
+		// Get query parameters
+		cmd := execCommand(c.QueryArray("cmd"))
+		user := utils.SanitizeString(c.Query("user"))
+		cols := parseTerminalSize(c.Query("cols"))
+		rows := parseTerminalSize(c.Query("rows"))
+
+		// Upgrade connection to WebSocket
+		upgrader := GetUpgrader()
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			logger.Error("Failed to upgrade connection", zap.Error(err))
+			return
+		}
+		defer conn.Close()
+
+		metrics.IncrementWebSocketConnections("exec")
+		defer metrics.DecrementWebSocketConnections("exec")
+
+		// Set connection parameters
+		conn.SetReadLimit(ExecMaxMessageSize)
+		_ = conn.SetReadDeadline(time.Now().Add(PongWait))
+		conn.SetPongHandler(func(string) error {
+			_ = conn.SetReadDeadline(time.Now().Add(PongWait))
+			return nil
+		})
+
+		// Create context for this connection
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+
+		// gorilla/websocket supports a single concurrent writer only
+		var writeMu sync.Mutex
+		writeMessage := func(messageType int, data []byte) error {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
+			return conn.WriteMessage(messageType, data)
+		}
+		writeControl := func(msg ExecMessage) error {
+			data, err := json.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			return writeMessage(websocket.TextMessage, data)
+		}
+
+		execOptions := container.ExecOptions{
+			User:         user,
+			Tty:          true,
+			AttachStdin:  true,
+			AttachStdout: true,
+			AttachStderr: true,
+			Cmd:          cmd,
+			Env:          []string{"TERM=xterm-256color"},
+		}
+		if cols > 0 && rows > 0 {
+			execOptions.ConsoleSize = &[2]uint{rows, cols}
+		}
+
+		exec, err := dockerClient.ContainerExecCreate(ctx, containerID, execOptions)
+		if err != nil {
+			logger.Error("Failed to create exec",
+				zap.String("container_id", containerID),
+				zap.Error(err))
+			_ = writeControl(ExecMessage{Type: "error", Error: "Failed to create exec session"})
+			return
+		}
+
+		hijacked, err := dockerClient.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{
+			Tty:         true,
+			ConsoleSize: execOptions.ConsoleSize,
+		})
+		if err != nil {
+			logger.Error("Failed to attach to exec",
+				zap.String("container_id", containerID),
+				zap.String("exec_id", exec.ID),
+				zap.Error(err))
+			_ = writeControl(ExecMessage{Type: "error", Error: "Failed to attach to exec session"})
+			return
+		}
+		defer hijacked.Close()
+
+		logger.Info("Exec session started",
+			zap.String("container_id", containerID),
+			zap.String("exec_id", exec.ID),
+			zap.Strings("cmd", cmd))
+
+		// Goroutine to read client input and forward it to the exec stdin
+		go func() {
+			defer cancel()
+			for {
+				messageType, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+
+				switch messageType {
+				case websocket.BinaryMessage:
+					if _, err := hijacked.Conn.Write(data); err != nil {
+						return
+					}
+				case websocket.TextMessage:
+					var msg ExecMessage
+					if err := json.Unmarshal(data, &msg); err != nil {
+						logger.Debug("Ignoring malformed exec message",
+							zap.String("exec_id", exec.ID),
+							zap.Error(err))
+						continue
+					}
+
+					switch msg.Type {
+					case "input":
+						if _, err := io.WriteString(hijacked.Conn, msg.Data); err != nil {
+							return
+						}
+					case "resize":
+						if msg.Cols == 0 || msg.Rows == 0 {
+							continue
+						}
+						if err := dockerClient.ContainerExecResize(ctx, exec.ID, container.ResizeOptions{
+							Height: msg.Rows,
+							Width:  msg.Cols,
+						}); err != nil {
+							logger.Warn("Failed to resize exec",
+								zap.String("exec_id", exec.ID),
+								zap.Error(err))
+						}
+					}
+				}
+			}
+		}()
+
+		// Goroutine to send ping messages
+		pingTicker := time.NewTicker(PingPeriod)
+		defer pingTicker.Stop()
+
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-pingTicker.C:
+					if err := writeMessage(websocket.PingMessage, nil); err != nil {
+						return
+					}
+				}
+			}
+		}()
+
+		// Close the hijacked connection once the client goes away so the
+		// blocking read below returns
+		go func() {
+			<-ctx.Done()
+			hijacked.Close()
+		}()
+
+		// Main loop: send exec output to client. With a TTY the stream is
+		// raw (no multiplexing header), so bytes are forwarded as-is.
+		buffer := make([]byte, 8192)
+		for {
+			n, err := hijacked.Reader.Read(buffer)
+			if n > 0 {
+				if werr := writeMessage(websocket.BinaryMessage, buffer[:n]); werr != nil {
+					logger.Error("Failed to write exec output",
+						zap.String("exec_id", exec.ID),
+						zap.Error(werr))
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					logger.Error("Failed to read exec output",
+						zap.String("exec_id", exec.ID),
+						zap.Error(err))
+				}
+				break
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Report the exit code so the client can tell a clean exit from a failure
+		inspectCtx, inspectCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer inspectCancel()
+		msg := ExecMessage{Type: "exit"}
+		if inspect, err := dockerClient.ContainerExecInspect(inspectCtx, exec.ID); err == nil {
+			exitCode := inspect.ExitCode
+			msg.ExitCode = &exitCode
+		}
+		_ = writeControl(msg)
+		_ = writeMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+
+		logger.Info("Exec session ended",
+			zap.String("container_id", containerID),
+			zap.String("exec_id", exec.ID))
+	}
+}
+
+// execCommand returns the command to run, falling back to EXEC_COMMAND
+func execCommand(requested []string) []string {
+	cmd := make([]string, 0, len(requested))
+	for _, arg := range requested {
+		if arg = utils.SanitizeString(arg); arg != "" {
+			cmd = append(cmd, arg)
+		}
+	}
+	if len(cmd) > 0 {
+		return cmd
+	}
+
+	if configured := strings.Fields(viper.GetString("EXEC_COMMAND")); len(configured) > 0 {
+		return configured
+	}
+	return []string{defaultExecCommand}
+}
+
+// parseTerminalSize parses a terminal dimension, returning 0 when invalid
+func parseTerminalSize(value string) uint {
+	size, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0
+	}
+	return uint(size)
+}
@@ -0,0 +1,209 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// fakeExecClient serves an exec session over an in-memory pipe that stands
+// in for the hijacked Docker connection
+type fakeExecClient struct {
+	mu      sync.Mutex
+	options container.ExecOptions
+	resizes []container.ResizeOptions
+	daemon  net.Conn
+}
+
+func (f *fakeExecClient) ContainerExecCreate(ctx context.Context, containerID string, options container.ExecOptions) (container.ExecCreateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.options = options
+	return container.ExecCreateResponse{ID: "exec-1"}, nil
+}
+
+func (f *fakeExecClient) ContainerExecAttach(ctx context.Context, execID string, config container.ExecAttachOptions) (types.HijackedResponse, error) {
+	client, daemon := net.Pipe()
+	f.mu.Lock()
+	f.daemon = daemon
+	f.mu.Unlock()
+
+	// Echo each line back, exiting when the shell receives "exit"
+	go func() {
+		defer daemon.Close()
+		reader := bufio.NewReader(daemon)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.TrimSpace(line) == "exit" {
+				return
+			}
+			if _, err := daemon.Write([]byte("out:" + line)); err != nil {
+				return
+			}
+		}
+	}()
+
+	return types.NewHijackedResponse(client, "application/vnd.docker.raw-stream"), nil
+}
+
+func (f *fakeExecClient) ContainerExecResize(ctx context.Context, execID string, options container.ResizeOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resizes = append(f.resizes, options)
+	return nil
+}
+
+func (f *fakeExecClient) ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error) {
+	return container.ExecInspect{ExecID: execID, ExitCode: 3}, nil
+}
+
+func newExecTestServer(t *testing.T, fake *fakeExecClient) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/ws/exec/:id", ExecHandler(fake, zap.NewNop()))
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func dialExec(t *testing.T, server *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/exec/abcdef123456" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func TestExecHandler_RoundTrip(t *testing.T) {
+	fake := &fakeExecClient{}
+	server := newExecTestServer(t, fake)
+	conn := dialExec(t, server, "?cmd=/bin/bash&cmd=-l&cols=120&rows=40")
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte("ls\n")); err != nil {
+		t.Fatalf("Failed to write stdin: %v", err)
+	}
+
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if messageType != websocket.BinaryMessage {
+		t.Errorf("Expected binary output frame, got %d", messageType)
+	}
+	if string(data) != "out:ls\n" {
+		t.Errorf("Expected echoed output, got %q", data)
+	}
+
+	// JSON input messages are forwarded the same way
+	input, _ := json.Marshal(ExecMessage{Type: "input", Data: "pwd\n"})
+	if err := conn.WriteMessage(websocket.TextMessage, input); err != nil {
+		t.Fatalf("Failed to write input message: %v", err)
+	}
+	if _, data, err = conn.ReadMessage(); err != nil || string(data) != "out:pwd\n" {
+		t.Fatalf("Expected echoed input message, got %q (err %v)", data, err)
+	}
+
+	fake.mu.Lock()
+	options := fake.options
+	fake.mu.Unlock()
+	if strings.Join(options.Cmd, " ") != "/bin/bash -l" {
+		t.Errorf("Expected requested command, got %v", options.Cmd)
+	}
+	if !options.Tty || !options.AttachStdin {
+		t.Error("Expected exec to be created with a TTY and stdin attached")
+	}
+	if options.ConsoleSize == nil || options.ConsoleSize[0] != 40 || options.ConsoleSize[1] != 120 {
+		t.Errorf("Expected initial console size 40x120, got %v", options.ConsoleSize)
+	}
+}
+
+func TestExecHandler_Resize(t *testing.T) {
+	fake := &fakeExecClient{}
+	server := newExecTestServer(t, fake)
+	conn := dialExec(t, server, "")
+
+	resize, _ := json.Marshal(ExecMessage{Type: "resize", Cols: 200, Rows: 50})
+	if err := conn.WriteMessage(websocket.TextMessage, resize); err != nil {
+		t.Fatalf("Failed to write resize message: %v", err)
+	}
+
+	// Round-trip a line so the resize has been processed before asserting
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte("sync\n")); err != nil {
+		t.Fatalf("Failed to write stdin: %v", err)
+	}
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.resizes) != 1 {
+		t.Fatalf("Expected 1 resize, got %d", len(fake.resizes))
+	}
+	if fake.resizes[0].Width != 200 || fake.resizes[0].Height != 50 {
+		t.Errorf("Expected resize to 200x50, got %+v", fake.resizes[0])
+	}
+	if strings.Join(fake.options.Cmd, " ") != defaultExecCommand {
+		t.Errorf("Expected default command, got %v", fake.options.Cmd)
+	}
+}
+
+func TestExecHandler_ExitCode(t *testing.T) {
+	fake := &fakeExecClient{}
+	server := newExecTestServer(t, fake)
+	conn := dialExec(t, server, "")
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte("exit\n")); err != nil {
+		t.Fatalf("Failed to write stdin: %v", err)
+	}
+
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read exit message: %v", err)
+	}
+	if messageType != websocket.TextMessage {
+		t.Fatalf("Expected text control frame, got %d", messageType)
+	}
+
+	var msg ExecMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("Failed to decode exit message: %v", err)
+	}
+	if msg.Type != "exit" || msg.ExitCode == nil || *msg.ExitCode != 3 {
+		t.Errorf("Expected exit message with code 3, got %+v", msg)
+	}
+}
+
+func TestExecHandler_InvalidContainerID(t *testing.T) {
+	server := newExecTestServer(t, &fakeExecClient{})
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/exec/bad"
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("Expected dial to fail for invalid container ID")
+	}
+	if resp == nil || resp.StatusCode != 400 {
+		t.Errorf("Expected 400 response, got %v", resp)
+	}
+}
@@ -0,0 +1,118 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/jobs"
+	"github.com/kubevision/kubevision/internal/metrics"
+)
+
+// JobMessage is a frame sent on the job WebSocket. The first frame
+// describes the job; each later frame carries one event, ending with a
+// "done" event holding the final state.
+type JobMessage struct {
+	Type  string      `json:"type"`
+	Job   *jobs.Info  `json:"job,omitempty"`
+	Event *jobs.Event `json:"event,omitempty"`
+}
+
+// JobHandler handles WebSocket connections streaming a job's progress
+func JobHandler(jobManager interface {
+	Get(id string) (*jobs.Job, bool)
+}, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := jobManager.Get(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Job not found"})
+			return
+		}
+
+		// Upgrade connection to WebSocket
+		upgrader := GetUpgrader()
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			logger.Error("Failed to upgrade connection", zap.Error(err))
+			return
+		}
+		defer conn.Close()
+
+		metrics.IncrementWebSocketConnections("jobs")
+		defer metrics.DecrementWebSocketConnections("jobs")
+
+		// Set connection parameters
+		_ = conn.SetReadDeadline(time.Now().Add(PongWait))
+		conn.SetPongHandler(func(string) error {
+			_ = conn.SetReadDeadline(time.Now().Add(PongWait))
+			return nil
+		})
+
+		// Create context for this connection
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+
+		snapshot, events, unsubscribe := job.Subscribe()
+		defer unsubscribe()
+
+		write := func(msg JobMessage) bool {
+			_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
+			if err := conn.WriteJSON(msg); err != nil {
+				logger.Debug("Failed to write job message", zap.Error(err))
+				return false
+			}
+			return true
+		}
+
+		info := job.Info()
+		if !write(JobMessage{Type: "job", Job: &info}) {
+			return
+		}
+		for i := range snapshot {
+			if !write(JobMessage{Type: "event", Event: &snapshot[i]}) {
+				return
+			}
+		}
+
+		// Read loop keeps pong handling alive and detects client disconnects
+		go func() {
+			defer cancel()
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		pingTicker := time.NewTicker(PingPeriod)
+		defer pingTicker.Stop()
+
+		// Main loop: send events and pings from a single writer
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-pingTicker.C:
+				_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			case event, ok := <-events:
+				if !ok {
+					// The job finished; the done event was the last one
+					_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
+					_ = conn.WriteMessage(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseNormalClosure, "job finished"))
+					return
+				}
+				if !write(JobMessage{Type: "event", Event: &event}) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package websocket
+
+import (
+	"bytes"
+
+	"github.com/kubevision/kubevision/internal/logparse"
+)
+
+// LogMessage is a log frame: one line of output, tagged with its stream
+type LogMessage struct {
+	Type   string `json:"type"`
+	Stream string `json:"stream"`
+	logparse.Entry
+}
+
+// logFormatter parses demultiplexed lines into frames
+type logFormatter struct {
+	format  logparse.Format
+	filters logparse.Filters
+}
+
+// message parses a line, falling back to the timestamp Docker prefixed it
+// with when the record has none. Lines that do not pass the filters are
+// dropped.
+func (f *logFormatter) message(line logLine) (LogMessage, bool) {
+	entry := logparse.Parse(string(bytes.TrimRight(line.Text, "\r")), f.format)
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = line.Timestamp
+	}
+	if !f.filters.Matches(entry) {
+		return LogMessage{}, false
+	}
+	return LogMessage{Type: "log", Stream: line.Stream, Entry: entry}, true
+}
@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/logparse"
+)
+
+func TestLogFormatter_Message(t *testing.T) {
+	filters, _ := logparse.ParseFilters([]string{"level>=info"})
+	formatter := &logFormatter{format: logparse.FormatAuto, filters: filters}
+	docker := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if _, ok := formatter.message(logLine{Stream: logparse.StreamStdout, Timestamp: docker, Text: []byte(`{"level":"debug","msg":"noise"}`)}); ok {
+		t.Error("Expected debug line to be filtered out")
+	}
+
+	msg, ok := formatter.message(logLine{Stream: logparse.StreamStderr, Timestamp: docker, Text: []byte("level=warn msg=\"slow query\" took=2s\r")})
+	if !ok || msg.Type != "log" || msg.Stream != logparse.StreamStderr || msg.Level != logparse.LevelWarn ||
+		msg.Message != "slow query" || msg.Fields["took"] != "2s" || !msg.Timestamp.Equal(docker) {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+
+	// The record's own timestamp wins over Docker's
+	msg, _ = formatter.message(logLine{Stream: logparse.StreamStdout, Timestamp: docker, Text: []byte(`{"level":"error","msg":"failed","time":"2025-03-01T11:59:59Z"}`)})
+	if !msg.Timestamp.Equal(docker.Add(-time.Second)) {
+		t.Errorf("Expected record timestamp, got %v", msg.Timestamp)
+	}
+}
+
+// fakeLogsClient returns fixed output
+type fakeLogsClient struct {
+	output []byte
+	tty    bool
+}
+
+func (f *fakeLogsClient) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	return container.InspectResponse{Config: &container.Config{Tty: f.tty}}, nil
+}
+
+func (f *fakeLogsClient) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(f.output)), nil
+}
+
+func TestLogsHandler_Format(t *testing.T) {
+	var output bytes.Buffer
+	stdout := stdcopy.NewStdWriter(&output, stdcopy.Stdout)
+	stderr := stdcopy.NewStdWriter(&output, stdcopy.Stderr)
+	_, _ = stdout.Write([]byte("2025-03-01T12:00:00Z {\"level\":\"info\",\"msg\":\"hello\",\"user_id\":42}\n"))
+	_, _ = stderr.Write([]byte("2025-03-01T12:00:01Z {\"level\":\"error\",\"msg\":\"boom\",\"user_id\":7}\n"))
+	_, _ = stdout.Write([]byte("2025-03-01T12:00:02Z {\"level\":\"warn\",\"msg\":\"careful\",\"user_id\":42}\n"))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws/logs/:id", LogsHandler(&fakeLogsClient{output: output.Bytes()}, zap.NewNop()))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/logs/abcdef123456?format=json&filter=field.user_id%3D42"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var messages []string
+	for {
+		var msg LogMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			break
+		}
+		messages = append(messages, msg.Stream+":"+msg.Level+":"+msg.Message)
+	}
+	if strings.Join(messages, ",") != "stdout:info:hello,stdout:warn:careful" {
+		t.Errorf("Unexpected messages: %v", messages)
+	}
+
+	for _, query := range []string{"?format=xml", "?format=json&filter=level>>warn"} {
+		resp, err := http.Get(server.URL + "/ws/logs/abcdef123456" + query)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, resp.StatusCode)
+		}
+	}
+}
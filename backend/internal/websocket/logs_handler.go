@@ -2,21 +2,24 @@ package websocket
 
 import (
 	"context"
-	"encoding/binary"
 	"io"
 	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types/container"
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 
+	"github.com/kubevision/kubevision/internal/logparse"
+	"github.com/kubevision/kubevision/internal/metrics"
 	"github.com/kubevision/kubevision/internal/utils"
 )
 
-
-// LogsHandler handles WebSocket connections for container logs
+// LogsHandler handles WebSocket connections for container logs. Each line is
+// sent as a LogMessage tagged with its stream; ?format= parses lines and
+// lines that do not pass every ?filter= are dropped.
 func LogsHandler(dockerClient interface {
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
 	ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
 }, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -37,6 +40,33 @@ func LogsHandler(dockerClient interface {
 		follow := c.DefaultQuery("follow", "true") == "true"
 		since := c.DefaultQuery("since", "")
 
+		format, err := logparse.ParseFormat(c.DefaultQuery("format", string(logparse.FormatRaw)))
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		filters, err := logparse.ParseFilters(c.QueryArray("filter"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		formatter := &logFormatter{format: format, filters: filters}
+
+		// TTY output is not multiplexed
+		inspect, err := dockerClient.ContainerInspect(c.Request.Context(), containerID)
+		if err != nil {
+			if cerrdefs.IsNotFound(err) {
+				c.JSON(404, gin.H{"error": "Container not found"})
+				return
+			}
+			logger.Error("Failed to inspect container",
+				zap.String("container_id", containerID),
+				zap.Error(err))
+			c.JSON(500, gin.H{"error": "Failed to inspect container"})
+			return
+		}
+		demuxer := &logDemuxer{tty: inspect.Config != nil && inspect.Config.Tty, timestamps: true}
+
 		// Upgrade connection to WebSocket
 		upgrader := GetUpgrader()
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -46,6 +76,9 @@ func LogsHandler(dockerClient interface {
 		}
 		defer conn.Close()
 
+		metrics.IncrementWebSocketConnections("logs")
+		defer metrics.DecrementWebSocketConnections("logs")
+
 		// Set connection parameters
 		_ = conn.SetReadDeadline(time.Now().Add(PongWait))
 		conn.SetPongHandler(func(string) error {
@@ -81,6 +114,23 @@ func LogsHandler(dockerClient interface {
 		}
 		defer logsReader.Close()
 
+		sendLines := func(lines []logLine) bool {
+			for _, line := range lines {
+				msg, ok := formatter.message(line)
+				if !ok {
+					continue
+				}
+				_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
+				if err := conn.WriteJSON(msg); err != nil {
+					logger.Error("Failed to write logs",
+						zap.String("container_id", containerID),
+						zap.Error(err))
+					return false
+				}
+			}
+			return true
+		}
+
 		// Buffer for reading logs
 		buffer := make([]byte, 8192)
 
@@ -91,9 +141,24 @@ func LogsHandler(dockerClient interface {
 				return
 			default:
 				n, err := logsReader.Read(buffer)
+				if n > 0 {
+					// Frames and lines split across reads are buffered
+					lines, demuxErr := demuxer.write(buffer[:n])
+					if !sendLines(lines) {
+						return
+					}
+					if demuxErr != nil {
+						logger.Error("Failed to demultiplex logs",
+							zap.String("container_id", containerID),
+							zap.Error(demuxErr))
+						_ = conn.WriteJSON(gin.H{"error": "Failed to read logs"})
+						return
+					}
+				}
 				if err != nil {
 					if err == io.EOF {
 						// End of stream
+						sendLines(demuxer.flush())
 						return
 					}
 					logger.Error("Failed to read logs",
@@ -101,63 +166,7 @@ func LogsHandler(dockerClient interface {
 						zap.Error(err))
 					return
 				}
-
-				if n == 0 {
-					continue
-				}
-
-				// Process Docker log header and send data
-				data := buffer[:n]
-				processedData := stripDockerHeader(data)
-
-				if len(processedData) > 0 {
-					_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
-					if err := conn.WriteMessage(websocket.TextMessage, processedData); err != nil {
-						logger.Error("Failed to write logs",
-							zap.String("container_id", containerID),
-							zap.Error(err))
-						return
-					}
-				}
 			}
 		}
 	}
 }
-
-// stripDockerHeader removes the 8-byte Docker log stream header
-// Format: [STREAM_TYPE(1)][RESERVED(3)][SIZE(4)]
-func stripDockerHeader(data []byte) []byte {
-	if len(data) < 8 {
-		return data
-	}
-
-	result := make([]byte, 0, len(data))
-	offset := 0
-
-	for offset < len(data) {
-		if offset+8 > len(data) {
-			// Not enough data for a header, append remaining
-			result = append(result, data[offset:]...)
-			break
-		}
-
-		// Extract size from bytes 4-7 (big-endian)
-		size := binary.BigEndian.Uint32(data[offset+4 : offset+8])
-
-		// Check if we have enough data
-		if offset+8+int(size) > len(data) {
-			// Partial frame, append remaining
-			result = append(result, data[offset+8:]...)
-			break
-		}
-
-		// Extract payload (skip 8-byte header)
-		payload := data[offset+8 : offset+8+int(size)]
-		result = append(result, payload...)
-
-		offset += 8 + int(size)
-	}
-
-	return result
-}
-
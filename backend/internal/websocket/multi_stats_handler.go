@@ -0,0 +1,519 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/metrics"
+	"github.com/kubevision/kubevision/internal/utils"
+)
+
+const (
+	// MultiStatsMaxSubscriptions caps the containers one connection may watch
+	MultiStatsMaxSubscriptions = 500
+
+	// MultiStatsMaxMessageSize is the maximum control message size from peer
+	MultiStatsMaxMessageSize = 32 * 1024
+
+	// selectorRefreshInterval is how often label selectors are re-resolved
+	// so new matching containers are picked up
+	selectorRefreshInterval = 10 * time.Second
+
+	minStatsBatchInterval = 500 * time.Millisecond
+	maxStatsBatchInterval = time.Minute
+)
+
+// StatsControlMessage is sent by the client to change its subscriptions.
+// Either IDs or Selector (a label selector) may be given.
+type StatsControlMessage struct {
+	Action   string   `json:"action"` // "subscribe" or "unsubscribe"
+	IDs      []string `json:"ids,omitempty"`
+	Selector string   `json:"selector,omitempty"`
+}
+
+// CompactStats is the per-container entry of a batched stats frame
+type CompactStats struct {
+	ID            string  `json:"id"`
+	CPUPercent    float64 `json:"cpu"`
+	MemoryUsage   uint64  `json:"mem"`
+	MemoryLimit   uint64  `json:"mem_limit"`
+	MemoryPercent float64 `json:"mem_pct"`
+	NetworkRx     uint64  `json:"rx"`
+	NetworkTx     uint64  `json:"tx"`
+	BlockRead     uint64  `json:"blk_r"`
+	BlockWrite    uint64  `json:"blk_w"`
+	PIDs          uint64  `json:"pids"`
+}
+
+// StatsBatchMessage is a frame sent on the multiplexed stats WebSocket.
+// "stats" frames hold containers updated since the previous frame;
+// "subscribed" frames acknowledge control messages; "ended" frames list
+// containers whose stats stream stopped; "error" frames report bad input.
+type StatsBatchMessage struct {
+	Type       string         `json:"type"`
+	Timestamp  time.Time      `json:"timestamp"`
+	Containers []CompactStats `json:"containers,omitempty"`
+	IDs        []string       `json:"ids,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+func compactStats(id string, stats *docker.ContainerStats) CompactStats {
+	return CompactStats{
+		ID:            id,
+		CPUPercent:    stats.CPUPercent,
+		MemoryUsage:   stats.MemoryUsage,
+		MemoryLimit:   stats.MemoryLimit,
+		MemoryPercent: stats.MemoryPercent,
+		NetworkRx:     stats.NetworkRx,
+		NetworkTx:     stats.NetworkTx,
+		BlockRead:     stats.BlockRead,
+		BlockWrite:    stats.BlockWrite,
+		PIDs:          stats.PIDs,
+	}
+}
+
+// statsFrame is a stats update forwarded from a hub subscription
+type statsFrame struct {
+	sub   *docker.StatsSubscription
+	stats *docker.ContainerStats
+}
+
+// multiStatsSession tracks the subscriptions of one connection. All fields
+// are owned by the handler's main loop.
+type multiStatsSession struct {
+	ctx          context.Context
+	hub          *docker.StatsHub
+	dockerClient interface {
+		ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	}
+	// readable checks labels against the principal's containers:read
+	// selector; nil if the principal may read every container
+	readable  func(labels map[string]string) bool
+	ids       map[string]bool                      // explicitly requested IDs
+	selectors map[string]map[string]bool           // selector -> resolved IDs
+	subs      map[string]*docker.StatsSubscription // active hub subscriptions
+	pending   map[string]*docker.ContainerStats    // updates since the last frame
+	frames    chan statsFrame
+	ended     chan *docker.StatsSubscription
+	logger    *zap.Logger
+}
+
+// MultiStatsHandler handles the multiplexed stats WebSocket. Clients send
+// StatsControlMessage frames and receive batched StatsBatchMessage frames
+// every interval (query param, default STATS_BATCH_INTERVAL). Principals
+// whose containers:read permission is restricted by a label selector may
+// only subscribe to containers it matches.
+func MultiStatsHandler(hub *docker.StatsHub, dockerClient interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+}, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		interval, err := parseBatchInterval(c.Query("interval"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Upgrade connection to WebSocket
+		upgrader := GetUpgrader()
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			logger.Error("Failed to upgrade connection", zap.Error(err))
+			return
+		}
+		defer conn.Close()
+
+		metrics.IncrementWebSocketConnections("stats_multi")
+		defer metrics.DecrementWebSocketConnections("stats_multi")
+
+		// Set connection parameters
+		conn.SetReadLimit(MultiStatsMaxMessageSize)
+		_ = conn.SetReadDeadline(time.Now().Add(PongWait))
+		conn.SetPongHandler(func(string) error {
+			_ = conn.SetReadDeadline(time.Now().Add(PongWait))
+			return nil
+		})
+
+		// Create context for this connection
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+
+		session := &multiStatsSession{
+			ctx:          ctx,
+			hub:          hub,
+			dockerClient: dockerClient,
+			readable:     readableFilter(c),
+			ids:          make(map[string]bool),
+			selectors:    make(map[string]map[string]bool),
+			subs:         make(map[string]*docker.StatsSubscription),
+			pending:      make(map[string]*docker.ContainerStats),
+			frames:       make(chan statsFrame, 64),
+			ended:        make(chan *docker.StatsSubscription, 16),
+			logger:       logger,
+		}
+		defer session.close()
+
+		// Read loop: control messages from the client
+		controls := make(chan StatsControlMessage)
+		go func() {
+			defer cancel()
+			for {
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				var msg StatsControlMessage
+				if err := json.Unmarshal(data, &msg); err != nil {
+					msg = StatsControlMessage{Action: "invalid"}
+				}
+				select {
+				case controls <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		batchTicker := time.NewTicker(interval)
+		defer batchTicker.Stop()
+		refreshTicker := time.NewTicker(selectorRefreshInterval)
+		defer refreshTicker.Stop()
+		pingTicker := time.NewTicker(PingPeriod)
+		defer pingTicker.Stop()
+
+		write := func(msg StatsBatchMessage) bool {
+			msg.Timestamp = time.Now()
+			_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
+			if err := conn.WriteJSON(msg); err != nil {
+				logger.Debug("Failed to write stats batch", zap.Error(err))
+				return false
+			}
+			return true
+		}
+
+		var ended []string
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-controls:
+				reply := session.handle(msg)
+				if !write(reply) {
+					return
+				}
+			case frame := <-session.frames:
+				id := frame.sub.ContainerID()
+				if session.subs[id] == frame.sub {
+					session.pending[id] = frame.stats
+				}
+			case sub := <-session.ended:
+				if session.removeEnded(sub) {
+					ended = append(ended, sub.ContainerID())
+				}
+			case <-refreshTicker.C:
+				session.refreshSelectors()
+			case <-batchTicker.C:
+				if len(ended) > 0 {
+					sort.Strings(ended)
+					if !write(StatsBatchMessage{Type: "ended", IDs: ended}) {
+						return
+					}
+					ended = nil
+				}
+				if len(session.pending) == 0 {
+					continue
+				}
+				if !write(StatsBatchMessage{Type: "stats", Containers: session.flush()}) {
+					return
+				}
+			case <-pingTicker.C:
+				_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// parseBatchInterval parses the interval query parameter
+func parseBatchInterval(value string) (time.Duration, error) {
+	if value == "" {
+		interval := viper.GetDuration("STATS_BATCH_INTERVAL")
+		if interval <= 0 {
+			interval = 2 * time.Second
+		}
+		return interval, nil
+	}
+
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval: %w", err)
+	}
+	if interval < minStatsBatchInterval || interval > maxStatsBatchInterval {
+		return 0, fmt.Errorf("interval must be between %s and %s", minStatsBatchInterval, maxStatsBatchInterval)
+	}
+	return interval, nil
+}
+
+// handle applies a control message and returns the reply frame
+func (s *multiStatsSession) handle(msg StatsControlMessage) StatsBatchMessage {
+	var selector utils.LabelSelector
+	if msg.Selector != "" {
+		parsed, err := utils.ParseLabelSelector(msg.Selector)
+		if err != nil {
+			return StatsBatchMessage{Type: "error", Error: err.Error()}
+		}
+		selector = parsed
+	}
+	for _, id := range msg.IDs {
+		if !utils.ValidateContainerID(id) {
+			return StatsBatchMessage{Type: "error", Error: fmt.Sprintf("invalid container ID %q", id)}
+		}
+	}
+
+	switch msg.Action {
+	case "subscribe":
+		if err := s.checkReadable(msg.IDs); err != nil {
+			return StatsBatchMessage{Type: "error", Error: err.Error(), IDs: s.subscribedIDs()}
+		}
+		for _, id := range msg.IDs {
+			s.ids[id] = true
+		}
+		if msg.Selector != "" {
+			resolved, err := s.resolve(selector)
+			if err != nil {
+				return StatsBatchMessage{Type: "error", Error: "failed to resolve selector"}
+			}
+			s.selectors[selector.String()] = resolved
+		}
+	case "unsubscribe":
+		for _, id := range msg.IDs {
+			delete(s.ids, id)
+		}
+		if msg.Selector != "" {
+			delete(s.selectors, selector.String())
+		}
+	default:
+		return StatsBatchMessage{Type: "error", Error: "action must be subscribe or unsubscribe"}
+	}
+
+	if err := s.sync(); err != nil {
+		return StatsBatchMessage{Type: "error", Error: err.Error(), IDs: s.subscribedIDs()}
+	}
+	return StatsBatchMessage{Type: "subscribed", IDs: s.subscribedIDs()}
+}
+
+// resolve lists running containers matching a selector
+func (s *multiStatsSession) resolve(selector utils.LabelSelector) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	defer cancel()
+
+	// Docker can pre-filter on equality terms; Matches applies the rest
+	listFilters := filters.NewArgs()
+	for _, req := range selector {
+		switch req.Operator {
+		case "=":
+			listFilters.Add("label", req.Key+"="+req.Value)
+		case "exists":
+			listFilters.Add("label", req.Key)
+		}
+	}
+
+	containers, err := s.dockerClient.ContainerList(ctx, container.ListOptions{Filters: listFilters})
+	if err != nil {
+		s.logger.Warn("Failed to resolve stats selector",
+			zap.String("selector", selector.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	resolved := make(map[string]bool)
+	for _, ctr := range containers {
+		if selector.Matches(ctr.Labels) && (s.readable == nil || s.readable(ctr.Labels)) {
+			resolved[ctr.ID] = true
+		}
+	}
+	return resolved, nil
+}
+
+// checkReadable rejects container IDs or names outside the principal's
+// containers:read selector
+func (s *multiStatsSession) checkReadable(ids []string) error {
+	if s.readable == nil || len(ids) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	defer cancel()
+
+	containers, err := s.dockerClient.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		s.logger.Warn("Failed to list containers for stats subscription", zap.Error(err))
+		return fmt.Errorf("failed to check containers")
+	}
+
+	for _, id := range ids {
+		permitted := false
+		for _, ctr := range containers {
+			if matchesContainer(ctr, id) {
+				permitted = s.readable(ctr.Labels)
+				break
+			}
+		}
+		if !permitted {
+			return fmt.Errorf("not allowed to read container %q", id)
+		}
+	}
+	return nil
+}
+
+// matchesContainer reports whether ref is a container's name, ID or ID prefix
+func matchesContainer(ctr container.Summary, ref string) bool {
+	if strings.HasPrefix(ctr.ID, ref) {
+		return true
+	}
+	for _, name := range ctr.Names {
+		if strings.TrimPrefix(name, "/") == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshSelectors re-resolves every selector and syncs subscriptions
+func (s *multiStatsSession) refreshSelectors() {
+	if len(s.selectors) == 0 {
+		return
+	}
+	for key := range s.selectors {
+		selector, _ := utils.ParseLabelSelector(key)
+		if resolved, err := s.resolve(selector); err == nil {
+			s.selectors[key] = resolved
+		}
+	}
+	_ = s.sync()
+}
+
+// wanted returns every container ID the client asked for
+func (s *multiStatsSession) wanted() map[string]bool {
+	wanted := make(map[string]bool, len(s.ids))
+	for id := range s.ids {
+		wanted[id] = true
+	}
+	for _, resolved := range s.selectors {
+		for id := range resolved {
+			wanted[id] = true
+		}
+	}
+	return wanted
+}
+
+// sync opens and closes hub subscriptions to match the wanted set
+func (s *multiStatsSession) sync() error {
+	wanted := s.wanted()
+
+	for id, sub := range s.subs {
+		if !wanted[id] {
+			sub.Close()
+			delete(s.subs, id)
+			delete(s.pending, id)
+		}
+	}
+
+	var err error
+	ids := make([]string, 0, len(wanted))
+	for id := range wanted {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, ok := s.subs[id]; ok {
+			continue
+		}
+		if len(s.subs) >= MultiStatsMaxSubscriptions {
+			err = fmt.Errorf("subscription limit of %d containers reached", MultiStatsMaxSubscriptions)
+			break
+		}
+		sub := s.hub.Subscribe(id)
+		s.subs[id] = sub
+		go s.forward(sub)
+	}
+	return err
+}
+
+// forward copies frames from a hub subscription into the session
+func (s *multiStatsSession) forward(sub *docker.StatsSubscription) {
+	for stats := range sub.Stats() {
+		select {
+		case s.frames <- statsFrame{sub: sub, stats: stats}:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+
+	select {
+	case s.ended <- sub:
+	case <-s.ctx.Done():
+	}
+}
+
+// removeEnded drops a subscription whose upstream stream ended (e.g. the
+// container stopped) and reports whether it was still active. Subscriptions
+// closed by sync were already removed, so they are ignored here.
+func (s *multiStatsSession) removeEnded(sub *docker.StatsSubscription) bool {
+	id := sub.ContainerID()
+	if s.subs[id] != sub {
+		return false
+	}
+
+	delete(s.subs, id)
+	delete(s.pending, id)
+	delete(s.ids, id)
+	for _, resolved := range s.selectors {
+		delete(resolved, id)
+	}
+	return true
+}
+
+// flush returns pending updates ordered by ID and clears them
+func (s *multiStatsSession) flush() []CompactStats {
+	ids := make([]string, 0, len(s.pending))
+	for id := range s.pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	batch := make([]CompactStats, 0, len(ids))
+	for _, id := range ids {
+		batch = append(batch, compactStats(id, s.pending[id]))
+	}
+	s.pending = make(map[string]*docker.ContainerStats)
+	return batch
+}
+
+func (s *multiStatsSession) subscribedIDs() []string {
+	ids := make([]string, 0, len(s.subs))
+	for id := range s.subs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *multiStatsSession) close() {
+	for _, sub := range s.subs {
+		sub.Close()
+	}
+}
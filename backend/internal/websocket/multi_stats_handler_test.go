@@ -0,0 +1,180 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/kubevision/kubevision/internal/auth"
+	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/middleware"
+)
+
+// fakeStatsClient streams a stats frame per container every few milliseconds
+type fakeStatsClient struct {
+	containers []container.Summary
+}
+
+func (f *fakeStatsClient) ContainerStats(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error) {
+	reader, writer := io.Pipe()
+	go func() {
+		encoder := json.NewEncoder(writer)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				writer.CloseWithError(ctx.Err())
+				return
+			case <-ticker.C:
+				if err := encoder.Encode(container.StatsResponse{PidsStats: container.PidsStats{Current: 5}}); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return container.StatsResponseReader{Body: reader}, nil
+}
+
+func (f *fakeStatsClient) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	return f.containers, nil
+}
+
+func dialMultiStats(t *testing.T, fake *fakeStatsClient) *websocket.Conn {
+	t.Helper()
+	return dialMultiStatsAs(t, fake, nil)
+}
+
+// dialMultiStatsAs connects to the stats WebSocket as principal
+func dialMultiStatsAs(t *testing.T, fake *fakeStatsClient, principal *auth.Principal) *websocket.Conn {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	hub := docker.NewStatsHub(fake, zap.NewNop())
+	t.Cleanup(hub.Close)
+
+	router := gin.New()
+	if principal != nil {
+		router.Use(func(c *gin.Context) { c.Set(middleware.PrincipalKey, principal) })
+	}
+	router.GET("/ws/stats", MultiStatsHandler(hub, fake, zap.NewNop()))
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/stats?interval=500ms"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func sendControl(t *testing.T, conn *websocket.Conn, msg StatsControlMessage) StatsBatchMessage {
+	t.Helper()
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("Failed to write control message: %v", err)
+	}
+	return readBatch(t, conn, "subscribed", "error")
+}
+
+// readBatch reads frames until one of the given types arrives
+func readBatch(t *testing.T, conn *websocket.Conn, frameTypes ...string) StatsBatchMessage {
+	t.Helper()
+	for {
+		var msg StatsBatchMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Failed to read frame: %v", err)
+		}
+		for _, frameType := range frameTypes {
+			if msg.Type == frameType {
+				return msg
+			}
+		}
+	}
+}
+
+func TestMultiStatsHandler_SelectorSubscription(t *testing.T) {
+	fake := &fakeStatsClient{containers: []container.Summary{
+		{ID: "aaaaaaaaaaaa", Labels: map[string]string{"com.docker.compose.project": "payments"}},
+		{ID: "bbbbbbbbbbbb", Labels: map[string]string{"com.docker.compose.project": "payments", "tier": "db"}},
+		{ID: "cccccccccccc", Labels: map[string]string{"com.docker.compose.project": "billing"}},
+	}}
+	conn := dialMultiStats(t, fake)
+
+	ack := sendControl(t, conn, StatsControlMessage{Action: "subscribe", Selector: "com.docker.compose.project=payments"})
+	if ack.Type != "subscribed" || strings.Join(ack.IDs, ",") != "aaaaaaaaaaaa,bbbbbbbbbbbb" {
+		t.Fatalf("Unexpected subscribe ack: %+v", ack)
+	}
+
+	batch := readBatch(t, conn, "stats")
+	if len(batch.Containers) == 0 {
+		t.Fatal("Expected stats in batch")
+	}
+	for _, stats := range batch.Containers {
+		if stats.ID == "cccccccccccc" {
+			t.Errorf("Unexpected stats for non-matching container")
+		}
+		if stats.PIDs != 5 {
+			t.Errorf("Expected calculated stats, got %+v", stats)
+		}
+	}
+
+	ack = sendControl(t, conn, StatsControlMessage{Action: "unsubscribe", Selector: "com.docker.compose.project=payments"})
+	if ack.Type != "subscribed" || len(ack.IDs) != 0 {
+		t.Errorf("Expected no subscriptions after unsubscribe, got %+v", ack)
+	}
+}
+
+func TestMultiStatsHandler_IDSubscriptionAndErrors(t *testing.T) {
+	conn := dialMultiStats(t, &fakeStatsClient{})
+
+	ack := sendControl(t, conn, StatsControlMessage{Action: "subscribe", IDs: []string{"dddddddddddd"}})
+	if ack.Type != "subscribed" || len(ack.IDs) != 1 {
+		t.Fatalf("Unexpected subscribe ack: %+v", ack)
+	}
+	if batch := readBatch(t, conn, "stats"); len(batch.Containers) != 1 || batch.Containers[0].ID != "dddddddddddd" {
+		t.Errorf("Unexpected batch: %+v", batch)
+	}
+
+	if reply := sendControl(t, conn, StatsControlMessage{Action: "subscribe", IDs: []string{"../etc"}}); reply.Type != "error" {
+		t.Errorf("Expected error for invalid ID, got %+v", reply)
+	}
+	if reply := sendControl(t, conn, StatsControlMessage{Action: "watch"}); reply.Type != "error" {
+		t.Errorf("Expected error for unknown action, got %+v", reply)
+	}
+}
+
+func TestMultiStatsHandler_RespectsReadSelector(t *testing.T) {
+	fake := &fakeStatsClient{containers: []container.Summary{
+		{ID: "aaaaaaaaaaaa", Names: []string{"/payments-api"}, Labels: map[string]string{"com.docker.compose.project": "payments"}},
+		{ID: "cccccccccccc", Names: []string{"/billing-api"}, Labels: map[string]string{"com.docker.compose.project": "billing"}},
+	}}
+	operator, _ := auth.NewPrincipal("ops", auth.RoleOperator, "t", map[auth.Permission]string{
+		auth.PermContainersRead: "com.docker.compose.project=payments",
+	})
+	conn := dialMultiStatsAs(t, fake, operator)
+
+	ack := sendControl(t, conn, StatsControlMessage{Action: "subscribe", Selector: "com.docker.compose.project"})
+	if ack.Type != "subscribed" || strings.Join(ack.IDs, ",") != "aaaaaaaaaaaa" {
+		t.Fatalf("Expected only the readable container, got %+v", ack)
+	}
+	for _, id := range []string{"cccccccccccc", "billing-api", "eeeeeeeeeeee"} {
+		if reply := sendControl(t, conn, StatsControlMessage{Action: "subscribe", IDs: []string{id}}); reply.Type != "error" {
+			t.Errorf("Expected %s to be rejected, got %+v", id, reply)
+		}
+	}
+	if ack := sendControl(t, conn, StatsControlMessage{Action: "subscribe", IDs: []string{"payments-api"}}); ack.Type != "subscribed" {
+		t.Errorf("Expected readable container name to be accepted, got %+v", ack)
+	}
+}
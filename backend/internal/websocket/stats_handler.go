@@ -2,23 +2,22 @@ package websocket
 
 import (
 	"context"
-	"encoding/json"
 	"time"
 
-	"github.com/docker/docker/api/types/container"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 
 	"github.com/kubevision/kubevision/internal/docker"
+	"github.com/kubevision/kubevision/internal/metrics"
 	"github.com/kubevision/kubevision/internal/utils"
 )
 
 
-// StatsHandler handles WebSocket connections for container stats
-func StatsHandler(dockerClient interface {
-	ContainerStats(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error)
-}, statsCalculator *docker.StatsCalculator, logger *zap.Logger) gin.HandlerFunc {
+// StatsHandler handles WebSocket connections for container stats. Frames
+// come from the shared stats hub, so any number of viewers of the same
+// container share one Docker stats stream.
+func StatsHandler(hub *docker.StatsHub, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		containerID := c.Param("id")
 		if containerID == "" {
@@ -41,7 +40,11 @@ func StatsHandler(dockerClient interface {
 		}
 		defer conn.Close()
 
+		metrics.IncrementWebSocketConnections("stats")
+		defer metrics.DecrementWebSocketConnections("stats")
+
 		// Set connection parameters
+		conn.SetReadLimit(MaxMessageSize)
 		_ = conn.SetReadDeadline(time.Now().Add(PongWait))
 		conn.SetPongHandler(func(string) error {
 			_ = conn.SetReadDeadline(time.Now().Add(PongWait))
@@ -52,96 +55,33 @@ func StatsHandler(dockerClient interface {
 		ctx, cancel := context.WithCancel(c.Request.Context())
 		defer cancel()
 
-		// Channel for stats with buffer to prevent blocking
-		statsChan := make(chan *docker.ContainerStats, 100)
+		subscription := hub.Subscribe(containerID)
+		defer subscription.Close()
 
-		// Goroutine to read Docker stats using streaming API
+		// Read loop: processes pongs and detects client disconnects
 		go func() {
-			defer close(statsChan)
-			
-			// Use streaming API for better performance
-			stats, err := dockerClient.ContainerStats(ctx, containerID, true)
-			if err != nil {
-				logger.Error("Failed to get container stats stream",
-					zap.String("container_id", containerID),
-					zap.Error(err))
-				return
-			}
-			defer stats.Body.Close()
-
-			decoder := json.NewDecoder(stats.Body)
-			var statsJSON container.StatsResponse
-			lastSendTime := time.Now()
-			const sendInterval = 1 * time.Second
-
+			defer cancel()
 			for {
-				select {
-				case <-ctx.Done():
+				if _, _, err := conn.ReadMessage(); err != nil {
 					return
-				default:
-					// Decode next stats JSON from stream
-					if err := decoder.Decode(&statsJSON); err != nil {
-						if err.Error() == "EOF" {
-							logger.Info("Stats stream ended",
-								zap.String("container_id", containerID))
-						} else {
-							logger.Error("Failed to decode stats",
-								zap.String("container_id", containerID),
-								zap.Error(err))
-						}
-						return
-					}
-
-					// Only send if enough time has passed (throttle to 1 second)
-					now := time.Now()
-					if now.Sub(lastSendTime) >= sendInterval {
-						// Calculate metrics
-						calculatedStats, err := statsCalculator.CalculateStats(containerID, &statsJSON)
-						if err != nil {
-							logger.Error("Failed to calculate stats",
-								zap.String("container_id", containerID),
-								zap.Error(err))
-							continue
-						}
-
-						// Send to channel (non-blocking)
-						select {
-						case statsChan <- calculatedStats:
-							lastSendTime = now
-						default:
-							// Buffer full, skip this frame
-							logger.Warn("Stats channel buffer full, skipping frame",
-								zap.String("container_id", containerID))
-						}
-					}
 				}
 			}
 		}()
 
-		// Goroutine to send ping messages
 		pingTicker := time.NewTicker(PingPeriod)
 		defer pingTicker.Stop()
 
-		go func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-pingTicker.C:
-					_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
-					if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-						return
-					}
-				}
-			}
-		}()
-
-		// Main loop: send stats to client
+		// Main loop: send stats and pings to client from a single writer
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case stats, ok := <-statsChan:
+			case <-pingTicker.C:
+				_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			case stats, ok := <-subscription.Stats():
 				_ = conn.SetWriteDeadline(time.Now().Add(WriteWait))
 				if !ok {
 					_ = conn.WriteMessage(websocket.CloseMessage, []byte{})
@@ -159,4 +99,3 @@ func StatsHandler(dockerClient interface {
 		}
 	}
 }
-
@@ -6,6 +6,18 @@ import 'xterm/css/xterm.css';
 import { useWebSocket } from '../hooks/useWebSocket';
 import { WS_URL } from '../config';
 
+// A line of container output, as sent by /ws/logs/:id
+interface LogFrame {
+  type: 'log';
+  stream: 'stdout' | 'stderr';
+  timestamp: string;
+  message: string;
+}
+
+function isLogFrame(data: unknown): data is LogFrame {
+  return typeof data === 'object' && data !== null && (data as LogFrame).type === 'log';
+}
+
 interface LogTerminalProps {
   containerId: string;
   visible?: boolean;
@@ -26,8 +38,13 @@ export default function LogTerminal({ containerId, visible = true }: LogTerminal
     url: wsUrl,
     onMessage: (data: unknown) => {
       if (terminalInstanceRef.current) {
-        // Handle both string and ArrayBuffer
-        if (typeof data === 'string') {
+        // Log frames carry one line each; stderr is shown in red
+        if (isLogFrame(data)) {
+          const line = `${data.timestamp} ${data.message}`;
+          terminalInstanceRef.current.write(
+            data.stream === 'stderr' ? `\x1b[31m${line}\x1b[0m\r\n` : `${line}\r\n`
+          );
+        } else if (typeof data === 'string') {
           terminalInstanceRef.current.write(data);
         } else if (data instanceof ArrayBuffer) {
           const decoder = new TextDecoder();
@@ -24,6 +24,7 @@ export interface ContainerInfo {
 }
 
 export interface Port {
+  ip?: string;
   private_port: number;
   public_port: number;
   type: string;